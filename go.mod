@@ -6,15 +6,17 @@ require (
 	github.com/huandu/go-clone/generic v1.7.2
 	github.com/rs/cors v1.6.0
 	github.com/rs/xid v1.2.1
-	github.com/stretchr/testify v1.4.0
+	github.com/stretchr/testify v1.6.1
+	github.com/vmihailenco/msgpack/v5 v5.4.1
 	golang.org/x/crypto v0.0.0-20181127143415-eb0de9b17e85
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/huandu/go-clone v1.6.0 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
-	gopkg.in/yaml.v2 v2.2.2 // indirect
+	github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
 )
 
-go 1.18
+go 1.21
@@ -68,10 +68,8 @@ func (h *Handler) ServeHTTPC(ctx context.Context, w http.ResponseWriter, r *http
 		RequestString: query,
 		Schema:        h.schema,
 	})
-	if resource.Logger != nil {
-		if len(result.Errors) > 0 {
-			resource.Logger(ctx, resource.LogLevelError, fmt.Sprintf("wrong result, unexpected errors: %v", result.Errors), nil)
-		}
+	if len(result.Errors) > 0 {
+		resource.Log(ctx, resource.LogLevelError, fmt.Sprintf("wrong result, unexpected errors: %v", result.Errors), nil)
 	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(result)
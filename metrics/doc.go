@@ -0,0 +1,18 @@
+/*
+Package metrics collects REST Layer resource and HTTP metrics and exposes them
+in the Prometheus text exposition format.
+
+A Prometheus collector implements both resource.MetricsRecorder and
+rest.HTTPMetrics and is a `net/http` handler serving the collected metrics:
+
+	m := metrics.NewPrometheus()
+	resource.Metrics = m
+	api, _ := rest.NewHandler(index)
+	api.Metrics = m
+	http.Handle("/api/", http.StripPrefix("/api/", api))
+	http.Handle("/metrics", m)
+
+This package is part of the rest-layer project. See http://rest-layer.io for
+full REST Layer documentation.
+*/
+package metrics
@@ -0,0 +1,200 @@
+package metrics
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/rest-layer/resource"
+)
+
+// DefaultBuckets are the default latency histogram buckets, in seconds.
+var DefaultBuckets = []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Prometheus collects resource operation and HTTP request metrics and serves
+// them in the Prometheus text format:
+//
+//	<ns>_resource_operations_total{resource,operation}
+//	<ns>_resource_errors_total{resource,operation,type}
+//	<ns>_resource_operation_duration_seconds{resource,operation} (histogram)
+//	<ns>_storage_retries_total{resource,operation,type}
+//	<ns>_http_requests_total{method,resource,status}
+//	<ns>_http_request_duration_seconds{method,resource} (histogram)
+//
+// The error type label is computed using resource.ErrorType.
+type Prometheus struct {
+	// Namespace prefixes metric names (default "restlayer").
+	Namespace string
+	// Buckets are the upper bounds of the latency histograms (default
+	// DefaultBuckets). It must not be changed once metrics are recorded.
+	Buckets []float64
+
+	mu         sync.Mutex
+	ops        map[labels]float64
+	errs       map[labels]float64
+	opLatency  map[labels]*histogram
+	retries    map[labels]float64
+	reqs       map[labels]float64
+	reqLatency map[labels]*histogram
+}
+
+// labels is a list of label values used as map key.
+type labels [3]string
+
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// NewPrometheus creates a new metrics collector.
+func NewPrometheus() *Prometheus {
+	return &Prometheus{
+		Namespace:  "restlayer",
+		Buckets:    DefaultBuckets,
+		ops:        map[labels]float64{},
+		errs:       map[labels]float64{},
+		opLatency:  map[labels]*histogram{},
+		retries:    map[labels]float64{},
+		reqs:       map[labels]float64{},
+		reqLatency: map[labels]*histogram{},
+	}
+}
+
+// ObserveOperation implements resource.MetricsRecorder interface.
+func (p *Prometheus) ObserveOperation(ctx context.Context, rsrc, op string, d time.Duration, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.ops[labels{rsrc, op}]++
+	if t := resource.ErrorType(err); t != "" {
+		p.errs[labels{rsrc, op, t}]++
+	}
+	p.observe(p.opLatency, labels{rsrc, op}, d)
+}
+
+// ObserveRetry implements resource.RetryRecorder interface.
+func (p *Prometheus) ObserveRetry(ctx context.Context, rsrc, op string, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.retries[labels{rsrc, op, resource.ErrorType(err)}]++
+}
+
+// ObserveRequest implements rest.HTTPMetrics interface.
+func (p *Prometheus) ObserveRequest(ctx context.Context, method, rsrc string, status int, d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.reqs[labels{method, rsrc, strconv.Itoa(status)}]++
+	p.observe(p.reqLatency, labels{method, rsrc}, d)
+}
+
+func (p *Prometheus) observe(m map[labels]*histogram, l labels, d time.Duration) {
+	h := m[l]
+	if h == nil {
+		h = &histogram{counts: make([]uint64, len(p.Buckets))}
+		m[l] = h
+	}
+	v := d.Seconds()
+	for i, b := range p.Buckets {
+		if v <= b {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+// ServeHTTP serves the collected metrics in the Prometheus text format.
+func (p *Prometheus) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	p.Write(w)
+}
+
+// Write writes the collected metrics in the Prometheus text format to out.
+func (p *Prometheus) Write(out io.Writer) error {
+	w := bufio.NewWriter(out)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ns := p.Namespace
+	if ns == "" {
+		ns = "restlayer"
+	}
+	opLabels := []string{"resource", "operation"}
+	p.writeCounter(w, ns+"_resource_operations_total", "Number of resource operations.", opLabels, p.ops)
+	p.writeCounter(w, ns+"_resource_errors_total", "Number of failed resource operations by error type.", []string{"resource", "operation", "type"}, p.errs)
+	p.writeHistogram(w, ns+"_resource_operation_duration_seconds", "Duration of resource operations.", opLabels, p.opLatency)
+	p.writeCounter(w, ns+"_storage_retries_total", "Number of retried storage calls by error type.", []string{"resource", "operation", "type"}, p.retries)
+	p.writeCounter(w, ns+"_http_requests_total", "Number of HTTP requests by status.", []string{"method", "resource", "status"}, p.reqs)
+	p.writeHistogram(w, ns+"_http_request_duration_seconds", "Duration of HTTP requests.", []string{"method", "resource"}, p.reqLatency)
+	return w.Flush()
+}
+
+func (p *Prometheus) writeCounter(w *bufio.Writer, name, help string, names []string, m map[labels]float64) {
+	if len(m) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+	keys := make([]labels, 0, len(m))
+	for l := range m {
+		keys = append(keys, l)
+	}
+	for _, l := range sortLabels(keys) {
+		fmt.Fprintf(w, "%s{%s} %s\n", name, formatLabels(names, l, ""), formatFloat(m[l]))
+	}
+}
+
+func (p *Prometheus) writeHistogram(w *bufio.Writer, name, help string, names []string, m map[labels]*histogram) {
+	if len(m) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", name, help, name)
+	keys := make([]labels, 0, len(m))
+	for l := range m {
+		keys = append(keys, l)
+	}
+	for _, l := range sortLabels(keys) {
+		h := m[l]
+		for i, b := range p.Buckets {
+			fmt.Fprintf(w, "%s_bucket{%s} %d\n", name, formatLabels(names, l, formatFloat(b)), h.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket{%s} %d\n", name, formatLabels(names, l, "+Inf"), h.count)
+		fmt.Fprintf(w, "%s_sum{%s} %s\n", name, formatLabels(names, l, ""), formatFloat(h.sum))
+		fmt.Fprintf(w, "%s_count{%s} %d\n", name, formatLabels(names, l, ""), h.count)
+	}
+}
+
+func sortLabels(keys []labels) []labels {
+	sort.Slice(keys, func(i, j int) bool {
+		for k := range keys[i] {
+			if keys[i][k] != keys[j][k] {
+				return keys[i][k] < keys[j][k]
+			}
+		}
+		return false
+	})
+	return keys
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// formatLabels formats the label pairs, adding the le label if not empty.
+func formatLabels(names []string, values labels, le string) string {
+	pairs := make([]string, 0, len(names)+1)
+	for i, n := range names {
+		pairs = append(pairs, n+`="`+labelEscaper.Replace(values[i])+`"`)
+	}
+	if le != "" {
+		pairs = append(pairs, `le="`+le+`"`)
+	}
+	return strings.Join(pairs, ",")
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
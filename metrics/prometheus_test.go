@@ -0,0 +1,90 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/resource/testing/mem"
+	"github.com/rs/rest-layer/rest"
+	"github.com/rs/rest-layer/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrometheusWrite(t *testing.T) {
+	p := NewPrometheus()
+	p.Buckets = []float64{.1, 1}
+	ctx := context.Background()
+	p.ObserveOperation(ctx, "users", resource.OpGet, 50*time.Millisecond, nil)
+	p.ObserveOperation(ctx, "users", resource.OpGet, 500*time.Millisecond, resource.ErrNotFound)
+	p.ObserveRequest(ctx, "GET", `a"b`, 404, 2*time.Second)
+	p.ObserveRetry(ctx, "users", resource.OpFind, context.DeadlineExceeded)
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, p.Write(buf))
+	assert.Equal(t, `# HELP restlayer_resource_operations_total Number of resource operations.
+# TYPE restlayer_resource_operations_total counter
+restlayer_resource_operations_total{resource="users",operation="get"} 2
+# HELP restlayer_resource_errors_total Number of failed resource operations by error type.
+# TYPE restlayer_resource_errors_total counter
+restlayer_resource_errors_total{resource="users",operation="get",type="not_found"} 1
+# HELP restlayer_resource_operation_duration_seconds Duration of resource operations.
+# TYPE restlayer_resource_operation_duration_seconds histogram
+restlayer_resource_operation_duration_seconds_bucket{resource="users",operation="get",le="0.1"} 1
+restlayer_resource_operation_duration_seconds_bucket{resource="users",operation="get",le="1"} 2
+restlayer_resource_operation_duration_seconds_bucket{resource="users",operation="get",le="+Inf"} 2
+restlayer_resource_operation_duration_seconds_sum{resource="users",operation="get"} 0.55
+restlayer_resource_operation_duration_seconds_count{resource="users",operation="get"} 2
+# HELP restlayer_storage_retries_total Number of retried storage calls by error type.
+# TYPE restlayer_storage_retries_total counter
+restlayer_storage_retries_total{resource="users",operation="find",type="timeout"} 1
+# HELP restlayer_http_requests_total Number of HTTP requests by status.
+# TYPE restlayer_http_requests_total counter
+restlayer_http_requests_total{method="GET",resource="a\"b",status="404"} 1
+# HELP restlayer_http_request_duration_seconds Duration of HTTP requests.
+# TYPE restlayer_http_request_duration_seconds histogram
+restlayer_http_request_duration_seconds_bucket{method="GET",resource="a\"b",le="0.1"} 0
+restlayer_http_request_duration_seconds_bucket{method="GET",resource="a\"b",le="1"} 0
+restlayer_http_request_duration_seconds_bucket{method="GET",resource="a\"b",le="+Inf"} 1
+restlayer_http_request_duration_seconds_sum{method="GET",resource="a\"b"} 2
+restlayer_http_request_duration_seconds_count{method="GET",resource="a\"b"} 1
+`, buf.String())
+}
+
+func TestPrometheusHandler(t *testing.T) {
+	p := NewPrometheus()
+	resource.Metrics = p
+	defer func() { resource.Metrics = nil }()
+
+	index := resource.NewIndex()
+	index.Bind("users", schema.Schema{Fields: schema.Fields{"id": {}}}, mem.NewHandler(), resource.DefaultConf)
+	api, err := rest.NewHandler(index)
+	if !assert.NoError(t, err) {
+		return
+	}
+	api.Metrics = p
+
+	for _, path := range []string{"/users", "/users/1", "/unknown"} {
+		api.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", w.Header().Get("Content-Type"))
+	body := w.Body.String()
+	for _, line := range []string{
+		`restlayer_resource_operations_total{resource="users",operation="find"} 1`,
+		`restlayer_resource_errors_total{resource="users",operation="get",type="not_found"} 1`,
+		`restlayer_http_requests_total{method="GET",resource="",status="404"} 1`,
+		`restlayer_http_requests_total{method="GET",resource="users",status="200"} 1`,
+		`restlayer_http_requests_total{method="GET",resource="users",status="404"} 1`,
+	} {
+		assert.True(t, strings.Contains(body, line+"\n"), "missing %s", line)
+	}
+}
@@ -0,0 +1,138 @@
+package coalesce
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema/query"
+)
+
+// Options configures the coalescing middlewares.
+type Options struct {
+	// Scope, if set, returns a string added to the keys of the calls made
+	// with ctx, so only the calls of the same scope are shared. Use it when
+	// hooks filter or alter the items depending on the context.
+	Scope func(ctx context.Context) string
+}
+
+// Middleware returns the OnGet, OnMultiGet and OnFind middlewares
+// coalescing the identical concurrent reads on r. The result is meant to be
+// passed to r.Chain.
+func Middleware(r *resource.Resource, opts Options) []interface{} {
+	c := &coalescer{path: r.Path(), scope: opts.Scope}
+	return []interface{}{
+		resource.OnGetMiddleware(c.onGet),
+		resource.OnMultiGetMiddleware(c.onMultiGet),
+		resource.OnFindMiddleware(c.onFind),
+	}
+}
+
+// coalescer holds the in-flight calls of a resource.
+type coalescer struct {
+	path  string
+	scope func(ctx context.Context) string
+	group group
+}
+
+// key returns the key of the op call with the given arguments.
+func (c *coalescer) key(ctx context.Context, op, args string) string {
+	scope := ""
+	if c.scope != nil {
+		scope = c.scope(ctx)
+	}
+	return strings.Join([]string{c.path, op, scope, args}, "\x00")
+}
+
+func (c *coalescer) onGet(next resource.OnGetMiddlewareHandler) resource.OnGetMiddlewareHandler {
+	return func(ctx context.Context, id interface{}) (*resource.Item, error) {
+		key := c.key(ctx, resource.OpGet, idKey(id))
+		v, shared, err := c.group.do(ctx, key, func(ctx context.Context) (interface{}, error) {
+			return next(ctx, id)
+		})
+		item, _ := v.(*resource.Item)
+		if shared {
+			item = item.Copy()
+		}
+		return item, err
+	}
+}
+
+func (c *coalescer) onMultiGet(next resource.OnMultiGetMiddlewareHandler) resource.OnMultiGetMiddlewareHandler {
+	return func(ctx context.Context, ids []interface{}) ([]*resource.Item, error) {
+		keys := make([]string, len(ids))
+		for i, id := range ids {
+			keys[i] = idKey(id)
+		}
+		key := c.key(ctx, resource.OpMultiGet, strings.Join(keys, "\x00"))
+		v, shared, err := c.group.do(ctx, key, func(ctx context.Context) (interface{}, error) {
+			return next(ctx, ids)
+		})
+		items, _ := v.([]*resource.Item)
+		if shared {
+			items = copyItems(items)
+		}
+		return items, err
+	}
+}
+
+func (c *coalescer) onFind(next resource.OnFindMiddlewareHandler) resource.OnFindMiddlewareHandler {
+	return func(ctx context.Context, q *query.Query, forceTotal bool) (*resource.ItemList, error) {
+		key := c.key(ctx, resource.OpFind, queryKey(q, forceTotal))
+		v, shared, err := c.group.do(ctx, key, func(ctx context.Context) (interface{}, error) {
+			return next(ctx, q, forceTotal)
+		})
+		list, _ := v.(*resource.ItemList)
+		if shared && list != nil {
+			l := *list
+			l.Items = copyItems(list.Items)
+			list = &l
+		}
+		return list, err
+	}
+}
+
+// idKey returns a key for id distinguishing ids of different types.
+func idKey(id interface{}) string {
+	return fmt.Sprintf("%T:%v", id, id)
+}
+
+// queryKey returns the normalized representation of q.
+func queryKey(q *query.Query, forceTotal bool) string {
+	b := &strings.Builder{}
+	b.WriteString(q.Projection.String())
+	b.WriteByte(0)
+	b.WriteString(q.Predicate.String())
+	b.WriteByte(0)
+	for i, s := range q.Sort {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		if s.Reversed {
+			b.WriteByte('-')
+		}
+		b.WriteString(s.Name)
+	}
+	b.WriteByte(0)
+	if q.Window != nil {
+		b.WriteString(strconv.Itoa(q.Window.Offset))
+		b.WriteByte(':')
+		b.WriteString(strconv.Itoa(q.Window.Limit))
+	}
+	b.WriteByte(0)
+	b.WriteString(strconv.FormatBool(forceTotal))
+	return b.String()
+}
+
+func copyItems(items []*resource.Item) []*resource.Item {
+	if items == nil {
+		return nil
+	}
+	c := make([]*resource.Item, len(items))
+	for i, item := range items {
+		c[i] = item.Copy()
+	}
+	return c
+}
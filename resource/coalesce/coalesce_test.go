@@ -0,0 +1,155 @@
+package coalesce
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/resource/testing/mem"
+	"github.com/rs/rest-layer/schema"
+	"github.com/rs/rest-layer/schema/query"
+	"github.com/stretchr/testify/assert"
+)
+
+// countingStorer counts Find calls, blocking them until release is closed.
+type countingStorer struct {
+	*mem.MemoryHandler
+	mu      sync.Mutex
+	finds   int
+	release chan struct{}
+}
+
+func (s *countingStorer) Find(ctx context.Context, q *query.Query) (*resource.ItemList, error) {
+	s.mu.Lock()
+	s.finds++
+	s.mu.Unlock()
+	select {
+	case <-s.release:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return s.MemoryHandler.Find(ctx, q)
+}
+
+func (s *countingStorer) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.finds
+}
+
+func newTestResource(t *testing.T, opts Options) (*resource.Resource, *countingStorer) {
+	s := &countingStorer{MemoryHandler: mem.NewHandler(), release: make(chan struct{})}
+	index := resource.NewIndex()
+	r := index.Bind("users", schema.Schema{Fields: schema.Fields{"id": {}, "name": {}}}, s, resource.DefaultConf)
+	item, _ := resource.NewItem(map[string]interface{}{"id": "1", "name": "john"})
+	assert.NoError(t, s.MemoryHandler.Insert(context.Background(), []*resource.Item{item}))
+	r.Chain(Middleware(r, opts))
+	return r, s
+}
+
+// waitFinds waits for the storage to receive n Find calls.
+func waitFinds(s *countingStorer, n int) {
+	for s.count() < n {
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestCoalesceGet(t *testing.T) {
+	r, s := newTestResource(t, Options{})
+	ctx := context.Background()
+	const n = 5
+	items := make([]*resource.Item, n)
+	wg := sync.WaitGroup{}
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			item, err := r.Get(ctx, "1")
+			assert.NoError(t, err)
+			items[i] = item
+		}(i)
+	}
+	waitFinds(s, 1)
+	// Let the other calls join the in-flight one.
+	time.Sleep(20 * time.Millisecond)
+	close(s.release)
+	wg.Wait()
+	assert.Equal(t, 1, s.count())
+	for i := 1; i < n; i++ {
+		assert.Equal(t, items[0], items[i])
+	}
+	items[0].Payload["name"] = "jane"
+	assert.Equal(t, "john", items[1].Payload["name"])
+}
+
+func TestCoalesceFind(t *testing.T) {
+	r, s := newTestResource(t, Options{})
+	ctx := context.Background()
+	q1, _ := query.New("", `{name: "john"}`, "", nil)
+	q2, _ := query.New("", `{name: "jane"}`, "", nil)
+	wg := sync.WaitGroup{}
+	lists := make([]*resource.ItemList, 3)
+	for i, q := range []*query.Query{q1, q1, q2} {
+		wg.Add(1)
+		go func(i int, q *query.Query) {
+			defer wg.Done()
+			list, err := r.Find(ctx, q)
+			assert.NoError(t, err)
+			lists[i] = list
+		}(i, q)
+	}
+	waitFinds(s, 2)
+	time.Sleep(20 * time.Millisecond)
+	close(s.release)
+	wg.Wait()
+	assert.Equal(t, 2, s.count())
+	assert.Len(t, lists[0].Items, 1)
+	assert.Equal(t, lists[0], lists[1])
+	assert.Len(t, lists[2].Items, 0)
+}
+
+func TestCoalesceScope(t *testing.T) {
+	type userKey struct{}
+	r, s := newTestResource(t, Options{Scope: func(ctx context.Context) string {
+		u, _ := ctx.Value(userKey{}).(string)
+		return u
+	}})
+	wg := sync.WaitGroup{}
+	for _, u := range []string{"a", "b"} {
+		wg.Add(1)
+		go func(u string) {
+			defer wg.Done()
+			_, err := r.Get(context.WithValue(context.Background(), userKey{}, u), "1")
+			assert.NoError(t, err)
+		}(u)
+	}
+	waitFinds(s, 2)
+	close(s.release)
+	wg.Wait()
+	assert.Equal(t, 2, s.count())
+}
+
+func TestCoalesceCanceledLeader(t *testing.T) {
+	r, s := newTestResource(t, Options{})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		_, err := r.Get(ctx, "1")
+		done <- err
+	}()
+	waitFinds(s, 1)
+	go func() {
+		item, err := r.Get(context.Background(), "1")
+		assert.NoError(t, err)
+		assert.NotNil(t, item)
+		done <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	assert.Equal(t, context.Canceled, <-done)
+	waitFinds(s, 2)
+	close(s.release)
+	assert.NoError(t, <-done)
+}
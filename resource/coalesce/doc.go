@@ -0,0 +1,26 @@
+/*
+Package coalesce deduplicates identical concurrent reads on a resource.
+
+When many requests read the same item at once, or resolve the same references
+while evaluating projections, each of them normally triggers its own storage
+call. The middlewares returned by Middleware let the first caller perform the
+call while the concurrent identical ones wait for its result, in the style of
+singleflight. Each caller gets an independent deep copy of the items.
+
+	users := index.Bind("users", user, s, resource.DefaultConf)
+	users.Chain(coalesce.Middleware(users, coalesce.Options{}))
+
+Gets are keyed by the resource path and the item id, multi-gets by the list of
+ids and finds by the normalized query. Calls are only shared while in flight:
+nothing is cached once the storage call returns.
+
+Hooks and middlewares chained before the coalescing ones run for each caller
+while the others run once for the shared call, with the context of the first
+caller. If those compute results depending on the context (i.e.: the
+authenticated user), set Options.Scope so calls are only shared within the
+same scope.
+
+This package is part of the rest-layer project. See http://rest-layer.io for
+full REST Layer documentation.
+*/
+package coalesce
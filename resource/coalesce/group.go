@@ -0,0 +1,72 @@
+package coalesce
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// call is an in-flight or completed call.
+type call struct {
+	done chan struct{}
+	val  interface{}
+	err  error
+	dups int
+}
+
+// group deduplicates concurrent calls sharing the same key.
+type group struct {
+	mu    sync.Mutex
+	calls map[string]*call
+}
+
+// do calls fn unless a call with the same key is in flight, in which case it
+// waits for its result. The shared return value tells if the result is shared
+// with other callers and must be copied before use.
+//
+// If the call was made with the context of another caller and failed because
+// this context is done, fn is called again with ctx, as long as ctx is not
+// done itself.
+func (g *group) do(ctx context.Context, key string, fn func(ctx context.Context) (interface{}, error)) (v interface{}, shared bool, err error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = map[string]*call{}
+	}
+	if c, ok := g.calls[key]; ok {
+		c.dups++
+		g.mu.Unlock()
+		select {
+		case <-c.done:
+		case <-ctx.Done():
+			return nil, false, ctx.Err()
+		}
+		if isContextErr(c.err) && ctx.Err() == nil {
+			v, err = fn(ctx)
+			return v, false, err
+		}
+		return c.val, true, c.err
+	}
+	c := &call{done: make(chan struct{})}
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	// Reported to the waiting callers if fn panics.
+	c.err = errPanicked
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		// When the result is shared, the first caller must also get a copy
+		// so nobody alters the value others are reading.
+		shared = c.dups > 0
+		g.mu.Unlock()
+		close(c.done)
+	}()
+	c.val, c.err = fn(ctx)
+	return c.val, false, c.err
+}
+
+var errPanicked = errors.New("coalesced call panicked")
+
+func isContextErr(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
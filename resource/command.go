@@ -0,0 +1,144 @@
+package resource
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+
+	"github.com/rs/rest-layer/schema"
+	"github.com/rs/rest-layer/schema/query"
+)
+
+// CommandSpec describes the input of a command.
+type CommandSpec struct {
+	// Description documents the command.
+	Description string
+	// Payload is the schema the payload of the command is validated against
+	// before its execution. Its default values and hooks are applied. If nil,
+	// the payload is not validated.
+	Payload *schema.Schema
+}
+
+// CollectionCommand is a command executed on the collection of a resource. The
+// query selects the items the command applies to, as parsed from the request
+// (i.e.: filter and parent item of a sub-resource).
+type CollectionCommand func(ctx context.Context, r *http.Request, q *query.Query, payload map[string]interface{}) (http.Header, map[string]interface{}, error)
+
+// ReadOnlyCommand is a command executed on an item without side effect. It
+// can't modify the item.
+type ReadOnlyCommand func(ctx context.Context, r *http.Request, item *Item, payload map[string]interface{}) (http.Header, map[string]interface{}, error)
+
+var commandNameRegexp = regexp.MustCompile(`^[A-Za-z0-9_\-]+$`)
+
+// assertCommandName panics if name is not a valid command name. Only A-Z,
+// a-z, 0-9, _ and - are allowed.
+func (r *Resource) assertCommandName(name string) {
+	if !commandNameRegexp.MatchString(name) {
+		logPanicf(context.Background(), "Invalid command name: %s for resource %s", name, r.name)
+	}
+}
+
+// ReadOnlyCommand sets a command executed with GET on the items of the
+// resource (i.e.: GET /orders/1/summary). The payload of the command is made
+// of the query-string parameters of the request. As the response of a
+// read-only command only depends on the item and the payload, conditional
+// requests are supported using the item's etag and update time.
+func (r *Resource) ReadOnlyCommand(name string, spec CommandSpec, command ReadOnlyCommand) {
+	r.assertCommandName(name)
+	assertNotBound(name, r.resources, r.aliases)
+	if _, found := r.commands[name]; found {
+		logPanicf(context.Background(), "Cannot bind `%s': already bound as command'", name)
+	}
+	r.readOnlyCommands[name] = command
+	r.commandSpecs[name] = spec
+}
+
+// GetReadOnlyCommand returns the read-only command set with name on the
+// resource.
+func (r *Resource) GetReadOnlyCommand(name string) (ReadOnlyCommand, bool) {
+	c, found := r.readOnlyCommands[name]
+	return c, found
+}
+
+// GetReadOnlyCommandNames returns the sorted names of the read-only commands
+// set on the resource.
+func (r *Resource) GetReadOnlyCommandNames() []string {
+	n := make([]string, 0, len(r.readOnlyCommands))
+	for name := range r.readOnlyCommands {
+		n = append(n, name)
+	}
+	sort.Strings(n)
+	return n
+}
+
+// CollectionCommand sets a command executed with POST on the collection of the
+// resource (i.e.: POST /orders/recalculate).
+func (r *Resource) CollectionCommand(name string, spec CommandSpec, command CollectionCommand) {
+	r.assertCommandName(name)
+	if _, found := r.aliases[name]; found {
+		logPanicf(context.Background(), "Cannot bind `%s': already bound as alias'", name)
+	}
+	r.collectionCommands[name] = command
+	r.collectionCommandSpecs[name] = spec
+}
+
+// GetCollectionCommand returns the collection command set with name on the
+// resource.
+func (r *Resource) GetCollectionCommand(name string) (CollectionCommand, bool) {
+	c, found := r.collectionCommands[name]
+	return c, found
+}
+
+// GetCollectionCommandNames returns the sorted names of the collection commands
+// set on the resource.
+func (r *Resource) GetCollectionCommandNames() []string {
+	n := make([]string, 0, len(r.collectionCommands))
+	for name := range r.collectionCommands {
+		n = append(n, name)
+	}
+	sort.Strings(n)
+	return n
+}
+
+// GetCollectionCommandSpec returns the spec of the collection command set with
+// name on the resource.
+func (r *Resource) GetCollectionCommandSpec(name string) (CommandSpec, bool) {
+	s, found := r.collectionCommandSpecs[name]
+	return s, found
+}
+
+// SetCommandSpec sets the spec of the item command set with name on the
+// resource using Command or AsyncCommand. It panics if there is no such
+// command.
+func (r *Resource) SetCommandSpec(name string, spec CommandSpec) {
+	if _, found := r.commands[name]; !found {
+		if _, found = r.readOnlyCommands[name]; !found {
+			logPanicf(context.Background(), "Cannot set spec of `%s': no such command", name)
+		}
+	}
+	r.commandSpecs[name] = spec
+}
+
+// GetCommandSpec returns the spec of the item command, read-only or not, set
+// with name on the resource.
+func (r *Resource) GetCommandSpec(name string) (CommandSpec, bool) {
+	s, found := r.commandSpecs[name]
+	return s, found
+}
+
+// compileCommands compiles the payload schemas of the commands.
+func (r *Resource) compileCommands(rc schema.ReferenceChecker) error {
+	for _, specs := range []map[string]CommandSpec{r.commandSpecs, r.collectionCommandSpecs} {
+		for name, spec := range specs {
+			if spec.Payload == nil {
+				continue
+			}
+			if err := spec.Payload.Compile(rc); err != nil {
+				return fmt.Errorf("command %s: payload schema compilation error: %s", name, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package resource
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/rs/rest-layer/schema"
+	"github.com/rs/rest-layer/schema/query"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResourceCommandKinds(t *testing.T) {
+	i := NewIndex()
+	foo := i.Bind("foo", schema.Schema{}, nil, DefaultConf)
+	cmd := func(ctx context.Context, r *http.Request, item *Item, payload map[string]interface{}) (http.Header, *Item, map[string]interface{}, error) {
+		return nil, item, nil, nil
+	}
+	roCmd := func(ctx context.Context, r *http.Request, item *Item, payload map[string]interface{}) (http.Header, map[string]interface{}, error) {
+		return nil, nil, nil
+	}
+	colCmd := func(ctx context.Context, r *http.Request, q *query.Query, payload map[string]interface{}) (http.Header, map[string]interface{}, error) {
+		return nil, nil, nil
+	}
+	spec := CommandSpec{Description: "Summarize", Payload: &schema.Schema{Fields: schema.Fields{"format": {}}}}
+	foo.Command("publish", cmd)
+	foo.ReadOnlyCommand("summary", spec, roCmd)
+	foo.ReadOnlyCommand("stats", CommandSpec{}, roCmd)
+	foo.CollectionCommand("recalculate", CommandSpec{}, colCmd)
+	// Item and collection commands don't share the same namespace.
+	foo.CollectionCommand("publish", CommandSpec{}, colCmd)
+
+	assert.Equal(t, []string{"publish"}, foo.GetCommandNames())
+	assert.Equal(t, []string{"stats", "summary"}, foo.GetReadOnlyCommandNames())
+	assert.Equal(t, []string{"publish", "recalculate"}, foo.GetCollectionCommandNames())
+	_, found := foo.GetReadOnlyCommand("publish")
+	assert.False(t, found)
+	_, found = foo.GetCollectionCommand("summary")
+	assert.False(t, found)
+
+	s, found := foo.GetCommandSpec("summary")
+	assert.True(t, found)
+	assert.Equal(t, spec, s)
+	_, found = foo.GetCommandSpec("publish")
+	assert.False(t, found)
+	foo.SetCommandSpec("publish", spec)
+	s, _ = foo.GetCommandSpec("publish")
+	assert.Equal(t, spec, s)
+
+	log.SetOutput(ioutil.Discard)
+	assert.Panics(t, func() {
+		foo.SetCommandSpec("unknown", spec)
+	})
+	assert.Panics(t, func() {
+		foo.ReadOnlyCommand("publish", CommandSpec{}, roCmd)
+	})
+	assert.Panics(t, func() {
+		foo.Command("summary", cmd)
+	})
+	assert.Panics(t, func() {
+		foo.ReadOnlyCommand("in valid", CommandSpec{}, roCmd)
+	})
+	foo.Alias("active", url.Values{})
+	assert.Panics(t, func() {
+		foo.CollectionCommand("active", CommandSpec{}, colCmd)
+	})
+	assert.Panics(t, func() {
+		foo.Alias("recalculate", url.Values{})
+	})
+}
+
+func TestResourceCommandSpecCompile(t *testing.T) {
+	i := NewIndex()
+	foo := i.Bind("foo", schema.Schema{}, nil, DefaultConf)
+	foo.CollectionCommand("recalculate", CommandSpec{Payload: &schema.Schema{Fields: schema.Fields{
+		"bar": {Validator: &schema.Reference{Path: "unknown"}},
+	}}}, func(ctx context.Context, r *http.Request, q *query.Query, payload map[string]interface{}) (http.Header, map[string]interface{}, error) {
+		return nil, nil, nil
+	})
+	err := i.(Compiler).Compile()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "command recalculate: payload schema compilation error")
+	}
+}
@@ -1,5 +1,7 @@
 package resource
 
+import "time"
+
 // Conf defines the configuration for a given resource.
 type Conf struct {
 	// AllowedModes is the list of Mode allowed for the resource.
@@ -21,6 +23,68 @@ type Conf struct {
 	//
 	// TotalDenied prevents the user from requesting the total.
 	ForceTotal ForceTotalMode
+	// Unique lists the unique constraints enforced on the resource's items in
+	// addition to fields flagged with schema.Field.Unique. Violations are
+	// reported with a *resource.UniqueError before the item is stored.
+	Unique []Unique
+	// Indexes declares the storage indexes expected on the resource's items.
+	// When the storage handler implements the resource.Indexer interface,
+	// Index.Compile verifies (or creates, see resource.CreateIndexes) them.
+	Indexes []IndexDef
+	// WarnUnindexed logs a warning when a filter references a field which is
+	// not the leading field of one of the declared Indexes.
+	WarnUnindexed bool
+	// SchemaVersion defines the current version of the resource's schema. When
+	// set, items are stamped with this version on write, and items stored with
+	// an older version are upgraded on read using the migrations registered
+	// with Resource.Migration.
+	SchemaVersion int
+	// MigrationWriteBack stores items upgraded on read, using the ETag of the
+	// original item so concurrent updates are never overwritten. Items are
+	// stored in the background with Resource.Update, so update hooks, unique
+	// constraints and events apply without delaying the read. A few items
+	// are stored at once, within the deadline of the read: use
+	// Resource.MigrateAll for bulk upgrades. A failure to store an item is
+	// logged.
+	MigrationWriteBack bool
+	// PublishEvents records an Event in an outbox for each item inserted,
+	// updated or deleted. If the storage handler implements the EventStorer
+	// interface, events are stored atomically with the write. Otherwise, they
+	// are appended to Outbox once the write succeeded. Clear then deletes the
+	// matching items one by one so an event is recorded for each of them.
+	PublishEvents bool
+	// Outbox is the outbox events are appended to when the storage handler
+	// does not implement EventStorer.
+	Outbox Outbox
+	// SlowOperationThreshold logs operations on the resource taking longer
+	// than this duration at warn level. By default, slow operations are not
+	// reported.
+	SlowOperationThreshold time.Duration
+	// Timeout is the maximum duration of an operation on the resource. It is
+	// applied as a context deadline to the hooks, middlewares and storage
+	// handler calls of the operation. By default, operations have no timeout.
+	Timeout time.Duration
+	// ModeTimeouts overrides Timeout for the listed modes.
+	ModeTimeouts map[Mode]time.Duration
+	// ImportChunkSize is the number of items inserted at once by the NDJSON
+	// imports on the resource's collection. If zero, items are inserted by
+	// chunks of 100.
+	ImportChunkSize int
+	// ItemCache is the HTTP cache policy of the responses to item reads.
+	ItemCache CachePolicy
+	// ListCache is the HTTP cache policy of the responses to list reads.
+	ListCache CachePolicy
+}
+
+// CachePolicy defines the HTTP caching headers of the responses to reads,
+// including the 304 Not Modified ones.
+type CachePolicy struct {
+	// CacheControl is the value of the Cache-Control header (i.e.: "private,
+	// max-age=60" or "no-cache"). No header is sent if empty.
+	CacheControl string
+	// Vary lists the request headers the response depends on, sent in the
+	// Vary header (i.e.: Authorization or Accept).
+	Vary []string
 }
 
 // ForceTotalMode defines Conf.ForceTotal modes.
@@ -59,6 +123,27 @@ const (
 	List
 )
 
+// String returns the lowercase name of the mode.
+func (m Mode) String() string {
+	switch m {
+	case Create:
+		return "create"
+	case Read:
+		return "read"
+	case Update:
+		return "update"
+	case Replace:
+		return "replace"
+	case Delete:
+		return "delete"
+	case Clear:
+		return "clear"
+	case List:
+		return "list"
+	}
+	return "unknown"
+}
+
 var (
 	// ReadWrite is a shortcut for all modes.
 	ReadWrite = []Mode{Create, Read, Update, Replace, Delete, List, Clear}
@@ -84,3 +169,12 @@ func (c Conf) IsModeAllowed(mode Mode) bool {
 	}
 	return false
 }
+
+// ModeTimeout returns the timeout of the operations of the given mode, or 0 if
+// they have no timeout.
+func (c Conf) ModeTimeout(mode Mode) time.Duration {
+	if d, found := c.ModeTimeouts[mode]; found {
+		return d
+	}
+	return c.Timeout
+}
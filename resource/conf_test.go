@@ -2,6 +2,7 @@ package resource
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -44,3 +45,10 @@ func TestModeAllowedWriteOnly(t *testing.T) {
 	assert.True(t, c.IsModeAllowed(Clear))
 	assert.False(t, c.IsModeAllowed(List))
 }
+
+func TestConfModeTimeout(t *testing.T) {
+	c := Conf{Timeout: time.Second, ModeTimeouts: map[Mode]time.Duration{List: 5 * time.Second}}
+	assert.Equal(t, 5*time.Second, c.ModeTimeout(List))
+	assert.Equal(t, time.Second, c.ModeTimeout(Read))
+	assert.Equal(t, time.Duration(0), Conf{}.ModeTimeout(Read))
+}
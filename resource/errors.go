@@ -1,6 +1,9 @@
 package resource
 
-import "errors"
+import (
+	"errors"
+	"time"
+)
 
 var (
 	// ErrNotFound is returned when the requested resource can't be found.
@@ -19,3 +22,18 @@ var (
 	// resource.
 	ErrNoStorage = errors.New("No Storage Defined")
 )
+
+// UnavailableError is returned when a storage call is rejected to protect an
+// unhealthy or overloaded backend, i.e.: by an open circuit breaker or a full
+// bulkhead (see the resilience package).
+type UnavailableError struct {
+	// Reason describes why the call was rejected.
+	Reason string
+	// RetryAfter is the delay after which the call may succeed.
+	RetryAfter time.Duration
+}
+
+// Error implements the built-in error interface.
+func (e *UnavailableError) Error() string {
+	return "Service Unavailable: " + e.Reason
+}
@@ -0,0 +1,234 @@
+package resource
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/rs/rest-layer/schema/query"
+	"github.com/rs/xid"
+)
+
+// EventType defines the type of a domain event.
+type EventType string
+
+const (
+	// EventInserted is recorded when an item is inserted.
+	EventInserted EventType = "inserted"
+	// EventUpdated is recorded when an item is updated or replaced.
+	EventUpdated EventType = "updated"
+	// EventDeleted is recorded when an item is deleted.
+	EventDeleted EventType = "deleted"
+	// EventCommand is recorded when a command is executed on an item.
+	EventCommand EventType = "command"
+)
+
+// Event is a domain event recorded after a successful write.
+type Event struct {
+	// ID uniquely identifies the event.
+	ID string `json:"id"`
+	// Type is the type of the event.
+	Type EventType `json:"type"`
+	// Resource is the path of the resource the event occurred on.
+	Resource string `json:"resource"`
+	// ItemID is the id of the item the event occurred on.
+	ItemID interface{} `json:"item_id"`
+	// ETag is the ETag of the item after the write.
+	ETag string `json:"etag,omitempty"`
+	// Payload is the payload of the item after the write or, for deletions,
+	// the payload of the deleted item.
+	Payload map[string]interface{} `json:"payload,omitempty"`
+	// Command is the name of the executed command for EventCommand events.
+	Command string `json:"command,omitempty"`
+	// Data holds the payload sent to the command for EventCommand events.
+	Data map[string]interface{} `json:"data,omitempty"`
+	// Time is the time at which the event was recorded.
+	Time time.Time `json:"time"`
+}
+
+// Outbox stores events until they are delivered by a dispatcher.
+type Outbox interface {
+	// Append durably stores events.
+	Append(ctx context.Context, events []Event) error
+	// Pending returns at most limit events not yet acknowledged, in the order
+	// they were appended.
+	Pending(ctx context.Context, limit int) ([]Event, error)
+	// Ack removes delivered events from the outbox.
+	Ack(ctx context.Context, ids []string) error
+}
+
+// EventStorer is an optional interface a Storer can implement to store events
+// in an outbox atomically with the writes producing them. When implemented,
+// the storage handler acts as the outbox of the resource.
+type EventStorer interface {
+	Outbox
+	// InsertWithEvents is equivalent to Insert but must store events in the
+	// same transaction.
+	InsertWithEvents(ctx context.Context, items []*Item, events []Event) error
+	// UpdateWithEvents is equivalent to Update but must store events in the
+	// same transaction.
+	UpdateWithEvents(ctx context.Context, item *Item, original *Item, events []Event) error
+	// DeleteWithEvents is equivalent to Delete but must store events in the
+	// same transaction.
+	DeleteWithEvents(ctx context.Context, item *Item, events []Event) error
+}
+
+// ErrNoOutbox is returned when events are published on a resource with no
+// outbox.
+var ErrNoOutbox = errors.New("No Outbox Defined")
+
+// NewEvent creates a new event of type t on the resource for the item. The
+// payload of the item is copied so later changes to the item don't alter the
+// event.
+func (r *Resource) NewEvent(t EventType, item *Item) Event {
+	e := Event{
+		ID:       xid.New().String(),
+		Type:     t,
+		Resource: r.path,
+		Time:     time.Now(),
+	}
+	if item != nil {
+		e.ItemID = item.ID
+		e.ETag = item.ETag
+		if item.Payload != nil {
+			e.Payload = copyValue(item.Payload).(map[string]interface{})
+		}
+	}
+	return e
+}
+
+// Outbox returns the outbox events of the resource are recorded in, or nil if
+// Conf.PublishEvents is not set.
+func (r *Resource) Outbox() Outbox {
+	if !r.conf.PublishEvents {
+		return nil
+	}
+	if es := r.eventStorer(); es != nil {
+		return es
+	}
+	return r.conf.Outbox
+}
+
+// RecordEvent appends e to the resource's outbox. It is used to record events
+// not produced by a write, like the execution of a command with no change.
+func (r *Resource) RecordEvent(ctx context.Context, e Event) error {
+	if !r.conf.PublishEvents {
+		return nil
+	}
+	o := r.Outbox()
+	if o == nil {
+		return ErrNoOutbox
+	}
+	return o.Append(ctx, []Event{e})
+}
+
+// eventStorer returns the storage handler if it implements EventStorer.
+func (r *Resource) eventStorer() EventStorer {
+	if s, ok := r.storage.(storageWrapper); ok {
+		if es, ok := s.Storer.(EventStorer); ok {
+			return es
+		}
+	}
+	return nil
+}
+
+// compileEvents ensures events can be recorded if enabled.
+func (r *Resource) compileEvents() error {
+	if r.conf.PublishEvents && r.Outbox() == nil {
+		return ErrNoOutbox
+	}
+	return nil
+}
+
+// appendEvents appends events to the configured outbox after a successful
+// write. As the write can't be reverted, a failure is only logged.
+func (r *Resource) appendEvents(ctx context.Context, events []Event) {
+	if err := r.conf.Outbox.Append(ctx, events); err != nil {
+		logErrorf(ctx, "%s: cannot record %d event(s): %v", r.path, len(events), err)
+	}
+}
+
+// insert stores items and records the corresponding events if enabled.
+func (r *Resource) insert(ctx context.Context, items []*Item) error {
+	if !r.conf.PublishEvents {
+		return r.storage.Insert(ctx, items)
+	}
+	events := make([]Event, 0, len(items))
+	for _, item := range items {
+		events = append(events, r.NewEvent(EventInserted, item))
+	}
+	if es := r.eventStorer(); es != nil {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return es.InsertWithEvents(ctx, items, events)
+	}
+	if err := r.storage.Insert(ctx, items); err != nil {
+		return err
+	}
+	r.appendEvents(ctx, events)
+	return nil
+}
+
+// update stores item and records the corresponding event if enabled.
+func (r *Resource) update(ctx context.Context, item *Item, original *Item) error {
+	if !r.conf.PublishEvents {
+		return r.storage.Update(ctx, item, original)
+	}
+	events := []Event{r.NewEvent(EventUpdated, item)}
+	if es := r.eventStorer(); es != nil {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return es.UpdateWithEvents(ctx, item, original, events)
+	}
+	if err := r.storage.Update(ctx, item, original); err != nil {
+		return err
+	}
+	r.appendEvents(ctx, events)
+	return nil
+}
+
+// delete deletes item and records the corresponding event if enabled.
+func (r *Resource) delete(ctx context.Context, item *Item) error {
+	if !r.conf.PublishEvents {
+		return r.storage.Delete(ctx, item)
+	}
+	events := []Event{r.NewEvent(EventDeleted, item)}
+	if es := r.eventStorer(); es != nil {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return es.DeleteWithEvents(ctx, item, events)
+	}
+	if err := r.storage.Delete(ctx, item); err != nil {
+		return err
+	}
+	r.appendEvents(ctx, events)
+	return nil
+}
+
+// clear deletes the items matching q and records the corresponding events if
+// enabled. To record an event per item, the matching items are fetched and
+// deleted one by one instead of being cleared by the storage handler.
+func (r *Resource) clear(ctx context.Context, q *query.Query) (int, error) {
+	if !r.conf.PublishEvents {
+		return r.storage.Clear(ctx, q)
+	}
+	list, err := r.storage.Find(ctx, q)
+	if err != nil {
+		return 0, err
+	}
+	deleted := 0
+	for _, item := range list.Items {
+		if err := r.delete(ctx, item); err != nil {
+			if err == ErrNotFound {
+				// Deleted concurrently.
+				continue
+			}
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, nil
+}
@@ -0,0 +1,119 @@
+package resource
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/rs/rest-layer/schema"
+	"github.com/rs/rest-layer/schema/query"
+	"github.com/stretchr/testify/assert"
+)
+
+type testOutbox struct {
+	events []Event
+	err    error
+}
+
+func (o *testOutbox) Append(ctx context.Context, events []Event) error {
+	if o.err != nil {
+		return o.err
+	}
+	o.events = append(o.events, events...)
+	return nil
+}
+
+func (o *testOutbox) Pending(ctx context.Context, limit int) ([]Event, error) {
+	return o.events, nil
+}
+
+func (o *testOutbox) Ack(ctx context.Context, ids []string) error {
+	return nil
+}
+
+func TestResourcePublishEvents(t *testing.T) {
+	o := &testOutbox{}
+	i := NewIndex()
+	s := newTestStorer()
+	r := i.Bind("users", schema.Schema{Fields: schema.Fields{"id": {}}}, s, Conf{PublishEvents: true, Outbox: o})
+	if !assert.NoError(t, i.(Compiler).Compile()) {
+		return
+	}
+	assert.Equal(t, o, r.Outbox())
+	ctx := context.Background()
+
+	item := &Item{ID: 1, Payload: map[string]interface{}{"id": 1}}
+	assert.NoError(t, r.Insert(ctx, []*Item{item}))
+	assert.NoError(t, r.Update(ctx, item, item))
+	assert.NoError(t, r.Delete(ctx, item))
+	if assert.Len(t, o.events, 3) {
+		for i, typ := range []EventType{EventInserted, EventUpdated, EventDeleted} {
+			assert.Equal(t, typ, o.events[i].Type)
+			assert.Equal(t, "users", o.events[i].Resource)
+			assert.Equal(t, 1, o.events[i].ItemID)
+			assert.NotEmpty(t, o.events[i].ID)
+		}
+	}
+
+	// No event is recorded when the write fails.
+	s.insert = func(ctx context.Context, items []*Item) error {
+		return ErrConflict
+	}
+	assert.Equal(t, ErrConflict, r.Insert(ctx, []*Item{item}))
+	assert.Len(t, o.events, 3)
+
+	// A failure of the outbox doesn't fail the write.
+	o.err = errors.New("outbox error")
+	assert.NoError(t, r.Update(ctx, item, item))
+}
+
+func TestResourcePublishEventsCopy(t *testing.T) {
+	o := &testOutbox{}
+	i := NewIndex()
+	r := i.Bind("users", schema.Schema{Fields: schema.Fields{"id": {}, "meta": {}}}, newTestStorer(), Conf{PublishEvents: true, Outbox: o})
+	item := &Item{ID: 1, Payload: map[string]interface{}{"id": 1, "meta": map[string]interface{}{"a": "1"}}}
+	assert.NoError(t, r.Insert(context.Background(), []*Item{item}))
+	item.Payload["id"] = 2
+	item.Payload["meta"].(map[string]interface{})["a"] = "2"
+	if assert.Len(t, o.events, 1) {
+		assert.Equal(t, map[string]interface{}{"id": 1, "meta": map[string]interface{}{"a": "1"}}, o.events[0].Payload)
+	}
+}
+
+func TestResourcePublishEventsClear(t *testing.T) {
+	o := &testOutbox{}
+	i := NewIndex()
+	s := newTestStorer()
+	var deleted []interface{}
+	s.find = func(ctx context.Context, q *query.Query) (*ItemList, error) {
+		return &ItemList{Items: []*Item{{ID: 1}, {ID: 2}, {ID: 3}}}, nil
+	}
+	s.delete = func(ctx context.Context, item *Item) error {
+		if item.ID == 2 {
+			return ErrNotFound
+		}
+		deleted = append(deleted, item.ID)
+		return nil
+	}
+	s.clear = func(ctx context.Context, q *query.Query) (int, error) {
+		t.Error("storage Clear called")
+		return 0, nil
+	}
+	r := i.Bind("users", schema.Schema{Fields: schema.Fields{"id": {}}}, s, Conf{PublishEvents: true, Outbox: o})
+	n, err := r.Clear(context.Background(), &query.Query{})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, []interface{}{1, 3}, deleted)
+	if assert.Len(t, o.events, 2) {
+		for i, id := range []interface{}{1, 3} {
+			assert.Equal(t, EventDeleted, o.events[i].Type)
+			assert.Equal(t, id, o.events[i].ItemID)
+		}
+	}
+}
+
+func TestResourcePublishEventsNoOutbox(t *testing.T) {
+	i := NewIndex()
+	i.Bind("users", schema.Schema{Fields: schema.Fields{"id": {}}}, newTestStorer(), Conf{PublishEvents: true})
+	assert.EqualError(t, i.(Compiler).Compile(), "users: No Outbox Defined")
+}
@@ -260,7 +260,10 @@ func (h *eventHandler) use(e interface{}) error {
 
 func (h *eventHandler) onFind(ctx context.Context, q *query.Query) error {
 	for _, e := range h.onFindH {
-		if err := e.OnFind(ctx, q); err != nil {
+		ctx, span := traceHook(ctx, "OnFind", e)
+		err := e.OnFind(ctx, q)
+		endSpan(span, err)
+		if err != nil {
 			return err
 		}
 	}
@@ -269,13 +272,18 @@ func (h *eventHandler) onFind(ctx context.Context, q *query.Query) error {
 
 func (h *eventHandler) onFound(ctx context.Context, q *query.Query, list **ItemList, err *error) {
 	for _, e := range h.onFoundH {
+		ctx, span := traceHook(ctx, "OnFound", e)
 		e.OnFound(ctx, q, list, err)
+		endSpanPtr(span, err)
 	}
 }
 
 func (h *eventHandler) onGet(ctx context.Context, id interface{}) error {
 	for _, e := range h.onGetH {
-		if err := e.OnGet(ctx, id); err != nil {
+		ctx, span := traceHook(ctx, "OnGet", e)
+		err := e.OnGet(ctx, id)
+		endSpan(span, err)
+		if err != nil {
 			return err
 		}
 	}
@@ -284,13 +292,18 @@ func (h *eventHandler) onGet(ctx context.Context, id interface{}) error {
 
 func (h *eventHandler) onGot(ctx context.Context, item **Item, err *error) {
 	for _, e := range h.onGotH {
+		ctx, span := traceHook(ctx, "OnGot", e)
 		e.OnGot(ctx, item, err)
+		endSpanPtr(span, err)
 	}
 }
 
 func (h *eventHandler) onInsert(ctx context.Context, items []*Item) error {
 	for _, e := range h.onInsertH {
-		if err := e.OnInsert(ctx, items); err != nil {
+		ctx, span := traceHook(ctx, "OnInsert", e)
+		err := e.OnInsert(ctx, items)
+		endSpan(span, err)
+		if err != nil {
 			return err
 		}
 	}
@@ -299,13 +312,18 @@ func (h *eventHandler) onInsert(ctx context.Context, items []*Item) error {
 
 func (h *eventHandler) onInserted(ctx context.Context, items []*Item, err *error) {
 	for _, e := range h.onInsertedH {
+		ctx, span := traceHook(ctx, "OnInserted", e)
 		e.OnInserted(ctx, items, err)
+		endSpanPtr(span, err)
 	}
 }
 
 func (h *eventHandler) onUpdate(ctx context.Context, item *Item, original *Item) error {
 	for _, e := range h.onUpdateH {
-		if err := e.OnUpdate(ctx, item, original); err != nil {
+		ctx, span := traceHook(ctx, "OnUpdate", e)
+		err := e.OnUpdate(ctx, item, original)
+		endSpan(span, err)
+		if err != nil {
 			return err
 		}
 	}
@@ -314,13 +332,18 @@ func (h *eventHandler) onUpdate(ctx context.Context, item *Item, original *Item)
 
 func (h *eventHandler) onUpdated(ctx context.Context, item *Item, original *Item, err *error) {
 	for _, e := range h.onUpdatedH {
+		ctx, span := traceHook(ctx, "OnUpdated", e)
 		e.OnUpdated(ctx, item, original, err)
+		endSpanPtr(span, err)
 	}
 }
 
 func (h *eventHandler) onDelete(ctx context.Context, item *Item) error {
 	for _, e := range h.onDeleteH {
-		if err := e.OnDelete(ctx, item); err != nil {
+		ctx, span := traceHook(ctx, "OnDelete", e)
+		err := e.OnDelete(ctx, item)
+		endSpan(span, err)
+		if err != nil {
 			return err
 		}
 	}
@@ -329,13 +352,18 @@ func (h *eventHandler) onDelete(ctx context.Context, item *Item) error {
 
 func (h *eventHandler) onDeleted(ctx context.Context, item *Item, err *error) {
 	for _, e := range h.onDeletedH {
+		ctx, span := traceHook(ctx, "OnDeleted", e)
 		e.OnDeleted(ctx, item, err)
+		endSpanPtr(span, err)
 	}
 }
 
 func (h *eventHandler) onClear(ctx context.Context, q *query.Query) error {
 	for _, e := range h.onClearH {
-		if err := e.OnClear(ctx, q); err != nil {
+		ctx, span := traceHook(ctx, "OnClear", e)
+		err := e.OnClear(ctx, q)
+		endSpan(span, err)
+		if err != nil {
 			return err
 		}
 	}
@@ -344,6 +372,8 @@ func (h *eventHandler) onClear(ctx context.Context, q *query.Query) error {
 
 func (h *eventHandler) onCleared(ctx context.Context, q *query.Query, deleted *int, err *error) {
 	for _, e := range h.onClearedH {
+		ctx, span := traceHook(ctx, "OnCleared", e)
 		e.OnCleared(ctx, q, deleted, err)
+		endSpanPtr(span, err)
 	}
 }
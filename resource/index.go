@@ -64,7 +64,7 @@ func (i *index) Compile() error {
 			return fmt.Errorf("%s%s%s", r.name, sep, err)
 		}
 	}
-	return nil
+	return ensureIndexes(context.Background(), i.resources)
 }
 
 // GetResource retrieves a given resource by it's path. For instance if a resource "user" has a sub-resource "posts", a
@@ -139,6 +139,26 @@ func (rc refChecker) ReferenceChecker(path string, skipCheck bool) (schema.Field
 	}), rsc.Validator()
 }
 
+// ReferenceLookup implements the schema.ReferenceLookup interface.
+func (rc refChecker) ReferenceLookup(path string) schema.ReferenceLookupFunc {
+	rsc, exists := rc.index.GetResource(path, nil)
+	if !exists {
+		return nil
+	}
+	return func(ctx context.Context, ids []interface{}) (missing []interface{}, err error) {
+		items, err := rsc.MultiGet(ctx, ids)
+		if err != nil {
+			return nil, err
+		}
+		for i, id := range ids {
+			if i >= len(items) || items[i] == nil {
+				missing = append(missing, id)
+			}
+		}
+		return missing, nil
+	}
+}
+
 // assertNotBound asserts a given resource name is not already bound.
 func assertNotBound(name string, resources subResources, aliases map[string]url.Values) {
 	for _, r := range resources {
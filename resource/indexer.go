@@ -0,0 +1,198 @@
+package resource
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rs/rest-layer/schema/query"
+)
+
+// IndexDef declares a storage index on the items of a resource.
+type IndexDef struct {
+	// Name is an optional name for the index. Storage handlers may generate
+	// one from the fields when empty.
+	Name string
+	// Fields lists the indexed fields in order. A field may be prefixed by a
+	// minus sign to request a descending order, as with the sort parameter.
+	// Sub-fields may be referenced using the dot notation.
+	Fields []string
+	// Unique requests the storage to reject items sharing the same values for
+	// the indexed fields.
+	Unique bool
+	// Sparse requests the storage to only index items containing the indexed
+	// fields.
+	Sparse bool
+	// TTL, when set, requests the storage to expire items once the duration
+	// elapsed since the time stored in the indexed field. TTL indexes must
+	// have a single field.
+	TTL time.Duration
+}
+
+// Indexer is an optional interface a Storer can implement to create or verify
+// the indexes declared on a resource using Conf.Indexes.
+type Indexer interface {
+	// EnsureIndexes compares the declared indexes with the ones existing in the
+	// backend store and returns those missing. Unless dryRun is true, the
+	// missing indexes must be created before returning.
+	EnsureIndexes(ctx context.Context, indexes []IndexDef, dryRun bool) (missing []IndexDef, err error)
+}
+
+// CreateIndexes controls whether Index.Compile creates missing storage
+// indexes. By default, Compile only performs a dry-run and logs a warning for
+// each missing index.
+var CreateIndexes = false
+
+// IndexReport lists missing storage indexes per resource path.
+type IndexReport map[string][]IndexDef
+
+// MissingIndexes performs a dry-run of Indexer.EnsureIndexes on all the
+// resources of the index and reports the missing indexes. Resources with no
+// declared indexes or whose storage does not implement Indexer are ignored.
+func MissingIndexes(ctx context.Context, i Index) (IndexReport, error) {
+	report := IndexReport{}
+	err := walkIndexers(i.GetResources(), func(r *Resource, idx Indexer) error {
+		missing, err := idx.EnsureIndexes(ctx, r.conf.Indexes, true)
+		if err != nil {
+			return err
+		}
+		if len(missing) > 0 {
+			report[r.path] = missing
+		}
+		return nil
+	})
+	return report, err
+}
+
+// ensureIndexes runs the Indexer of each resource. Missing indexes are created
+// when CreateIndexes is true or reported as warnings otherwise.
+func ensureIndexes(ctx context.Context, resources []*Resource) error {
+	return walkIndexers(resources, func(r *Resource, idx Indexer) error {
+		missing, err := idx.EnsureIndexes(ctx, r.conf.Indexes, !CreateIndexes)
+		if err != nil {
+			return err
+		}
+		if !CreateIndexes {
+			for _, def := range missing {
+				r.log(ctx, LogLevelWarn, fmt.Sprintf("%s: missing index on %s", r.path, strings.Join(def.Fields, ", ")), map[string]interface{}{
+					"index": def,
+				})
+			}
+		}
+		return nil
+	})
+}
+
+// walkIndexers calls fn for each resource (and sub-resource) with declared
+// indexes and a storage implementing the Indexer interface.
+func walkIndexers(resources []*Resource, fn func(r *Resource, idx Indexer) error) error {
+	for _, r := range resources {
+		if len(r.conf.Indexes) > 0 {
+			for _, s := range r.storers() {
+				if idx, ok := s.(Indexer); ok {
+					if err := fn(r, idx); err != nil {
+						return fmt.Errorf("%s: %v", r.path, err)
+					}
+					break
+				}
+			}
+		}
+		if err := walkIndexers(r.resources, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compileIndexes checks the indexes declared on the resource.
+func (r *Resource) compileIndexes() error {
+	for _, def := range r.conf.Indexes {
+		if len(def.Fields) == 0 {
+			return fmt.Errorf("index without fields")
+		}
+		if def.TTL > 0 && len(def.Fields) > 1 {
+			return fmt.Errorf("TTL index on %s must have a single field", strings.Join(def.Fields, ", "))
+		}
+		for _, f := range def.Fields {
+			if r.validator.GetField(strings.TrimPrefix(f, "-")) == nil {
+				return fmt.Errorf("index on unknown field `%s'", f)
+			}
+		}
+	}
+	return nil
+}
+
+// isIndexed returns true if field is the leading field of one of the indexes
+// declared on the resource. The id field is always considered as indexed.
+func (r *Resource) isIndexed(field string) bool {
+	if field == "id" {
+		return true
+	}
+	for _, def := range r.conf.Indexes {
+		if strings.TrimPrefix(def.Fields[0], "-") == field {
+			return true
+		}
+	}
+	return false
+}
+
+// warnUnindexed logs a warning for each field of q's predicate not covered by
+// a declared index.
+func (r *Resource) warnUnindexed(ctx context.Context, q *query.Query) {
+	if !r.conf.WarnUnindexed || q == nil || r.LogLevel() > LogLevelWarn || Logger == nil {
+		return
+	}
+	for _, field := range predicateFields(q.Predicate, nil) {
+		if !r.isIndexed(field) {
+			r.log(ctx, LogLevelWarn, fmt.Sprintf("%s: filter on non-indexed field `%s'", r.path, field), map[string]interface{}{
+				"filter": q.Predicate.String(),
+			})
+		}
+	}
+}
+
+// predicateFields returns the distinct fields referenced by exps.
+func predicateFields(exps []query.Expression, fields []string) []string {
+	add := func(f string) {
+		for _, e := range fields {
+			if e == f {
+				return
+			}
+		}
+		fields = append(fields, f)
+	}
+	for _, exp := range exps {
+		switch e := exp.(type) {
+		case *query.And:
+			fields = predicateFields(*e, fields)
+		case *query.Or:
+			fields = predicateFields(*e, fields)
+		case *query.Equal:
+			add(e.Field)
+		case *query.NotEqual:
+			add(e.Field)
+		case *query.In:
+			add(e.Field)
+		case *query.NotIn:
+			add(e.Field)
+		case *query.Exist:
+			add(e.Field)
+		case *query.NotExist:
+			add(e.Field)
+		case *query.GreaterThan:
+			add(e.Field)
+		case *query.GreaterOrEqual:
+			add(e.Field)
+		case *query.LowerThan:
+			add(e.Field)
+		case *query.LowerOrEqual:
+			add(e.Field)
+		case *query.Regex:
+			add(e.Field)
+		case *query.ElemMatch:
+			add(e.Field)
+		}
+	}
+	return fields
+}
@@ -0,0 +1,114 @@
+package resource
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rs/rest-layer/schema"
+	"github.com/rs/rest-layer/schema/query"
+	"github.com/stretchr/testify/assert"
+)
+
+type testIndexer struct {
+	*testStorer
+	existing []string
+	created  []IndexDef
+}
+
+func (s *testIndexer) EnsureIndexes(ctx context.Context, indexes []IndexDef, dryRun bool) ([]IndexDef, error) {
+	missing := []IndexDef{}
+	for _, def := range indexes {
+		found := false
+		for _, name := range s.existing {
+			if def.Name == name {
+				found = true
+			}
+		}
+		if !found {
+			missing = append(missing, def)
+		}
+	}
+	if !dryRun {
+		s.created = append(s.created, missing...)
+	}
+	return missing, nil
+}
+
+func newIndexerTestIndex(s Storer) Index {
+	i := NewIndex()
+	i.Bind("users", schema.Schema{Fields: schema.Fields{
+		"id":      {},
+		"email":   {},
+		"name":    {},
+		"expires": {},
+	}}, s, Conf{Indexes: []IndexDef{
+		{Name: "email", Fields: []string{"email"}, Unique: true},
+		{Name: "name_email", Fields: []string{"name", "-email"}, Sparse: true},
+		{Name: "expires", Fields: []string{"expires"}, TTL: time.Hour},
+	}})
+	return i
+}
+
+func TestIndexCompileIndexes(t *testing.T) {
+	s := &testIndexer{testStorer: newTestStorer(), existing: []string{"email"}}
+	i := newIndexerTestIndex(s)
+
+	if assert.NoError(t, i.(Compiler).Compile()) {
+		assert.Empty(t, s.created)
+	}
+
+	report, err := MissingIndexes(context.Background(), i)
+	if assert.NoError(t, err) {
+		assert.Equal(t, IndexReport{"users": {
+			{Name: "name_email", Fields: []string{"name", "-email"}, Sparse: true},
+			{Name: "expires", Fields: []string{"expires"}, TTL: time.Hour},
+		}}, report)
+	}
+
+	CreateIndexes = true
+	defer func() { CreateIndexes = false }()
+	if assert.NoError(t, i.(Compiler).Compile()) {
+		assert.Len(t, s.created, 2)
+	}
+}
+
+func TestIndexCompileIndexesError(t *testing.T) {
+	i := NewIndex()
+	i.Bind("users", schema.Schema{Fields: schema.Fields{"id": {}}}, nil, Conf{
+		Indexes: []IndexDef{{Fields: []string{"-unknown"}}},
+	})
+	assert.EqualError(t, i.(Compiler).Compile(), "users: index on unknown field `-unknown'")
+
+	i = NewIndex()
+	i.Bind("users", schema.Schema{Fields: schema.Fields{"id": {}, "a": {}, "b": {}}}, nil, Conf{
+		Indexes: []IndexDef{{Fields: []string{"a", "b"}, TTL: time.Hour}},
+	})
+	assert.EqualError(t, i.(Compiler).Compile(), "users: TTL index on a, b must have a single field")
+}
+
+func TestResourceWarnUnindexed(t *testing.T) {
+	var warnings []string
+	defer func(l func(context.Context, LogLevel, string, map[string]interface{})) { Logger = l }(Logger)
+	Logger = func(ctx context.Context, level LogLevel, msg string, fields map[string]interface{}) {
+		if level == LogLevelWarn {
+			warnings = append(warnings, msg)
+		}
+	}
+	i := NewIndex()
+	r := i.Bind("users", schema.Schema{Fields: schema.Fields{
+		"id":    {},
+		"email": {Filterable: true},
+		"name":  {Filterable: true},
+	}}, newTestStorer(), Conf{
+		Indexes:       []IndexDef{{Fields: []string{"email", "name"}}},
+		WarnUnindexed: true,
+	})
+	q, err := query.New("", `{$or: [{email: "a"}, {name: "b"}], id: "c"}`, "", nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, err = r.Find(context.Background(), q)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"users: filter on non-indexed field `name'"}, warnings)
+}
@@ -21,6 +21,11 @@ type Item struct {
 	Updated time.Time
 	// Payload the actual data of the item
 	Payload map[string]interface{}
+	// SchemaVersion is the version of the resource schema the payload was
+	// stored with. It is set by REST Layer on write when Conf.SchemaVersion is
+	// defined and must be stored by the storage handler together with the
+	// payload.
+	SchemaVersion int
 }
 
 // ItemList represents a list of items
@@ -37,6 +42,10 @@ type ItemList struct {
 	// Items is the list of items contained in the current page given the
 	// current context.
 	Items []*Item
+	// ETag is the etag of the list as served, computed by REST Layer from the
+	// items, the projection and the page metadata. Storage handlers don't
+	// need to set it.
+	ETag string
 }
 
 // NewItem creates a new item from a payload.
@@ -58,6 +67,44 @@ func NewItem(payload map[string]interface{}) (*Item, error) {
 	return item, nil
 }
 
+// Copy returns a deep copy of the item. Maps and slices of the payload are
+// copied recursively, other values are shared.
+func (i *Item) Copy() *Item {
+	if i == nil {
+		return nil
+	}
+	c := *i
+	if i.Payload != nil {
+		c.Payload = copyValue(i.Payload).(map[string]interface{})
+	}
+	return &c
+}
+
+// copyValue returns a deep copy of the maps and slices of v.
+func copyValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		c := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			c[k] = copyValue(val)
+		}
+		return c
+	case []interface{}:
+		c := make([]interface{}, len(v))
+		for i, val := range v {
+			c[i] = copyValue(val)
+		}
+		return c
+	case []map[string]interface{}:
+		c := make([]map[string]interface{}, len(v))
+		for i, val := range v {
+			c[i] = copyValue(val).(map[string]interface{})
+		}
+		return c
+	}
+	return v
+}
+
 // GetField returns the item's payload field by its name.
 //
 // A field name may use the dot notation to reference a sub field. A GetField on
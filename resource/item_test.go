@@ -29,3 +29,20 @@ func TestItemGetField(t *testing.T) {
 	assert.Equal(t, map[string]interface{}{"subfield": 1}, i.GetField("field"))
 	assert.Equal(t, 1, i.GetField("field.subfield"))
 }
+
+func TestItemCopy(t *testing.T) {
+	i := &Item{ID: 1, ETag: "a", Payload: map[string]interface{}{
+		"id":   1,
+		"tags": []interface{}{"a", map[string]interface{}{"b": 1}},
+		"sub":  map[string]interface{}{"c": "d"},
+	}}
+	c := i.Copy()
+	assert.Equal(t, i, c)
+	c.Payload["sub"].(map[string]interface{})["c"] = "e"
+	c.Payload["tags"].([]interface{})[1].(map[string]interface{})["b"] = 2
+	c.ETag = "b"
+	assert.Equal(t, "d", i.Payload["sub"].(map[string]interface{})["c"])
+	assert.Equal(t, 1, i.Payload["tags"].([]interface{})[1].(map[string]interface{})["b"])
+	assert.Equal(t, "a", i.ETag)
+	assert.Nil(t, (*Item)(nil).Copy())
+}
@@ -0,0 +1,46 @@
+package resource
+
+import (
+	"context"
+	"net/http"
+)
+
+// AsyncCommand is a long-running command executed in the background by a
+// JobQueue. The ctx is canceled when the job is canceled. The progress function
+// reports the completion ratio of the job, between 0 and 1. The returned result
+// is stored in the job.
+type AsyncCommand func(ctx context.Context, item *Item, payload map[string]interface{}, progress func(float64)) (result map[string]interface{}, err error)
+
+// JobQueue executes asynchronous commands in the background and tracks their
+// execution as items of a jobs resource (see the jobs package).
+type JobQueue interface {
+	// Jobs returns the resource storing the jobs.
+	Jobs() *Resource
+	// Enqueue stores a new job executing the command name of rsrc on item with
+	// payload, and queues it for execution. It returns the stored job.
+	Enqueue(ctx context.Context, rsrc *Resource, name string, command AsyncCommand, item *Item, payload map[string]interface{}) (*Item, error)
+}
+
+type asyncCommand struct {
+	queue   JobQueue
+	command AsyncCommand
+}
+
+// AsyncCommand sets a command executed in the background on the resource.
+// Executed through the REST API, the command is queued in q and the request
+// returns as soon as the job is stored. The command is also available as a
+// regular Command executing it synchronously.
+func (r *Resource) AsyncCommand(name string, q JobQueue, command AsyncCommand) {
+	r.Command(name, func(ctx context.Context, _ *http.Request, item *Item, payload map[string]interface{}) (http.Header, *Item, map[string]interface{}, error) {
+		result, err := command(ctx, item, payload, func(float64) {})
+		return nil, item, result, err
+	})
+	r.asyncCommands[name] = asyncCommand{q, command}
+}
+
+// GetAsyncCommand returns the asynchronous command set with name on the
+// resource and the queue executing it.
+func (r *Resource) GetAsyncCommand(name string) (JobQueue, AsyncCommand, bool) {
+	c, found := r.asyncCommands[name]
+	return c.queue, c.command, found
+}
@@ -0,0 +1,30 @@
+/*
+Package jobs executes long-running commands in the background and tracks their
+execution as items of a regular resource.
+
+Jobs are items of a resource bound with Schema, stored with any storage
+handler. A Pool executes the jobs with a fixed number of workers. Commands
+registered with resource.Resource.AsyncCommand are queued in the pool when
+executed through the REST API: the request returns 202 Accepted with the job in
+the body and a Location header pointing at the job item, which reports the
+status, progress, result and error of the execution.
+
+	jobsRsrc := index.Bind("jobs", jobs.Schema, jobsHandler, resource.Conf{
+		AllowedModes: []resource.Mode{resource.Read, resource.List, resource.Delete},
+	})
+	pool := jobs.NewPool(jobsRsrc)
+	pool.Concurrency = 8
+	go pool.Run(ctx)
+	reports.AsyncCommand("generate", pool, generateReport)
+
+Deleting a job cancels its execution. The queue of a pool is only kept in
+memory: the jobs pending or running when the process stops can't be resumed.
+Each pool holds a lease on its unfinished jobs, stored with them and renewed
+while Run is running. The pools sharing a jobs resource, like the replicas of
+a service, mark the unfinished jobs whose lease expired as failed with the
+"interrupted" error, so clients can execute the command again.
+
+This package is part of the rest-layer project. See http://rest-layer.io for
+full REST Layer documentation.
+*/
+package jobs
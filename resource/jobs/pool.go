@@ -0,0 +1,425 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+	"github.com/rs/rest-layer/schema/query"
+	"github.com/rs/xid"
+)
+
+// Pool executes the jobs of asynchronous commands with a fixed number of
+// workers. It implements the resource.JobQueue interface.
+type Pool struct {
+	// Concurrency is the number of jobs executed at once (default 4).
+	Concurrency int
+	// MaxPending is the maximum number of jobs waiting for a worker. Once
+	// reached, Enqueue returns a resource.UnavailableError (default 1000, 0
+	// means unlimited).
+	MaxPending int
+	// ProgressInterval is the minimum delay between two stores of the progress
+	// of a running job (default 1s).
+	ProgressInterval time.Duration
+	// LeaseDuration is the duration of the lease held by the pool on its
+	// pending and running jobs. Run renews the leases every third of this
+	// duration. The unfinished jobs whose lease expired, because their pool
+	// stopped, are recovered by the other pools (default 1m).
+	LeaseDuration time.Duration
+
+	jobs *resource.Resource
+	// id identifies the pool as the owner of its jobs.
+	id    string
+	mu    sync.Mutex
+	queue []*task
+	// tasks holds the pending and running tasks by job id.
+	tasks map[interface{}]*task
+	wake  chan struct{}
+}
+
+// task is the execution of a command queued in the pool.
+type task struct {
+	id      interface{}
+	rsrc    *resource.Resource
+	name    string
+	command resource.AsyncCommand
+	item    *resource.Item
+	payload map[string]interface{}
+	// mu serializes the stores of the job.
+	mu sync.Mutex
+	// job is the last stored version of the job.
+	job *resource.Item
+	// ctx is canceled when the job is canceled.
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewPool creates a pool storing its jobs in the jobs resource, which must be
+// bound with Schema. Deleting a job from the jobs resource cancels it.
+func NewPool(jobs *resource.Resource) *Pool {
+	p := &Pool{
+		Concurrency:      4,
+		MaxPending:       1000,
+		ProgressInterval: time.Second,
+		LeaseDuration:    time.Minute,
+		jobs:             jobs,
+		id:               xid.New().String(),
+		tasks:            map[interface{}]*task{},
+		wake:             make(chan struct{}, 1),
+	}
+	jobs.Use(resource.DeletedEventHandlerFunc(func(ctx context.Context, item *resource.Item, err *error) {
+		if *err == nil && item != nil {
+			p.Cancel(item.ID)
+		}
+	}))
+	return p
+}
+
+// Jobs implements the resource.JobQueue interface.
+func (p *Pool) Jobs() *resource.Resource {
+	return p.jobs
+}
+
+// Enqueue implements the resource.JobQueue interface.
+func (p *Pool) Enqueue(ctx context.Context, rsrc *resource.Resource, name string, command resource.AsyncCommand, item *resource.Item, payload map[string]interface{}) (*resource.Item, error) {
+	if p.MaxPending > 0 && p.Pending() >= p.MaxPending {
+		return nil, &resource.UnavailableError{Reason: "too many pending jobs"}
+	}
+	now := time.Now()
+	job, err := resource.NewItem(map[string]interface{}{
+		"id":       schema.NewID(ctx, nil),
+		"created":  now,
+		"updated":  now,
+		"resource": rsrc.Path(),
+		"item":     item.ID,
+		"command":  name,
+		"status":   StatusPending,
+		"progress": 0.0,
+		"owner":    p.id,
+		"lease":    now.Add(p.leaseDuration()),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err = p.jobs.Insert(ctx, []*resource.Item{job}); err != nil {
+		return nil, err
+	}
+	t := &task{
+		id:      job.ID,
+		rsrc:    rsrc,
+		name:    name,
+		command: command,
+		item:    item,
+		payload: payload,
+		job:     job,
+	}
+	t.ctx, t.cancel = context.WithCancel(context.Background())
+	p.mu.Lock()
+	p.queue = append(p.queue, t)
+	p.tasks[job.ID] = t
+	p.mu.Unlock()
+	p.notify()
+	return job, nil
+}
+
+// Cancel cancels the pending or running job with id. It returns false if the
+// job is not handled by the pool or is already finished.
+func (p *Pool) Cancel(id interface{}) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	t, found := p.tasks[id]
+	if found {
+		t.cancel()
+	}
+	return found
+}
+
+// Pending returns the number of jobs waiting for a worker.
+func (p *Pool) Pending() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.queue)
+}
+
+// notify wakes up a worker.
+func (p *Pool) notify() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+// next dequeues the next pending task, or returns nil if there is none.
+func (p *Pool) next() *task {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.queue) == 0 {
+		return nil
+	}
+	t := p.queue[0]
+	p.queue[0] = nil
+	p.queue = p.queue[1:]
+	if len(p.queue) > 0 {
+		// Let another worker pick the next one.
+		p.notify()
+	}
+	return t
+}
+
+// leaseDuration returns the duration of the leases held by the pool.
+func (p *Pool) leaseDuration() time.Duration {
+	if p.LeaseDuration <= 0 {
+		return time.Minute
+	}
+	return p.LeaseDuration
+}
+
+// Recover marks as failed the jobs left pending or running by a stopped pool,
+// i.e.: before a restart, as their execution was lost with it. Such jobs are
+// recognized by their expired lease, so the jobs of the other running pools
+// sharing the jobs resource are left untouched. It returns the number of
+// recovered jobs. It is called by Run on start and then periodically.
+func (p *Pool) Recover(ctx context.Context) (recovered int, err error) {
+	list, err := p.jobs.Find(ctx, &query.Query{Predicate: query.Predicate{
+		&query.In{Field: "status", Values: []query.Value{StatusPending, StatusRunning}},
+	}})
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	for _, job := range list.Items {
+		p.mu.Lock()
+		_, handled := p.tasks[job.ID]
+		p.mu.Unlock()
+		// Jobs with no lease were stored by a pool predating leases.
+		if lease, ok := job.Payload["lease"].(time.Time); handled || (ok && lease.After(now)) {
+			continue
+		}
+		// The store fails with a conflict if the owner renewed the lease or
+		// another pool recovered the job meanwhile.
+		err := p.store(ctx, &task{id: job.ID, job: job}, map[string]interface{}{
+			"status":   StatusFailed,
+			"error":    "interrupted",
+			"finished": now,
+		})
+		if err == nil {
+			recovered++
+		} else if !errors.Is(err, resource.ErrNotFound) && !errors.Is(err, resource.ErrConflict) {
+			return recovered, err
+		}
+	}
+	return recovered, nil
+}
+
+// Run recovers the jobs left unfinished by stopped pools (see Recover), then
+// executes the queued jobs with Concurrency workers until ctx is canceled.
+// While running, the leases of the pending and running jobs of the pool are
+// renewed and the jobs of stopped pools recovered. Running jobs are canceled
+// with ctx, and Run returns the ctx error once they are finished.
+func (p *Pool) Run(ctx context.Context) error {
+	if _, err := p.Recover(ctx); err != nil {
+		logErrorf(ctx, "cannot recover unfinished jobs: %v", err)
+	}
+	n := p.Concurrency
+	if n <= 0 {
+		n = 1
+	}
+	var wg sync.WaitGroup
+	wg.Add(n + 1)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer wg.Done()
+			p.work(ctx)
+		}()
+	}
+	go func() {
+		defer wg.Done()
+		p.maintain(ctx)
+	}()
+	wg.Wait()
+	return ctx.Err()
+}
+
+// maintain renews the leases of the jobs of the pool and recovers the jobs of
+// stopped pools until ctx is canceled.
+func (p *Pool) maintain(ctx context.Context) {
+	ticker := time.NewTicker(p.leaseDuration() / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		p.renew(ctx)
+		if _, err := p.Recover(ctx); err != nil && ctx.Err() == nil {
+			logErrorf(ctx, "cannot recover unfinished jobs: %v", err)
+		}
+	}
+}
+
+// renew extends the leases of the pending and running jobs of the pool. Jobs
+// deleted by another process are canceled.
+func (p *Pool) renew(ctx context.Context) {
+	p.mu.Lock()
+	tasks := make([]*task, 0, len(p.tasks))
+	for _, t := range p.tasks {
+		tasks = append(tasks, t)
+	}
+	p.mu.Unlock()
+	for _, t := range tasks {
+		t.mu.Lock()
+		if status := t.job.Payload["status"]; status == StatusPending || status == StatusRunning {
+			err := p.store(ctx, t, map[string]interface{}{"lease": time.Now().Add(p.leaseDuration())})
+			if errors.Is(err, resource.ErrNotFound) {
+				t.cancel()
+			} else if err != nil && ctx.Err() == nil {
+				logErrorf(ctx, "job %v: cannot renew lease: %v", t.id, err)
+			}
+		}
+		t.mu.Unlock()
+	}
+}
+
+// work executes queued tasks until ctx is canceled.
+func (p *Pool) work(ctx context.Context) {
+	for ctx.Err() == nil {
+		t := p.next()
+		if t == nil {
+			select {
+			case <-ctx.Done():
+			case <-p.wake:
+			}
+			continue
+		}
+		p.run(ctx, t)
+	}
+}
+
+// run executes t and stores its status as it changes.
+func (p *Pool) run(ctx context.Context, t *task) {
+	defer func() {
+		p.mu.Lock()
+		delete(p.tasks, t.id)
+		p.mu.Unlock()
+		t.cancel()
+	}()
+	// The job must be stored even once canceled.
+	sctx := context.WithoutCancel(ctx)
+	if t.ctx.Err() != nil {
+		p.update(sctx, t, map[string]interface{}{"status": StatusCanceled, "finished": time.Now()})
+		return
+	}
+	if err := p.update(sctx, t, map[string]interface{}{"status": StatusRunning, "started": time.Now()}); err != nil {
+		return
+	}
+
+	jctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := context.AfterFunc(t.ctx, cancel)
+	defer stop()
+	var progress atomic.Uint64
+	var result map[string]interface{}
+	var err error
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("panic: %v", r)
+			}
+		}()
+		result, err = t.command(jctx, t.item, t.payload, func(f float64) {
+			progress.Store(math.Float64bits(math.Max(0, math.Min(1, f))))
+		})
+	}()
+	interval := p.ProgressInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	stored := 0.0
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		case <-ticker.C:
+			f := math.Float64frombits(progress.Load())
+			if f == stored {
+				continue
+			}
+			if err := p.update(sctx, t, map[string]interface{}{"progress": f}); err == nil {
+				stored = f
+			} else if errors.Is(err, resource.ErrNotFound) {
+				// The job has been deleted by another process.
+				t.cancel()
+			}
+		}
+	}
+
+	changes := map[string]interface{}{"finished": time.Now()}
+	switch {
+	case t.ctx.Err() != nil:
+		changes["status"] = StatusCanceled
+	case err != nil:
+		changes["status"] = StatusFailed
+		changes["error"] = err.Error()
+	default:
+		changes["status"] = StatusSucceeded
+		changes["progress"] = 1.0
+		if result != nil {
+			changes["result"] = result
+		}
+		e := t.rsrc.NewEvent(resource.EventCommand, t.item)
+		e.Command = t.name
+		e.Data = t.payload
+		if err := t.rsrc.RecordEvent(sctx, e); err != nil {
+			logErrorf(sctx, "job %v: cannot record command event: %v", t.id, err)
+		}
+	}
+	p.update(sctx, t, changes)
+}
+
+// update stores the job of t with changes. Errors other than
+// resource.ErrNotFound, returned when the job has been deleted, are logged.
+func (p *Pool) update(ctx context.Context, t *task, changes map[string]interface{}) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	err := p.store(ctx, t, changes)
+	if err != nil && !errors.Is(err, resource.ErrNotFound) {
+		logErrorf(ctx, "job %v: cannot store job: %v", t.id, err)
+	}
+	return err
+}
+
+// store stores the job of t with changes. The caller must hold t.mu.
+func (p *Pool) store(ctx context.Context, t *task, changes map[string]interface{}) error {
+	payload := make(map[string]interface{}, len(t.job.Payload)+len(changes)+1)
+	for k, v := range t.job.Payload {
+		payload[k] = v
+	}
+	for k, v := range changes {
+		payload[k] = v
+	}
+	payload["updated"] = time.Now()
+	job, err := resource.NewItem(payload)
+	if err == nil {
+		err = p.jobs.Update(ctx, job, t.job)
+	}
+	if err != nil {
+		return err
+	}
+	t.job = job
+	return nil
+}
+
+func logErrorf(ctx context.Context, format string, a ...interface{}) {
+	resource.Log(ctx, resource.LogLevelError, fmt.Sprintf(format, a...), nil)
+}
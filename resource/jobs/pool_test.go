@@ -0,0 +1,230 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/resource/testing/mem"
+	"github.com/rs/rest-layer/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestPool(t *testing.T) (*Pool, *resource.Resource) {
+	t.Helper()
+	index := resource.NewIndex()
+	users := index.Bind("users", schema.Schema{Fields: schema.Fields{"id": {}}}, mem.NewHandler(), resource.DefaultConf)
+	jobs := index.Bind("jobs", Schema, mem.NewHandler(), resource.DefaultConf)
+	if !assert.NoError(t, index.(resource.Compiler).Compile()) {
+		t.FailNow()
+	}
+	p := NewPool(jobs)
+	p.ProgressInterval = 5 * time.Millisecond
+	return p, users
+}
+
+// waitJob waits for the job with id to reach status and returns its payload.
+func waitJob(t *testing.T, p *Pool, id interface{}, status string) map[string]interface{} {
+	t.Helper()
+	var job *resource.Item
+	var err error
+	for i := 0; i < 200; i++ {
+		if job, err = p.Jobs().Get(context.Background(), id); err == nil && job.Payload["status"] == status {
+			return job.Payload
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("job %v: %v", id, err)
+	}
+	t.Fatalf("job %v: expected status %s, got %v", id, status, job.Payload["status"])
+	return nil
+}
+
+func TestPool(t *testing.T) {
+	p, users := newTestPool(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go p.Run(ctx)
+
+	item := &resource.Item{ID: "u1", Payload: map[string]interface{}{"id": "u1"}}
+	step := make(chan struct{})
+	job, err := p.Enqueue(ctx, users, "export", func(ctx context.Context, item *resource.Item, payload map[string]interface{}, progress func(float64)) (map[string]interface{}, error) {
+		progress(0.5)
+		<-step
+		return map[string]interface{}{"user": item.ID, "format": payload["format"]}, nil
+	}, item, map[string]interface{}{"format": "csv"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "users", job.Payload["resource"])
+	assert.Equal(t, "u1", job.Payload["item"])
+	assert.Equal(t, "export", job.Payload["command"])
+	assert.Equal(t, StatusPending, job.Payload["status"])
+
+	for i := 0; i < 200; i++ {
+		if j, err := p.Jobs().Get(ctx, job.ID); err == nil && j.Payload["progress"] == 0.5 {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	running := waitJob(t, p, job.ID, StatusRunning)
+	assert.Equal(t, 0.5, running["progress"])
+	assert.Contains(t, running, "started")
+	close(step)
+
+	done := waitJob(t, p, job.ID, StatusSucceeded)
+	assert.Equal(t, 1.0, done["progress"])
+	assert.Equal(t, map[string]interface{}{"user": "u1", "format": "csv"}, done["result"])
+	assert.Contains(t, done, "finished")
+	assert.False(t, p.Cancel(job.ID))
+}
+
+func TestPoolFailure(t *testing.T) {
+	p, users := newTestPool(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go p.Run(ctx)
+
+	item := &resource.Item{ID: "u1"}
+	job, err := p.Enqueue(ctx, users, "fail", func(ctx context.Context, item *resource.Item, payload map[string]interface{}, progress func(float64)) (map[string]interface{}, error) {
+		return nil, errors.New("boom")
+	}, item, nil)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "boom", waitJob(t, p, job.ID, StatusFailed)["error"])
+	}
+	job, err = p.Enqueue(ctx, users, "panic", func(ctx context.Context, item *resource.Item, payload map[string]interface{}, progress func(float64)) (map[string]interface{}, error) {
+		panic("boom")
+	}, item, nil)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "panic: boom", waitJob(t, p, job.ID, StatusFailed)["error"])
+	}
+}
+
+func TestPoolCancel(t *testing.T) {
+	p, users := newTestPool(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	item := &resource.Item{ID: "u1"}
+	canceled := make(chan struct{})
+	block := func(ctx context.Context, item *resource.Item, payload map[string]interface{}, progress func(float64)) (map[string]interface{}, error) {
+		<-ctx.Done()
+		close(canceled)
+		return nil, ctx.Err()
+	}
+
+	// Canceled while pending.
+	pending, err := p.Enqueue(ctx, users, "block", block, item, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.True(t, p.Cancel(pending.ID))
+	go p.Run(ctx)
+	waitJob(t, p, pending.ID, StatusCanceled)
+
+	// Canceled while running by deleting the job.
+	running, err := p.Enqueue(ctx, users, "block", block, item, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	waitJob(t, p, running.ID, StatusRunning)
+	job, err := p.Jobs().Get(ctx, running.ID)
+	if assert.NoError(t, err) && assert.NoError(t, p.Jobs().Delete(ctx, job)) {
+		select {
+		case <-canceled:
+		case <-time.After(time.Second):
+			t.Fatal("job not canceled")
+		}
+	}
+}
+
+func TestPoolMaxPending(t *testing.T) {
+	p, users := newTestPool(t)
+	p.MaxPending = 1
+	noop := func(ctx context.Context, item *resource.Item, payload map[string]interface{}, progress func(float64)) (map[string]interface{}, error) {
+		return nil, nil
+	}
+	item := &resource.Item{ID: "u1"}
+	_, err := p.Enqueue(context.Background(), users, "noop", noop, item, nil)
+	assert.NoError(t, err)
+	_, err = p.Enqueue(context.Background(), users, "noop", noop, item, nil)
+	var uErr *resource.UnavailableError
+	assert.True(t, errors.As(err, &uErr), "expected UnavailableError, got %v", err)
+	assert.Equal(t, 1, p.Pending())
+}
+
+func TestPoolRecover(t *testing.T) {
+	p, users := newTestPool(t)
+	ctx := context.Background()
+	expired, live := time.Now().Add(-time.Minute), time.Now().Add(time.Hour)
+	var items []*resource.Item
+	for _, payload := range []map[string]interface{}{
+		{"id": "j1", "status": StatusPending, "owner": "stopped", "lease": expired},
+		// Jobs with no lease are recovered too.
+		{"id": "j2", "status": StatusRunning},
+		{"id": "j3", "status": StatusSucceeded, "owner": "stopped", "lease": expired},
+		// Jobs held by another running pool are not recovered.
+		{"id": "j4", "status": StatusRunning, "owner": "running", "lease": live},
+	} {
+		item, _ := resource.NewItem(payload)
+		items = append(items, item)
+	}
+	assert.NoError(t, p.Jobs().Insert(ctx, items))
+	// Jobs of the pool itself are not recovered.
+	p.LeaseDuration = time.Nanosecond
+	step := make(chan struct{})
+	defer close(step)
+	job, err := p.Enqueue(ctx, users, "wait", func(ctx context.Context, item *resource.Item, payload map[string]interface{}, progress func(float64)) (map[string]interface{}, error) {
+		<-step
+		return nil, nil
+	}, &resource.Item{ID: "u1"}, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	time.Sleep(time.Millisecond)
+
+	recovered, err := p.Recover(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, recovered)
+	for id, status := range map[interface{}]string{"j1": StatusFailed, "j2": StatusFailed, "j3": StatusSucceeded, "j4": StatusRunning, job.ID: StatusPending} {
+		j, err := p.Jobs().Get(ctx, id)
+		if assert.NoError(t, err) {
+			assert.Equal(t, status, j.Payload["status"], "job %v", id)
+		}
+	}
+	j, _ := p.Jobs().Get(ctx, "j1")
+	assert.Equal(t, "interrupted", j.Payload["error"])
+}
+
+func TestPoolLease(t *testing.T) {
+	p, users := newTestPool(t)
+	p.LeaseDuration = 30 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go p.Run(ctx)
+
+	step := make(chan struct{})
+	defer close(step)
+	job, err := p.Enqueue(ctx, users, "wait", func(ctx context.Context, item *resource.Item, payload map[string]interface{}, progress func(float64)) (map[string]interface{}, error) {
+		<-step
+		return nil, nil
+	}, &resource.Item{ID: "u1"}, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, p.id, job.Payload["owner"])
+	lease := job.Payload["lease"].(time.Time)
+	waitJob(t, p, job.ID, StatusRunning)
+
+	// Another pool sharing the jobs resource doesn't recover the running job
+	// as its lease is renewed.
+	time.Sleep(100 * time.Millisecond)
+	other := NewPool(p.Jobs())
+	recovered, err := other.Recover(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, recovered)
+	running := waitJob(t, p, job.ID, StatusRunning)
+	assert.True(t, running["lease"].(time.Time).After(lease))
+}
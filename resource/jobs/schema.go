@@ -0,0 +1,90 @@
+package jobs
+
+import (
+	"github.com/rs/rest-layer/schema"
+)
+
+// Job statuses.
+const (
+	// StatusPending is the status of a job waiting for a worker.
+	StatusPending = "pending"
+	// StatusRunning is the status of a job being executed.
+	StatusRunning = "running"
+	// StatusSucceeded is the status of a job whose command returned no error.
+	StatusSucceeded = "succeeded"
+	// StatusFailed is the status of a job whose command returned an error.
+	StatusFailed = "failed"
+	// StatusCanceled is the status of a job canceled before its completion.
+	StatusCanceled = "canceled"
+)
+
+// Schema is the schema of the jobs resource. All its fields are read-only:
+// jobs are created and updated by the Pool.
+var Schema = schema.Schema{
+	Description: "The execution of an asynchronous command",
+	Fields: schema.Fields{
+		"id":      schema.IDField,
+		"created": schema.CreatedField,
+		"updated": schema.UpdatedField,
+		"resource": {
+			Description: "The path of the resource the command is executed on (i.e.: users.posts)",
+			ReadOnly:    true,
+			Filterable:  true,
+			Validator:   &schema.String{},
+		},
+		"item": {
+			Description: "The id of the item the command is executed on",
+			ReadOnly:    true,
+			Filterable:  true,
+		},
+		"command": {
+			Description: "The name of the command",
+			ReadOnly:    true,
+			Filterable:  true,
+			Validator:   &schema.String{},
+		},
+		"status": {
+			Description: "The status of the job",
+			ReadOnly:    true,
+			Filterable:  true,
+			Validator: &schema.String{
+				Allowed: []string{StatusPending, StatusRunning, StatusSucceeded, StatusFailed, StatusCanceled},
+			},
+		},
+		"progress": {
+			Description: "The completion ratio of the job, between 0 and 1",
+			ReadOnly:    true,
+			Validator:   &schema.Float{Boundaries: &schema.Boundaries{Min: 0, Max: 1}},
+		},
+		"result": {
+			Description: "The result returned by the command",
+			ReadOnly:    true,
+		},
+		"error": {
+			Description: "The error returned by the command",
+			ReadOnly:    true,
+			Validator:   &schema.String{},
+		},
+		"started": {
+			Description: "The time at which the execution started",
+			ReadOnly:    true,
+			Validator:   &schema.Time{},
+		},
+		"finished": {
+			Description: "The time at which the execution ended",
+			ReadOnly:    true,
+			Validator:   &schema.Time{},
+		},
+		"owner": {
+			Description: "The id of the pool executing the job",
+			ReadOnly:    true,
+			Filterable:  true,
+			Validator:   &schema.String{},
+		},
+		"lease": {
+			Description: "The time until which the job is held by its pool, renewed while the pool runs",
+			ReadOnly:    true,
+			Validator:   &schema.Time{},
+		},
+	},
+}
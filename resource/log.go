@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"log/slog"
+	"sort"
 )
 
 // LogLevel defines log levels
@@ -27,16 +29,128 @@ var Logger = func(ctx context.Context, level LogLevel, msg string, fields map[st
 	log.Output(2, msg)
 }
 
-func logErrorf(ctx context.Context, format string, a ...interface{}) {
-	if LoggerLevel <= LogLevelError && Logger != nil {
-		Logger(ctx, LogLevelError, fmt.Sprintf(format, a...), nil)
+// NewSlogLogger returns a Logger function logging to l. Fields are logged as
+// attributes and levels are converted using SlogLevel.
+//
+//	resource.Logger = resource.NewSlogLogger(slog.Default())
+func NewSlogLogger(l *slog.Logger) func(ctx context.Context, level LogLevel, msg string, fields map[string]interface{}) {
+	return func(ctx context.Context, level LogLevel, msg string, fields map[string]interface{}) {
+		lvl := SlogLevel(level)
+		if !l.Enabled(ctx, lvl) {
+			return
+		}
+		keys := make([]string, 0, len(fields))
+		for k := range fields {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		attrs := make([]slog.Attr, 0, len(fields))
+		for _, k := range keys {
+			attrs = append(attrs, slog.Any(k, fields[k]))
+		}
+		l.LogAttrs(ctx, lvl, msg, attrs...)
+	}
+}
+
+// SlogLevel converts a LogLevel to a slog.Level. LogLevelFatal is converted to
+// slog.LevelError+4.
+func SlogLevel(level LogLevel) slog.Level {
+	switch level {
+	case LogLevelDebug:
+		return slog.LevelDebug
+	case LogLevelInfo:
+		return slog.LevelInfo
+	case LogLevelWarn:
+		return slog.LevelWarn
+	case LogLevelError:
+		return slog.LevelError
+	}
+	return slog.LevelError + 4
+}
+
+type logFieldsKey struct{}
+
+// WithLogFields returns a copy of ctx holding fields. Fields stored in the
+// context are added to all the messages logged with it, like the request id
+// set by rest.Handler.
+func WithLogFields(ctx context.Context, fields map[string]interface{}) context.Context {
+	parent := LogFields(ctx)
+	merged := make(map[string]interface{}, len(parent)+len(fields))
+	for k, v := range parent {
+		merged[k] = v
 	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return context.WithValue(ctx, logFieldsKey{}, merged)
+}
+
+// LogFields returns the fields stored in ctx with WithLogFields.
+func LogFields(ctx context.Context) map[string]interface{} {
+	if ctx == nil {
+		return nil
+	}
+	fields, _ := ctx.Value(logFieldsKey{}).(map[string]interface{})
+	return fields
+}
+
+// Log logs msg with Logger if level is greater or equal to LoggerLevel. The
+// fields stored in ctx are added to fields.
+func Log(ctx context.Context, level LogLevel, msg string, fields map[string]interface{}) {
+	logAt(ctx, LoggerLevel, level, msg, fields)
+}
+
+// logAt logs msg if level is greater or equal to min.
+func logAt(ctx context.Context, min, level LogLevel, msg string, fields map[string]interface{}) {
+	if level < min || Logger == nil {
+		return
+	}
+	if ctxFields := LogFields(ctx); len(ctxFields) > 0 {
+		merged := make(map[string]interface{}, len(ctxFields)+len(fields))
+		for k, v := range ctxFields {
+			merged[k] = v
+		}
+		for k, v := range fields {
+			merged[k] = v
+		}
+		fields = merged
+	}
+	Logger(ctx, level, msg, fields)
+}
+
+// SetLogLevel sets the minimum level of the messages logged for operations on
+// the resource, overriding LoggerLevel.
+func (r *Resource) SetLogLevel(level LogLevel) {
+	r.logLevel = &level
+}
+
+// LogLevel returns the minimum level of the messages logged for the resource.
+func (r *Resource) LogLevel() LogLevel {
+	if r.logLevel != nil {
+		return *r.logLevel
+	}
+	return LoggerLevel
+}
+
+// log logs msg for the resource if level is enabled for it. The resource path
+// is added to the fields.
+func (r *Resource) log(ctx context.Context, level LogLevel, msg string, fields map[string]interface{}) {
+	if level < r.LogLevel() || Logger == nil {
+		return
+	}
+	if fields == nil {
+		fields = map[string]interface{}{}
+	}
+	fields["resource"] = r.path
+	logAt(ctx, LogLevelDebug, level, msg, fields)
+}
+
+func logErrorf(ctx context.Context, format string, a ...interface{}) {
+	Log(ctx, LogLevelError, fmt.Sprintf(format, a...), nil)
 }
 
 func logPanicf(ctx context.Context, format string, a ...interface{}) {
 	msg := fmt.Sprintf(format, a...)
-	if LoggerLevel <= LogLevelFatal && Logger != nil {
-		Logger(ctx, LogLevelFatal, msg, nil)
-	}
+	Log(ctx, LogLevelFatal, msg, nil)
 	panic(msg)
 }
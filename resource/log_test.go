@@ -0,0 +1,92 @@
+package resource
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/rest-layer/schema"
+	"github.com/rs/rest-layer/schema/query"
+	"github.com/stretchr/testify/assert"
+)
+
+// captureSlog installs a slog backed Logger at level and returns the logged
+// records.
+func captureSlog(t *testing.T, level LogLevel) func() []map[string]interface{} {
+	buf := &bytes.Buffer{}
+	l := slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	logger, loggerLevel := Logger, LoggerLevel
+	Logger, LoggerLevel = NewSlogLogger(l), level
+	t.Cleanup(func() { Logger, LoggerLevel = logger, loggerLevel })
+	return func() []map[string]interface{} {
+		records := []map[string]interface{}{}
+		for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			if line == "" {
+				continue
+			}
+			var rec map[string]interface{}
+			if assert.NoError(t, json.Unmarshal([]byte(line), &rec)) {
+				delete(rec, "time")
+				records = append(records, rec)
+			}
+		}
+		return records
+	}
+}
+
+func TestLogSlog(t *testing.T) {
+	records := captureSlog(t, LogLevelInfo)
+	ctx := WithLogFields(context.Background(), map[string]interface{}{"request_id": "abc"})
+	Log(ctx, LogLevelDebug, "ignored", nil)
+	Log(ctx, LogLevelWarn, "hello", map[string]interface{}{"count": 2})
+	assert.Equal(t, []map[string]interface{}{
+		{"level": "WARN", "msg": "hello", "count": float64(2), "request_id": "abc"},
+	}, records())
+}
+
+func TestLogResourceLevel(t *testing.T) {
+	records := captureSlog(t, LogLevelInfo)
+	r := newResource("users", schema.Schema{Fields: schema.Fields{"id": {}}}, newTestStorer(), DefaultConf)
+	ctx := WithLogFields(context.Background(), map[string]interface{}{"request_id": "abc"})
+	r.Find(ctx, &query.Query{})
+	assert.Empty(t, records())
+
+	r.SetLogLevel(LogLevelDebug)
+	assert.Equal(t, LogLevelDebug, r.LogLevel())
+	r.Get(ctx, 1)
+	if recs := records(); assert.Len(t, recs, 1) {
+		rec := recs[0]
+		assert.Equal(t, "DEBUG", rec["level"])
+		assert.Equal(t, "users.get", rec["msg"])
+		assert.Equal(t, "users", rec["resource"])
+		assert.Equal(t, "get", rec["operation"])
+		assert.Equal(t, float64(1), rec["id"])
+		assert.Equal(t, "Not Found", rec["error"])
+		assert.Equal(t, "abc", rec["request_id"])
+		assert.Contains(t, rec, "duration")
+	}
+}
+
+func TestLogSlowOperation(t *testing.T) {
+	records := captureSlog(t, LogLevelWarn)
+	s := newTestStorer()
+	s.find = func(ctx context.Context, q *query.Query) (*ItemList, error) {
+		time.Sleep(2 * time.Millisecond)
+		return &ItemList{Items: []*Item{{ID: 1}}}, nil
+	}
+	conf := DefaultConf
+	conf.SlowOperationThreshold = time.Millisecond
+	r := newResource("users", schema.Schema{Fields: schema.Fields{"id": {}}}, s, conf)
+	r.Find(context.Background(), &query.Query{})
+	if recs := records(); assert.Len(t, recs, 1) {
+		rec := recs[0]
+		assert.Equal(t, "WARN", rec["level"])
+		assert.Equal(t, "users.find: slow operation", rec["msg"])
+		assert.Equal(t, float64(1), rec["count"])
+		assert.Equal(t, float64(time.Millisecond), rec["threshold"])
+	}
+}
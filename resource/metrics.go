@@ -0,0 +1,65 @@
+package resource
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Operation names reported to the MetricsRecorder.
+const (
+	OpGet      = "get"
+	OpMultiGet = "multi_get"
+	OpFind     = "find"
+	OpReduce   = "reduce"
+	OpInsert   = "insert"
+	OpUpdate   = "update"
+	OpDelete   = "delete"
+	OpClear    = "clear"
+	OpCount    = "count"
+)
+
+// MetricsRecorder receives the metrics of resource operations.
+type MetricsRecorder interface {
+	// ObserveOperation is called after each operation on a resource with the
+	// path of the resource, the operation name (see Op* constants), its
+	// duration and the error it returned if any.
+	ObserveOperation(ctx context.Context, resource, operation string, duration time.Duration, err error)
+}
+
+// RetryRecorder is an optional interface a MetricsRecorder can implement to
+// count the retries of storage calls (see the resilience package).
+type RetryRecorder interface {
+	// ObserveRetry is called before each retry of an operation with the
+	// error of the failed attempt.
+	ObserveRetry(ctx context.Context, resource, operation string, err error)
+}
+
+// Metrics is the recorder used by rest-layer to report resource operation
+// metrics. By default it is nil and no metrics are recorded.
+var Metrics MetricsRecorder
+
+// ErrorType returns a short name classifying err for metrics labels: "" for a
+// nil error, "not_found", "conflict", "forbidden", "not_implemented",
+// "unavailable", "canceled", "timeout" or "other".
+func ErrorType(err error) string {
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, ErrNotFound):
+		return "not_found"
+	case errors.Is(err, ErrConflict):
+		return "conflict"
+	case errors.Is(err, ErrForbidden):
+		return "forbidden"
+	case errors.Is(err, ErrNotImplemented):
+		return "not_implemented"
+	case errors.As(err, new(*UnavailableError)):
+		return "unavailable"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	}
+	return "other"
+}
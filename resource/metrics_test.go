@@ -0,0 +1,38 @@
+package resource
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/rs/rest-layer/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+type testMetrics struct {
+	ops []string
+}
+
+func (m *testMetrics) ObserveOperation(ctx context.Context, resource, op string, d time.Duration, err error) {
+	m.ops = append(m.ops, fmt.Sprintf("%s.%s:%s", resource, op, ErrorType(err)))
+}
+
+func TestResourceMetrics(t *testing.T) {
+	m := &testMetrics{}
+	Metrics = m
+	defer func() { Metrics = nil }()
+	r := newResource("users", schema.Schema{Fields: schema.Fields{"id": {}}}, newTestStorer(), DefaultConf)
+	r.Get(context.Background(), 1)
+	assert.Equal(t, []string{"users.get:not_found"}, m.ops)
+}
+
+func TestErrorType(t *testing.T) {
+	assert.Equal(t, "", ErrorType(nil))
+	assert.Equal(t, "not_found", ErrorType(ErrNotFound))
+	assert.Equal(t, "conflict", ErrorType(fmt.Errorf("update: %w", ErrConflict)))
+	assert.Equal(t, "canceled", ErrorType(context.Canceled))
+	assert.Equal(t, "timeout", ErrorType(context.DeadlineExceeded))
+	assert.Equal(t, "other", ErrorType(errors.New("boom")))
+}
@@ -32,13 +32,14 @@ type OnClearMiddlewareHandler func(ctx context.Context, q *query.Query) (int, er
 type OnClearMiddleware func(next OnClearMiddlewareHandler) OnClearMiddlewareHandler
 
 type middlewareHandlers struct {
-	onGetC    []OnGetMiddleware
-	onFindC   []OnFindMiddleware
-	onReduceC []OnReduceMiddleware
-	onInsertC []OnInsertMiddleware
-	onUpdateC []OnUpdateMiddleware
-	onDeleteC []OnDeleteMiddleware
-	onClearC  []OnClearMiddleware
+	onGetC      []OnGetMiddleware
+	onMultiGetC []OnMultiGetMiddleware
+	onFindC     []OnFindMiddleware
+	onReduceC   []OnReduceMiddleware
+	onInsertC   []OnInsertMiddleware
+	onUpdateC   []OnUpdateMiddleware
+	onDeleteC   []OnDeleteMiddleware
+	onClearC    []OnClearMiddleware
 
 	onGetThen      OnGetMiddlewareHandler
 	onMultiGetThen OnMultiGetMiddlewareHandler
@@ -60,52 +61,56 @@ func (r *Resource) Chain(middlewares ...interface{}) {
 			r.middlewares.onGetC = append(r.middlewares.onGetC, m)
 			r.middlewares.onGetThen = onGetMiddlewareDefault(r)
 			for i := len(r.middlewares.onGetC) - 1; i >= 0; i-- {
-				r.middlewares.onGetThen = r.middlewares.onGetC[i](r.middlewares.onGetThen)
+				r.middlewares.onGetThen = traceOnGetMiddleware(i, r.middlewares.onGetC[i](r.middlewares.onGetThen))
 			}
 
-		// case OnMultiGetMiddleware:
-		// TODO: implement if feasible
+		case OnMultiGetMiddleware:
+			r.middlewares.onMultiGetC = append(r.middlewares.onMultiGetC, m)
+			r.middlewares.onMultiGetThen = onMultiGetMiddlewareDefault(r)
+			for i := len(r.middlewares.onMultiGetC) - 1; i >= 0; i-- {
+				r.middlewares.onMultiGetThen = traceOnMultiGetMiddleware(i, r.middlewares.onMultiGetC[i](r.middlewares.onMultiGetThen))
+			}
 
 		case OnFindMiddleware:
 			r.middlewares.onFindC = append(r.middlewares.onFindC, m)
 			r.middlewares.onFindThen = onFindMiddlewareDefault(r)
 			for i := len(r.middlewares.onFindC) - 1; i >= 0; i-- {
-				r.middlewares.onFindThen = r.middlewares.onFindC[i](r.middlewares.onFindThen)
+				r.middlewares.onFindThen = traceOnFindMiddleware(i, r.middlewares.onFindC[i](r.middlewares.onFindThen))
 			}
 
 		case OnReduceMiddleware:
 			r.middlewares.onReduceC = append(r.middlewares.onReduceC, m)
 			r.middlewares.onReduceThen = onReduceMiddlewareDefault(r)
 			for i := len(r.middlewares.onReduceC) - 1; i >= 0; i-- {
-				r.middlewares.onReduceThen = r.middlewares.onReduceC[i](r.middlewares.onReduceThen)
+				r.middlewares.onReduceThen = traceOnReduceMiddleware(i, r.middlewares.onReduceC[i](r.middlewares.onReduceThen))
 			}
 
 		case OnInsertMiddleware:
 			r.middlewares.onInsertC = append(r.middlewares.onInsertC, m)
 			r.middlewares.onInsertThen = onInsertMiddlewareDefault(r)
 			for i := len(r.middlewares.onInsertC) - 1; i >= 0; i-- {
-				r.middlewares.onInsertThen = r.middlewares.onInsertC[i](r.middlewares.onInsertThen)
+				r.middlewares.onInsertThen = traceOnInsertMiddleware(i, r.middlewares.onInsertC[i](r.middlewares.onInsertThen))
 			}
 
 		case OnUpdateMiddleware:
 			r.middlewares.onUpdateC = append(r.middlewares.onUpdateC, m)
 			r.middlewares.onUpdateThen = onUpdateMiddlewareDefault(r)
 			for i := len(r.middlewares.onUpdateC) - 1; i >= 0; i-- {
-				r.middlewares.onUpdateThen = r.middlewares.onUpdateC[i](r.middlewares.onUpdateThen)
+				r.middlewares.onUpdateThen = traceOnUpdateMiddleware(i, r.middlewares.onUpdateC[i](r.middlewares.onUpdateThen))
 			}
 
 		case OnDeleteMiddleware:
 			r.middlewares.onDeleteC = append(r.middlewares.onDeleteC, m)
 			r.middlewares.onDeleteThen = onDeleteMiddlewareDefault(r)
 			for i := len(r.middlewares.onDeleteC) - 1; i >= 0; i-- {
-				r.middlewares.onDeleteThen = r.middlewares.onDeleteC[i](r.middlewares.onDeleteThen)
+				r.middlewares.onDeleteThen = traceOnDeleteMiddleware(i, r.middlewares.onDeleteC[i](r.middlewares.onDeleteThen))
 			}
 
 		case OnClearMiddleware:
 			r.middlewares.onClearC = append(r.middlewares.onClearC, m)
 			r.middlewares.onClearThen = onClearMiddlewareDefault(r)
 			for i := len(r.middlewares.onClearC) - 1; i >= 0; i-- {
-				r.middlewares.onClearThen = r.middlewares.onClearC[i](r.middlewares.onClearThen)
+				r.middlewares.onClearThen = traceOnClearMiddleware(i, r.middlewares.onClearC[i](r.middlewares.onClearThen))
 			}
 
 		default:
@@ -128,7 +133,9 @@ func initMiddlewares(r *Resource) {
 func onGetMiddlewareDefault(r *Resource) OnGetMiddlewareHandler {
 	return func(ctx context.Context, id interface{}) (item *Item, err error) {
 		if err = r.hooks.onGet(ctx, id); err == nil {
-			item, err = r.storage.Get(ctx, id)
+			if item, err = r.storage.Get(ctx, id); err == nil {
+				item, err = r.migrate(ctx, item)
+			}
 		}
 		r.hooks.onGot(ctx, &item, &err)
 		return
@@ -147,7 +154,9 @@ func onMultiGetMiddlewareDefault(r *Resource) OnMultiGetMiddlewareHandler {
 		}
 		// Perform the storage request if none of the pre-hook returned an err.
 		if err == nil {
-			items, err = r.storage.MultiGet(ctx, ids)
+			if items, err = r.storage.MultiGet(ctx, ids); err == nil {
+				err = r.migrateItems(ctx, items)
+			}
 		}
 		var errOverwrite error
 		for i := range ids {
@@ -182,6 +191,9 @@ func onFindMiddlewareDefault(r *Resource) OnFindMiddlewareHandler {
 	return func(ctx context.Context, q *query.Query, forceTotal bool) (list *ItemList, err error) {
 		if err = r.hooks.onFind(ctx, q); err == nil {
 			list, err = r.storage.Find(ctx, q)
+			if err == nil {
+				err = r.migrateItems(ctx, list.Items)
+			}
 			if err == nil && list.Total == -1 && forceTotal {
 				// Send a query with no window so the storage won't be tempted to
 				// count within the window.
@@ -206,8 +218,11 @@ func onInsertMiddlewareDefault(r *Resource) OnInsertMiddlewareHandler {
 	return func(ctx context.Context, items []*Item) ([]*Item, error) {
 		var err error
 		if err = r.hooks.onInsert(ctx, items); err == nil {
+			r.stampVersion(items)
 			if err = recalcEtag(items); err == nil {
-				err = r.storage.Insert(ctx, items)
+				if err = r.checkUnique(ctx, items, nil); err == nil {
+					err = r.insert(ctx, items)
+				}
 			}
 		}
 		r.hooks.onInserted(ctx, items, &err)
@@ -219,8 +234,11 @@ func onUpdateMiddlewareDefault(r *Resource) OnUpdateMiddlewareHandler {
 	return func(ctx context.Context, item *Item, original *Item) (*Item, error) {
 		var err error
 		if err = r.hooks.onUpdate(ctx, item, original); err == nil {
+			r.stampVersion([]*Item{item})
 			if err = recalcEtag([]*Item{item}); err == nil {
-				err = r.storage.Update(ctx, item, original)
+				if err = r.checkUnique(ctx, []*Item{item}, original); err == nil {
+					err = r.update(ctx, item, original)
+				}
 			}
 		}
 		r.hooks.onUpdated(ctx, item, original, &err)
@@ -232,7 +250,7 @@ func onDeleteMiddlewareDefault(r *Resource) OnDeleteMiddlewareHandler {
 	return func(ctx context.Context, item *Item) (*Item, error) {
 		var err error
 		if err = r.hooks.onDelete(ctx, item); err == nil {
-			err = r.storage.Delete(ctx, item)
+			err = r.delete(ctx, item)
 		}
 		r.hooks.onDeleted(ctx, item, &err)
 		return item, err
@@ -242,7 +260,7 @@ func onDeleteMiddlewareDefault(r *Resource) OnDeleteMiddlewareHandler {
 func onClearMiddlewareDefault(r *Resource) OnClearMiddlewareHandler {
 	return func(ctx context.Context, q *query.Query) (deleted int, err error) {
 		if err = r.hooks.onClear(ctx, q); err == nil {
-			deleted, err = r.storage.Clear(ctx, q)
+			deleted, err = r.clear(ctx, q)
 		}
 		r.hooks.onCleared(ctx, q, &deleted, &err)
 		return
@@ -0,0 +1,208 @@
+package resource
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rs/rest-layer/schema/query"
+)
+
+// MigrationFunc upgrades an item payload stored with a given schema version to
+// the next version. The function may modify and return the provided payload.
+type MigrationFunc func(ctx context.Context, payload map[string]interface{}) (map[string]interface{}, error)
+
+// Migration registers the function upgrading payloads stored with the schema
+// version from to version from+1. Items stored before versioning was enabled
+// are considered to be at version 1.
+//
+//	users := index.Bind("users", userSchema, userHandler, resource.Conf{
+//		AllowedModes:  resource.ReadWrite,
+//		SchemaVersion: 2,
+//	})
+//	// Split the name field in first and last names.
+//	users.Migration(1, func(ctx context.Context, p map[string]interface{}) (map[string]interface{}, error) {
+//		...
+//	})
+//
+// This method will panic if a migration is already registered for the version.
+func (r *Resource) Migration(from int, fn MigrationFunc) {
+	if from < 1 {
+		logPanicf(context.Background(), "Invalid migration version %d for resource %s", from, r.name)
+	}
+	if _, found := r.migrations[from]; found {
+		logPanicf(context.Background(), "Migration from version %d already registered for resource %s", from, r.name)
+	}
+	r.migrations[from] = fn
+}
+
+// compileMigrations ensures a migration is registered for each version step up
+// to the current schema version.
+func (r *Resource) compileMigrations() error {
+	for from := range r.migrations {
+		if from >= r.conf.SchemaVersion {
+			return fmt.Errorf("migration from version %d exceeds schema version %d", from, r.conf.SchemaVersion)
+		}
+	}
+	for v := 1; v < r.conf.SchemaVersion; v++ {
+		if _, found := r.migrations[v]; !found {
+			return fmt.Errorf("missing migration from version %d", v)
+		}
+	}
+	return nil
+}
+
+// stampVersion sets the current schema version on items about to be stored.
+func (r *Resource) stampVersion(items []*Item) {
+	if r.conf.SchemaVersion == 0 {
+		return
+	}
+	for _, item := range items {
+		if item != nil {
+			item.SchemaVersion = r.conf.SchemaVersion
+		}
+	}
+}
+
+// needsMigration returns true if item was stored with an outdated schema
+// version.
+func (r *Resource) needsMigration(item *Item) bool {
+	return item != nil && r.conf.SchemaVersion > 1 && item.SchemaVersion < r.conf.SchemaVersion
+}
+
+// maxWriteBacks is the maximum number of migrated items stored at once in the
+// background by a resource.
+const maxWriteBacks = 4
+
+// migrate returns a copy of item upgraded to the current schema version. When
+// Conf.MigrationWriteBack is set, the upgraded item is stored in the
+// background, so the read doesn't wait for it (see writeBackAsync). The
+// upgraded item keeps the ETag of the original.
+func (r *Resource) migrate(ctx context.Context, item *Item) (*Item, error) {
+	migrated, err := r.upgrade(ctx, item)
+	if err != nil || migrated == item || !r.conf.MigrationWriteBack {
+		return migrated, err
+	}
+	r.writeBackAsync(ctx, migrated, item)
+	return migrated, nil
+}
+
+// writeBackAsync stores a copy of migrated in the background, bounded by the
+// deadline of the read if any. At most maxWriteBacks items are stored at once:
+// beyond, the item is left to be stored by a later read or MigrateAll. A
+// failure to store the item is logged.
+func (r *Resource) writeBackAsync(ctx context.Context, migrated *Item, original *Item) {
+	select {
+	case r.writeBacks <- struct{}{}:
+	default:
+		return
+	}
+	// Copy the item as the caller may modify the returned one.
+	item := migrated.Copy()
+	wctx, cancel := context.WithoutCancel(ctx), context.CancelFunc(func() {})
+	if deadline, ok := ctx.Deadline(); ok {
+		wctx, cancel = context.WithDeadline(wctx, deadline)
+	}
+	r.pendingWriteBacks.Add(1)
+	go func() {
+		defer func() {
+			cancel()
+			<-r.writeBacks
+			r.pendingWriteBacks.Done()
+		}()
+		if err := r.writeBack(wctx, item, original); err != nil {
+			logErrorf(wctx, "%s: cannot store migrated item %v: %v", r.path, original.ID, err)
+		}
+	}()
+}
+
+// upgrade returns a copy of item upgraded to the current schema version, or
+// item itself if it is up to date.
+func (r *Resource) upgrade(ctx context.Context, item *Item) (*Item, error) {
+	if !r.needsMigration(item) {
+		return item, nil
+	}
+	// Deep copy the payload so the migrations can't alter the stored or
+	// cached original.
+	payload, _ := copyValue(item.Payload).(map[string]interface{})
+	if payload == nil {
+		payload = map[string]interface{}{}
+	}
+	v := item.SchemaVersion
+	if v < 1 {
+		v = 1
+	}
+	var err error
+	for ; v < r.conf.SchemaVersion; v++ {
+		fn, found := r.migrations[v]
+		if !found {
+			return nil, fmt.Errorf("%s: no migration from schema version %d", r.path, v)
+		}
+		if payload, err = fn(ctx, payload); err != nil {
+			return nil, err
+		}
+	}
+	return &Item{
+		ID:            item.ID,
+		ETag:          item.ETag,
+		Updated:       item.Updated,
+		Payload:       payload,
+		SchemaVersion: r.conf.SchemaVersion,
+	}, nil
+}
+
+// writeBack stores the migrated version of original through Update, so the
+// hooks, unique constraints and events apply as for any other update. A
+// conflict is ignored as it means the item has been concurrently updated or
+// deleted.
+func (r *Resource) writeBack(ctx context.Context, migrated *Item, original *Item) error {
+	if err := r.Update(ctx, migrated, original); err != nil {
+		if err == ErrConflict || err == ErrNotFound {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// migrateItems upgrades items in place. Only the outdated items are replaced
+// in the slice.
+func (r *Resource) migrateItems(ctx context.Context, items []*Item) error {
+	if r.conf.SchemaVersion < 2 {
+		return nil
+	}
+	for i, item := range items {
+		migrated, err := r.migrate(ctx, item)
+		if err != nil {
+			return err
+		}
+		if migrated != item {
+			items[i] = migrated
+		}
+	}
+	return nil
+}
+
+// MigrateAll eagerly upgrades and stores all the items of the resource stored
+// with an outdated schema version, and returns the number of migrated items.
+// The storage handler must implement the Reducer interface and allow updates
+// from within the reducer function.
+func (r *Resource) MigrateAll(ctx context.Context) (migrated int, err error) {
+	if r.conf.SchemaVersion < 2 {
+		return 0, nil
+	}
+	err = r.storage.Reduce(ctx, &query.Query{}, func(item *Item) error {
+		if !r.needsMigration(item) {
+			return nil
+		}
+		m, err := r.upgrade(ctx, item)
+		if err != nil {
+			return err
+		}
+		if err := r.writeBack(ctx, m, item); err != nil {
+			return err
+		}
+		migrated++
+		return nil
+	})
+	return migrated, err
+}
@@ -0,0 +1,203 @@
+package resource
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/rs/rest-layer/schema"
+	"github.com/rs/rest-layer/schema/query"
+	"github.com/stretchr/testify/assert"
+)
+
+// newMigrationTestStorer returns a storer backed by items, updating them in
+// place on Update.
+func newMigrationTestStorer(items map[interface{}]*Item) *testStorer {
+	s := newTestStorer()
+	s.find = func(ctx context.Context, q *query.Query) (*ItemList, error) {
+		list := &ItemList{Total: -1, Items: []*Item{}}
+		for _, item := range items {
+			if q.Predicate.Match(item.Payload) {
+				i := *item
+				list.Items = append(list.Items, &i)
+			}
+		}
+		return list, nil
+	}
+	s.reduce = func(ctx context.Context, q *query.Query, reducer ReducerFunc) error {
+		list, _ := s.find(ctx, q)
+		for _, item := range list.Items {
+			if err := reducer(item); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	s.update = func(ctx context.Context, item *Item, original *Item) error {
+		if o, found := items[original.ID]; !found {
+			return ErrNotFound
+		} else if o.ETag != original.ETag {
+			return ErrConflict
+		}
+		i := *item
+		items[item.ID] = &i
+		return nil
+	}
+	return s
+}
+
+func newMigrationTestResource(s Storer, writeBack bool) (*Resource, error) {
+	i := NewIndex()
+	r := i.Bind("users", schema.Schema{Fields: schema.Fields{
+		"id":    {},
+		"first": {},
+		"last":  {},
+		"full":  {},
+	}}, s, Conf{SchemaVersion: 3, MigrationWriteBack: writeBack})
+	// v1 -> v2: split name in first and last.
+	r.Migration(1, func(ctx context.Context, p map[string]interface{}) (map[string]interface{}, error) {
+		parts := strings.SplitN(p["name"].(string), " ", 2)
+		delete(p, "name")
+		p["first"], p["last"] = parts[0], parts[1]
+		return p, nil
+	})
+	// v2 -> v3: add full name.
+	r.Migration(2, func(ctx context.Context, p map[string]interface{}) (map[string]interface{}, error) {
+		p["full"] = p["first"].(string) + " " + p["last"].(string)
+		return p, nil
+	})
+	return r, i.(Compiler).Compile()
+}
+
+func TestResourceMigrationOnRead(t *testing.T) {
+	items := map[interface{}]*Item{
+		1: {ID: 1, ETag: "a", Payload: map[string]interface{}{"id": 1, "name": "John Doe"}},
+		2: {ID: 2, ETag: "b", SchemaVersion: 2, Payload: map[string]interface{}{"id": 2, "first": "Jane", "last": "Doe"}},
+	}
+	r, err := newMigrationTestResource(newMigrationTestStorer(items), false)
+	if !assert.NoError(t, err) {
+		return
+	}
+	ctx := context.Background()
+	item, err := r.Get(ctx, 1)
+	if assert.NoError(t, err) {
+		assert.Equal(t, map[string]interface{}{"id": 1, "first": "John", "last": "Doe", "full": "John Doe"}, item.Payload)
+		assert.Equal(t, 3, item.SchemaVersion)
+		assert.Equal(t, "a", item.ETag)
+	}
+	item, err = r.Get(ctx, 2)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "Jane Doe", item.Payload["full"])
+	}
+	// Stored items are left untouched without write back.
+	assert.Equal(t, 0, items[1].SchemaVersion)
+
+	// Updates stamp the current version.
+	updated := &Item{ID: 1, Payload: item.Payload}
+	if assert.NoError(t, r.Update(ctx, updated, &Item{ID: 1, ETag: "a"})) {
+		assert.Equal(t, 3, items[1].SchemaVersion)
+	}
+}
+
+func TestResourceMigrationWriteBack(t *testing.T) {
+	items := map[interface{}]*Item{
+		1: {ID: 1, ETag: "a", Payload: map[string]interface{}{"id": 1, "name": "John Doe"}},
+	}
+	r, err := newMigrationTestResource(newMigrationTestStorer(items), true)
+	if !assert.NoError(t, err) {
+		return
+	}
+	// The item is stored in the background, even once the read is done.
+	ctx, cancel := context.WithCancel(context.Background())
+	list, err := r.Find(ctx, &query.Query{})
+	cancel()
+	r.pendingWriteBacks.Wait()
+	if assert.NoError(t, err) && assert.Len(t, list.Items, 1) {
+		assert.Equal(t, 3, items[1].SchemaVersion)
+		assert.Equal(t, "John Doe", items[1].Payload["full"])
+		assert.Equal(t, "a", list.Items[0].ETag)
+		assert.NotEqual(t, "a", items[1].ETag)
+	}
+
+	// Beyond maxWriteBacks concurrent write backs, items are not stored.
+	items[2] = &Item{ID: 2, ETag: "b", Payload: map[string]interface{}{"id": 2, "name": "Jane Doe"}}
+	for i := 0; i < maxWriteBacks; i++ {
+		r.writeBacks <- struct{}{}
+	}
+	_, err = r.Get(context.Background(), 2)
+	assert.NoError(t, err)
+	r.pendingWriteBacks.Wait()
+	assert.Equal(t, 0, items[2].SchemaVersion)
+}
+
+func TestResourceMigrateAll(t *testing.T) {
+	items := map[interface{}]*Item{
+		1: {ID: 1, ETag: "a", Payload: map[string]interface{}{"id": 1, "name": "John Doe"}},
+		2: {ID: 2, ETag: "b", SchemaVersion: 3, Payload: map[string]interface{}{"id": 2, "first": "Jane", "last": "Doe", "full": "Jane Doe"}},
+	}
+	r, err := newMigrationTestResource(newMigrationTestStorer(items), false)
+	if !assert.NoError(t, err) {
+		return
+	}
+	migrated, err := r.MigrateAll(context.Background())
+	if assert.NoError(t, err) {
+		assert.Equal(t, 1, migrated)
+		assert.Equal(t, 3, items[1].SchemaVersion)
+		assert.Equal(t, "John Doe", items[1].Payload["full"])
+	}
+}
+
+func TestResourceMigrationCompileError(t *testing.T) {
+	i := NewIndex()
+	r := i.Bind("users", schema.Schema{Fields: schema.Fields{"id": {}}}, nil, Conf{SchemaVersion: 3})
+	r.Migration(2, func(ctx context.Context, p map[string]interface{}) (map[string]interface{}, error) {
+		return p, nil
+	})
+	assert.EqualError(t, i.(Compiler).Compile(), "users: missing migration from version 1")
+}
+
+func TestResourceMigrationWriteBackHooks(t *testing.T) {
+	items := map[interface{}]*Item{
+		1: {ID: 1, ETag: "a", Payload: map[string]interface{}{"id": 1, "name": "John Doe"}},
+	}
+	r, err := newMigrationTestResource(newMigrationTestStorer(items), true)
+	if !assert.NoError(t, err) {
+		return
+	}
+	var updated []interface{}
+	r.Use(UpdatedEventHandlerFunc(func(ctx context.Context, item *Item, original *Item, err *error) {
+		if *err == nil {
+			updated = append(updated, original.ID)
+		}
+	}))
+	_, err = r.Get(context.Background(), 1)
+	assert.NoError(t, err)
+	r.pendingWriteBacks.Wait()
+	assert.Equal(t, []interface{}{1}, updated, "write back goes through Update")
+}
+
+func TestResourceMigrationCopy(t *testing.T) {
+	meta := map[string]interface{}{"source": "import"}
+	items := map[interface{}]*Item{
+		1: {ID: 1, ETag: "a", Payload: map[string]interface{}{"id": 1, "name": "John Doe", "meta": meta}},
+	}
+	i := NewIndex()
+	r := i.Bind("users", schema.Schema{Fields: schema.Fields{
+		"id":   {},
+		"meta": {},
+	}}, newMigrationTestStorer(items), Conf{SchemaVersion: 2})
+	r.Migration(1, func(ctx context.Context, p map[string]interface{}) (map[string]interface{}, error) {
+		delete(p, "name")
+		p["meta"].(map[string]interface{})["source"] = "migration"
+		return p, nil
+	})
+	if !assert.NoError(t, i.(Compiler).Compile()) {
+		return
+	}
+	item, err := r.Get(context.Background(), 1)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "migration", item.Payload["meta"].(map[string]interface{})["source"])
+	}
+	assert.Equal(t, map[string]interface{}{"source": "import"}, meta)
+	assert.Equal(t, "John Doe", items[1].Payload["name"])
+}
@@ -0,0 +1,118 @@
+package resource
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rs/rest-layer/schema/query"
+	"github.com/rs/rest-layer/trace"
+)
+
+// operation tracks a resource operation for logging, metrics and tracing.
+type operation struct {
+	r      *Resource
+	ctx    context.Context
+	name   string
+	id     interface{}
+	count  int
+	start  time.Time
+	span   trace.Span
+	cancel context.CancelFunc
+}
+
+// operationModes maps operations to the mode defining their timeout.
+var operationModes = map[string]Mode{
+	OpGet:      Read,
+	OpMultiGet: Read,
+	OpFind:     List,
+	OpReduce:   List,
+	OpCount:    List,
+	OpInsert:   Create,
+	OpUpdate:   Update,
+	OpDelete:   Delete,
+	OpClear:    Clear,
+}
+
+// startOperation starts tracking the operation op on the item id if not nil.
+// The returned context holds the operation span and the deadline of the
+// operation if the resource has a timeout for its mode. If q is not nil, its
+// predicate is added to the span.
+func (r *Resource) startOperation(ctx context.Context, op string, id interface{}, q *query.Query) (context.Context, *operation) {
+	o := &operation{r: r, name: op, id: id, count: -1, start: time.Now()}
+	if d := r.conf.ModeTimeout(operationModes[op]); d > 0 {
+		ctx, o.cancel = context.WithTimeout(ctx, d)
+	}
+	if trace.Enabled() {
+		attrs := []trace.Attribute{
+			trace.Attr(trace.AttrResource, r.path),
+			trace.Attr(trace.AttrOperation, op),
+		}
+		if id != nil {
+			attrs = append(attrs, trace.Attr(trace.AttrID, fmt.Sprint(id)))
+		}
+		if q != nil && len(q.Predicate) > 0 {
+			attrs = append(attrs, trace.Attr(trace.AttrPredicate, q.Predicate.String()))
+		}
+		ctx, o.span = trace.Start(ctx, "resource."+op, attrs...)
+	}
+	o.ctx = ctx
+	return ctx, o
+}
+
+// setItemCount sets the number of items read or written.
+func (o *operation) setItemCount(n int) {
+	o.count = n
+	if o.span != nil {
+		o.span.SetAttributes(trace.Attr(trace.AttrItemCount, n))
+	}
+}
+
+// end logs the operation, reports it to Metrics, ends its span and releases
+// its deadline. It is meant to be deferred with a pointer to the named error
+// result of the operation.
+func (o *operation) end(err *error) {
+	d := time.Since(o.start)
+	o.log(d, *err)
+	if Metrics != nil {
+		Metrics.ObserveOperation(o.ctx, o.r.path, o.name, d, *err)
+	}
+	if o.span != nil {
+		o.span.End(*err)
+	}
+	if o.cancel != nil {
+		o.cancel()
+	}
+}
+
+// log logs the operation at debug level, or at warn level if it took longer
+// than Conf.SlowOperationThreshold.
+func (o *operation) log(d time.Duration, err error) {
+	level := LogLevelDebug
+	msg := fmt.Sprintf("%s.%s", o.r.path, o.name)
+	threshold := o.r.conf.SlowOperationThreshold
+	if threshold > 0 && d >= threshold {
+		level = LogLevelWarn
+		msg += ": slow operation"
+	}
+	if level < o.r.LogLevel() || Logger == nil {
+		return
+	}
+	fields := map[string]interface{}{
+		"operation": o.name,
+		"duration":  d,
+	}
+	if o.id != nil {
+		fields["id"] = o.id
+	}
+	if o.count >= 0 {
+		fields["count"] = o.count
+	}
+	if err != nil {
+		fields["error"] = err
+	}
+	if level == LogLevelWarn {
+		fields["threshold"] = threshold
+	}
+	o.r.log(o.ctx, level, msg, fields)
+}
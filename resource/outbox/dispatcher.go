@@ -0,0 +1,196 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rs/rest-layer/resource"
+)
+
+// Dispatcher delivers the events pending in a set of outboxes to its sinks.
+type Dispatcher struct {
+	// Outboxes lists the outboxes to poll. Use resource.Resource.Outbox to
+	// get the outbox of a resource.
+	Outboxes []resource.Outbox
+	// Sinks lists the sinks each event is delivered to.
+	Sinks []Sink
+	// Interval is the delay between two polls of the outboxes when idle
+	// (default 1s).
+	Interval time.Duration
+	// BatchSize is the maximum number of events fetched from an outbox at
+	// once (default 100).
+	BatchSize int
+	// MaxAttempts is the number of delivery attempts of an event to a sink
+	// before giving up on the event. The event is then passed to OnGiveUp and
+	// removed from the outbox, so a poison event doesn't block the following
+	// ones (default 5).
+	MaxAttempts int
+	// MinBackoff is the delay before the first retry. It is doubled after
+	// each failed attempt (default 100ms).
+	MinBackoff time.Duration
+	// MaxBackoff caps the delay between two retries (default 10s).
+	MaxBackoff time.Duration
+	// OnError is called, if set, each time a delivery attempt fails.
+	OnError func(e resource.Event, attempt int, err error)
+	// OnGiveUp is called with the events given up after MaxAttempts, for
+	// instance to store them in a dead-letter queue. By default, they are
+	// logged.
+	OnGiveUp func(e resource.Event, err error)
+}
+
+// Run dispatches pending events until ctx is canceled. It returns the ctx
+// error.
+func (d *Dispatcher) Run(ctx context.Context) error {
+	interval := d.Interval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	for {
+		n, err := d.Dispatch(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if n > 0 && err == nil {
+			// Keep on draining the outboxes.
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// Dispatch delivers one batch of pending events from each outbox and returns
+// the number of delivered events. Events of an outbox are delivered in order.
+// A failure of an outbox doesn't prevent the dispatch of the others: the first
+// error met is returned once all the outboxes are processed.
+func (d *Dispatcher) Dispatch(ctx context.Context) (delivered int, err error) {
+	batchSize := d.BatchSize
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+	for _, o := range d.Outboxes {
+		n, oErr := d.dispatch(ctx, o, batchSize)
+		delivered += n
+		if oErr != nil && err == nil {
+			err = oErr
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return delivered, err
+}
+
+// dispatch delivers one batch of pending events from o. Events failing
+// delivery are given up and acknowledged with the delivered ones, unless ctx
+// is done. The error of the first failed event is returned.
+func (d *Dispatcher) dispatch(ctx context.Context, o resource.Outbox, batchSize int) (delivered int, err error) {
+	events, err := o.Pending(ctx, batchSize)
+	if err != nil {
+		return 0, err
+	}
+	acked := make([]string, 0, len(events))
+	for _, e := range events {
+		if dErr := d.deliver(ctx, e); dErr != nil {
+			if ctx.Err() != nil {
+				// Keep the event for the next dispatch.
+				if err == nil {
+					err = ctx.Err()
+				}
+				break
+			}
+			d.giveUp(ctx, e, dErr)
+			if err == nil {
+				err = dErr
+			}
+		} else {
+			delivered++
+		}
+		acked = append(acked, e.ID)
+	}
+	if len(acked) > 0 {
+		if aErr := o.Ack(ctx, acked); aErr != nil {
+			return 0, aErr
+		}
+	}
+	return delivered, err
+}
+
+// deliver publishes e to all the sinks, retrying failed attempts. The event is
+// published to all the sinks even if one fails, and the first error is
+// returned.
+func (d *Dispatcher) deliver(ctx context.Context, e resource.Event) (err error) {
+	for _, s := range d.Sinks {
+		if sErr := d.publish(ctx, s, e); sErr != nil && err == nil {
+			err = sErr
+		}
+	}
+	return err
+}
+
+// giveUp passes the undeliverable event e to OnGiveUp, or logs it.
+func (d *Dispatcher) giveUp(ctx context.Context, e resource.Event, err error) {
+	if d.OnGiveUp != nil {
+		d.OnGiveUp(e, err)
+		return
+	}
+	resource.Log(ctx, resource.LogLevelError, fmt.Sprintf("outbox: giving up %v", err), nil)
+}
+
+// publish publishes e to s with retries and exponential backoff.
+func (d *Dispatcher) publish(ctx context.Context, s Sink, e resource.Event) (err error) {
+	maxAttempts := d.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = 5
+	}
+	backoff := Backoff{Min: d.MinBackoff, Max: d.MaxBackoff}
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		if err = s.Publish(ctx, e); err == nil {
+			return nil
+		}
+		if d.OnError != nil {
+			d.OnError(e, attempt, err)
+		}
+		if attempt == maxAttempts {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff.Delay(attempt)):
+		}
+	}
+	return fmt.Errorf("event %s: delivery failed after %d attempt(s): %v", e.ID, maxAttempts, err)
+}
+
+// Backoff computes exponential retry delays.
+type Backoff struct {
+	// Min is the delay before the first retry (default 100ms).
+	Min time.Duration
+	// Max caps the delay (default 10s).
+	Max time.Duration
+}
+
+// Delay returns the delay to wait after the given failed attempt (starting at
+// 1).
+func (b Backoff) Delay(attempt int) time.Duration {
+	min, max := b.Min, b.Max
+	if min <= 0 {
+		min = 100 * time.Millisecond
+	}
+	if max <= 0 {
+		max = 10 * time.Second
+	}
+	d := min
+	for i := 1; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	return d
+}
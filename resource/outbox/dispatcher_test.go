@@ -0,0 +1,133 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/resource/testing/mem"
+	"github.com/rs/rest-layer/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDispatcherTransactionalOutbox(t *testing.T) {
+	s := mem.NewHandler()
+	index := resource.NewIndex()
+	users := index.Bind("users", schema.Schema{Fields: schema.Fields{"id": {}}}, s, resource.Conf{
+		AllowedModes:  resource.ReadWrite,
+		PublishEvents: true,
+	})
+	if !assert.NoError(t, index.(resource.Compiler).Compile()) {
+		return
+	}
+	ctx := context.Background()
+	item := &resource.Item{ID: "1", Payload: map[string]interface{}{"id": "1"}}
+	assert.NoError(t, users.Insert(ctx, []*resource.Item{item}))
+	assert.NoError(t, users.Delete(ctx, item))
+
+	sink := &MemorySink{}
+	d := &Dispatcher{Outboxes: []resource.Outbox{users.Outbox()}, Sinks: []Sink{sink}}
+	n, err := d.Dispatch(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	if events := sink.Events(); assert.Len(t, events, 2) {
+		assert.Equal(t, resource.EventInserted, events[0].Type)
+		assert.Equal(t, resource.EventDeleted, events[1].Type)
+	}
+	pending, err := s.Pending(ctx, 0)
+	assert.NoError(t, err)
+	assert.Empty(t, pending)
+}
+
+func TestDispatcherRetry(t *testing.T) {
+	s := mem.NewHandler()
+	ctx := context.Background()
+	assert.NoError(t, s.Append(ctx, []resource.Event{{ID: "a"}, {ID: "b"}}))
+
+	attempts := 0
+	sink := SinkFunc(func(ctx context.Context, e resource.Event) error {
+		if e.ID == "b" {
+			attempts++
+			if attempts < 3 {
+				return errors.New("unavailable")
+			}
+		}
+		return nil
+	})
+	failures := 0
+	d := &Dispatcher{
+		Outboxes:    []resource.Outbox{s},
+		Sinks:       []Sink{sink},
+		MaxAttempts: 3,
+		MinBackoff:  time.Millisecond,
+		OnError: func(e resource.Event, attempt int, err error) {
+			failures++
+		},
+	}
+	n, err := d.Dispatch(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, 2, failures)
+	pending, _ := s.Pending(ctx, 0)
+	assert.Empty(t, pending)
+}
+
+func TestDispatcherGiveUp(t *testing.T) {
+	ctx := context.Background()
+	s1, s2 := mem.NewHandler(), mem.NewHandler()
+	assert.NoError(t, s1.Append(ctx, []resource.Event{{ID: "a"}, {ID: "poison"}, {ID: "b"}}))
+	assert.NoError(t, s2.Append(ctx, []resource.Event{{ID: "c"}}))
+
+	sink := &MemorySink{}
+	var givenUp []string
+	d := &Dispatcher{
+		Outboxes: []resource.Outbox{s1, s2},
+		Sinks: []Sink{SinkFunc(func(ctx context.Context, e resource.Event) error {
+			if e.ID == "poison" {
+				return errors.New("rejected")
+			}
+			return nil
+		}), sink},
+		MaxAttempts: 2,
+		MinBackoff:  time.Millisecond,
+		OnGiveUp: func(e resource.Event, err error) {
+			givenUp = append(givenUp, e.ID)
+		},
+	}
+	n, err := d.Dispatch(ctx)
+	assert.EqualError(t, err, "event poison: delivery failed after 2 attempt(s): rejected")
+	assert.Equal(t, 3, n)
+	assert.Equal(t, []string{"poison"}, givenUp)
+	// The other sinks still receive the given up event.
+	var ids []string
+	for _, e := range sink.Events() {
+		ids = append(ids, e.ID)
+	}
+	assert.Equal(t, []string{"a", "poison", "b", "c"}, ids)
+	for _, s := range []*mem.MemoryHandler{s1, s2} {
+		pending, _ := s.Pending(ctx, 0)
+		assert.Empty(t, pending)
+	}
+
+	// Events are kept when the dispatch is canceled.
+	assert.NoError(t, s1.Append(ctx, []resource.Event{{ID: "poison"}}))
+	cctx, cancel := context.WithCancel(ctx)
+	d.OnError = func(e resource.Event, attempt int, err error) {
+		cancel()
+	}
+	_, err = d.Dispatch(cctx)
+	assert.Equal(t, context.Canceled, err)
+	assert.Len(t, givenUp, 1)
+	pending, _ := s1.Pending(ctx, 0)
+	assert.Len(t, pending, 1)
+}
+
+func TestBackoffDelay(t *testing.T) {
+	b := Backoff{Min: 10 * time.Millisecond, Max: 50 * time.Millisecond}
+	assert.Equal(t, 10*time.Millisecond, b.Delay(1))
+	assert.Equal(t, 20*time.Millisecond, b.Delay(2))
+	assert.Equal(t, 40*time.Millisecond, b.Delay(3))
+	assert.Equal(t, 50*time.Millisecond, b.Delay(4))
+}
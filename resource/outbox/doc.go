@@ -0,0 +1,16 @@
+/*
+Package outbox delivers the domain events recorded by resources configured with
+resource.Conf.PublishEvents to pluggable sinks.
+
+Events are recorded in a resource.Outbox, either by the storage handler itself
+when it implements resource.EventStorer, or by a local durable queue like
+FileQueue. A Dispatcher then polls the outboxes and delivers pending events to
+its sinks with retries and exponential backoff. Events still failing after
+MaxAttempts are given up, passed to OnGiveUp and removed from their outbox, so
+they don't block the following events. Delivery is at-least-once: sinks must be
+prepared to receive the same event more than once.
+
+This package is part of the rest-layer project. See http://rest-layer.io for
+full REST Layer documentation.
+*/
+package outbox
@@ -0,0 +1,204 @@
+package outbox
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/rs/rest-layer/resource"
+)
+
+// FileQueue is a durable local outbox storing events in an append-only file.
+// Each appended event and each acknowledgement is written as a JSON line and
+// synced to disk. The file is replayed when the queue is opened. It is
+// truncated once all events are acknowledged, and compacted once
+// CompactThreshold events are acknowledged.
+type FileQueue struct {
+	// CompactThreshold is the number of acknowledged events kept in the file
+	// before it is compacted (default 1000). The compaction rewrites the
+	// pending events to a temporary file renamed over the queue file.
+	CompactThreshold int
+
+	mu     sync.Mutex
+	path   string
+	f      *os.File
+	events []resource.Event
+	// acked is the number of acknowledged events still in the file.
+	acked int
+}
+
+// fileEntry is a line of the queue file.
+type fileEntry struct {
+	Event *resource.Event `json:"event,omitempty"`
+	Ack   string          `json:"ack,omitempty"`
+}
+
+// OpenFileQueue opens or creates the queue stored at path.
+func OpenFileQueue(path string) (*FileQueue, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	q := &FileQueue{CompactThreshold: 1000, path: path, f: f}
+	if err := q.replay(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return q, nil
+}
+
+// replay loads the pending events from the file.
+func (q *FileQueue) replay() error {
+	scanner := bufio.NewScanner(q.f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var entry fileEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			// Ignore a partially written last line.
+			continue
+		}
+		if entry.Event != nil {
+			q.events = append(q.events, *entry.Event)
+		} else if entry.Ack != "" {
+			q.acked += q.remove(map[string]bool{entry.Ack: true})
+		}
+	}
+	return scanner.Err()
+}
+
+// write appends entries to the file and syncs it.
+func (q *FileQueue) write(entries []fileEntry) error {
+	return writeEntries(q.f, entries)
+}
+
+// writeEntries appends entries to f and syncs it.
+func writeEntries(f *os.File, entries []fileEntry) error {
+	buf := []byte{}
+	for _, entry := range entries {
+		b, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		buf = append(append(buf, b...), '\n')
+	}
+	if _, err := f.Write(buf); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// compact replaces the file with a file holding only the pending events. The
+// new file is written aside and renamed over the queue file, so a crash during
+// the compaction leaves the previous file intact.
+func (q *FileQueue) compact() error {
+	tmp := q.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	entries := make([]fileEntry, len(q.events))
+	for i := range q.events {
+		entries[i] = fileEntry{Event: &q.events[i]}
+	}
+	if err = writeEntries(f, entries); err == nil {
+		err = os.Rename(tmp, q.path)
+	}
+	if err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	// Make the rename durable.
+	if d, err := os.Open(filepath.Dir(q.path)); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	// The descriptor of the temporary file now refers to the queue file.
+	q.f.Close()
+	q.f = f
+	q.acked = 0
+	return nil
+}
+
+// remove removes acknowledged events from memory and returns the number of
+// removed events.
+func (q *FileQueue) remove(acked map[string]bool) int {
+	events := q.events[:0]
+	for _, e := range q.events {
+		if !acked[e.ID] {
+			events = append(events, e)
+		}
+	}
+	removed := len(q.events) - len(events)
+	q.events = events
+	return removed
+}
+
+// Append implements the resource.Outbox interface.
+func (q *FileQueue) Append(ctx context.Context, events []resource.Event) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	entries := make([]fileEntry, len(events))
+	for i := range events {
+		entries[i] = fileEntry{Event: &events[i]}
+	}
+	if err := q.write(entries); err != nil {
+		return err
+	}
+	q.events = append(q.events, events...)
+	return nil
+}
+
+// Pending implements the resource.Outbox interface.
+func (q *FileQueue) Pending(ctx context.Context, limit int) ([]resource.Event, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if limit <= 0 || limit > len(q.events) {
+		limit = len(q.events)
+	}
+	events := make([]resource.Event, limit)
+	copy(events, q.events)
+	return events, nil
+}
+
+// Ack implements the resource.Outbox interface.
+func (q *FileQueue) Ack(ctx context.Context, ids []string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	acked := make(map[string]bool, len(ids))
+	entries := make([]fileEntry, len(ids))
+	for i, id := range ids {
+		acked[id] = true
+		entries[i] = fileEntry{Ack: id}
+	}
+	q.acked += q.remove(acked)
+	if len(q.events) == 0 {
+		// Nothing left to deliver, empty the file.
+		if err := q.f.Truncate(0); err != nil {
+			return err
+		}
+		q.acked = 0
+		return q.f.Sync()
+	}
+	threshold := q.CompactThreshold
+	if threshold <= 0 {
+		threshold = 1000
+	}
+	if q.acked >= threshold {
+		if err := q.compact(); err == nil {
+			return nil
+		}
+		// Keep the acknowledgements in the current file.
+	}
+	return q.write(entries)
+}
+
+// Close closes the queue file.
+func (q *FileQueue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.f.Close()
+}
@@ -0,0 +1,90 @@
+package outbox
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileQueue(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox")
+	ctx := context.Background()
+
+	q, err := OpenFileQueue(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, q.Append(ctx, []resource.Event{{ID: "a", Type: resource.EventInserted}, {ID: "b"}}))
+	assert.NoError(t, q.Append(ctx, []resource.Event{{ID: "c"}}))
+	assert.NoError(t, q.Ack(ctx, []string{"a"}))
+	assert.NoError(t, q.Close())
+
+	// Pending events survive a reopening.
+	q, err = OpenFileQueue(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+	events, err := q.Pending(ctx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []resource.Event{{ID: "b"}}, events)
+	events, err = q.Pending(ctx, 0)
+	assert.NoError(t, err)
+	assert.Len(t, events, 2)
+
+	// The file is compacted once all events are acknowledged.
+	assert.NoError(t, q.Ack(ctx, []string{"b", "c"}))
+	fi, err := os.Stat(path)
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(0), fi.Size())
+	}
+	assert.NoError(t, q.Close())
+}
+
+func TestFileQueueCompact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox")
+	ctx := context.Background()
+	lines := func() int {
+		b, err := os.ReadFile(path)
+		assert.NoError(t, err)
+		return strings.Count(string(b), "\n")
+	}
+
+	q, err := OpenFileQueue(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+	q.CompactThreshold = 3
+	assert.NoError(t, q.Append(ctx, []resource.Event{{ID: "a"}, {ID: "b"}, {ID: "c"}, {ID: "d"}, {ID: "e"}}))
+	assert.NoError(t, q.Ack(ctx, []string{"a", "b"}))
+	assert.Equal(t, 7, lines())
+	assert.NoError(t, q.Close())
+
+	// Acknowledged events replayed from the file count toward the threshold.
+	q, err = OpenFileQueue(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+	q.CompactThreshold = 3
+	assert.NoError(t, q.Ack(ctx, []string{"c"}))
+	assert.Equal(t, 2, lines())
+	_, err = os.Stat(path + ".tmp")
+	assert.True(t, os.IsNotExist(err))
+
+	// The queue keeps on appending to the compacted file.
+	assert.NoError(t, q.Append(ctx, []resource.Event{{ID: "f"}}))
+	assert.Equal(t, 3, lines())
+	assert.NoError(t, q.Close())
+	q, err = OpenFileQueue(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+	events, err := q.Pending(ctx, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []resource.Event{{ID: "d"}, {ID: "e"}, {ID: "f"}}, events)
+	assert.NoError(t, q.Close())
+}
@@ -0,0 +1,53 @@
+package outbox
+
+import (
+	"context"
+	"sync"
+
+	"github.com/rs/rest-layer/resource"
+)
+
+// Sink is the interface of event destinations, like a message bus.
+type Sink interface {
+	// Publish delivers the event. An error must be returned if the event could
+	// not be delivered so the dispatcher can retry.
+	Publish(ctx context.Context, e resource.Event) error
+}
+
+// SinkFunc is an adapter to allow the use of ordinary functions as sinks.
+type SinkFunc func(ctx context.Context, e resource.Event) error
+
+// Publish calls f(ctx, e).
+func (f SinkFunc) Publish(ctx context.Context, e resource.Event) error {
+	return f(ctx, e)
+}
+
+// MemorySink is a sink keeping published events in memory, for testing.
+type MemorySink struct {
+	mu     sync.Mutex
+	events []resource.Event
+}
+
+// Publish implements the Sink interface.
+func (s *MemorySink) Publish(ctx context.Context, e resource.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, e)
+	return nil
+}
+
+// Events returns the events published so far.
+func (s *MemorySink) Events() []resource.Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	events := make([]resource.Event, len(s.events))
+	copy(events, s.events)
+	return events
+}
+
+// Reset removes all events from the sink.
+func (s *MemorySink) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = nil
+}
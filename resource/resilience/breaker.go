@@ -0,0 +1,190 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/rs/rest-layer/resource"
+)
+
+// State is the state of a circuit breaker.
+type State int
+
+// Circuit breaker states.
+const (
+	// Closed lets all calls through.
+	Closed State = iota
+	// Open rejects all calls.
+	Open
+	// HalfOpen lets a limited number of probe calls through.
+	HalfOpen
+)
+
+// String returns the name of the state.
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// BreakerConfig configures the circuit breakers.
+type BreakerConfig struct {
+	// FailureThreshold is the number of consecutive failures opening the
+	// circuit (default 5).
+	FailureThreshold int
+	// OpenTimeout is the time the circuit stays open before letting probe
+	// calls through (default 30s).
+	OpenTimeout time.Duration
+	// HalfOpenProbes is the maximum number of concurrent probe calls when the
+	// circuit is half-open (default 1).
+	HalfOpenProbes int
+	// IsFailure tells if an error returned by the storage counts as a
+	// failure. By default, all errors are failures but the ones produced by
+	// the request itself: resource.ErrNotFound, resource.ErrConflict,
+	// resource.ErrForbidden, resource.ErrNotImplemented,
+	// *resource.UniqueError and context.Canceled.
+	IsFailure func(err error) bool
+}
+
+// IsFailure is the default BreakerConfig.IsFailure function.
+func IsFailure(err error) bool {
+	switch {
+	case err == nil,
+		errors.Is(err, resource.ErrNotFound),
+		errors.Is(err, resource.ErrConflict),
+		errors.Is(err, resource.ErrForbidden),
+		errors.Is(err, resource.ErrNotImplemented),
+		errors.As(err, new(*resource.UniqueError)),
+		errors.Is(err, context.Canceled):
+		return false
+	}
+	return true
+}
+
+// Breaker is a circuit breaker.
+type Breaker struct {
+	name     string
+	conf     BreakerConfig
+	onChange func(name string, from, to State)
+
+	mu       sync.Mutex
+	state    State
+	failures int
+	openedAt time.Time
+	probes   int
+}
+
+// NewBreaker creates a circuit breaker named name. The onChange function, if
+// not nil, is called on each state change.
+func NewBreaker(name string, conf BreakerConfig, onChange func(name string, from, to State)) *Breaker {
+	if conf.FailureThreshold <= 0 {
+		conf.FailureThreshold = 5
+	}
+	if conf.OpenTimeout <= 0 {
+		conf.OpenTimeout = 30 * time.Second
+	}
+	if conf.HalfOpenProbes <= 0 {
+		conf.HalfOpenProbes = 1
+	}
+	if conf.IsFailure == nil {
+		conf.IsFailure = IsFailure
+	}
+	return &Breaker{name: name, conf: conf, onChange: onChange}
+}
+
+// Name returns the name of the breaker.
+func (b *Breaker) Name() string {
+	return b.name
+}
+
+// State returns the current state of the breaker.
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.halfOpenIfDue()
+	return b.state
+}
+
+// Do calls fn if the circuit lets it through and records its outcome. If the
+// call is rejected, a *resource.UnavailableError is returned.
+func (b *Breaker) Do(fn func() error) error {
+	probe, err := b.allow()
+	if err != nil {
+		return err
+	}
+	err = fn()
+	b.done(probe, err)
+	return err
+}
+
+// allow tells if a call can be made and if it is a probe call.
+func (b *Breaker) allow() (probe bool, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.halfOpenIfDue()
+	switch b.state {
+	case Open:
+		return false, &resource.UnavailableError{
+			Reason:     "circuit breaker " + b.name + " is open",
+			RetryAfter: time.Until(b.openedAt.Add(b.conf.OpenTimeout)),
+		}
+	case HalfOpen:
+		if b.probes >= b.conf.HalfOpenProbes {
+			return false, &resource.UnavailableError{
+				Reason:     "circuit breaker " + b.name + " is half-open",
+				RetryAfter: time.Second,
+			}
+		}
+		b.probes++
+		return true, nil
+	}
+	return false, nil
+}
+
+// done records the outcome of a call.
+func (b *Breaker) done(probe bool, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if probe {
+		b.probes--
+	}
+	if b.conf.IsFailure(err) {
+		b.failures++
+		if b.state == HalfOpen || (b.state == Closed && b.failures >= b.conf.FailureThreshold) {
+			b.openedAt = time.Now()
+			b.setState(Open)
+		}
+		return
+	}
+	b.failures = 0
+	if b.state == HalfOpen && probe {
+		b.setState(Closed)
+	}
+}
+
+// halfOpenIfDue moves an open circuit to half-open once its timeout elapsed.
+func (b *Breaker) halfOpenIfDue() {
+	if b.state == Open && time.Since(b.openedAt) >= b.conf.OpenTimeout {
+		b.probes = 0
+		b.setState(HalfOpen)
+	}
+}
+
+func (b *Breaker) setState(s State) {
+	from := b.state
+	b.state = s
+	if s == Closed {
+		b.failures = 0
+	}
+	if b.onChange != nil {
+		b.onChange(b.name, from, s)
+	}
+}
@@ -0,0 +1,72 @@
+package resilience
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBreaker(t *testing.T) {
+	var changes []string
+	b := NewBreaker("users.find", BreakerConfig{FailureThreshold: 2, OpenTimeout: 20 * time.Millisecond}, func(name string, from, to State) {
+		changes = append(changes, name+": "+from.String()+" -> "+to.String())
+	})
+	fail := func() error { return errors.New("boom") }
+	ok := func() error { return nil }
+
+	assert.Error(t, b.Do(fail))
+	assert.NoError(t, b.Do(ok))
+	assert.Error(t, b.Do(fail))
+	assert.Equal(t, Closed, b.State())
+	// Request errors are not failures.
+	assert.Equal(t, resource.ErrNotFound, b.Do(func() error { return resource.ErrNotFound }))
+	assert.Equal(t, Closed, b.State())
+	assert.Error(t, b.Do(fail))
+	assert.Error(t, b.Do(fail))
+	assert.Equal(t, Open, b.State())
+
+	called := false
+	err := b.Do(func() error { called = true; return nil })
+	assert.False(t, called)
+	var unErr *resource.UnavailableError
+	if assert.True(t, errors.As(err, &unErr)) {
+		assert.Equal(t, "Service Unavailable: circuit breaker users.find is open", unErr.Error())
+		assert.True(t, unErr.RetryAfter > 0 && unErr.RetryAfter <= 20*time.Millisecond)
+	}
+
+	time.Sleep(25 * time.Millisecond)
+	assert.Equal(t, HalfOpen, b.State())
+	assert.Error(t, b.Do(fail))
+	assert.Equal(t, Open, b.State())
+
+	time.Sleep(25 * time.Millisecond)
+	assert.NoError(t, b.Do(ok))
+	assert.Equal(t, Closed, b.State())
+	assert.Equal(t, []string{
+		"users.find: closed -> open",
+		"users.find: open -> half-open",
+		"users.find: half-open -> open",
+		"users.find: open -> half-open",
+		"users.find: half-open -> closed",
+	}, changes)
+}
+
+func TestBreakerHalfOpenProbes(t *testing.T) {
+	b := NewBreaker("users.find", BreakerConfig{FailureThreshold: 1, OpenTimeout: time.Millisecond}, nil)
+	b.Do(func() error { return errors.New("boom") })
+	time.Sleep(2 * time.Millisecond)
+	probing := make(chan struct{})
+	release := make(chan struct{})
+	go b.Do(func() error {
+		close(probing)
+		<-release
+		return nil
+	})
+	<-probing
+	err := b.Do(func() error { return nil })
+	assert.True(t, errors.As(err, new(*resource.UnavailableError)))
+	close(release)
+}
@@ -0,0 +1,76 @@
+package resilience
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/rest-layer/resource"
+)
+
+// BulkheadConfig configures a bulkhead.
+type BulkheadConfig struct {
+	// MaxConcurrent is the maximum number of concurrent calls.
+	MaxConcurrent int
+	// MaxWait is how long a call waits for a slot before being rejected. By
+	// default, calls are rejected as soon as MaxConcurrent calls are in
+	// flight.
+	MaxWait time.Duration
+	// RetryAfter is the delay advertised to the clients of rejected calls
+	// (default 1s).
+	RetryAfter time.Duration
+}
+
+// Bulkhead limits the number of concurrent calls.
+type Bulkhead struct {
+	name  string
+	conf  BulkheadConfig
+	slots chan struct{}
+}
+
+// NewBulkhead creates a bulkhead named name. A MaxConcurrent lower than 1 is
+// treated as 1.
+func NewBulkhead(name string, conf BulkheadConfig) *Bulkhead {
+	if conf.MaxConcurrent <= 0 {
+		conf.MaxConcurrent = 1
+	}
+	if conf.RetryAfter <= 0 {
+		conf.RetryAfter = time.Second
+	}
+	return &Bulkhead{name: name, conf: conf, slots: make(chan struct{}, conf.MaxConcurrent)}
+}
+
+// InFlight returns the number of calls in flight.
+func (b *Bulkhead) InFlight() int {
+	return len(b.slots)
+}
+
+// Do calls fn once a slot is available. If no slot gets available within
+// MaxWait, a *resource.UnavailableError is returned. If ctx is done while
+// waiting, its error is returned.
+func (b *Bulkhead) Do(ctx context.Context, fn func() error) error {
+	select {
+	case b.slots <- struct{}{}:
+	default:
+		if b.conf.MaxWait <= 0 {
+			return b.full()
+		}
+		t := time.NewTimer(b.conf.MaxWait)
+		defer t.Stop()
+		select {
+		case b.slots <- struct{}{}:
+		case <-t.C:
+			return b.full()
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	defer func() { <-b.slots }()
+	return fn()
+}
+
+func (b *Bulkhead) full() error {
+	return &resource.UnavailableError{
+		Reason:     "too many concurrent calls on " + b.name,
+		RetryAfter: b.conf.RetryAfter,
+	}
+}
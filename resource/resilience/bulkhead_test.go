@@ -0,0 +1,64 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBulkhead(t *testing.T) {
+	b := NewBulkhead("users", BulkheadConfig{MaxConcurrent: 1, RetryAfter: 2 * time.Second})
+	ctx := context.Background()
+	running := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- b.Do(ctx, func() error {
+			close(running)
+			<-release
+			return nil
+		})
+	}()
+	<-running
+	assert.Equal(t, 1, b.InFlight())
+	err := b.Do(ctx, func() error { return nil })
+	var unErr *resource.UnavailableError
+	if assert.True(t, errors.As(err, &unErr)) {
+		assert.Equal(t, "Service Unavailable: too many concurrent calls on users", unErr.Error())
+		assert.Equal(t, 2*time.Second, unErr.RetryAfter)
+	}
+	close(release)
+	assert.NoError(t, <-done)
+	assert.Equal(t, 0, b.InFlight())
+	assert.NoError(t, b.Do(ctx, func() error { return nil }))
+}
+
+func TestBulkheadMaxWait(t *testing.T) {
+	b := NewBulkhead("users", BulkheadConfig{MaxConcurrent: 1, MaxWait: time.Second})
+	ctx := context.Background()
+	running := make(chan struct{})
+	go b.Do(ctx, func() error {
+		close(running)
+		time.Sleep(10 * time.Millisecond)
+		return nil
+	})
+	<-running
+	assert.NoError(t, b.Do(ctx, func() error { return nil }))
+
+	running = make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	go b.Do(ctx, func() error {
+		close(running)
+		<-release
+		return nil
+	})
+	<-running
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, b.Do(ctx, func() error { return nil }))
+}
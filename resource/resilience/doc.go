@@ -0,0 +1,33 @@
+/*
+Package resilience protects storage backends with circuit breakers, bulkheads
+and retries.
+
+Wrap a resource's Storer to guard each of its operations with a circuit
+breaker, and to limit the number of concurrent storage calls on the resource:
+
+	index.Bind("posts", post, resilience.Wrap("posts", s, resilience.Options{
+		Breaker:  &resilience.BreakerConfig{FailureThreshold: 10},
+		Bulkhead: &resilience.BulkheadConfig{MaxConcurrent: 50},
+		OnStateChange: func(name string, from, to resilience.State) {
+			breakerState.WithLabelValues(name).Set(float64(to))
+		},
+	}), resource.DefaultConf)
+
+One circuit breaker is created per operation (find, multi_get, insert, update,
+delete, clear, count and reduce), named after the wrapper's name and the
+operation (i.e.: posts.find). After FailureThreshold consecutive failures, the
+circuit opens and calls fail fast for OpenTimeout. It then half-opens, letting
+a limited number of probe calls through: the circuit closes on a successful
+probe and opens again on a failed one.
+
+When Options.Retry is set, calls failing with a transient error are retried
+with a jittered exponential backoff, within the deadline of the context. Reads
+are always retried while writes are retried only when safe (see RetryConfig).
+
+Rejected calls return a *resource.UnavailableError, sent by the rest package
+as a 503 Service Unavailable with a Retry-After header.
+
+This package is part of the rest-layer project. See http://rest-layer.io for
+full REST Layer documentation.
+*/
+package resilience
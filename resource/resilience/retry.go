@@ -0,0 +1,129 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"syscall"
+	"time"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/resource/outbox"
+)
+
+// RetryConfig configures the retries of storage calls.
+//
+// Reads (find, multi_get and count) are retried on transient errors. Writes
+// are retried only when safe: updates and deletes conditioned by the ETag of
+// the original item, and the writes the Storer reports as idempotent through
+// the IdempotentStorer interface. As a failed attempt of a conditional write
+// may still have been applied, a retry failing with resource.ErrConflict or
+// resource.ErrNotFound is checked by reading the item back: the write is
+// reported as successful if the stored item has the updated ETag, or is gone
+// for a delete.
+type RetryConfig struct {
+	// MaxAttempts is the maximum number of attempts of a call, including the
+	// first one (default 3).
+	MaxAttempts int
+	// Backoff defines the delay between two attempts (default from 50ms to
+	// 2s). Delays are jittered between half and the full computed delay.
+	Backoff outbox.Backoff
+	// IsTransient tells if an error is transient and the call worth
+	// retrying (default IsTransient).
+	IsTransient func(err error) bool
+}
+
+// IdempotentStorer is an optional interface a Storer can implement to report
+// the writes which can safely be retried.
+type IdempotentStorer interface {
+	// Idempotent returns true if applying the op write (resource.OpInsert,
+	// resource.OpUpdate, resource.OpDelete or resource.OpClear) more than
+	// once has the same effect as applying it once.
+	Idempotent(op string) bool
+}
+
+// IsTransient is the default RetryConfig.IsTransient function. It reports as
+// transient the errors with a Temporary or Timeout method returning true (like
+// net.Error), unexpected EOFs, and refused or reset connections. Context
+// errors are never transient.
+func IsTransient(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var tmp interface{ Temporary() bool }
+	if errors.As(err, &tmp) && tmp.Temporary() {
+		return true
+	}
+	var to interface{ Timeout() bool }
+	if errors.As(err, &to) && to.Timeout() {
+		return true
+	}
+	return errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET)
+}
+
+// retrier retries calls as configured by a RetryConfig.
+type retrier struct {
+	conf RetryConfig
+}
+
+func newRetrier(conf RetryConfig) *retrier {
+	if conf.MaxAttempts <= 0 {
+		conf.MaxAttempts = 3
+	}
+	if conf.Backoff.Min <= 0 {
+		conf.Backoff.Min = 50 * time.Millisecond
+	}
+	if conf.Backoff.Max <= 0 {
+		conf.Backoff.Max = 2 * time.Second
+	}
+	if conf.IsTransient == nil {
+		conf.IsTransient = IsTransient
+	}
+	return &retrier{conf: conf}
+}
+
+// do calls fn until it succeeds, fails with a non transient error or
+// MaxAttempts is reached. A retry is not attempted if the deadline of ctx
+// would be reached before the end of the backoff delay.
+func (r *retrier) do(ctx context.Context, name, op string, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= r.conf.MaxAttempts || !r.conf.IsTransient(err) {
+			return err
+		}
+		delay := r.delay(attempt)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return err
+		}
+		resource.Log(ctx, resource.LogLevelWarn, fmt.Sprintf("%s.%s: retrying after transient error", name, op), map[string]interface{}{
+			"resource":  name,
+			"operation": op,
+			"attempt":   attempt,
+			"delay":     delay,
+			"error":     err.Error(),
+		})
+		if rr, ok := resource.Metrics.(resource.RetryRecorder); ok {
+			rr.ObserveRetry(ctx, name, op, err)
+		}
+		t := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return err
+		case <-t.C:
+		}
+	}
+}
+
+// delay returns the jittered delay to wait after the given failed attempt.
+func (r *retrier) delay(attempt int) time.Duration {
+	d := r.conf.Backoff.Delay(attempt)
+	if half := int64(d / 2); half > 0 {
+		d = time.Duration(half + rand.Int63n(half+1))
+	}
+	return d
+}
@@ -0,0 +1,195 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/resource/outbox"
+	"github.com/rs/rest-layer/resource/testing/mem"
+	"github.com/rs/rest-layer/schema/query"
+	"github.com/stretchr/testify/assert"
+)
+
+// flakyStorer fails the first calls of each operation with a transient
+// error.
+type flakyStorer struct {
+	*mem.MemoryHandler
+	failures   int
+	idempotent bool
+	calls      map[string]int
+}
+
+func newFlakyStorer(failures int) *flakyStorer {
+	return &flakyStorer{MemoryHandler: mem.NewHandler(), failures: failures, calls: map[string]int{}}
+}
+
+func (s *flakyStorer) fail(op string) error {
+	s.calls[op]++
+	if s.calls[op] <= s.failures {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+
+func (s *flakyStorer) Find(ctx context.Context, q *query.Query) (*resource.ItemList, error) {
+	if err := s.fail(resource.OpFind); err != nil {
+		return nil, err
+	}
+	return s.MemoryHandler.Find(ctx, q)
+}
+
+func (s *flakyStorer) Insert(ctx context.Context, items []*resource.Item) error {
+	if err := s.fail(resource.OpInsert); err != nil {
+		return err
+	}
+	return s.MemoryHandler.Insert(ctx, items)
+}
+
+func (s *flakyStorer) Update(ctx context.Context, item *resource.Item, original *resource.Item) error {
+	if err := s.fail(resource.OpUpdate); err != nil {
+		return err
+	}
+	return s.MemoryHandler.Update(ctx, item, original)
+}
+
+// lossyStorer applies the first write of each operation but fails it with a
+// transient error, as if the response was lost.
+type lossyStorer struct {
+	*mem.MemoryHandler
+	calls map[string]int
+}
+
+func (s *lossyStorer) Update(ctx context.Context, item *resource.Item, original *resource.Item) error {
+	s.calls[resource.OpUpdate]++
+	err := s.MemoryHandler.Update(ctx, item, original)
+	if err == nil && s.calls[resource.OpUpdate] == 1 {
+		return syscall.ECONNRESET
+	}
+	return err
+}
+
+func (s *lossyStorer) Delete(ctx context.Context, item *resource.Item) error {
+	s.calls[resource.OpDelete]++
+	err := s.MemoryHandler.Delete(ctx, item)
+	if err == nil && s.calls[resource.OpDelete] == 1 {
+		return syscall.ECONNRESET
+	}
+	return err
+}
+
+func (s *flakyStorer) Idempotent(op string) bool {
+	return s.idempotent
+}
+
+type retryRecorder struct {
+	retries []string
+}
+
+func (r *retryRecorder) ObserveOperation(ctx context.Context, rsrc, op string, d time.Duration, err error) {
+}
+
+func (r *retryRecorder) ObserveRetry(ctx context.Context, rsrc, op string, err error) {
+	r.retries = append(r.retries, rsrc+"."+op+": "+err.Error())
+}
+
+func TestIsTransient(t *testing.T) {
+	assert.False(t, IsTransient(nil))
+	assert.False(t, IsTransient(errors.New("invalid query")))
+	assert.False(t, IsTransient(context.DeadlineExceeded))
+	assert.False(t, IsTransient(resource.ErrNotFound))
+	assert.True(t, IsTransient(io.ErrUnexpectedEOF))
+	assert.True(t, IsTransient(&net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}))
+	assert.True(t, IsTransient(&net.DNSError{IsTimeout: true}))
+}
+
+func TestRetryReads(t *testing.T) {
+	metrics := resource.Metrics
+	rr := &retryRecorder{}
+	resource.Metrics = rr
+	defer func() { resource.Metrics = metrics }()
+
+	retry := &RetryConfig{Backoff: outbox.Backoff{Min: time.Millisecond, Max: time.Millisecond}}
+	fs := newFlakyStorer(2)
+	s := Wrap("users", fs, Options{Retry: retry})
+	_, err := s.Find(context.Background(), &query.Query{})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, fs.calls[resource.OpFind])
+	assert.Equal(t, []string{
+		"users.find: unexpected EOF",
+		"users.find: unexpected EOF",
+	}, rr.retries)
+
+	fs = newFlakyStorer(3)
+	s = Wrap("users", fs, Options{Retry: retry})
+	_, err = s.Find(context.Background(), &query.Query{})
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+	assert.Equal(t, 3, fs.calls[resource.OpFind])
+}
+
+func TestRetryDeadline(t *testing.T) {
+	fs := newFlakyStorer(2)
+	s := Wrap("users", fs, Options{Retry: &RetryConfig{Backoff: outbox.Backoff{Min: time.Second, Max: time.Second}}})
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err := s.Find(ctx, &query.Query{})
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+	assert.Equal(t, 1, fs.calls[resource.OpFind])
+}
+
+func TestRetryWrites(t *testing.T) {
+	retry := &RetryConfig{Backoff: outbox.Backoff{Min: time.Millisecond, Max: time.Millisecond}}
+	ctx := context.Background()
+	item := &resource.Item{ID: "1", ETag: "a", Payload: map[string]interface{}{"id": "1"}}
+
+	// Inserts are not retried unless the storer is idempotent.
+	fs := newFlakyStorer(1)
+	s := Wrap("users", fs, Options{Retry: retry})
+	assert.Equal(t, io.ErrUnexpectedEOF, s.Insert(ctx, []*resource.Item{item}))
+	assert.Equal(t, 1, fs.calls[resource.OpInsert])
+
+	fs = newFlakyStorer(1)
+	fs.idempotent = true
+	s = Wrap("users", fs, Options{Retry: retry})
+	assert.NoError(t, s.Insert(ctx, []*resource.Item{item}))
+	assert.Equal(t, 2, fs.calls[resource.OpInsert])
+
+	// Updates are retried when conditioned by the original ETag.
+	fs.idempotent = false
+	updated := &resource.Item{ID: "1", ETag: "b", Payload: map[string]interface{}{"id": "1"}}
+	assert.NoError(t, s.Update(ctx, updated, item))
+	assert.Equal(t, 2, fs.calls[resource.OpUpdate])
+
+	fs.calls[resource.OpUpdate] = 0
+	assert.Equal(t, io.ErrUnexpectedEOF, s.Update(ctx, item, &resource.Item{ID: "1"}))
+	assert.Equal(t, 1, fs.calls[resource.OpUpdate])
+}
+
+func TestRetryAmbiguousWrites(t *testing.T) {
+	retry := &RetryConfig{Backoff: outbox.Backoff{Min: time.Millisecond, Max: time.Millisecond}}
+	ctx := context.Background()
+	ls := &lossyStorer{MemoryHandler: mem.NewHandler(), calls: map[string]int{}}
+	s := Wrap("users", ls, Options{Retry: retry})
+	item := &resource.Item{ID: "1", ETag: "a", Payload: map[string]interface{}{"id": "1"}}
+	assert.NoError(t, s.Insert(ctx, []*resource.Item{item}))
+
+	// The first attempt is applied but fails: the retry conflicts, and the
+	// update is reported as successful as the item is the updated one.
+	updated := &resource.Item{ID: "1", ETag: "b", Payload: map[string]interface{}{"id": "1", "v": 1}}
+	assert.NoError(t, s.Update(ctx, updated, item))
+	assert.Equal(t, 2, ls.calls[resource.OpUpdate])
+
+	// The first delete attempt is applied but fails: the retry doesn't find
+	// the item, and the delete is reported as successful.
+	assert.NoError(t, s.Delete(ctx, updated))
+	assert.Equal(t, 2, ls.calls[resource.OpDelete])
+	list, err := ls.MemoryHandler.Find(ctx, &query.Query{})
+	if assert.NoError(t, err) {
+		assert.Len(t, list.Items, 0)
+	}
+}
@@ -0,0 +1,235 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema/query"
+)
+
+// Options configures the protections of a wrapped Storer.
+type Options struct {
+	// Breaker configures the circuit breakers guarding each operation. When
+	// nil, no circuit breaker is used.
+	Breaker *BreakerConfig
+	// Bulkhead configures the bulkhead limiting the concurrent calls to the
+	// Storer. When nil, the concurrency is not limited.
+	Bulkhead *BulkheadConfig
+	// Retry configures the retries of the calls failing with a transient
+	// error. When nil, calls are not retried.
+	Retry *RetryConfig
+	// OnStateChange is called when a circuit breaker changes state.
+	OnStateChange func(name string, from, to State)
+	// OnReject is called when a call is rejected by an open circuit breaker
+	// or a full bulkhead. The name is the one of the operation's breaker.
+	OnReject func(name string, err *resource.UnavailableError)
+}
+
+// Wrap returns a Storer guarding the calls to s as configured by opts. The
+// name is used as a prefix for the breakers' names and in error and log
+// messages, usually the path of the resource.
+//
+// Each attempt of a retried call goes through the bulkhead and the circuit
+// breaker, and counts as a failure for the latter.
+//
+// The returned Storer implements resource.MultiGetter if s does, and
+// resource.Counter and resource.Reducer, returning resource.ErrNotImplemented
+// when s doesn't. Writes with events are not guarded: a wrapped Storer is not
+// used as a resource.EventStorer, use resource.Conf.Outbox to publish the
+// events of the resource.
+func Wrap(name string, s resource.Storer, opts Options) resource.Storer {
+	w := &storer{Storer: s, name: name, onReject: opts.OnReject}
+	if opts.Breaker != nil {
+		w.breakers = map[string]*Breaker{}
+		for _, op := range []string{
+			resource.OpFind, resource.OpMultiGet, resource.OpReduce, resource.OpCount,
+			resource.OpInsert, resource.OpUpdate, resource.OpDelete, resource.OpClear,
+		} {
+			w.breakers[op] = NewBreaker(name+"."+op, *opts.Breaker, opts.OnStateChange)
+		}
+	}
+	if opts.Bulkhead != nil {
+		w.bulkhead = NewBulkhead(name, *opts.Bulkhead)
+	}
+	if opts.Retry != nil {
+		w.retry = newRetrier(*opts.Retry)
+	}
+	if _, ok := s.(resource.MultiGetter); ok {
+		return multiGetStorer{w}
+	}
+	return w
+}
+
+// storer guards the calls to a Storer.
+type storer struct {
+	resource.Storer
+	name     string
+	breakers map[string]*Breaker
+	bulkhead *Bulkhead
+	retry    *retrier
+	onReject func(name string, err *resource.UnavailableError)
+}
+
+// multiGetStorer is a storer wrapping a resource.MultiGetter.
+type multiGetStorer struct {
+	*storer
+}
+
+// Unwrap implements the resource.StorerWrapper interface.
+func (s *storer) Unwrap() resource.Storer {
+	return s.Storer
+}
+
+// do calls fn through the bulkhead and the breaker of op. If retry is true,
+// fn is retried on transient errors.
+func (s *storer) do(ctx context.Context, op string, retry bool, fn func() error) error {
+	if !retry || s.retry == nil {
+		return s.call(ctx, op, fn)
+	}
+	return s.retry.do(ctx, s.name, op, func() error {
+		return s.call(ctx, op, fn)
+	})
+}
+
+// call calls fn once through the bulkhead and the breaker of op.
+func (s *storer) call(ctx context.Context, op string, fn func() error) error {
+	call := fn
+	if b := s.breakers[op]; b != nil {
+		call = func() error { return b.Do(fn) }
+	}
+	var err error
+	if s.bulkhead != nil {
+		err = s.bulkhead.Do(ctx, call)
+	} else {
+		err = call()
+	}
+	var unErr *resource.UnavailableError
+	if s.onReject != nil && errors.As(err, &unErr) {
+		s.onReject(s.name+"."+op, unErr)
+	}
+	return err
+}
+
+// idempotent tells if the op write can safely be retried according to the
+// wrapped Storer.
+func (s *storer) idempotent(op string) bool {
+	is, ok := s.Storer.(IdempotentStorer)
+	return ok && is.Idempotent(op)
+}
+
+// Find implements the resource.Storer interface.
+func (s *storer) Find(ctx context.Context, q *query.Query) (list *resource.ItemList, err error) {
+	err = s.do(ctx, resource.OpFind, true, func() (err error) {
+		list, err = s.Storer.Find(ctx, q)
+		return err
+	})
+	return list, err
+}
+
+// Insert implements the resource.Storer interface.
+func (s *storer) Insert(ctx context.Context, items []*resource.Item) error {
+	return s.do(ctx, resource.OpInsert, s.idempotent(resource.OpInsert), func() error {
+		return s.Storer.Insert(ctx, items)
+	})
+}
+
+// Update implements the resource.Storer interface.
+func (s *storer) Update(ctx context.Context, item *resource.Item, original *resource.Item) error {
+	conditional := original.ETag != "" && !s.idempotent(resource.OpUpdate)
+	attempt := 0
+	return s.do(ctx, resource.OpUpdate, original.ETag != "" || s.idempotent(resource.OpUpdate), func() error {
+		attempt++
+		err := s.Storer.Update(ctx, item, original)
+		if attempt > 1 && conditional && isAmbiguous(err) {
+			// A previous attempt may have been applied: it is the case if the
+			// stored item is the updated one.
+			if stored, rErr := s.get(ctx, original.ID); rErr == nil && stored != nil && stored.ETag == item.ETag {
+				return nil
+			}
+		}
+		return err
+	})
+}
+
+// Delete implements the resource.Storer interface.
+func (s *storer) Delete(ctx context.Context, item *resource.Item) error {
+	conditional := item.ETag != "" && !s.idempotent(resource.OpDelete)
+	attempt := 0
+	return s.do(ctx, resource.OpDelete, item.ETag != "" || s.idempotent(resource.OpDelete), func() error {
+		attempt++
+		err := s.Storer.Delete(ctx, item)
+		if attempt > 1 && conditional && isAmbiguous(err) {
+			// A previous attempt may have been applied: it is the case if the
+			// item is gone.
+			if stored, rErr := s.get(ctx, item.ID); rErr == nil && stored == nil {
+				return nil
+			}
+		}
+		return err
+	})
+}
+
+// isAmbiguous tells if err, returned by the retry of a conditional write, may
+// be caused by a previous attempt applied despite its failure.
+func isAmbiguous(err error) bool {
+	return errors.Is(err, resource.ErrConflict) || errors.Is(err, resource.ErrNotFound)
+}
+
+// get returns the stored item with id, or nil if not found.
+func (s *storer) get(ctx context.Context, id interface{}) (item *resource.Item, err error) {
+	err = s.call(ctx, resource.OpFind, func() error {
+		q := &query.Query{
+			Predicate: query.Predicate{&query.Equal{Field: "id", Value: id}},
+			Window:    &query.Window{Limit: 1},
+		}
+		list, err := s.Storer.Find(ctx, q)
+		if err == nil && len(list.Items) > 0 {
+			item = list.Items[0]
+		}
+		return err
+	})
+	return item, err
+}
+
+// Clear implements the resource.Storer interface.
+func (s *storer) Clear(ctx context.Context, q *query.Query) (deleted int, err error) {
+	err = s.do(ctx, resource.OpClear, s.idempotent(resource.OpClear), func() (err error) {
+		deleted, err = s.Storer.Clear(ctx, q)
+		return err
+	})
+	return deleted, err
+}
+
+// Count implements the resource.Counter interface.
+func (s *storer) Count(ctx context.Context, q *query.Query) (total int, err error) {
+	c, ok := s.Storer.(resource.Counter)
+	if !ok {
+		return -1, resource.ErrNotImplemented
+	}
+	err = s.do(ctx, resource.OpCount, true, func() (err error) {
+		total, err = c.Count(ctx, q)
+		return err
+	})
+	return total, err
+}
+
+// Reduce implements the resource.Reducer interface.
+func (s *storer) Reduce(ctx context.Context, q *query.Query, reducer resource.ReducerFunc) error {
+	r, ok := s.Storer.(resource.Reducer)
+	if !ok {
+		return resource.ErrNotImplemented
+	}
+	return s.do(ctx, resource.OpReduce, false, func() error {
+		return r.Reduce(ctx, q, reducer)
+	})
+}
+
+// MultiGet implements the resource.MultiGetter interface.
+func (s multiGetStorer) MultiGet(ctx context.Context, ids []interface{}) (items []*resource.Item, err error) {
+	err = s.do(ctx, resource.OpMultiGet, true, func() (err error) {
+		items, err = s.Storer.(resource.MultiGetter).MultiGet(ctx, ids)
+		return err
+	})
+	return items, err
+}
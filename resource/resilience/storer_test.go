@@ -0,0 +1,90 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/resource/testing/mem"
+	"github.com/rs/rest-layer/rest"
+	"github.com/rs/rest-layer/schema"
+	"github.com/rs/rest-layer/schema/query"
+	"github.com/stretchr/testify/assert"
+)
+
+// failingStorer fails all its Find calls.
+type failingStorer struct {
+	*mem.MemoryHandler
+	finds int
+}
+
+func (s *failingStorer) Find(ctx context.Context, q *query.Query) (*resource.ItemList, error) {
+	s.finds++
+	return nil, errors.New("connection refused")
+}
+
+// multiGetter is a storer implementing resource.MultiGetter.
+type multiGetter struct {
+	*mem.MemoryHandler
+}
+
+func (s multiGetter) MultiGet(ctx context.Context, ids []interface{}) ([]*resource.Item, error) {
+	return nil, nil
+}
+
+func TestWrap(t *testing.T) {
+	s := Wrap("users", multiGetter{mem.NewHandler()}, Options{})
+	_, ok := s.(resource.MultiGetter)
+	assert.True(t, ok)
+	assert.IsType(t, multiGetter{}, s.(resource.StorerWrapper).Unwrap())
+
+	s = Wrap("users", mem.NewHandler(), Options{})
+	_, ok = s.(resource.MultiGetter)
+	assert.False(t, ok)
+	_, err := s.(resource.Counter).Count(context.Background(), &query.Query{})
+	assert.Equal(t, resource.ErrNotImplemented, err)
+}
+
+func TestWrapBreaker(t *testing.T) {
+	fs := &failingStorer{MemoryHandler: mem.NewHandler()}
+	var changes []string
+	var rejects []string
+	s := Wrap("users", fs, Options{
+		Breaker: &BreakerConfig{FailureThreshold: 2, OpenTimeout: time.Minute},
+		OnStateChange: func(name string, from, to State) {
+			changes = append(changes, name+": "+to.String())
+		},
+		OnReject: func(name string, err *resource.UnavailableError) {
+			rejects = append(rejects, name)
+		},
+	})
+	index := resource.NewIndex()
+	index.Bind("users", schema.Schema{Fields: schema.Fields{"id": {}}}, s, resource.DefaultConf)
+	h, err := rest.NewHandler(index)
+	if !assert.NoError(t, err) {
+		return
+	}
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/users", nil))
+		assert.Equal(t, 520, w.Code)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/users", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "60", w.Header().Get("Retry-After"))
+	assert.Contains(t, w.Body.String(), "circuit breaker users.find is open")
+	assert.Equal(t, 2, fs.finds)
+	assert.Equal(t, []string{"users.find: open"}, changes)
+	assert.Equal(t, []string{"users.find"}, rejects)
+
+	// Other operations have their own breaker.
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "/users", strings.NewReader(`{"id": "1"}`)))
+	assert.Equal(t, http.StatusCreated, w.Code)
+}
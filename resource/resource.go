@@ -5,9 +5,8 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
-	"regexp"
 	"sort"
-	"time"
+	"sync"
 
 	"github.com/rs/rest-layer/schema"
 	"github.com/rs/rest-layer/schema/query"
@@ -27,6 +26,26 @@ type Resource struct {
 	hooks       eventHandler
 	middlewares middlewareHandlers
 	commands    map[string]Command
+	// asyncCommands holds the commands executed in the background.
+	asyncCommands      map[string]asyncCommand
+	readOnlyCommands   map[string]ReadOnlyCommand
+	collectionCommands map[string]CollectionCommand
+	// commandSpecs holds the specs of the item commands, and
+	// collectionCommandSpecs the ones of the collection commands.
+	commandSpecs           map[string]CommandSpec
+	collectionCommandSpecs map[string]CommandSpec
+	migrations             map[int]MigrationFunc
+	// writeBacks bounds the number of migrated items stored in the
+	// background, and pendingWriteBacks tracks them.
+	writeBacks        chan struct{}
+	pendingWriteBacks sync.WaitGroup
+	// unique holds the compiled unique constraints of the resource.
+	unique []Unique
+	// uniqueEnforced is true when unique constraints are enforced by the
+	// storage handler.
+	uniqueEnforced bool
+	// logLevel overrides LoggerLevel for the resource if not nil.
+	logLevel *LogLevel
 }
 
 type Command func(ctx context.Context, r *http.Request, item *Item, payload map[string]interface{}) (http.Header, *Item, map[string]interface{}, error)
@@ -72,6 +91,24 @@ func (v validatorFallback) GetField(name string) *schema.Field {
 	return v.fallback.GetField(name)
 }
 
+// VerifyReferences implements the schema.ReferenceVerifier interface when the
+// wrapped validator does.
+func (v validatorFallback) VerifyReferences(ctx context.Context, doc map[string]interface{}) (map[string][]interface{}, error) {
+	if rv, ok := v.Validator.(schema.ReferenceVerifier); ok {
+		return rv.VerifyReferences(ctx, doc)
+	}
+	return nil, nil
+}
+
+// PrepareMerge implements the schema.MergePreparer interface, falling back on
+// a replacing Prepare when the wrapped validator doesn't implement it.
+func (v validatorFallback) PrepareMerge(ctx context.Context, payload map[string]interface{}, original *map[string]interface{}) (map[string]interface{}, map[string]interface{}) {
+	if mp, ok := v.Validator.(schema.MergePreparer); ok {
+		return mp.PrepareMerge(ctx, payload, original)
+	}
+	return v.Validator.Prepare(ctx, payload, original, true)
+}
+
 // newResource creates a new resource with provided spec, handler and config.
 func newResource(name string, s schema.Schema, h Storer, c Conf) *Resource {
 	r := &Resource{
@@ -82,11 +119,18 @@ func newResource(name string, s schema.Schema, h Storer, c Conf) *Resource {
 			Validator: s,
 			fallback:  schema.Schema{Fields: schema.Fields{}},
 		},
-		storage:   storageWrapper{h},
-		conf:      c,
-		resources: subResources{},
-		aliases:   map[string]url.Values{},
-		commands:  map[string]Command{},
+		storage:                storageWrapper{h},
+		conf:                   c,
+		resources:              subResources{},
+		aliases:                map[string]url.Values{},
+		commands:               map[string]Command{},
+		asyncCommands:          map[string]asyncCommand{},
+		readOnlyCommands:       map[string]ReadOnlyCommand{},
+		collectionCommands:     map[string]CollectionCommand{},
+		commandSpecs:           map[string]CommandSpec{},
+		collectionCommandSpecs: map[string]CommandSpec{},
+		migrations:             map[int]MigrationFunc{},
+		writeBacks:             make(chan struct{}, maxWriteBacks),
 	}
 	initMiddlewares(r)
 	return r
@@ -117,6 +161,21 @@ func (r *Resource) Compile(rc schema.ReferenceChecker) error {
 			return fmt.Errorf(": schema compilation error: %s", err)
 		}
 	}
+	if err := r.compileUnique(); err != nil {
+		return fmt.Errorf(": %s", err)
+	}
+	if err := r.compileIndexes(); err != nil {
+		return fmt.Errorf(": %s", err)
+	}
+	if err := r.compileMigrations(); err != nil {
+		return fmt.Errorf(": %s", err)
+	}
+	if err := r.compileEvents(); err != nil {
+		return fmt.Errorf(": %s", err)
+	}
+	if err := r.compileCommands(rc); err != nil {
+		return fmt.Errorf(": %s", err)
+	}
 	for _, r := range r.resources {
 		if err := r.Compile(rc); err != nil {
 			if err.Error()[0] == ':' {
@@ -201,6 +260,9 @@ func (r *Resource) GetResources() []*Resource {
 // This method will panic an alias or a resource with the same name is already bound.
 func (r *Resource) Alias(name string, v url.Values) {
 	assertNotBound(name, r.resources, r.aliases)
+	if _, found := r.collectionCommands[name]; found {
+		logPanicf(context.Background(), "Cannot bind `%s': already bound as command'", name)
+	}
 	r.aliases[name] = v
 }
 
@@ -220,14 +282,13 @@ func (r *Resource) GetAliases() []string {
 }
 
 func (r *Resource) Command(name string, command Command) {
-	// allow only A-Z,a-z,0-9, _ and - in command name. Panic if not
-	matched, _ := regexp.MatchString(`^[A-Za-z0-9_\-]+$`, name)
-	if !matched {
-		logPanicf(context.Background(), "Invalid command name: %s for resource %s", name, r.name)
-	}
-
+	r.assertCommandName(name)
 	assertNotBound(name, r.resources, r.aliases)
+	if _, found := r.readOnlyCommands[name]; found {
+		logPanicf(context.Background(), "Cannot bind `%s': already bound as read-only command'", name)
+	}
 	r.commands[name] = command
+	delete(r.asyncCommands, name)
 }
 
 func (r *Resource) GetCommand(name string) (Command, bool) {
@@ -243,6 +304,17 @@ func (r *Resource) GetCommands() []Command {
 	return n
 }
 
+// GetCommandNames returns the sorted names of the commands set on the
+// resource.
+func (r *Resource) GetCommandNames() []string {
+	n := make([]string, 0, len(r.commands))
+	for name := range r.commands {
+		n = append(n, name)
+	}
+	sort.Strings(n)
+	return n
+}
+
 // Schema returns the resource's schema.
 func (r *Resource) Schema() schema.Schema {
 	return r.schema
@@ -268,14 +340,8 @@ func (r *Resource) Use(e interface{}) error {
 // Get get one item by its id. If item is not found, ErrNotFound error is
 // returned.
 func (r *Resource) Get(ctx context.Context, id interface{}) (item *Item, err error) {
-	if LoggerLevel <= LogLevelDebug && Logger != nil {
-		defer func(t time.Time) {
-			Logger(ctx, LogLevelDebug, fmt.Sprintf("%s.Get(%v)", r.path, id), map[string]interface{}{
-				"duration": time.Since(t),
-				"error":    err,
-			})
-		}(time.Now())
-	}
+	ctx, op := r.startOperation(ctx, OpGet, id, nil)
+	defer op.end(&err)
 	item, err = r.middlewares.onGetThen(ctx, id)
 	return
 }
@@ -283,16 +349,10 @@ func (r *Resource) Get(ctx context.Context, id interface{}) (item *Item, err err
 // MultiGet get some items by their id and return them in the same order. If one
 // or more item(s) is not found, their slot in the response is set to nil.
 func (r *Resource) MultiGet(ctx context.Context, ids []interface{}) (items []*Item, err error) {
-	if LoggerLevel <= LogLevelDebug && Logger != nil {
-		defer func(t time.Time) {
-			Logger(ctx, LogLevelDebug, fmt.Sprintf("%s.MultiGet(%v)", r.path, ids), map[string]interface{}{
-				"duration": time.Since(t),
-				"found":    len(items),
-				"error":    err,
-			})
-		}(time.Now())
-	}
+	ctx, op := r.startOperation(ctx, OpMultiGet, nil, nil)
+	defer op.end(&err)
 	items, err = r.middlewares.onMultiGetThen(ctx, ids)
+	op.setItemCount(len(items))
 	return
 }
 
@@ -311,20 +371,13 @@ func (r *Resource) FindWithTotal(ctx context.Context, q *query.Query) (list *Ite
 }
 
 func (r *Resource) find(ctx context.Context, q *query.Query, forceTotal bool) (list *ItemList, err error) {
-	if LoggerLevel <= LogLevelDebug && Logger != nil {
-		defer func(t time.Time) {
-			found := -1
-			if list != nil {
-				found = len(list.Items)
-			}
-			Logger(ctx, LogLevelDebug, fmt.Sprintf("%s.Find(...)", r.path), map[string]interface{}{
-				"duration": time.Since(t),
-				"found":    found,
-				"error":    err,
-			})
-		}(time.Now())
-	}
+	ctx, op := r.startOperation(ctx, OpFind, nil, q)
+	defer op.end(&err)
+	r.warnUnindexed(ctx, q)
 	list, err = r.middlewares.onFindThen(ctx, q, forceTotal)
+	if list != nil {
+		op.setItemCount(len(list.Items))
+	}
 	return
 }
 
@@ -333,14 +386,8 @@ type ReducerFunc = func(item *Item) error
 // Reduce calls the Reduce method on the storage handler with the corresponding without hooks.
 // Reduce does not return `Total` number of items. You need to call `Count` method to get it.
 func (r *Resource) Reduce(ctx context.Context, q *query.Query, reducer ReducerFunc) (err error) {
-	if LoggerLevel <= LogLevelDebug && Logger != nil {
-		defer func(t time.Time) {
-			Logger(ctx, LogLevelDebug, fmt.Sprintf("%s.Reduce(...)", r.path), map[string]interface{}{
-				"duration": time.Since(t),
-				"error":    err,
-			})
-		}(time.Now())
-	}
+	ctx, op := r.startOperation(ctx, OpReduce, nil, q)
+	defer op.end(&err)
 	err = r.middlewares.onReduceThen(ctx, q, reducer)
 	return
 
@@ -348,14 +395,9 @@ func (r *Resource) Reduce(ctx context.Context, q *query.Query, reducer ReducerFu
 
 // Insert implements Storer interface.
 func (r *Resource) Insert(ctx context.Context, items []*Item) (err error) {
-	if LoggerLevel <= LogLevelDebug && Logger != nil {
-		defer func(t time.Time) {
-			Logger(ctx, LogLevelDebug, fmt.Sprintf("%s.Insert(items[%d])", r.path, len(items)), map[string]interface{}{
-				"duration": time.Since(t),
-				"error":    err,
-			})
-		}(time.Now())
-	}
+	ctx, op := r.startOperation(ctx, OpInsert, nil, nil)
+	defer op.end(&err)
+	op.setItemCount(len(items))
 	var newItems []*Item
 	newItems, err = r.middlewares.onInsertThen(ctx, items)
 	if err == nil {
@@ -384,14 +426,8 @@ func recalcEtag(items []*Item) error {
 
 // Update implements Storer interface.
 func (r *Resource) Update(ctx context.Context, item *Item, original *Item) (err error) {
-	if LoggerLevel <= LogLevelDebug && Logger != nil {
-		defer func(t time.Time) {
-			Logger(ctx, LogLevelDebug, fmt.Sprintf("%s.Update(%v, %v)", r.path, item.ID, original.ID), map[string]interface{}{
-				"duration": time.Since(t),
-				"error":    err,
-			})
-		}(time.Now())
-	}
+	ctx, op := r.startOperation(ctx, OpUpdate, original.ID, nil)
+	defer op.end(&err)
 	var newItem *Item
 	newItem, err = r.middlewares.onUpdateThen(ctx, item, original)
 	if err == nil {
@@ -402,14 +438,8 @@ func (r *Resource) Update(ctx context.Context, item *Item, original *Item) (err
 
 // Delete implements Storer interface.
 func (r *Resource) Delete(ctx context.Context, item *Item) (err error) {
-	if LoggerLevel <= LogLevelDebug && Logger != nil {
-		defer func(t time.Time) {
-			Logger(ctx, LogLevelDebug, fmt.Sprintf("%s.Delete(%v)", r.path, item.ID), map[string]interface{}{
-				"duration": time.Since(t),
-				"error":    err,
-			})
-		}(time.Now())
-	}
+	ctx, op := r.startOperation(ctx, OpDelete, item.ID, nil)
+	defer op.end(&err)
 	var newItem *Item
 	newItem, err = r.middlewares.onDeleteThen(ctx, item)
 	if err == nil {
@@ -420,30 +450,18 @@ func (r *Resource) Delete(ctx context.Context, item *Item) (err error) {
 
 // Clear implements Storer interface.
 func (r *Resource) Clear(ctx context.Context, q *query.Query) (deleted int, err error) {
-	if LoggerLevel <= LogLevelDebug && Logger != nil {
-		defer func(t time.Time) {
-			Logger(ctx, LogLevelDebug, fmt.Sprintf("%s.Clear(%v)", r.path, q), map[string]interface{}{
-				"duration": time.Since(t),
-				"deleted":  deleted,
-				"error":    err,
-			})
-		}(time.Now())
-	}
+	ctx, op := r.startOperation(ctx, OpClear, nil, q)
+	defer op.end(&err)
 	deleted, err = r.middlewares.onClearThen(ctx, q)
+	op.setItemCount(deleted)
 	return
 }
 
 // Count implements Counter interface.
 func (r *Resource) Count(ctx context.Context, q *query.Query) (total int, err error) {
-	if LoggerLevel <= LogLevelDebug && Logger != nil {
-		defer func(t time.Time) {
-			Logger(ctx, LogLevelDebug, fmt.Sprintf("%s.Clear(%v)", r.path, q), map[string]interface{}{
-				"duration": time.Since(t),
-				"total":    total,
-				"error":    err,
-			})
-		}(time.Now())
-	}
+	ctx, op := r.startOperation(ctx, OpCount, nil, q)
+	defer op.end(&err)
 	total, err = r.storage.Count(ctx, q)
+	op.setItemCount(total)
 	return
 }
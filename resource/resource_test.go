@@ -1,12 +1,16 @@
 package resource
 
 import (
+	"context"
 	"io/ioutil"
 	"log"
+	"net/http"
 	"net/url"
 	"testing"
+	"time"
 
 	"github.com/rs/rest-layer/schema"
+	"github.com/rs/rest-layer/schema/query"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -77,6 +81,18 @@ func TestResourceAlias(t *testing.T) {
 	assert.Equal(t, []string{"foo"}, foo.GetAliases())
 }
 
+func TestResourceCommandNames(t *testing.T) {
+	i := NewIndex()
+	foo := i.Bind("foo", schema.Schema{}, nil, DefaultConf)
+	assert.Equal(t, []string{}, foo.GetCommandNames())
+	cmd := func(ctx context.Context, r *http.Request, item *Item, payload map[string]interface{}) (http.Header, *Item, map[string]interface{}, error) {
+		return nil, item, nil, nil
+	}
+	foo.Command("publish", cmd)
+	foo.Command("archive", cmd)
+	assert.Equal(t, []string{"archive", "publish"}, foo.GetCommandNames())
+}
+
 func TestSubResources(t *testing.T) {
 	sr := subResources{}
 	sr.add(&Resource{name: "b"})
@@ -141,3 +157,16 @@ func TestResourceUse(t *testing.T) {
 	err := r.Use("non handler")
 	assert.EqualError(t, err, "does not implement any event handler interface")
 }
+
+func TestResourceTimeout(t *testing.T) {
+	s := newTestStorer()
+	s.find = func(ctx context.Context, q *query.Query) (*ItemList, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	conf := DefaultConf
+	conf.ModeTimeouts = map[Mode]time.Duration{List: time.Millisecond}
+	r := newResource("users", schema.Schema{Fields: schema.Fields{"id": {}}}, s, conf)
+	_, err := r.Find(context.Background(), &query.Query{})
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
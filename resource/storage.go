@@ -116,6 +116,37 @@ type Reducer interface {
 	Reduce(ctx context.Context, q *query.Query, reducer ReducerFunc) error
 }
 
+// StorerWrapper is implemented by Storers wrapping another Storer, like the
+// resilience package's wrapper. REST Layer uses it to find the optional
+// interfaces of the wrapped Storer used at compile time only: Indexer and
+// UniqueEnforcer.
+type StorerWrapper interface {
+	// Unwrap returns the wrapped Storer.
+	Unwrap() Storer
+}
+
+// unwrapStorer returns s followed by the Storers it wraps.
+func unwrapStorer(s Storer) []Storer {
+	chain := []Storer{}
+	for s != nil {
+		chain = append(chain, s)
+		w, ok := s.(StorerWrapper)
+		if !ok {
+			break
+		}
+		s = w.Unwrap()
+	}
+	return chain
+}
+
+// storers returns the resource's Storer followed by the Storers it wraps.
+func (r *Resource) storers() []Storer {
+	if s, ok := r.storage.(storageWrapper); ok {
+		return unwrapStorer(s.Storer)
+	}
+	return nil
+}
+
 type storageHandler interface {
 	Storer
 	MultiGetter
@@ -145,6 +176,8 @@ func (s storageWrapper) Get(ctx context.Context, id interface{}) (item *Item, er
 // MultiGet get some items by their id and return them in the same order. If one
 // or more item(s) is not found, their slot in the response is set to nil.
 func (s storageWrapper) MultiGet(ctx context.Context, ids []interface{}) (items []*Item, err error) {
+	ctx, span := traceStorage(ctx, "MultiGet", nil)
+	defer func() { endSpan(span, err) }()
 	if s.Storer == nil {
 		return nil, ErrNoStorage
 	}
@@ -195,6 +228,8 @@ func (s storageWrapper) MultiGet(ctx context.Context, ids []interface{}) (items
 
 // Find tries to use storer MultiGet with some pattern or Find otherwise.
 func (s storageWrapper) Find(ctx context.Context, q *query.Query) (list *ItemList, err error) {
+	ctx, span := traceStorage(ctx, "Find", q)
+	defer func() { endSpan(span, err) }()
 	if s.Storer == nil {
 		return nil, ErrNoStorage
 	}
@@ -222,7 +257,9 @@ func (s storageWrapper) Find(ctx context.Context, q *query.Query) (list *ItemLis
 }
 
 // Reduce tries to use storer Reduce with some pattern.
-func (s storageWrapper) Reduce(ctx context.Context, q *query.Query, reducer ReducerFunc) error {
+func (s storageWrapper) Reduce(ctx context.Context, q *query.Query, reducer ReducerFunc) (err error) {
+	ctx, span := traceStorage(ctx, "Reduce", q)
+	defer func() { endSpan(span, err) }()
 	if s.Storer == nil {
 		return ErrNoStorage
 	}
@@ -245,6 +282,8 @@ func wrapMgetList(items []*Item, err error) (*ItemList, error) {
 }
 
 func (s storageWrapper) Insert(ctx context.Context, items []*Item) (err error) {
+	ctx, span := traceStorage(ctx, "Insert", nil)
+	defer func() { endSpan(span, err) }()
 	if s.Storer == nil {
 		return ErrNoStorage
 	}
@@ -255,6 +294,8 @@ func (s storageWrapper) Insert(ctx context.Context, items []*Item) (err error) {
 }
 
 func (s storageWrapper) Update(ctx context.Context, item *Item, original *Item) (err error) {
+	ctx, span := traceStorage(ctx, "Update", nil)
+	defer func() { endSpan(span, err) }()
 	if s.Storer == nil {
 		return ErrNoStorage
 	}
@@ -265,6 +306,8 @@ func (s storageWrapper) Update(ctx context.Context, item *Item, original *Item)
 }
 
 func (s storageWrapper) Delete(ctx context.Context, item *Item) (err error) {
+	ctx, span := traceStorage(ctx, "Delete", nil)
+	defer func() { endSpan(span, err) }()
 	if s.Storer == nil {
 		return ErrNoStorage
 	}
@@ -275,6 +318,8 @@ func (s storageWrapper) Delete(ctx context.Context, item *Item) (err error) {
 }
 
 func (s storageWrapper) Clear(ctx context.Context, q *query.Query) (deleted int, err error) {
+	ctx, span := traceStorage(ctx, "Clear", q)
+	defer func() { endSpan(span, err) }()
 	if s.Storer == nil {
 		return 0, ErrNoStorage
 	}
@@ -285,6 +330,8 @@ func (s storageWrapper) Clear(ctx context.Context, q *query.Query) (deleted int,
 }
 
 func (s storageWrapper) Count(ctx context.Context, q *query.Query) (total int, err error) {
+	ctx, span := traceStorage(ctx, "Count", q)
+	defer func() { endSpan(span, err) }()
 	if s.Storer == nil {
 		return -1, ErrNoStorage
 	}
@@ -21,8 +21,9 @@ type MemoryHandler struct {
 	// all operations.
 	Latency time.Duration
 
-	items map[interface{}][]byte
-	ids   []interface{}
+	items  map[interface{}][]byte
+	ids    []interface{}
+	events []resource.Event
 }
 
 func init() {
@@ -91,6 +92,12 @@ func (m *MemoryHandler) delete(id interface{}) {
 
 // Insert inserts new items in memory.
 func (m *MemoryHandler) Insert(ctx context.Context, items []*resource.Item) (err error) {
+	return m.InsertWithEvents(ctx, items, nil)
+}
+
+// InsertWithEvents inserts new items in memory and appends events to the
+// handler's outbox atomically.
+func (m *MemoryHandler) InsertWithEvents(ctx context.Context, items []*resource.Item, events []resource.Event) (err error) {
 	m.Lock()
 	defer m.Unlock()
 	err = handleWithLatency(m.Latency, ctx, func() error {
@@ -106,6 +113,7 @@ func (m *MemoryHandler) Insert(ctx context.Context, items []*resource.Item) (err
 			// Store ids in ordered slice for sorting
 			m.ids = append(m.ids, item.ID)
 		}
+		m.events = append(m.events, events...)
 		return nil
 	})
 	return err
@@ -113,6 +121,12 @@ func (m *MemoryHandler) Insert(ctx context.Context, items []*resource.Item) (err
 
 // Update replace an item by a new one in memory.
 func (m *MemoryHandler) Update(ctx context.Context, item *resource.Item, original *resource.Item) (err error) {
+	return m.UpdateWithEvents(ctx, item, original, nil)
+}
+
+// UpdateWithEvents replace an item by a new one in memory and appends events
+// to the handler's outbox atomically.
+func (m *MemoryHandler) UpdateWithEvents(ctx context.Context, item *resource.Item, original *resource.Item, events []resource.Event) (err error) {
 	m.Lock()
 	defer m.Unlock()
 	err = handleWithLatency(m.Latency, ctx, func() error {
@@ -126,13 +140,23 @@ func (m *MemoryHandler) Update(ctx context.Context, item *resource.Item, origina
 		if original.ETag != o.ETag {
 			return resource.ErrConflict
 		}
-		return m.store(item)
+		if err := m.store(item); err != nil {
+			return err
+		}
+		m.events = append(m.events, events...)
+		return nil
 	})
 	return err
 }
 
 // Delete deletes an item from memory.
 func (m *MemoryHandler) Delete(ctx context.Context, item *resource.Item) (err error) {
+	return m.DeleteWithEvents(ctx, item, nil)
+}
+
+// DeleteWithEvents deletes an item from memory and appends events to the
+// handler's outbox atomically.
+func (m *MemoryHandler) DeleteWithEvents(ctx context.Context, item *resource.Item, events []resource.Event) (err error) {
 	m.Lock()
 	defer m.Unlock()
 	err = handleWithLatency(m.Latency, ctx, func() error {
@@ -147,11 +171,50 @@ func (m *MemoryHandler) Delete(ctx context.Context, item *resource.Item) (err er
 			return resource.ErrConflict
 		}
 		m.delete(item.ID)
+		m.events = append(m.events, events...)
 		return nil
 	})
 	return err
 }
 
+// Append appends events to the handler's outbox.
+func (m *MemoryHandler) Append(ctx context.Context, events []resource.Event) error {
+	m.Lock()
+	defer m.Unlock()
+	m.events = append(m.events, events...)
+	return nil
+}
+
+// Pending returns at most limit events from the handler's outbox.
+func (m *MemoryHandler) Pending(ctx context.Context, limit int) ([]resource.Event, error) {
+	m.RLock()
+	defer m.RUnlock()
+	if limit <= 0 || limit > len(m.events) {
+		limit = len(m.events)
+	}
+	events := make([]resource.Event, limit)
+	copy(events, m.events)
+	return events, nil
+}
+
+// Ack removes the events with the provided ids from the handler's outbox.
+func (m *MemoryHandler) Ack(ctx context.Context, ids []string) error {
+	m.Lock()
+	defer m.Unlock()
+	acked := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		acked[id] = true
+	}
+	events := m.events[:0]
+	for _, e := range m.events {
+		if !acked[e.ID] {
+			events = append(events, e)
+		}
+	}
+	m.events = events
+	return nil
+}
+
 // Clear clears all items from the memory store matching q.
 func (m *MemoryHandler) Clear(ctx context.Context, q *query.Query) (total int, err error) {
 	m.Lock()
@@ -182,6 +245,30 @@ func (m *MemoryHandler) Find(ctx context.Context, q *query.Query) (list *resourc
 	return list, err
 }
 
+// Reduce calls reducer on each item matching q. Matching items are fetched
+// before calling reducer so the reducer may write to the handler.
+func (m *MemoryHandler) Reduce(ctx context.Context, q *query.Query, reducer resource.ReducerFunc) (err error) {
+	var list *resource.ItemList
+	m.RLock()
+	err = handleWithLatency(m.Latency, ctx, func() error {
+		list, err = m.find(ctx, q)
+		return err
+	})
+	m.RUnlock()
+	if err != nil {
+		return err
+	}
+	for _, item := range list.Items {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := reducer(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (m *MemoryHandler) find(ctx context.Context, q *query.Query) (*resource.ItemList, error) {
 	// Fetch all items matching the filter
 	list := resource.ItemList{Items: []*resource.Item{}}
@@ -0,0 +1,167 @@
+package resource
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rs/rest-layer/schema/query"
+	"github.com/rs/rest-layer/trace"
+)
+
+// traceHook starts the span of the event handler h called for the hook named
+// name.
+func traceHook(ctx context.Context, name string, h interface{}) (context.Context, trace.Span) {
+	if !trace.Enabled() {
+		return ctx, nil
+	}
+	return trace.Start(ctx, "hook."+name, trace.Attr(trace.AttrHandler, fmt.Sprintf("%T", h)))
+}
+
+// endSpanPtr ends span if not nil with the error pointed by err if any.
+func endSpanPtr(span trace.Span, err *error) {
+	if span != nil {
+		var e error
+		if err != nil {
+			e = *err
+		}
+		span.End(e)
+	}
+}
+
+// traceStorage starts the span of the storage handler method named name. If q
+// is not nil, its predicate is added to the span.
+func traceStorage(ctx context.Context, name string, q *query.Query) (context.Context, trace.Span) {
+	if !trace.Enabled() {
+		return ctx, nil
+	}
+	var attrs []trace.Attribute
+	if q != nil && len(q.Predicate) > 0 {
+		attrs = append(attrs, trace.Attr(trace.AttrPredicate, q.Predicate.String()))
+	}
+	return trace.Start(ctx, "storage."+name, attrs...)
+}
+
+// endSpan ends span if not nil.
+func endSpan(span trace.Span, err error) {
+	if span != nil {
+		span.End(err)
+	}
+}
+
+// traceMiddleware starts the span of the i-th middleware of the chain named
+// name.
+func traceMiddleware(ctx context.Context, name string, i int) (context.Context, trace.Span) {
+	return trace.Start(ctx, fmt.Sprintf("middleware.%s[%d]", name, i))
+}
+
+// traceOnGetMiddleware wraps the handler returned by the i-th OnGet middleware
+// in a span.
+func traceOnGetMiddleware(i int, next OnGetMiddlewareHandler) OnGetMiddlewareHandler {
+	return func(ctx context.Context, id interface{}) (*Item, error) {
+		if !trace.Enabled() {
+			return next(ctx, id)
+		}
+		ctx, span := traceMiddleware(ctx, "OnGet", i)
+		item, err := next(ctx, id)
+		endSpan(span, err)
+		return item, err
+	}
+}
+
+// traceOnMultiGetMiddleware wraps the handler returned by the i-th OnMultiGet
+// middleware in a span.
+func traceOnMultiGetMiddleware(i int, next OnMultiGetMiddlewareHandler) OnMultiGetMiddlewareHandler {
+	return func(ctx context.Context, ids []interface{}) ([]*Item, error) {
+		if !trace.Enabled() {
+			return next(ctx, ids)
+		}
+		ctx, span := traceMiddleware(ctx, "OnMultiGet", i)
+		items, err := next(ctx, ids)
+		endSpan(span, err)
+		return items, err
+	}
+}
+
+// traceOnFindMiddleware wraps the handler returned by the i-th OnFind middleware
+// in a span.
+func traceOnFindMiddleware(i int, next OnFindMiddlewareHandler) OnFindMiddlewareHandler {
+	return func(ctx context.Context, q *query.Query, forceTotal bool) (*ItemList, error) {
+		if !trace.Enabled() {
+			return next(ctx, q, forceTotal)
+		}
+		ctx, span := traceMiddleware(ctx, "OnFind", i)
+		list, err := next(ctx, q, forceTotal)
+		endSpan(span, err)
+		return list, err
+	}
+}
+
+// traceOnReduceMiddleware wraps the handler returned by the i-th OnReduce middleware
+// in a span.
+func traceOnReduceMiddleware(i int, next OnReduceMiddlewareHandler) OnReduceMiddlewareHandler {
+	return func(ctx context.Context, q *query.Query, reducer ReducerFunc) error {
+		if !trace.Enabled() {
+			return next(ctx, q, reducer)
+		}
+		ctx, span := traceMiddleware(ctx, "OnReduce", i)
+		err := next(ctx, q, reducer)
+		endSpan(span, err)
+		return err
+	}
+}
+
+// traceOnInsertMiddleware wraps the handler returned by the i-th OnInsert middleware
+// in a span.
+func traceOnInsertMiddleware(i int, next OnInsertMiddlewareHandler) OnInsertMiddlewareHandler {
+	return func(ctx context.Context, items []*Item) ([]*Item, error) {
+		if !trace.Enabled() {
+			return next(ctx, items)
+		}
+		ctx, span := traceMiddleware(ctx, "OnInsert", i)
+		items, err := next(ctx, items)
+		endSpan(span, err)
+		return items, err
+	}
+}
+
+// traceOnUpdateMiddleware wraps the handler returned by the i-th OnUpdate middleware
+// in a span.
+func traceOnUpdateMiddleware(i int, next OnUpdateMiddlewareHandler) OnUpdateMiddlewareHandler {
+	return func(ctx context.Context, item *Item, original *Item) (*Item, error) {
+		if !trace.Enabled() {
+			return next(ctx, item, original)
+		}
+		ctx, span := traceMiddleware(ctx, "OnUpdate", i)
+		item, err := next(ctx, item, original)
+		endSpan(span, err)
+		return item, err
+	}
+}
+
+// traceOnDeleteMiddleware wraps the handler returned by the i-th OnDelete middleware
+// in a span.
+func traceOnDeleteMiddleware(i int, next OnDeleteMiddlewareHandler) OnDeleteMiddlewareHandler {
+	return func(ctx context.Context, item *Item) (*Item, error) {
+		if !trace.Enabled() {
+			return next(ctx, item)
+		}
+		ctx, span := traceMiddleware(ctx, "OnDelete", i)
+		item, err := next(ctx, item)
+		endSpan(span, err)
+		return item, err
+	}
+}
+
+// traceOnClearMiddleware wraps the handler returned by the i-th OnClear middleware
+// in a span.
+func traceOnClearMiddleware(i int, next OnClearMiddlewareHandler) OnClearMiddlewareHandler {
+	return func(ctx context.Context, q *query.Query) (int, error) {
+		if !trace.Enabled() {
+			return next(ctx, q)
+		}
+		ctx, span := traceMiddleware(ctx, "OnClear", i)
+		deleted, err := next(ctx, q)
+		endSpan(span, err)
+		return deleted, err
+	}
+}
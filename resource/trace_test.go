@@ -0,0 +1,59 @@
+package resource
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/rs/rest-layer/schema"
+	"github.com/rs/rest-layer/schema/query"
+	"github.com/rs/rest-layer/trace"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResourceTracing(t *testing.T) {
+	rec := &trace.Recorder{}
+	trace.DefaultTracer = rec
+	defer func() { trace.DefaultTracer = nil }()
+
+	s := newTestStorer()
+	s.find = func(ctx context.Context, q *query.Query) (*ItemList, error) {
+		return &ItemList{Items: []*Item{{ID: 1}, {ID: 2}}}, nil
+	}
+	r := newResource("users", schema.Schema{Fields: schema.Fields{"id": {}, "name": {Filterable: true}}}, s, DefaultConf)
+	r.Use(FindEventHandlerFunc(func(ctx context.Context, q *query.Query) error {
+		return nil
+	}))
+	r.Chain(OnFindMiddleware(func(next OnFindMiddlewareHandler) OnFindMiddlewareHandler {
+		return next
+	}))
+	q, _ := query.New("", `{name:"foo"}`, "", nil)
+	_, err := r.Find(context.Background(), q)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{"hook.OnFind", "storage.Find", "middleware.OnFind[0]", "resource.find"}, rec.Names())
+	spans := rec.Spans()
+	if assert.Len(t, spans, 4) {
+		op := spans[3]
+		assert.Nil(t, op.Parent)
+		assert.Equal(t, map[string]interface{}{
+			trace.AttrResource:  "users",
+			trace.AttrOperation: "find",
+			trace.AttrPredicate: `{name: "foo"}`,
+			trace.AttrItemCount: 2,
+		}, op.Attributes)
+		assert.Equal(t, spans[2], spans[1].Parent)
+		assert.Equal(t, op, spans[2].Parent)
+		assert.Equal(t, `{name: "foo"}`, spans[1].Attributes[trace.AttrPredicate])
+	}
+
+	rec.Reset()
+	s.find = func(ctx context.Context, q *query.Query) (*ItemList, error) {
+		return nil, errors.New("storage error")
+	}
+	r.Get(context.Background(), 1)
+	if spans := rec.Spans(); assert.NotEmpty(t, spans) {
+		assert.Equal(t, "resource.get", spans[len(spans)-1].Name)
+		assert.EqualError(t, spans[len(spans)-1].Err, "storage error")
+	}
+}
@@ -0,0 +1,156 @@
+package resource
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/rs/rest-layer/schema"
+	"github.com/rs/rest-layer/schema/query"
+)
+
+// Unique defines a unique constraint on one or more fields of a resource.
+type Unique struct {
+	// Fields lists the fields whose combined values must be unique across the
+	// resource's items. Sub-fields may be referenced using the dot notation.
+	Fields []string
+	// Scoped restricts the constraint to items sharing the same parent when
+	// the resource is a sub-resource. The parent field is then implicitly
+	// added to Fields.
+	Scoped bool
+}
+
+// UniqueEnforcer is an optional interface a Storer can implement when the
+// storage engine is able to atomically enforce unique constraints.
+//
+// When implemented, EnforceUnique is called when the resource is compiled with
+// all the constraints declared on the resource. The storage handler is then
+// responsible for rejecting any Insert or Update violating one of them with a
+// *resource.UniqueError. When not implemented, REST Layer checks constraints
+// using a Find before each write, which is not atomic.
+type UniqueEnforcer interface {
+	EnforceUnique(constraints []Unique) error
+}
+
+// UniqueError is returned when a write would violate a unique constraint.
+type UniqueError struct {
+	// Fields lists the fields of the violated constraint.
+	Fields []string
+}
+
+// Error implements the built-in error interface.
+func (e *UniqueError) Error() string {
+	return fmt.Sprintf("Unique constraint violated on %s", strings.Join(e.Fields, ", "))
+}
+
+// Issues returns the violation as per field errors.
+func (e *UniqueError) Issues() map[string][]interface{} {
+	issues := make(map[string][]interface{}, len(e.Fields))
+	for _, f := range e.Fields {
+		issues[f] = []interface{}{"must be unique"}
+	}
+	return issues
+}
+
+// compileUnique resolves the unique constraints declared on the resource's
+// configuration and schema fields, and hands them to the storage handler when
+// it can enforce them.
+func (r *Resource) compileUnique() error {
+	constraints := []Unique{}
+	for _, name := range uniqueFields(r.schema, "") {
+		constraints = append(constraints, Unique{Fields: []string{name}})
+	}
+	for _, u := range r.conf.Unique {
+		if len(u.Fields) == 0 {
+			return fmt.Errorf("unique constraint without fields")
+		}
+		fields := make([]string, 0, len(u.Fields)+1)
+		if u.Scoped {
+			if r.parentField == "" {
+				return fmt.Errorf("scoped unique constraint on %s without parent", strings.Join(u.Fields, ", "))
+			}
+			fields = append(fields, r.parentField)
+		}
+		for _, f := range u.Fields {
+			if r.validator.GetField(f) == nil {
+				return fmt.Errorf("unique constraint on unknown field `%s'", f)
+			}
+			fields = append(fields, f)
+		}
+		constraints = append(constraints, Unique{Fields: fields})
+	}
+	if len(constraints) == 0 {
+		r.unique = nil
+		return nil
+	}
+	r.unique = constraints
+	r.uniqueEnforced = false
+	for _, s := range r.storers() {
+		if ue, ok := s.(UniqueEnforcer); ok {
+			if err := ue.EnforceUnique(constraints); err != nil {
+				return err
+			}
+			r.uniqueEnforced = true
+			break
+		}
+	}
+	return nil
+}
+
+// uniqueFields returns the dotted paths of the fields flagged as unique in s.
+func uniqueFields(s schema.Schema, prefix string) []string {
+	names := []string{}
+	for name, def := range s.Fields {
+		if def.Unique {
+			names = append(names, prefix+name)
+		}
+		if def.Schema != nil {
+			names = append(names, uniqueFields(*def.Schema, prefix+name+".")...)
+		}
+	}
+	return names
+}
+
+// checkUnique ensures none of the items violates the resource's unique
+// constraints, either against the stored items or against each other. The
+// original item, if any, is excluded from the lookup.
+func (r *Resource) checkUnique(ctx context.Context, items []*Item, original *Item) error {
+	if len(r.unique) == 0 || r.uniqueEnforced {
+		return nil
+	}
+	for _, u := range r.unique {
+		seen := make([][]interface{}, 0, len(items))
+	next:
+		for _, item := range items {
+			values := make([]interface{}, len(u.Fields))
+			for i, f := range u.Fields {
+				if values[i] = item.GetField(f); values[i] == nil {
+					// Items missing one of the fields are not constrained.
+					continue next
+				}
+			}
+			for _, s := range seen {
+				if reflect.DeepEqual(s, values) {
+					return &UniqueError{Fields: u.Fields}
+				}
+			}
+			seen = append(seen, values)
+			q := &query.Query{Window: &query.Window{Limit: 1}}
+			for i, f := range u.Fields {
+				q.Predicate = append(q.Predicate, &query.Equal{Field: f, Value: values[i]})
+			}
+			if original != nil {
+				q.Predicate = append(q.Predicate, &query.NotEqual{Field: "id", Value: original.ID})
+			}
+			list, err := r.storage.Find(ctx, q)
+			if err != nil {
+				return err
+			}
+			if len(list.Items) > 0 {
+				return &UniqueError{Fields: u.Fields}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package resource
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rs/rest-layer/schema"
+	"github.com/rs/rest-layer/schema/query"
+	"github.com/stretchr/testify/assert"
+)
+
+type testUniqueStorer struct {
+	*testStorer
+	constraints []Unique
+}
+
+func (s *testUniqueStorer) EnforceUnique(constraints []Unique) error {
+	s.constraints = constraints
+	return nil
+}
+
+func newUniqueTestStorer(stored ...map[string]interface{}) *testStorer {
+	s := newTestStorer()
+	s.find = func(ctx context.Context, q *query.Query) (*ItemList, error) {
+		list := &ItemList{Total: -1, Items: []*Item{}}
+		for _, p := range stored {
+			if q.Predicate.Match(p) {
+				list.Items = append(list.Items, &Item{ID: p["id"], Payload: p})
+			}
+		}
+		return list, nil
+	}
+	return s
+}
+
+func TestResourceUnique(t *testing.T) {
+	s := newUniqueTestStorer(
+		map[string]interface{}{"id": 1, "email": "a@example.com", "first": "John", "last": "Doe"},
+	)
+	i := NewIndex()
+	r := i.Bind("users", schema.Schema{Fields: schema.Fields{
+		"id":    {},
+		"email": {Unique: true},
+		"first": {},
+		"last":  {},
+	}}, s, Conf{Unique: []Unique{{Fields: []string{"first", "last"}}}})
+	if !assert.NoError(t, i.(Compiler).Compile()) {
+		return
+	}
+	ctx := context.Background()
+
+	err := r.Insert(ctx, []*Item{{ID: 2, Payload: map[string]interface{}{"id": 2, "email": "a@example.com"}}})
+	assert.Equal(t, &UniqueError{Fields: []string{"email"}}, err)
+
+	err = r.Insert(ctx, []*Item{{ID: 2, Payload: map[string]interface{}{"id": 2, "first": "John", "last": "Doe"}}})
+	assert.Equal(t, &UniqueError{Fields: []string{"first", "last"}}, err)
+
+	err = r.Insert(ctx, []*Item{
+		{ID: 2, Payload: map[string]interface{}{"id": 2, "email": "b@example.com"}},
+		{ID: 3, Payload: map[string]interface{}{"id": 3, "email": "b@example.com"}},
+	})
+	assert.Equal(t, &UniqueError{Fields: []string{"email"}}, err)
+
+	err = r.Insert(ctx, []*Item{{ID: 2, Payload: map[string]interface{}{"id": 2, "email": "b@example.com", "first": "John"}}})
+	assert.NoError(t, err)
+
+	original := &Item{ID: 1, Payload: map[string]interface{}{"id": 1, "email": "a@example.com", "first": "John", "last": "Doe"}}
+	err = r.Update(ctx, &Item{ID: 1, Payload: map[string]interface{}{"id": 1, "email": "a@example.com", "first": "John", "last": "Smith"}}, original)
+	assert.NoError(t, err)
+}
+
+func TestResourceUniqueScoped(t *testing.T) {
+	s := newUniqueTestStorer(
+		map[string]interface{}{"id": 1, "user": "a", "slug": "hello"},
+	)
+	i := NewIndex()
+	users := i.Bind("users", schema.Schema{Fields: schema.Fields{"id": {}}}, nil, DefaultConf)
+	posts := users.Bind("posts", "user", schema.Schema{Fields: schema.Fields{
+		"id":   {},
+		"user": {},
+		"slug": {},
+	}}, s, Conf{Unique: []Unique{{Fields: []string{"slug"}, Scoped: true}}})
+	if !assert.NoError(t, i.(Compiler).Compile()) {
+		return
+	}
+	ctx := context.Background()
+
+	err := posts.Insert(ctx, []*Item{{ID: 2, Payload: map[string]interface{}{"id": 2, "user": "a", "slug": "hello"}}})
+	assert.Equal(t, &UniqueError{Fields: []string{"user", "slug"}}, err)
+
+	err = posts.Insert(ctx, []*Item{{ID: 2, Payload: map[string]interface{}{"id": 2, "user": "b", "slug": "hello"}}})
+	assert.NoError(t, err)
+}
+
+func TestResourceUniqueEnforcer(t *testing.T) {
+	s := &testUniqueStorer{testStorer: newTestStorer()}
+	s.find = func(ctx context.Context, q *query.Query) (*ItemList, error) {
+		t.Error("unexpected Find pre-check")
+		return &ItemList{}, nil
+	}
+	i := NewIndex()
+	r := i.Bind("users", schema.Schema{Fields: schema.Fields{
+		"id":    {},
+		"email": {Unique: true},
+	}}, s, DefaultConf)
+	if !assert.NoError(t, i.(Compiler).Compile()) {
+		return
+	}
+	assert.Equal(t, []Unique{{Fields: []string{"email"}}}, s.constraints)
+	err := r.Insert(context.Background(), []*Item{{ID: 1, Payload: map[string]interface{}{"id": 1, "email": "a@example.com"}}})
+	assert.NoError(t, err)
+}
+
+func TestResourceUniqueCompileError(t *testing.T) {
+	i := NewIndex()
+	i.Bind("users", schema.Schema{Fields: schema.Fields{"id": {}}}, nil, Conf{
+		Unique: []Unique{{Fields: []string{"unknown"}}},
+	})
+	assert.EqualError(t, i.(Compiler).Compile(), "users: unique constraint on unknown field `unknown'")
+
+	i = NewIndex()
+	i.Bind("users", schema.Schema{Fields: schema.Fields{"id": {}}}, nil, Conf{
+		Unique: []Unique{{Fields: []string{"id"}, Scoped: true}},
+	})
+	assert.EqualError(t, i.(Compiler).Compile(), "users: scoped unique constraint on id without parent")
+}
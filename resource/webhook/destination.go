@@ -0,0 +1,65 @@
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// ErrDestinationNotAllowed is returned when a delivery is sent to an address
+// rejected by Notifier.AllowAddress. Such deliveries are not retried.
+var ErrDestinationNotAllowed = errors.New("destination not allowed")
+
+// PublicAddress returns true if ip is a public address, i.e.: not a loopback,
+// link-local, private, unspecified or multicast address. It is the default
+// Notifier.AllowAddress, preventing subscribers from making the server POST to
+// its internal network.
+func PublicAddress(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() && !ip.IsMulticast() && !ip.IsPrivate() && !ip.IsUnspecified()
+}
+
+// Control checks that the address dialed for a delivery is allowed by
+// AllowAddress. As it is called once the host name is resolved, the check
+// can't be bypassed by DNS rebinding. It is the net.Dialer.Control of the
+// default Client, and must be set on the dialer of a custom Client to keep the
+// check.
+func (n *Notifier) Control(network, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	allow := n.AllowAddress
+	if allow == nil {
+		allow = PublicAddress
+	}
+	if ip := net.ParseIP(host); ip == nil || !allow(ip) {
+		return fmt.Errorf("%w: %s", ErrDestinationNotAllowed, host)
+	}
+	return nil
+}
+
+// newClient returns the default HTTP client of n, dialing only the addresses
+// allowed by n.Control. Proxies are not used so the check applies to the
+// subscribers' addresses.
+func newClient(n *Notifier) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   10 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   n.Control,
+	}
+	return &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			DialContext:           dialer.DialContext,
+			ForceAttemptHTTP2:     true,
+			MaxIdleConns:          100,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: time.Second,
+		},
+	}
+}
@@ -0,0 +1,41 @@
+/*
+Package webhook delivers resource changes to HTTP subscribers.
+
+Subscriptions are items of a regular resource bound with SubscriptionSchema. A
+subscription names a target resource path, the event types it wants (see
+resource.EventType), an optional filter predicate and projection, the URL to
+POST to and a secret used to sign deliveries.
+
+The Notifier implements the outbox.Sink interface: plug it into an
+outbox.Dispatcher polling the outboxes of the resources configured with
+resource.Conf.PublishEvents. Deliveries are queued per subscription, sent
+with bounded concurrency and retried with exponential backoff. When a resource bound with DeliverySchema is
+provided, each delivery attempt is logged in it. When a resource bound with
+QueueSchema is provided, the pending deliveries are persisted in it and
+restored by Run, so they survive a restart; otherwise they are only kept in
+memory.
+
+	subscriptions := index.Bind("webhooks", webhook.SubscriptionSchema, subsHandler, resource.DefaultConf)
+	deliveries := index.Bind("webhook_deliveries", webhook.DeliverySchema, deliveriesHandler, resource.Conf{
+		AllowedModes: resource.ReadOnly,
+	})
+	n := webhook.NewNotifier(index, subscriptions)
+	n.Deliveries = deliveries
+	n.Queue = index.Bind("webhook_queue", webhook.QueueSchema, queueHandler, resource.Conf{
+		AllowedModes: resource.ReadOnly,
+	})
+	go n.Run(ctx)
+	d := &outbox.Dispatcher{Outboxes: []resource.Outbox{users.Outbox()}, Sinks: []outbox.Sink{n}}
+	go d.Run(ctx)
+
+By default, deliveries are only sent to public addresses: the addresses of the
+subscription URLs are checked once resolved, when dialing, so subscribers
+can't make the server POST to its loopback, link-local or private network.
+Set Notifier.AllowAddress to change the allowed destinations.
+
+Receivers can check the authenticity of a delivery using Verify.
+
+This package is part of the rest-layer project. See http://rest-layer.io for
+full REST Layer documentation.
+*/
+package webhook
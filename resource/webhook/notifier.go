@@ -0,0 +1,555 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/resource/outbox"
+	"github.com/rs/rest-layer/schema"
+	"github.com/rs/rest-layer/schema/query"
+)
+
+// Message is the JSON body POSTed to subscribers.
+type Message struct {
+	// ID is the id of the event, also sent in the X-Webhook-Delivery header.
+	ID string `json:"id"`
+	// Type is the type of the event.
+	Type resource.EventType `json:"type"`
+	// Resource is the path of the resource the event occurred on.
+	Resource string `json:"resource"`
+	// ItemID is the id of the item the event occurred on.
+	ItemID interface{} `json:"item_id"`
+	// Command is the name of the executed command for command events.
+	Command string `json:"command,omitempty"`
+	// Time is the time at which the event was recorded.
+	Time time.Time `json:"time"`
+	// Item is the item, projected with the subscription's fields if any.
+	// Hidden fields are never included.
+	Item map[string]interface{} `json:"item,omitempty"`
+}
+
+// delivery is a queued message for a subscriber.
+type delivery struct {
+	subscription interface{}
+	url          string
+	secret       string
+	event        resource.Event
+	body         []byte
+	attempt      int
+	due          time.Time
+	// item is the row of the delivery in the Queue resource, if any.
+	item *resource.Item
+}
+
+// Notifier delivers events to the matching webhook subscriptions.
+type Notifier struct {
+	// Deliveries is an optional resource bound with DeliverySchema in which
+	// each delivery attempt is logged.
+	Deliveries *resource.Resource
+	// Queue is an optional resource bound with QueueSchema in which the
+	// pending deliveries are persisted. Without it, the pending deliveries
+	// and their retries are only kept in memory, and lost on restart.
+	Queue *resource.Resource
+	// Client is the HTTP client used for deliveries (default: a client with a
+	// 10 seconds timeout, dialing only the addresses allowed by
+	// AllowAddress). A custom client must use Control as the Control function
+	// of its dialer to keep the check of the destinations.
+	Client *http.Client
+	// AllowAddress returns true if deliveries can be sent to ip, the resolved
+	// address of a subscription URL (default: PublicAddress).
+	AllowAddress func(ip net.IP) bool
+	// MaxAttempts is the maximum number of delivery attempts of an event to a
+	// subscriber (default 8).
+	MaxAttempts int
+	// Backoff defines the delay between two attempts (default from 1s to 1h).
+	Backoff outbox.Backoff
+	// Concurrency is the maximum number of deliveries sent at once, so a slow
+	// subscriber doesn't delay the deliveries to the others (default 8).
+	Concurrency int
+
+	index         resource.Index
+	subscriptions *resource.Resource
+	mu            sync.Mutex
+	queue         []*delivery
+	wake          chan struct{}
+}
+
+// NewNotifier creates a notifier delivering events to the subscriptions stored
+// in the subscriptions resource. The index is used to lookup the resources
+// targeted by the subscriptions.
+func NewNotifier(index resource.Index, subscriptions *resource.Resource) *Notifier {
+	n := &Notifier{
+		MaxAttempts:   8,
+		Backoff:       outbox.Backoff{Min: time.Second, Max: time.Hour},
+		Concurrency:   8,
+		index:         index,
+		subscriptions: subscriptions,
+		wake:          make(chan struct{}, 1),
+	}
+	n.Client = newClient(n)
+	return n
+}
+
+// Publish implements the outbox.Sink interface. It queues a delivery for each
+// active subscription matching the event. An error is returned if the
+// subscriptions can't be fetched or the deliveries can't be stored in Queue,
+// so the event is published again later.
+func (n *Notifier) Publish(ctx context.Context, e resource.Event) error {
+	rsrc, found := n.index.GetResource(e.Resource, nil)
+	if !found {
+		return nil
+	}
+	q := &query.Query{Predicate: query.Predicate{
+		&query.Equal{Field: "resource", Value: e.Resource},
+		&query.Equal{Field: "active", Value: true},
+	}}
+	list, err := n.subscriptions.Find(ctx, q)
+	if err != nil {
+		return err
+	}
+	var ds []*delivery
+	for _, sub := range list.Items {
+		d, err := n.newDelivery(ctx, rsrc, sub, e)
+		if err != nil {
+			logErrorf(ctx, "webhook %v: %v", sub.ID, err)
+			continue
+		}
+		if d != nil {
+			ds = append(ds, d)
+		}
+	}
+	if len(ds) == 0 {
+		return nil
+	}
+	if n.Queue != nil {
+		// Store all the deliveries of the event at once, so the event is
+		// either fully queued or published again.
+		items := make([]*resource.Item, 0, len(ds))
+		for _, d := range ds {
+			item, err := resource.NewItem(d.row(ctx))
+			if err != nil {
+				return err
+			}
+			d.item = item
+			items = append(items, item)
+		}
+		if err := n.Queue.Insert(ctx, items); err != nil {
+			return err
+		}
+	}
+	for _, d := range ds {
+		n.enqueue(d)
+	}
+	n.notify()
+	return nil
+}
+
+// Restore queues the deliveries persisted in Queue which are not queued yet.
+// It is called by Run on start.
+func (n *Notifier) Restore(ctx context.Context) error {
+	if n.Queue == nil {
+		return nil
+	}
+	list, err := n.Queue.Find(ctx, &query.Query{Sort: query.Sort{{Name: "due"}}})
+	if err != nil {
+		return err
+	}
+	n.mu.Lock()
+	queued := map[interface{}]bool{}
+	for _, d := range n.queue {
+		if d.item != nil {
+			queued[d.item.ID] = true
+		}
+	}
+	n.mu.Unlock()
+	restored := 0
+	for _, item := range list.Items {
+		if queued[item.ID] {
+			continue
+		}
+		n.enqueue(deliveryFromRow(item))
+		restored++
+	}
+	if restored > 0 {
+		n.notify()
+	}
+	return nil
+}
+
+// row returns the payload of the Queue row of d.
+func (d *delivery) row(ctx context.Context) map[string]interface{} {
+	var id, created interface{} = schema.NewID(ctx, nil), time.Now()
+	if d.item != nil {
+		id, created = d.item.ID, d.item.Payload["created"]
+	}
+	return map[string]interface{}{
+		"id":           id,
+		"created":      created,
+		"subscription": fmt.Sprint(d.subscription),
+		"event":        d.event.ID,
+		"type":         string(d.event.Type),
+		"url":          d.url,
+		"secret":       d.secret,
+		"body":         string(d.body),
+		"attempt":      d.attempt,
+		"due":          d.due,
+	}
+}
+
+// deliveryFromRow returns the delivery stored in a Queue row.
+func deliveryFromRow(item *resource.Item) *delivery {
+	p := item.Payload
+	d := &delivery{item: item, due: time.Now()}
+	d.subscription, _ = p["subscription"].(string)
+	d.url, _ = p["url"].(string)
+	d.secret, _ = p["secret"].(string)
+	d.event.ID, _ = p["event"].(string)
+	t, _ := p["type"].(string)
+	d.event.Type = resource.EventType(t)
+	body, _ := p["body"].(string)
+	d.body = []byte(body)
+	switch a := p["attempt"].(type) {
+	case int:
+		d.attempt = a
+	case float64:
+		d.attempt = int(a)
+	}
+	switch due := p["due"].(type) {
+	case time.Time:
+		d.due = due
+	case string:
+		if t, err := time.Parse(time.RFC3339Nano, due); err == nil {
+			d.due = t
+		}
+	}
+	return d
+}
+
+// persist updates or removes the Queue row of d, depending on whether d is
+// still pending.
+func (n *Notifier) persist(ctx context.Context, d *delivery, pending bool) {
+	if n.Queue == nil || d.item == nil {
+		return
+	}
+	var err error
+	if pending {
+		var item *resource.Item
+		if item, err = resource.NewItem(d.row(ctx)); err == nil {
+			if err = n.Queue.Update(ctx, item, d.item); err == nil {
+				d.item = item
+			}
+		}
+	} else {
+		err = n.Queue.Delete(ctx, d.item)
+	}
+	if err != nil {
+		logErrorf(ctx, "webhook %v: cannot persist delivery of event %s: %v", d.subscription, d.event.ID, err)
+	}
+}
+
+// newDelivery builds the delivery of e to sub, or returns nil if sub does not
+// match e.
+func (n *Notifier) newDelivery(ctx context.Context, rsrc *resource.Resource, sub *resource.Item, e resource.Event) (*delivery, error) {
+	wanted := false
+	if events, ok := sub.Payload["events"].([]interface{}); ok {
+		for _, t := range events {
+			if t == string(e.Type) {
+				wanted = true
+				break
+			}
+		}
+	}
+	if !wanted {
+		return nil, nil
+	}
+	if filter, _ := sub.Payload["filter"].(string); filter != "" {
+		p, err := query.ParsePredicate(filter)
+		if err == nil {
+			err = p.Prepare(rsrc.Validator())
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid filter: %v", err)
+		}
+		if !p.Match(e.Payload) {
+			return nil, nil
+		}
+	}
+	msg := Message{
+		ID:       e.ID,
+		Type:     e.Type,
+		Resource: e.Resource,
+		ItemID:   e.ItemID,
+		Command:  e.Command,
+		Time:     e.Time,
+	}
+	if e.Payload != nil {
+		// Always project the item, with an empty projection if no fields are
+		// set, so hidden fields are never sent to subscribers.
+		var p query.Projection
+		var err error
+		if fields, _ := sub.Payload["fields"].(string); fields != "" {
+			if p, err = query.ParseProjection(fields); err == nil {
+				err = p.Validate(rsrc.Validator())
+			}
+		}
+		if err == nil {
+			msg.Item, err = p.Eval(ctx, e.Payload, indexResource{n.index, rsrc})
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid fields: %v", err)
+		}
+	}
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	url, _ := sub.Payload["url"].(string)
+	secret, _ := sub.Payload["secret"].(string)
+	return &delivery{
+		subscription: sub.ID,
+		url:          url,
+		secret:       secret,
+		event:        e,
+		body:         body,
+		due:          time.Now(),
+	}, nil
+}
+
+// enqueue adds d to the queue, keeping it sorted by due time.
+func (n *Notifier) enqueue(d *delivery) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	i := sort.Search(len(n.queue), func(i int) bool {
+		return n.queue[i].due.After(d.due)
+	})
+	n.queue = append(n.queue, nil)
+	copy(n.queue[i+1:], n.queue[i:])
+	n.queue[i] = d
+}
+
+// notify wakes up Run.
+func (n *Notifier) notify() {
+	select {
+	case n.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Pending returns the number of queued deliveries.
+func (n *Notifier) Pending() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return len(n.queue)
+}
+
+// next returns the due time of the next queued delivery.
+func (n *Notifier) next() (time.Time, bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if len(n.queue) == 0 {
+		return time.Time{}, false
+	}
+	return n.queue[0].due, true
+}
+
+// Deliver performs all the deliveries due, at most Concurrency at once, and
+// returns the number of successful ones. Failed deliveries are queued again
+// with a backoff delay until MaxAttempts is reached.
+func (n *Notifier) Deliver(ctx context.Context) int {
+	now := time.Now()
+	n.mu.Lock()
+	i := sort.Search(len(n.queue), func(i int) bool {
+		return n.queue[i].due.After(now)
+	})
+	due := make([]*delivery, i)
+	copy(due, n.queue[:i])
+	n.queue = n.queue[i:]
+	n.mu.Unlock()
+
+	concurrency := n.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	var delivered atomic.Int64
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, concurrency)
+	for _, d := range due {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(d *delivery) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if n.deliver(ctx, d) {
+				delivered.Add(1)
+			}
+		}(d)
+	}
+	wg.Wait()
+	return int(delivered.Load())
+}
+
+// deliver performs the delivery d and returns true if it succeeded. A failed
+// delivery is queued again with a backoff delay until MaxAttempts is reached.
+func (n *Notifier) deliver(ctx context.Context, d *delivery) bool {
+	d.attempt++
+	status, err := n.send(ctx, d)
+	n.logAttempt(ctx, d, status, err)
+	if err == nil {
+		n.persist(ctx, d, false)
+		return true
+	}
+	if d.attempt < n.MaxAttempts && !errors.Is(err, ErrDestinationNotAllowed) {
+		d.due = time.Now().Add(n.Backoff.Delay(d.attempt))
+		n.persist(ctx, d, true)
+		n.enqueue(d)
+	} else {
+		logErrorf(ctx, "webhook %v: giving up delivery of event %s after %d attempt(s): %v", d.subscription, d.event.ID, d.attempt, err)
+		n.persist(ctx, d, false)
+	}
+	return false
+}
+
+// Run restores the deliveries persisted in Queue, then performs the
+// deliveries as they become due until ctx is canceled. It returns the ctx
+// error, or the error of the restoration.
+func (n *Notifier) Run(ctx context.Context) error {
+	if err := n.Restore(ctx); err != nil {
+		return err
+	}
+	for {
+		n.Deliver(ctx)
+		wait := time.Minute
+		if due, ok := n.next(); ok {
+			wait = time.Until(due)
+		}
+		if wait > 0 {
+			t := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				t.Stop()
+				return ctx.Err()
+			case <-n.wake:
+			case <-t.C:
+			}
+			t.Stop()
+		} else if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+}
+
+// send POSTs the signed delivery body and returns the response status.
+func (n *Notifier) send(ctx context.Context, d *delivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.url, bytes.NewReader(d.body))
+	if err != nil {
+		return 0, err
+	}
+	ts := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(ts, 10))
+	req.Header.Set(HeaderSignature, Sign(d.secret, ts, d.body))
+	req.Header.Set(HeaderEvent, string(d.event.Type))
+	req.Header.Set(HeaderDelivery, d.event.ID)
+	res, err := n.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, res.Body)
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("unexpected status %d", res.StatusCode)
+	}
+	return res.StatusCode, nil
+}
+
+// logAttempt stores a delivery attempt in the Deliveries resource if any.
+func (n *Notifier) logAttempt(ctx context.Context, d *delivery, status int, err error) {
+	if n.Deliveries == nil {
+		return
+	}
+	payload := map[string]interface{}{
+		"id":           schema.NewID(ctx, nil),
+		"created":      time.Now(),
+		"subscription": fmt.Sprint(d.subscription),
+		"event":        d.event.ID,
+		"url":          d.url,
+		"attempt":      d.attempt,
+		"succeeded":    err == nil,
+	}
+	if status > 0 {
+		payload["status"] = status
+	}
+	if err != nil {
+		payload["error"] = err.Error()
+	}
+	item, iErr := resource.NewItem(payload)
+	if iErr == nil {
+		iErr = n.Deliveries.Insert(ctx, []*resource.Item{item})
+	}
+	if iErr != nil {
+		logErrorf(ctx, "webhook %v: cannot log delivery attempt: %v", d.subscription, iErr)
+	}
+}
+
+// indexResource implements the query.Resource interface for projections.
+type indexResource struct {
+	index resource.Index
+	*resource.Resource
+}
+
+// Find implements query.Resource interface.
+func (r indexResource) Find(ctx context.Context, q *query.Query) ([]map[string]interface{}, error) {
+	list, err := r.Resource.Find(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	payloads := make([]map[string]interface{}, 0, len(list.Items))
+	for _, i := range list.Items {
+		payloads = append(payloads, i.Payload)
+	}
+	return payloads, nil
+}
+
+// MultiGet implements query.Resource interface.
+func (r indexResource) MultiGet(ctx context.Context, ids []interface{}) ([]map[string]interface{}, error) {
+	items, err := r.Resource.MultiGet(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	payloads := make([]map[string]interface{}, 0, len(items))
+	for _, i := range items {
+		var p map[string]interface{}
+		if i != nil {
+			p = i.Payload
+		}
+		payloads = append(payloads, p)
+	}
+	return payloads, nil
+}
+
+// SubResource implements query.Resource interface.
+func (r indexResource) SubResource(ctx context.Context, path string) (query.Resource, error) {
+	rsc, found := r.index.GetResource(path, r.Resource)
+	if !found {
+		return nil, fmt.Errorf("invalid resource reference: %s", path)
+	}
+	return indexResource{r.index, rsc}, nil
+}
+
+func logErrorf(ctx context.Context, format string, a ...interface{}) {
+	resource.Log(ctx, resource.LogLevelError, fmt.Sprintf(format, a...), nil)
+}
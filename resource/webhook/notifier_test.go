@@ -0,0 +1,310 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/resource/outbox"
+	"github.com/rs/rest-layer/resource/testing/mem"
+	"github.com/rs/rest-layer/schema"
+	"github.com/rs/rest-layer/schema/query"
+	"github.com/stretchr/testify/assert"
+)
+
+const testSecret = "0123456789abcdef"
+
+type receiver struct {
+	mu       sync.Mutex
+	status   int
+	messages []Message
+	verified []bool
+}
+
+func (rcv *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rcv.mu.Lock()
+	defer rcv.mu.Unlock()
+	body, _ := io.ReadAll(r.Body)
+	var m Message
+	json.Unmarshal(body, &m)
+	rcv.messages = append(rcv.messages, m)
+	rcv.verified = append(rcv.verified, Verify(testSecret, r.Header, body, time.Minute))
+	if rcv.status != 0 {
+		w.WriteHeader(rcv.status)
+	}
+}
+
+func newTestNotifier(t *testing.T, url, filter, fields string) (*Notifier, *resource.Resource) {
+	t.Helper()
+	index := resource.NewIndex()
+	users := index.Bind("users", schema.Schema{Fields: schema.Fields{
+		"id":     {},
+		"name":   {Filterable: true},
+		"secret": {},
+		"hash":   {Hidden: true},
+	}}, mem.NewHandler(), resource.DefaultConf)
+	subs := index.Bind("webhooks", SubscriptionSchema, mem.NewHandler(), resource.DefaultConf)
+	deliveries := index.Bind("deliveries", DeliverySchema, mem.NewHandler(), resource.DefaultConf)
+	queue := index.Bind("queue", QueueSchema, mem.NewHandler(), resource.DefaultConf)
+	if !assert.NoError(t, index.(resource.Compiler).Compile()) {
+		t.FailNow()
+	}
+	payload := map[string]interface{}{
+		"id":       "s1",
+		"resource": "users",
+		"events":   []interface{}{"inserted"},
+		"url":      url,
+		"secret":   testSecret,
+		"active":   true,
+	}
+	if filter != "" {
+		payload["filter"] = filter
+	}
+	if fields != "" {
+		payload["fields"] = fields
+	}
+	item, _ := resource.NewItem(payload)
+	assert.NoError(t, subs.Insert(context.Background(), []*resource.Item{item}))
+	n := NewNotifier(index, subs)
+	n.Deliveries = deliveries
+	n.Queue = queue
+	n.Backoff = outbox.Backoff{Min: time.Millisecond, Max: time.Millisecond}
+	// Test servers listen on the loopback.
+	n.AllowAddress = func(ip net.IP) bool { return true }
+	return n, users
+}
+
+func TestNotifierDeliver(t *testing.T) {
+	rcv := &receiver{}
+	ts := httptest.NewServer(rcv)
+	defer ts.Close()
+	n, users := newTestNotifier(t, ts.URL, `{name:"john"}`, "id,name")
+	ctx := context.Background()
+
+	john := &resource.Item{ID: "1", Payload: map[string]interface{}{"id": "1", "name": "john", "secret": "x"}}
+	jane := &resource.Item{ID: "2", Payload: map[string]interface{}{"id": "2", "name": "jane"}}
+	assert.NoError(t, n.Publish(ctx, users.NewEvent(resource.EventInserted, john)))
+	assert.NoError(t, n.Publish(ctx, users.NewEvent(resource.EventInserted, jane)))
+	assert.NoError(t, n.Publish(ctx, users.NewEvent(resource.EventDeleted, john)))
+	assert.Equal(t, 1, n.Pending())
+
+	assert.Equal(t, 1, n.Deliver(ctx))
+	assert.Equal(t, 0, n.Pending())
+	if assert.Len(t, rcv.messages, 1) {
+		m := rcv.messages[0]
+		assert.True(t, rcv.verified[0])
+		assert.Equal(t, resource.EventInserted, m.Type)
+		assert.Equal(t, "1", m.ItemID)
+		assert.Equal(t, map[string]interface{}{"id": "1", "name": "john"}, m.Item)
+	}
+}
+
+func TestNotifierHiddenFields(t *testing.T) {
+	rcv := &receiver{}
+	ts := httptest.NewServer(rcv)
+	defer ts.Close()
+	n, users := newTestNotifier(t, ts.URL, "", "")
+	ctx := context.Background()
+
+	item := &resource.Item{ID: "1", Payload: map[string]interface{}{"id": "1", "name": "john", "hash": "x"}}
+	assert.NoError(t, n.Publish(ctx, users.NewEvent(resource.EventInserted, item)))
+	assert.Equal(t, 1, n.Deliver(ctx))
+	if assert.Len(t, rcv.messages, 1) {
+		assert.Equal(t, map[string]interface{}{"id": "1", "name": "john"}, rcv.messages[0].Item)
+	}
+}
+
+func TestNotifierRetry(t *testing.T) {
+	rcv := &receiver{status: http.StatusServiceUnavailable}
+	ts := httptest.NewServer(rcv)
+	defer ts.Close()
+	n, users := newTestNotifier(t, ts.URL, "", "")
+	n.MaxAttempts = 3
+	ctx := context.Background()
+
+	item := &resource.Item{ID: "1", Payload: map[string]interface{}{"id": "1", "name": "john"}}
+	assert.NoError(t, n.Publish(ctx, users.NewEvent(resource.EventInserted, item)))
+	assert.Equal(t, 0, n.Deliver(ctx))
+	assert.Equal(t, 1, n.Pending())
+
+	time.Sleep(5 * time.Millisecond)
+	rcv.mu.Lock()
+	rcv.status = http.StatusOK
+	rcv.mu.Unlock()
+	assert.Equal(t, 1, n.Deliver(ctx))
+	assert.Equal(t, 0, n.Pending())
+	assert.Len(t, rcv.messages, 2)
+
+	list, err := n.Deliveries.Find(ctx, &query.Query{})
+	if assert.NoError(t, err) && assert.Len(t, list.Items, 2) {
+		var succeeded []interface{}
+		for _, i := range list.Items {
+			succeeded = append(succeeded, i.Payload["succeeded"])
+		}
+		assert.ElementsMatch(t, []interface{}{true, false}, succeeded)
+	}
+}
+
+func TestNotifierGiveUp(t *testing.T) {
+	rcv := &receiver{status: http.StatusInternalServerError}
+	ts := httptest.NewServer(rcv)
+	defer ts.Close()
+	n, users := newTestNotifier(t, ts.URL, "", "")
+	n.MaxAttempts = 2
+	ctx := context.Background()
+
+	item := &resource.Item{ID: "1", Payload: map[string]interface{}{"id": "1"}}
+	assert.NoError(t, n.Publish(ctx, users.NewEvent(resource.EventInserted, item)))
+	n.Deliver(ctx)
+	time.Sleep(5 * time.Millisecond)
+	n.Deliver(ctx)
+	assert.Equal(t, 0, n.Pending())
+	assert.Len(t, rcv.messages, 2)
+}
+
+func TestNotifierRestore(t *testing.T) {
+	rcv := &receiver{status: http.StatusServiceUnavailable}
+	ts := httptest.NewServer(rcv)
+	defer ts.Close()
+	n, users := newTestNotifier(t, ts.URL, "", "")
+	ctx := context.Background()
+	pending := func() []*resource.Item {
+		list, err := n.Queue.Find(ctx, &query.Query{})
+		assert.NoError(t, err)
+		return list.Items
+	}
+
+	item := &resource.Item{ID: "1", Payload: map[string]interface{}{"id": "1", "name": "john"}}
+	e := users.NewEvent(resource.EventInserted, item)
+	assert.NoError(t, n.Publish(ctx, e))
+	assert.Len(t, pending(), 1)
+	assert.Equal(t, 0, n.Deliver(ctx))
+	if p := pending(); assert.Len(t, p, 1) {
+		assert.Equal(t, 1, p[0].Payload["attempt"])
+	}
+
+	// A new notifier, as after a restart, restores the pending delivery.
+	restarted := NewNotifier(n.index, n.subscriptions)
+	restarted.Queue = n.Queue
+	restarted.AllowAddress = n.AllowAddress
+	assert.NoError(t, restarted.Restore(ctx))
+	assert.NoError(t, restarted.Restore(ctx))
+	assert.Equal(t, 1, restarted.Pending())
+
+	time.Sleep(5 * time.Millisecond)
+	rcv.mu.Lock()
+	rcv.status = http.StatusOK
+	rcv.mu.Unlock()
+	assert.Equal(t, 1, restarted.Deliver(ctx))
+	assert.Len(t, pending(), 0)
+	if assert.Len(t, rcv.messages, 2) {
+		assert.True(t, rcv.verified[1])
+		assert.Equal(t, e.ID, rcv.messages[1].ID)
+	}
+}
+
+func TestNotifierConcurrency(t *testing.T) {
+	fast := &receiver{}
+	fts := httptest.NewServer(fast)
+	defer fts.Close()
+	received := make(chan struct{})
+	blocked := make(chan bool, 1)
+	sts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Wait for the fast subscriber to be delivered.
+		select {
+		case <-received:
+			blocked <- false
+		case <-time.After(2 * time.Second):
+			blocked <- true
+		}
+	}))
+	defer sts.Close()
+	n, users := newTestNotifier(t, sts.URL, "", "")
+	ctx := context.Background()
+	sub, _ := resource.NewItem(map[string]interface{}{
+		"id":       "s2",
+		"resource": "users",
+		"events":   []interface{}{"inserted"},
+		"url":      fts.URL,
+		"secret":   testSecret,
+		"active":   true,
+	})
+	assert.NoError(t, n.subscriptions.Insert(ctx, []*resource.Item{sub}))
+	go func() {
+		for {
+			fast.mu.Lock()
+			l := len(fast.messages)
+			fast.mu.Unlock()
+			if l > 0 {
+				close(received)
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	item := &resource.Item{ID: "1", Payload: map[string]interface{}{"id": "1"}}
+	assert.NoError(t, n.Publish(ctx, users.NewEvent(resource.EventInserted, item)))
+	assert.Equal(t, 2, n.Pending())
+	assert.Equal(t, 2, n.Deliver(ctx))
+	assert.False(t, <-blocked)
+}
+
+func TestNotifierDestination(t *testing.T) {
+	rcv := &receiver{}
+	ts := httptest.NewServer(rcv)
+	defer ts.Close()
+	n, users := newTestNotifier(t, ts.URL, "", "")
+	n.AllowAddress = nil
+	ctx := context.Background()
+
+	item := &resource.Item{ID: "1", Payload: map[string]interface{}{"id": "1"}}
+	assert.NoError(t, n.Publish(ctx, users.NewEvent(resource.EventInserted, item)))
+	assert.Equal(t, 0, n.Deliver(ctx))
+	// Deliveries to a denied address are not retried.
+	assert.Equal(t, 0, n.Pending())
+	assert.Empty(t, rcv.messages)
+	list, err := n.Deliveries.Find(ctx, &query.Query{})
+	if assert.NoError(t, err) && assert.Len(t, list.Items, 1) {
+		assert.Contains(t, list.Items[0].Payload["error"], "destination not allowed: 127.0.0.1")
+	}
+}
+
+func TestPublicAddress(t *testing.T) {
+	for addr, public := range map[string]bool{
+		"93.184.216.34":   true,
+		"2606:2800:220::": true,
+		"127.0.0.1":       false,
+		"::1":             false,
+		"10.1.2.3":        false,
+		"172.16.0.1":      false,
+		"192.168.1.1":     false,
+		"169.254.169.254": false,
+		"fe80::1":         false,
+		"fd00::1":         false,
+		"0.0.0.0":         false,
+		"::ffff:10.0.0.1": false,
+	} {
+		assert.Equal(t, public, PublicAddress(net.ParseIP(addr)), addr)
+	}
+}
+
+func TestSignVerify(t *testing.T) {
+	body := []byte(`{"id":"1"}`)
+	h := http.Header{}
+	h.Set(HeaderTimestamp, "1")
+	h.Set(HeaderSignature, Sign(testSecret, 1, body))
+	assert.True(t, Verify(testSecret, h, body, 0))
+	assert.False(t, Verify(testSecret, h, body, time.Minute))
+	assert.False(t, Verify("other", h, body, 0))
+	assert.False(t, Verify(testSecret, h, []byte(`{"id":"2"}`), 0))
+	h.Set(HeaderTimestamp, "bad")
+	assert.False(t, Verify(testSecret, h, body, 0))
+}
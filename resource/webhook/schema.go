@@ -0,0 +1,195 @@
+package webhook
+
+import (
+	"errors"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+	"github.com/rs/rest-layer/schema/query"
+)
+
+var (
+	// SubscriptionSchema is the schema of the webhook subscriptions resource.
+	SubscriptionSchema = schema.Schema{
+		Description: "A webhook subscription",
+		Fields: schema.Fields{
+			"id":      schema.IDField,
+			"created": schema.CreatedField,
+			"updated": schema.UpdatedField,
+			"resource": {
+				Description: "The path of the resource to watch (i.e.: users.posts)",
+				Required:    true,
+				Filterable:  true,
+				Validator:   &schema.String{MinLen: 1},
+			},
+			"events": {
+				Description: "The types of events to deliver",
+				Required:    true,
+				Validator: &schema.Array{
+					MinLen: 1,
+					Values: schema.Field{
+						Validator: &schema.String{
+							Allowed: []string{
+								string(resource.EventInserted),
+								string(resource.EventUpdated),
+								string(resource.EventDeleted),
+								string(resource.EventCommand),
+							},
+						},
+					},
+				},
+			},
+			"filter": {
+				Description: "An optional filter the item must match to be delivered",
+				Validator:   &syntaxValidator{check: checkPredicate},
+			},
+			"fields": {
+				Description: "An optional projection applied to the delivered item",
+				Validator:   &syntaxValidator{check: checkProjection},
+			},
+			"url": {
+				Description: "The URL deliveries are POSTed to",
+				Required:    true,
+				Validator:   &schema.URL{AllowedSchemes: []string{"http", "https"}},
+			},
+			"secret": {
+				Description: "The secret used to sign deliveries",
+				Required:    true,
+				Hidden:      true,
+				Validator:   &schema.String{MinLen: 16},
+			},
+			"active": {
+				Description: "Deliveries are suspended when false",
+				Filterable:  true,
+				Default:     true,
+				Validator:   &schema.Bool{},
+			},
+		},
+	}
+
+	// DeliverySchema is the schema of the delivery attempts log resource.
+	DeliverySchema = schema.Schema{
+		Description: "A webhook delivery attempt",
+		Fields: schema.Fields{
+			"id":      schema.IDField,
+			"created": schema.CreatedField,
+			"subscription": {
+				Description: "The id of the subscription",
+				Required:    true,
+				Filterable:  true,
+				Validator:   &schema.String{},
+			},
+			"event": {
+				Description: "The id of the delivered event",
+				Required:    true,
+				Filterable:  true,
+				Validator:   &schema.String{},
+			},
+			"url": {
+				Description: "The URL the delivery was POSTed to",
+				Validator:   &schema.String{},
+			},
+			"attempt": {
+				Description: "The attempt number, starting at 1",
+				Sortable:    true,
+				Validator:   &schema.Integer{},
+			},
+			"status": {
+				Description: "The HTTP status returned by the subscriber, if any",
+				Filterable:  true,
+				Validator:   &schema.Integer{},
+			},
+			"error": {
+				Description: "The error of a failed attempt",
+				Validator:   &schema.String{},
+			},
+			"succeeded": {
+				Description: "Whether the attempt succeeded",
+				Filterable:  true,
+				Validator:   &schema.Bool{},
+			},
+		},
+	}
+)
+
+// QueueSchema is the schema of the pending deliveries resource, persisting
+// the deliveries waiting for an attempt so they survive a restart.
+var QueueSchema = schema.Schema{
+	Description: "A pending webhook delivery",
+	Fields: schema.Fields{
+		"id":      schema.IDField,
+		"created": schema.CreatedField,
+		"subscription": {
+			Description: "The id of the subscription",
+			Required:    true,
+			Filterable:  true,
+			Validator:   &schema.String{},
+		},
+		"event": {
+			Description: "The id of the event to deliver",
+			Required:    true,
+			Filterable:  true,
+			Validator:   &schema.String{},
+		},
+		"type": {
+			Description: "The type of the event to deliver",
+			Required:    true,
+			Validator:   &schema.String{},
+		},
+		"url": {
+			Description: "The URL the delivery is POSTed to",
+			Required:    true,
+			Validator:   &schema.String{},
+		},
+		"secret": {
+			Description: "The secret used to sign the delivery",
+			Hidden:      true,
+			Validator:   &schema.String{},
+		},
+		"body": {
+			Description: "The JSON message to deliver",
+			Required:    true,
+			Validator:   &schema.String{},
+		},
+		"attempt": {
+			Description: "The number of attempts already made",
+			Validator:   &schema.Integer{},
+		},
+		"due": {
+			Description: "The time of the next attempt",
+			Required:    true,
+			Sortable:    true,
+			Validator:   &schema.Time{},
+		},
+	},
+}
+
+// syntaxValidator validates the syntax of a string using check.
+type syntaxValidator struct {
+	schema.String
+	check func(v string) error
+}
+
+// Validate implements the schema.FieldValidator interface.
+func (v *syntaxValidator) Validate(value interface{}) (interface{}, error) {
+	value, err := v.String.Validate(value)
+	if err != nil {
+		return nil, err
+	}
+	if s := value.(string); s != "" {
+		if err := v.check(s); err != nil {
+			return nil, errors.New("invalid syntax: " + err.Error())
+		}
+	}
+	return value, nil
+}
+
+func checkPredicate(v string) error {
+	_, err := query.ParsePredicate(v)
+	return err
+}
+
+func checkProjection(v string) error {
+	_, err := query.ParseProjection(v)
+	return err
+}
@@ -0,0 +1,47 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// HeaderSignature holds the HMAC-SHA256 signature of a delivery.
+	HeaderSignature = "X-Webhook-Signature"
+	// HeaderTimestamp holds the unix time at which a delivery was signed.
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	// HeaderEvent holds the type of the delivered event.
+	HeaderEvent = "X-Webhook-Event"
+	// HeaderDelivery holds the id of the delivered event. It can be used by
+	// receivers to discard duplicate deliveries.
+	HeaderDelivery = "X-Webhook-Delivery"
+)
+
+// Sign returns the signature of a delivery body sent at timestamp (unix time)
+// using secret. The signature is the hex encoded HMAC-SHA256 of the timestamp
+// and the body separated by a dot, prefixed by "sha256=".
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte{'.'})
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the signature headers of a delivery with the given body. If
+// tolerance is not zero, deliveries signed longer than tolerance ago are
+// rejected.
+func Verify(secret string, h http.Header, body []byte, tolerance time.Duration) bool {
+	ts, err := strconv.ParseInt(h.Get(HeaderTimestamp), 10, 64)
+	if err != nil {
+		return false
+	}
+	if tolerance > 0 && time.Since(time.Unix(ts, 0)) > tolerance {
+		return false
+	}
+	return hmac.Equal([]byte(h.Get(HeaderSignature)), []byte(Sign(secret, ts, body)))
+}
@@ -0,0 +1,512 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema/query"
+)
+
+// Batch modes, selected with the mode query-string parameter of the batch
+// request.
+const (
+	// batchSequential executes the sub-requests one after the other, whatever
+	// the outcome of the previous ones.
+	batchSequential = "sequential"
+	// batchParallel executes the sub-requests concurrently. References to the
+	// responses of other sub-requests are not supported.
+	batchParallel = "parallel"
+	// batchAtomic executes the sub-requests one after the other and stops at
+	// the first failure, undoing the writes of the previous ones.
+	batchAtomic = "atomic"
+)
+
+// batchRef matches the references to the response bodies of previous
+// sub-requests: ${<index>} or ${<index>.<field>[.<field>...]}.
+var batchRef = regexp.MustCompile(`\$\{(\d+)((?:\.[^.{}]+)*)\}`)
+
+// batchRequest is a sub-request of a batch.
+type batchRequest struct {
+	Method  string            `json:"method"`
+	Path    string            `json:"path"`
+	Headers map[string]string `json:"headers"`
+	Body    interface{}       `json:"body"`
+}
+
+// batchResult is the response to a sub-request of a batch.
+type batchResult struct {
+	status  int
+	headers http.Header
+	body    interface{}
+}
+
+// batch holds the state of a batch request being executed.
+type batch struct {
+	h       *Handler
+	r       *http.Request
+	reqs    []batchRequest
+	results []batchResult
+	// docs caches the JSON representation of the bodies of the results
+	// referenced by other sub-requests.
+	docs map[int]interface{}
+}
+
+// compensation undoes a write executed by an atomic batch.
+type compensation struct {
+	index int
+	rsrc  *resource.Resource
+	// original is the item before the write, nil if the item was created.
+	original *resource.Item
+	// written is the item after the write, nil if the item was deleted or not
+	// returned by the write.
+	written *resource.Item
+	deleted bool
+}
+
+// serveBatch handles requests on the batch endpoint.
+func (h *Handler) serveBatch(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	ctx, e := h.negotiateEncoder(ctx, r, false)
+	if e != nil {
+		h.sendResponse(ctx, w, 0, http.Header{}, e, false)
+		return
+	}
+	if r.Method != http.MethodPost {
+		headers := http.Header{}
+		headers.Set("Allow", http.MethodPost)
+		h.sendResponse(ctx, w, ErrInvalidMethod.Code, headers, ErrInvalidMethod, r.Method == http.MethodHead)
+		return
+	}
+	reqs, mode, e := h.parseBatch(r)
+	if e != nil {
+		h.sendResponse(ctx, w, 0, http.Header{}, e, false)
+		return
+	}
+	ctx = contextWithIndex(ctx, h.index)
+	b := &batch{h: h, r: r, reqs: reqs, results: make([]batchResult, len(reqs))}
+	switch mode {
+	case batchParallel:
+		var wg sync.WaitGroup
+		for i := range reqs {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				b.results[i], _ = b.exec(ctx, i, false)
+			}(i)
+		}
+		wg.Wait()
+	case batchAtomic:
+		b.runAtomic(ctx)
+	default:
+		for i := range reqs {
+			b.results[i], _ = b.exec(ctx, i, false)
+		}
+	}
+	res := make([]interface{}, 0, len(b.results))
+	for _, result := range b.results {
+		res = append(res, result.response())
+	}
+	h.sendResponse(ctx, w, http.StatusOK, http.Header{}, res, false)
+}
+
+// parseBatch decodes and validates the sub-requests and the mode of a batch
+// request.
+func (h *Handler) parseBatch(r *http.Request) ([]batchRequest, string, *Error) {
+	mode := r.URL.Query().Get("mode")
+	switch mode {
+	case "":
+		mode = batchSequential
+	case batchSequential, batchParallel, batchAtomic:
+	default:
+		return nil, "", &Error{422, "URL parameters contain error(s)", map[string][]interface{}{
+			"mode": {"invalid mode, must be one of sequential, parallel or atomic"},
+		}}
+	}
+	if ct := r.Header.Get("Content-Type"); ct != "" && strings.TrimSpace(strings.SplitN(ct, ";", 2)[0]) != "application/json" {
+		return nil, "", &Error{http.StatusUnsupportedMediaType, fmt.Sprintf("Invalid Content-Type header: `%s' not supported", ct), nil}
+	}
+	if r.Body == nil {
+		return nil, "", &Error{400, "Malformed body: missing sub-requests", nil}
+	}
+	defer r.Body.Close()
+	var reqs []batchRequest
+	dec := json.NewDecoder(r.Body)
+	dec.UseNumber()
+	if err := dec.Decode(&reqs); err != nil {
+		return nil, "", &Error{400, fmt.Sprintf("Malformed body: %v", err), nil}
+	}
+	if h.BatchLimit > 0 && len(reqs) > h.BatchLimit {
+		return nil, "", &Error{http.StatusRequestEntityTooLarge, fmt.Sprintf("Too many sub-requests: %d exceeds the limit of %d", len(reqs), h.BatchLimit), nil}
+	}
+	issues := map[string][]interface{}{}
+	addIssue := func(i int, field string, err interface{}) {
+		key := fmt.Sprintf("%d.%s", i, field)
+		issues[key] = append(issues[key], err)
+	}
+	checkRefs := func(i int, field, s string) {
+		for _, m := range batchRef.FindAllStringSubmatch(s, -1) {
+			if mode == batchParallel {
+				addIssue(i, field, "references are not supported in parallel mode")
+			} else if n, _ := strconv.Atoi(m[1]); n >= i {
+				addIssue(i, field, fmt.Sprintf("invalid reference `%s': sub-request %d is not executed before", m[0], n))
+			}
+		}
+	}
+	for i := range reqs {
+		req := &reqs[i]
+		req.Method = strings.ToUpper(req.Method)
+		switch req.Method {
+		case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions:
+		default:
+			addIssue(i, "method", fmt.Sprintf("invalid method `%s'", req.Method))
+		}
+		if !strings.HasPrefix(req.Path, "/") {
+			addIssue(i, "path", "must start with /")
+		} else if strings.SplitN(req.Path, "?", 2)[0] == h.BatchPath {
+			addIssue(i, "path", "batches can't be nested")
+		}
+		checkRefs(i, "path", req.Path)
+		for _, v := range req.Headers {
+			checkRefs(i, "headers", v)
+		}
+		walkStrings(req.Body, func(s string) interface{} {
+			checkRefs(i, "body", s)
+			return s
+		})
+	}
+	if len(issues) > 0 {
+		return nil, "", &Error{422, "Document contains error(s)", issues}
+	}
+	return reqs, mode, nil
+}
+
+// runAtomic executes the sub-requests in order until one fails. On failure,
+// the writes of the previous sub-requests are undone in reverse order and the
+// following sub-requests are not executed.
+func (b *batch) runAtomic(ctx context.Context) {
+	var comps []*compensation
+	for i := range b.reqs {
+		var comp *compensation
+		b.results[i], comp = b.exec(ctx, i, true)
+		if b.results[i].status >= 400 {
+			b.abort(ctx, i, comps)
+			return
+		}
+		if comp != nil {
+			comps = append(comps, comp)
+		}
+	}
+}
+
+// abort undoes the writes of comps after the failure of sub-request failed
+// and reports the other sub-requests as failed dependencies.
+func (b *batch) abort(ctx context.Context, failed int, comps []*compensation) {
+	// Undo the writes even if the client is gone.
+	ctx = context.WithoutCancel(ctx)
+	for i := range b.results {
+		switch {
+		case i < failed:
+			b.results[i] = b.errorResult(ctx, &Error{http.StatusFailedDependency, fmt.Sprintf("Rolled back: sub-request %d failed", failed), nil})
+		case i > failed:
+			b.results[i] = b.errorResult(ctx, &Error{http.StatusFailedDependency, fmt.Sprintf("Not executed: sub-request %d failed", failed), nil})
+		}
+	}
+	for i := len(comps) - 1; i >= 0; i-- {
+		c := comps[i]
+		if err := c.undo(ctx); err != nil {
+			logErrorf(ctx, "Batch sub-request %d rollback failed: %v", c.index, err)
+			b.results[c.index] = b.errorResult(ctx, &Error{http.StatusInternalServerError, fmt.Sprintf("Rollback failed: %v", err), nil})
+		}
+	}
+}
+
+// exec executes the sub-request i. In atomic mode, the compensation undoing
+// its write is returned if any.
+func (b *batch) exec(ctx context.Context, i int, atomic bool) (batchResult, *compensation) {
+	req := b.reqs[i]
+	sub, e := b.request(ctx, i, req)
+	if e != nil {
+		return b.errorResult(ctx, e), nil
+	}
+	skipBody := sub.Method == http.MethodHead
+	route, err := FindRoute(b.h.index, sub)
+	if err != nil {
+		headers := http.Header{}
+		_, status, body := formatResponse(ctx, b.h.ResponseFormatter, nil, 0, headers, err, skipBody)
+		return batchResult{status, headers, body}, nil
+	}
+	defer route.Release()
+	timeout, err := b.h.requestTimeout(sub, route)
+	if err != nil {
+		return b.errorResult(ctx, err), nil
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	ctx = contextWithRoute(ctx, route)
+	var comp *compensation
+	if atomic {
+		if comp, err = prepareCompensation(ctx, sub, route); err != nil {
+			return b.errorResult(ctx, err), nil
+		}
+	}
+	status, headers, body := routeHandler(ctx, sub, route)
+	if headers == nil {
+		headers = http.Header{}
+	}
+	if _, isErr := body.(error); isErr && timeout > 0 && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		status, body = http.StatusGatewayTimeout, &Error{http.StatusGatewayTimeout, fmt.Sprintf("Deadline Exceeded: request timeout of %s reached", timeout), nil}
+	}
+	if comp != nil {
+		if status >= 400 {
+			comp = nil
+		} else {
+			comp.index = i
+			comp.written, _ = body.(*resource.Item)
+		}
+	}
+	_, status, body = formatResponse(ctx, b.h.ResponseFormatter, nil, status, headers, body, skipBody)
+	return batchResult{status, headers, body}, comp
+}
+
+// request builds the HTTP request of the sub-request i, resolving its
+// references to the responses of the previous sub-requests.
+func (b *batch) request(ctx context.Context, i int, req batchRequest) (*http.Request, *Error) {
+	path, e := b.resolveString(req.Path, func(v interface{}) string {
+		return url.PathEscape(toString(v))
+	})
+	if e != nil {
+		return nil, e
+	}
+	var body io.Reader
+	if req.Body != nil {
+		var e *Error
+		doc := walkStrings(req.Body, func(s string) interface{} {
+			if e != nil {
+				return s
+			}
+			// A string made of a single reference is replaced by the
+			// referenced value, whatever its type.
+			if m := batchRef.FindStringSubmatchIndex(s); m != nil && m[0] == 0 && m[1] == len(s) {
+				var v interface{}
+				v, e = b.resolve(s)
+				return v
+			}
+			var r string
+			r, e = b.resolveString(s, toString)
+			return r
+		})
+		if e != nil {
+			return nil, e
+		}
+		j, err := json.Marshal(doc)
+		if err != nil {
+			return nil, &Error{400, fmt.Sprintf("Malformed body: %v", err), nil}
+		}
+		body = bytes.NewReader(j)
+	}
+	sub, err := http.NewRequest(req.Method, path, body)
+	if err != nil {
+		return nil, &Error{400, fmt.Sprintf("Invalid path `%s': %v", path, err), nil}
+	}
+	sub = sub.WithContext(ctx)
+	for k, v := range req.Headers {
+		if v, e = b.resolveString(v, toString); e != nil {
+			return nil, e
+		}
+		sub.Header.Set(k, v)
+	}
+	if body != nil && sub.Header.Get("Content-Type") == "" {
+		sub.Header.Set("Content-Type", "application/json")
+	}
+	sub.Host = b.r.Host
+	sub.RemoteAddr = b.r.RemoteAddr
+	return sub, nil
+}
+
+// resolveString replaces the references found in s by their value formatted
+// with format.
+func (b *batch) resolveString(s string, format func(v interface{}) string) (string, *Error) {
+	var e *Error
+	s = batchRef.ReplaceAllStringFunc(s, func(ref string) string {
+		if e != nil {
+			return ref
+		}
+		var v interface{}
+		if v, e = b.resolve(ref); e != nil {
+			return ref
+		}
+		return format(v)
+	})
+	return s, e
+}
+
+// resolve returns the value referenced by ref in the response body of a
+// previous sub-request. The reference can't be resolved if this sub-request
+// failed or if its body doesn't contain the referenced field.
+func (b *batch) resolve(ref string) (interface{}, *Error) {
+	m := batchRef.FindStringSubmatch(ref)
+	n, _ := strconv.Atoi(m[1])
+	if status := b.results[n].status; status == 0 || status >= 400 {
+		return nil, &Error{http.StatusFailedDependency, fmt.Sprintf("Failed Dependency: sub-request %d failed", n), nil}
+	}
+	doc, found := b.docs[n]
+	if !found {
+		// Use the JSON representation of the body so references are
+		// resolved the same way whatever the types used by the formatter.
+		j, err := json.Marshal(b.results[n].body)
+		if err == nil {
+			dec := json.NewDecoder(bytes.NewReader(j))
+			dec.UseNumber()
+			err = dec.Decode(&doc)
+		}
+		if err != nil {
+			return nil, &Error{http.StatusFailedDependency, fmt.Sprintf("Unresolved reference `%s': %v", ref, err), nil}
+		}
+		if b.docs == nil {
+			b.docs = map[int]interface{}{}
+		}
+		b.docs[n] = doc
+	}
+	v := doc
+	for _, name := range strings.Split(m[2], ".")[1:] {
+		switch d := v.(type) {
+		case map[string]interface{}:
+			v, found = d[name]
+		case []interface{}:
+			idx, err := strconv.Atoi(name)
+			found = err == nil && idx >= 0 && idx < len(d)
+			if found {
+				v = d[idx]
+			}
+		default:
+			found = false
+		}
+		if !found {
+			return nil, &Error{http.StatusFailedDependency, fmt.Sprintf("Unresolved reference `%s'", ref), nil}
+		}
+	}
+	return v, nil
+}
+
+// errorResult returns the result of a sub-request failing with e.
+func (b *batch) errorResult(ctx context.Context, err error) batchResult {
+	headers := http.Header{}
+	_, status, body := formatResponse(ctx, b.h.ResponseFormatter, nil, 0, headers, err, false)
+	return batchResult{status, headers, body}
+}
+
+// response returns the representation of the result in the batch response.
+func (r batchResult) response() map[string]interface{} {
+	headers := make(map[string]interface{}, len(r.headers))
+	for k, v := range r.headers {
+		headers[k] = strings.Join(v, ", ")
+	}
+	res := map[string]interface{}{
+		"status":  r.status,
+		"headers": headers,
+	}
+	if r.body != nil {
+		res["body"] = r.body
+	}
+	return res
+}
+
+// prepareCompensation returns the compensation undoing the write of the
+// request routed to route, fetching the item it modifies. Writes which can't
+// be undone are refused.
+func prepareCompensation(ctx context.Context, r *http.Request, route *RouteMatch) (*compensation, error) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return nil, nil
+	}
+	if route.CommandName() != "" {
+		return nil, &Error{422, "Commands can't be undone in atomic mode", nil}
+	}
+	rsrc := route.Resource()
+	if rsrc == nil {
+		return nil, nil
+	}
+	// The created items must be returned to be deleted on rollback.
+	if (r.Method == http.MethodPost || r.Method == http.MethodPut) && isNoContent(r) {
+		return nil, &Error{422, "Prefer: return=minimal is not supported in atomic mode", nil}
+	}
+	if route.ResourceID() == nil {
+		switch r.Method {
+		case http.MethodPost:
+			return &compensation{rsrc: rsrc}, nil
+		case http.MethodDelete:
+			return nil, &Error{422, "Collection deletions can't be undone in atomic mode", nil}
+		}
+		return nil, nil
+	}
+	q, e := route.Query()
+	if e != nil {
+		return nil, e
+	}
+	q.Window = &query.Window{Limit: 1}
+	l, err := rsrc.Find(ctx, q)
+	if err != nil {
+		err, _ = NewError(err)
+		return nil, err
+	}
+	comp := &compensation{rsrc: rsrc, deleted: r.Method == http.MethodDelete}
+	if len(l.Items) > 0 {
+		comp.original = l.Items[0]
+	}
+	return comp, nil
+}
+
+// undo executes the compensating write.
+func (c *compensation) undo(ctx context.Context) error {
+	switch {
+	case c.deleted:
+		if c.original == nil {
+			return nil
+		}
+		return c.rsrc.Insert(ctx, []*resource.Item{c.original})
+	case c.original == nil:
+		if c.written == nil {
+			return errors.New("created item unknown")
+		}
+		return c.rsrc.Delete(ctx, c.written)
+	}
+	current := c.written
+	if current == nil {
+		var err error
+		if current, err = c.rsrc.Get(ctx, c.original.ID); err != nil {
+			return err
+		}
+	}
+	return c.rsrc.Update(ctx, c.original, current)
+}
+
+// walkStrings returns v with the strings it contains replaced by the result
+// of fn.
+func walkStrings(v interface{}, fn func(s string) interface{}) interface{} {
+	switch v := v.(type) {
+	case string:
+		return fn(v)
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = walkStrings(e, fn)
+		}
+	case []interface{}:
+		for i, e := range v {
+			v[i] = walkStrings(e, fn)
+		}
+	}
+	return v
+}
@@ -0,0 +1,211 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/resource/testing/mem"
+	"github.com/rs/rest-layer/schema"
+	"github.com/rs/rest-layer/schema/query"
+	"github.com/stretchr/testify/assert"
+)
+
+func newBatchTestHandler(t *testing.T) (*Handler, *mem.MemoryHandler, *mem.MemoryHandler) {
+	var n int
+	nextID := func(ctx context.Context, v interface{}) interface{} {
+		if v == nil {
+			n++
+			v = fmt.Sprintf("n%d", n)
+		}
+		return v
+	}
+	users := mem.NewHandler()
+	john, _ := resource.NewItem(map[string]interface{}{"id": "1", "name": "John"})
+	jane, _ := resource.NewItem(map[string]interface{}{"id": "2", "name": "Jane"})
+	users.Insert(context.Background(), []*resource.Item{john, jane})
+	posts := mem.NewHandler()
+	index := resource.NewIndex()
+	u := index.Bind("users", schema.Schema{Fields: schema.Fields{
+		"id":   {OnInit: nextID},
+		"name": {Required: true, Validator: &schema.String{}},
+	}}, users, resource.Conf{AllowedModes: resource.ReadWrite})
+	u.Bind("posts", "user", schema.Schema{Fields: schema.Fields{
+		"id":    {OnInit: nextID},
+		"user":  {Validator: &schema.Reference{Path: "users"}},
+		"title": {Required: true},
+	}}, posts, resource.Conf{AllowedModes: resource.ReadWrite})
+	u.Command("notify", func(ctx context.Context, r *http.Request, item *resource.Item, payload map[string]interface{}) (http.Header, *resource.Item, map[string]interface{}, error) {
+		return nil, item, nil, nil
+	})
+	h, err := NewHandler(index)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	h.BatchPath = "/_batch"
+	return h, users, posts
+}
+
+func getItem(s *mem.MemoryHandler, id interface{}) *resource.Item {
+	l, err := s.Find(context.Background(), &query.Query{Predicate: query.Predicate{&query.Equal{Field: "id", Value: id}}})
+	if err != nil || len(l.Items) == 0 {
+		return nil
+	}
+	return l.Items[0]
+}
+
+func postBatch(h *Handler, target, body string) (int, []map[string]interface{}, map[string]interface{}) {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", target, strings.NewReader(body)))
+	var results []map[string]interface{}
+	var e map[string]interface{}
+	if w.Code == http.StatusOK {
+		json.Unmarshal(w.Body.Bytes(), &results)
+	} else {
+		json.Unmarshal(w.Body.Bytes(), &e)
+	}
+	return w.Code, results, e
+}
+
+func statuses(results []map[string]interface{}) []float64 {
+	s := make([]float64, 0, len(results))
+	for _, r := range results {
+		s = append(s, r["status"].(float64))
+	}
+	return s
+}
+
+func TestHandlerBatchSequential(t *testing.T) {
+	h, _, posts := newBatchTestHandler(t)
+	status, results, _ := postBatch(h, "/_batch", `[
+		{"method": "POST", "path": "/users", "body": {"name": "Bob"}},
+		{"method": "POST", "path": "/users/${0.id}/posts", "body": {"title": "Hello ${0.name}"}},
+		{"method": "GET", "path": "/users/42"},
+		{"method": "GET", "path": "/users/${2.id}"},
+		{"method": "get", "path": "/users/${0.id}/posts/${1.id}", "headers": {"X-Request-Timeout": "10s"}}
+	]`)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, []float64{201, 201, 404, 424, 200}, statuses(results))
+	assert.Equal(t, map[string]interface{}{"id": "n1", "name": "Bob"}, results[0]["body"])
+	assert.NotEmpty(t, results[0]["headers"].(map[string]interface{})["Etag"])
+	assert.Equal(t, map[string]interface{}{"id": "n2", "user": "n1", "title": "Hello Bob"}, results[1]["body"])
+	assert.Equal(t, map[string]interface{}{"code": float64(424), "message": "Failed Dependency: sub-request 2 failed"}, results[3]["body"])
+	assert.Equal(t, results[1]["body"], results[4]["body"])
+	assert.NotNil(t, getItem(posts, "n2"))
+
+	// A reference to a missing field can't be resolved.
+	_, results, _ = postBatch(h, "/_batch", `[
+		{"method": "GET", "path": "/users/1"},
+		{"method": "POST", "path": "/users/1/posts", "body": {"title": "${0.title}"}}
+	]`)
+	assert.Equal(t, []float64{200, 424}, statuses(results))
+	assert.Equal(t, "Unresolved reference `${0.title}'", results[1]["body"].(map[string]interface{})["message"])
+}
+
+func TestHandlerBatchParallel(t *testing.T) {
+	h, _, _ := newBatchTestHandler(t)
+	status, results, _ := postBatch(h, "/_batch?mode=parallel", `[
+		{"method": "GET", "path": "/users/1"},
+		{"method": "GET", "path": "/users/2"},
+		{"method": "GET", "path": "/users/3"},
+		{"method": "HEAD", "path": "/users/1"}
+	]`)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, []float64{200, 200, 404, 200}, statuses(results))
+	assert.Equal(t, map[string]interface{}{"id": "2", "name": "Jane"}, results[1]["body"])
+	assert.Nil(t, results[3]["body"])
+	assert.Equal(t, results[0]["headers"], results[3]["headers"])
+
+	status, _, e := postBatch(h, "/_batch?mode=parallel", `[
+		{"method": "GET", "path": "/users/1"},
+		{"method": "GET", "path": "/users/${0.id}"}
+	]`)
+	assert.Equal(t, http.StatusUnprocessableEntity, status)
+	assert.Equal(t, map[string]interface{}{"1.path": []interface{}{"references are not supported in parallel mode"}}, e["issues"])
+}
+
+func TestHandlerBatchAtomic(t *testing.T) {
+	h, users, _ := newBatchTestHandler(t)
+	john := getItem(users, "1")
+	status, results, _ := postBatch(h, "/_batch?mode=atomic", `[
+		{"method": "POST", "path": "/users", "body": {"name": "Bob"}},
+		{"method": "PATCH", "path": "/users/1", "body": {"name": "Johnny"}},
+		{"method": "PATCH", "path": "/users/1", "body": {"name": "Johnny B."}},
+		{"method": "DELETE", "path": "/users/2"},
+		{"method": "PUT", "path": "/users/3", "body": {"name": "Jim"}},
+		{"method": "POST", "path": "/users/${0.id}/posts", "body": {}},
+		{"method": "GET", "path": "/users/1"}
+	]`)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, []float64{424, 424, 424, 424, 424, 422, 424}, statuses(results))
+	assert.Equal(t, "Rolled back: sub-request 5 failed", results[0]["body"].(map[string]interface{})["message"])
+	assert.Equal(t, "Not executed: sub-request 5 failed", results[6]["body"].(map[string]interface{})["message"])
+	// All the writes are undone.
+	for _, id := range []string{"n1", "3"} {
+		assert.Nil(t, getItem(users, id), id)
+	}
+	if item := getItem(users, "1"); assert.NotNil(t, item) {
+		assert.Equal(t, john.ETag, item.ETag)
+		assert.Equal(t, john.Payload, item.Payload)
+	}
+	assert.NotNil(t, getItem(users, "2"))
+
+	// Writes which can't be undone are refused.
+	_, results, _ = postBatch(h, "/_batch?mode=atomic", `[
+		{"method": "POST", "path": "/users", "body": {"name": "Bob"}},
+		{"method": "POST", "path": "/users/1/notify"}
+	]`)
+	assert.Equal(t, []float64{424, 422}, statuses(results))
+	assert.Equal(t, "Commands can't be undone in atomic mode", results[1]["body"].(map[string]interface{})["message"])
+	_, results, _ = postBatch(h, "/_batch?mode=atomic", `[{"method": "DELETE", "path": "/users"}]`)
+	assert.Equal(t, []float64{422}, statuses(results))
+
+	status, results, _ = postBatch(h, "/_batch?mode=atomic", `[
+		{"method": "POST", "path": "/users", "body": {"name": "Bob"}},
+		{"method": "POST", "path": "/users/${0.id}/posts", "body": {"title": "Hi"}}
+	]`)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, []float64{201, 201}, statuses(results))
+	assert.NotNil(t, getItem(users, results[0]["body"].(map[string]interface{})["id"]))
+}
+
+func TestHandlerBatchErrors(t *testing.T) {
+	h, _, _ := newBatchTestHandler(t)
+	h.BatchLimit = 2
+	cases := []struct {
+		method, target, body string
+		status               int
+		want                 string
+	}{
+		{"GET", "/_batch", "", http.StatusMethodNotAllowed, `{"code": 405, "message": "Invalid Method"}`},
+		{"POST", "/_batch?mode=foo", "[]", http.StatusUnprocessableEntity, `{"code": 422, "message": "URL parameters contain error(s)", "issues": {"mode": ["invalid mode, must be one of sequential, parallel or atomic"]}}`},
+		{"POST", "/_batch", "{}", http.StatusBadRequest, `{"code": 400, "message": "Malformed body: json: cannot unmarshal object into Go value of type []rest.batchRequest"}`},
+		{"POST", "/_batch", `[{}, {}, {}]`, http.StatusRequestEntityTooLarge, `{"code": 413, "message": "Too many sub-requests: 3 exceeds the limit of 2"}`},
+		{"POST", "/_batch", `[
+			{"method": "FOO", "path": "users"},
+			{"method": "GET", "path": "/_batch", "headers": {"If-Match": "${1._etag}"}}
+		]`, http.StatusUnprocessableEntity, `{"code": 422, "message": "Document contains error(s)", "issues": {
+			"0.method": ["invalid method ` + "`FOO'" + `"],
+			"0.path": ["must start with /"],
+			"1.path": ["batches can't be nested"],
+			"1.headers": ["invalid reference ` + "`${1._etag}'" + `: sub-request 1 is not executed before"]
+		}}`},
+	}
+	for _, tc := range cases {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(tc.method, tc.target, strings.NewReader(tc.body)))
+		assert.Equal(t, tc.status, w.Code, tc.body)
+		assert.JSONEq(t, tc.want, w.Body.String(), tc.body)
+	}
+
+	// The batch endpoint is disabled by default.
+	h.BatchPath = ""
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "/_batch", strings.NewReader("[]")))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,129 @@
+package rest
+
+import (
+	"crypto/md5"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+	"github.com/rs/rest-layer/schema/query"
+)
+
+// notModified returns true if the representation with etag, last updated at
+// updated, matches the If-None-Match header of r or, in its absence, hasn't
+// been modified since its If-Modified-Since header.
+func notModified(r *http.Request, etag string, updated time.Time) (bool, *Error) {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		return matchEtags(inm, etag), nil
+	}
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" {
+		ifModTime, err := time.Parse(time.RFC1123, ims)
+		if err != nil {
+			return false, &Error{400, "Invalid If-Modified-Since header", nil}
+		}
+		if updated.IsZero() {
+			return false, nil
+		}
+		// The update time is truncated to the second because RFC1123 doesn't
+		// support more.
+		return !updated.Truncate(time.Second).After(ifModTime), nil
+	}
+	return false, nil
+}
+
+// matchEtags returns true if etag is listed in the comma separated list of
+// etags of an If-None-Match header, or if the header is "*".
+func matchEtags(header, etag string) bool {
+	if etag == "" {
+		return false
+	}
+	for _, e := range strings.Split(header, ",") {
+		if e = strings.TrimSpace(e); e == "*" || compareEtag(e, etag) {
+			return true
+		}
+	}
+	return false
+}
+
+// notModifiedHeaders returns the headers of a 304 response, which must be the
+// ones the 200 response would have sent.
+func notModifiedHeaders(policy resource.CachePolicy, etag string, updated time.Time) http.Header {
+	headers := http.Header{}
+	setCacheHeaders(headers, policy)
+	if etag != "" {
+		headers.Set("Etag", `W/"`+etag+`"`)
+	}
+	if !updated.IsZero() {
+		headers.Set("Last-Modified", updated.In(time.UTC).Format("Mon, 02 Jan 2006 15:04:05 GMT"))
+	}
+	return headers
+}
+
+// setCacheHeaders sets the Cache-Control and Vary headers of policy.
+func setCacheHeaders(headers http.Header, policy resource.CachePolicy) {
+	if policy.CacheControl != "" {
+		headers.Set("Cache-Control", policy.CacheControl)
+	}
+	if len(policy.Vary) > 0 {
+		headers.Set("Vary", strings.Join(policy.Vary, ", "))
+	}
+}
+
+// lastModified returns the update time of the most recently updated item.
+func lastModified(items []*resource.Item) time.Time {
+	var updated time.Time
+	for _, item := range items {
+		if item != nil && item.Updated.After(updated) {
+			updated = item.Updated
+		}
+	}
+	return updated
+}
+
+// listETag returns the etag of the list l returned for q. It covers the etags
+// of the items and their order, the projection and the page metadata. If
+// projected is true, the payloads of the items are hashed instead of their
+// etags, as the projection embeds data not covered by them.
+func listETag(l *resource.ItemList, q *query.Query, projected bool) string {
+	h := md5.New()
+	fmt.Fprintf(h, "%d:%d:%s", l.Total, l.Offset, q.Projection)
+	if q.Window != nil {
+		fmt.Fprintf(h, ":%d", q.Window.Limit)
+	}
+	for _, item := range l.Items {
+		h.Write([]byte{0})
+		if projected {
+			if j, err := json.Marshal(item.Payload); err == nil {
+				h.Write(j)
+				continue
+			}
+		}
+		if item.ETag != "" {
+			h.Write([]byte(item.ETag))
+		} else {
+			fmt.Fprint(h, item.ID)
+		}
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// embedsData returns true if the projection p embeds data which is not part of
+// the projected items: referenced or connected items, or fields computed from
+// parameters.
+func embedsData(p query.Projection, v schema.Validator) bool {
+	for _, pf := range p {
+		if len(pf.Children) > 0 || len(pf.Params) > 0 {
+			return true
+		}
+		if def := v.GetField(pf.Name); def != nil {
+			if _, ok := def.Validator.(*schema.Connection); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
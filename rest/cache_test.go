@@ -0,0 +1,140 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/resource/testing/mem"
+	"github.com/rs/rest-layer/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchEtags(t *testing.T) {
+	assert.True(t, matchEtags(`W/"a"`, "a"))
+	assert.True(t, matchEtags(`W/"b", W/"a"`, "a"))
+	assert.True(t, matchEtags(`*`, "a"))
+	assert.False(t, matchEtags(`W/"b", W/"c"`, "a"))
+	assert.False(t, matchEtags(`*`, ""))
+}
+
+func TestNotModified(t *testing.T) {
+	updated := time.Date(2020, 1, 2, 3, 4, 5, 600, time.UTC)
+	req := func(headers map[string]string) *http.Request {
+		r := httptest.NewRequest("GET", "/", nil)
+		for k, v := range headers {
+			r.Header.Set(k, v)
+		}
+		return r
+	}
+	cases := []struct {
+		headers map[string]string
+		updated time.Time
+		want    bool
+		err     *Error
+	}{
+		{nil, updated, false, nil},
+		{map[string]string{"If-None-Match": `W/"a"`}, updated, true, nil},
+		{map[string]string{"If-None-Match": `W/"b"`}, updated, false, nil},
+		// If-Modified-Since is ignored when If-None-Match is set.
+		{map[string]string{"If-None-Match": `W/"b"`, "If-Modified-Since": "Thu, 02 Jan 2020 03:04:05 UTC"}, updated, false, nil},
+		{map[string]string{"If-Modified-Since": "Thu, 02 Jan 2020 03:04:05 UTC"}, updated, true, nil},
+		{map[string]string{"If-Modified-Since": "Thu, 02 Jan 2020 03:04:04 UTC"}, updated, false, nil},
+		{map[string]string{"If-Modified-Since": "Thu, 02 Jan 2020 03:04:05 UTC"}, time.Time{}, false, nil},
+		{map[string]string{"If-Modified-Since": "invalid"}, updated, false, &Error{400, "Invalid If-Modified-Since header", nil}},
+	}
+	for _, tc := range cases {
+		got, err := notModified(req(tc.headers), "a", tc.updated)
+		assert.Equal(t, tc.want, got, "%v", tc.headers)
+		assert.Equal(t, tc.err, err, "%v", tc.headers)
+	}
+}
+
+func TestHandlerConditionalList(t *testing.T) {
+	yesterday := time.Now().Add(-24 * time.Hour).Truncate(time.Second)
+	users := mem.NewHandler()
+	users.Insert(context.Background(), []*resource.Item{
+		{ID: "1", ETag: "u1", Updated: yesterday, Payload: map[string]interface{}{"id": "1", "name": "John"}},
+	})
+	posts := mem.NewHandler()
+	posts.Insert(context.Background(), []*resource.Item{
+		{ID: "a", ETag: "pa", Updated: yesterday.Add(-time.Hour), Payload: map[string]interface{}{"id": "a", "user": "1"}},
+		{ID: "b", ETag: "pb", Updated: yesterday, Payload: map[string]interface{}{"id": "b", "user": "1"}},
+	})
+	index := resource.NewIndex()
+	index.Bind("users", schema.Schema{Fields: schema.Fields{"id": {}, "name": {}}}, users, resource.Conf{
+		AllowedModes: resource.ReadWrite,
+		ItemCache:    resource.CachePolicy{CacheControl: "private, max-age=60"},
+	})
+	index.Bind("posts", schema.Schema{Fields: schema.Fields{
+		"id":   {Sortable: true},
+		"user": {Validator: &schema.Reference{Path: "users"}},
+	}}, posts, resource.Conf{
+		AllowedModes:           resource.ReadWrite,
+		PaginationDefaultLimit: 10,
+		ListCache:              resource.CachePolicy{CacheControl: "no-cache", Vary: []string{"Authorization", "Accept"}},
+	})
+	h, err := NewHandler(index)
+	if !assert.NoError(t, err) {
+		return
+	}
+	get := func(target string, headers map[string]string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", target, nil)
+		for k, v := range headers {
+			r.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	w := get("/posts?sort=id", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	etag := w.Header().Get("Etag")
+	assert.NotEmpty(t, etag)
+	assert.Equal(t, yesterday.UTC().Format("Mon, 02 Jan 2006 15:04:05 GMT"), w.Header().Get("Last-Modified"))
+	assert.Equal(t, "no-cache", w.Header().Get("Cache-Control"))
+	assert.Equal(t, "Authorization, Accept", w.Header().Get("Vary"))
+
+	w = get("/posts?sort=id", map[string]string{"If-None-Match": etag})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Equal(t, "", w.Body.String())
+	assert.Equal(t, etag, w.Header().Get("Etag"))
+	assert.Equal(t, "no-cache", w.Header().Get("Cache-Control"))
+	assert.Equal(t, "Authorization, Accept", w.Header().Get("Vary"))
+
+	w = get("/posts?sort=id", map[string]string{"If-Modified-Since": yesterday.UTC().Format(time.RFC1123)})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	w = get("/posts?sort=id", map[string]string{"If-Modified-Since": yesterday.Add(-time.Second).UTC().Format(time.RFC1123)})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// The etag reflects the projection, the order and the page metadata.
+	for _, target := range []string{"/posts?sort=id&fields=id", "/posts?sort=-id", "/posts?sort=id&limit=5", "/posts?sort=id&page=2"} {
+		w = get(target, map[string]string{"If-None-Match": etag})
+		assert.Equal(t, http.StatusOK, w.Code, target)
+		assert.NotEqual(t, etag, w.Header().Get("Etag"), target)
+	}
+
+	// Embedded items are covered by the etag.
+	w = get("/posts?sort=id&fields=id,user{name}", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	embedEtag := w.Header().Get("Etag")
+	w = get("/posts?sort=id&fields=id,user{name}", map[string]string{"If-None-Match": embedEtag})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	users.Update(context.Background(), &resource.Item{ID: "1", ETag: "u2", Updated: time.Now(), Payload: map[string]interface{}{"id": "1", "name": "Jane"}}, &resource.Item{ID: "1", ETag: "u1"})
+	w = get("/posts?sort=id&fields=id,user{name}", map[string]string{"If-None-Match": embedEtag})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Item policy.
+	w = get("/users/1", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "private, max-age=60", w.Header().Get("Cache-Control"))
+	w = get("/users/1", map[string]string{"If-None-Match": `W/"u2"`})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Equal(t, `W/"u2"`, w.Header().Get("Etag"))
+	assert.Equal(t, "private, max-age=60", w.Header().Get("Cache-Control"))
+	assert.Equal(t, "", w.Header().Get("Vary"))
+}
@@ -0,0 +1,363 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Encoder serializes response bodies.
+type Encoder interface {
+	// MediaType returns the media type of the encoded bodies, sent as
+	// Content-Type (i.e.: application/json).
+	MediaType() string
+	// Encode writes the serialization of v to w.
+	Encode(ctx context.Context, w io.Writer, v interface{}) error
+}
+
+// Decoder deserializes request bodies.
+type Decoder interface {
+	// MediaType returns the media type of the bodies the decoder accepts,
+	// matched against the Content-Type of the request.
+	MediaType() string
+	// Decode reads a payload from r into v. Objects must be decoded as
+	// map[string]interface{} and numbers as float64, like encoding/json does.
+	Decode(r io.Reader, v *map[string]interface{}) error
+}
+
+// ListEncoder is an optional interface an Encoder can implement to restrict
+// its use to the responses listing items, like CSV. Other responses of a
+// request negotiating such an encoder are sent in JSON.
+type ListEncoder interface {
+	Encoder
+	// ListOnly returns true if the encoder can only encode lists of items.
+	ListOnly() bool
+}
+
+// Codecs is a registry of the encoders and decoders available to negotiate
+// the format of the response bodies from the Accept header, and of the
+// request bodies from the Content-Type header.
+type Codecs struct {
+	encoders []Encoder
+	decoders []Decoder
+}
+
+// NewCodecs creates a registry with the built-in codecs: JSON, MessagePack,
+// YAML and CSV for lists. JSON is used when the request doesn't express a
+// preference.
+func NewCodecs() *Codecs {
+	c := &Codecs{}
+	c.Register(JSONCodec{})
+	c.Register(MsgPackCodec{})
+	c.Register(YAMLCodec{})
+	c.Register(CSVEncoder{})
+	return c
+}
+
+// Register adds codec as an encoder and/or a decoder depending on the
+// interfaces it implements. It replaces the codec previously registered for
+// the same media type if any. The first registered encoder is the default
+// one.
+func (c *Codecs) Register(codec interface{}) {
+	if e, ok := codec.(Encoder); ok {
+		c.encoders = replaceEncoder(c.encoders, e)
+	}
+	if d, ok := codec.(Decoder); ok {
+		c.decoders = replaceDecoder(c.decoders, d)
+	}
+}
+
+func replaceEncoder(encoders []Encoder, e Encoder) []Encoder {
+	for i, cur := range encoders {
+		if cur.MediaType() == e.MediaType() {
+			encoders[i] = e
+			return encoders
+		}
+	}
+	return append(encoders, e)
+}
+
+func replaceDecoder(decoders []Decoder, d Decoder) []Decoder {
+	for i, cur := range decoders {
+		if cur.MediaType() == d.MediaType() {
+			decoders[i] = d
+			return decoders
+		}
+	}
+	return append(decoders, d)
+}
+
+// SetDefault makes the encoder and the decoder registered for mediaType the
+// default ones, used when the request doesn't express a preference. It returns
+// false if no codec is registered for mediaType.
+func (c *Codecs) SetDefault(mediaType string) bool {
+	found := false
+	for i, e := range c.encoders {
+		if e.MediaType() == mediaType {
+			copy(c.encoders[1:i+1], c.encoders[:i])
+			c.encoders[0] = e
+			found = true
+			break
+		}
+	}
+	for i, d := range c.decoders {
+		if d.MediaType() == mediaType {
+			copy(c.decoders[1:i+1], c.decoders[:i])
+			c.decoders[0] = d
+			found = true
+			break
+		}
+	}
+	return found
+}
+
+// Encoder returns the encoder best matching the accept header value. When
+// list is false, the encoders implementing ListEncoder are ignored. If accept
+// is empty, the default encoder is returned. If no encoder is acceptable, ok
+// is false.
+func (c *Codecs) Encoder(accept string, list bool) (e Encoder, ok bool) {
+	candidates := make([]Encoder, 0, len(c.encoders))
+	for _, e := range c.encoders {
+		if le, ok := e.(ListEncoder); ok && le.ListOnly() && !list {
+			continue
+		}
+		candidates = append(candidates, e)
+	}
+	if len(candidates) == 0 {
+		return nil, false
+	}
+	if strings.TrimSpace(accept) == "" {
+		return candidates[0], true
+	}
+	ranges := parseAccept(accept)
+	refused := map[string]bool{}
+	for _, r := range ranges {
+		if r.q <= 0 && r.subtype != "*" {
+			refused[r.typ+"/"+r.subtype] = true
+		}
+	}
+	var best Encoder
+	bestQ, bestSpec := 0.0, -1
+	for _, r := range ranges {
+		for _, e := range candidates {
+			spec := r.match(e.MediaType())
+			if spec < 0 || refused[e.MediaType()] {
+				continue
+			}
+			// Prefer the highest quality, then the most specific range.
+			// Candidates are ordered by preference.
+			if best == nil || r.q > bestQ || (r.q == bestQ && spec > bestSpec) {
+				best, bestQ, bestSpec = e, r.q, spec
+			}
+			break
+		}
+	}
+	if best == nil || bestQ <= 0 {
+		return nil, false
+	}
+	return best, true
+}
+
+// Decoder returns the decoder of the contentType media type. If contentType
+// is empty, the default (JSON) decoder is returned.
+func (c *Codecs) Decoder(contentType string) (Decoder, bool) {
+	if contentType == "" {
+		if len(c.decoders) == 0 {
+			return nil, false
+		}
+		return c.decoders[0], true
+	}
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, false
+	}
+	for _, d := range c.decoders {
+		if d.MediaType() == mt {
+			return d, true
+		}
+	}
+	return nil, false
+}
+
+// MediaTypes returns the media types of the registered encoders.
+func (c *Codecs) MediaTypes() []string {
+	types := make([]string, 0, len(c.encoders))
+	for _, e := range c.encoders {
+		types = append(types, e.MediaType())
+	}
+	return types
+}
+
+// mediaRange is a media range of an Accept header.
+type mediaRange struct {
+	typ, subtype string
+	q            float64
+}
+
+// parseAccept parses an Accept header value, ordering the media ranges by
+// decreasing quality.
+func parseAccept(accept string) []mediaRange {
+	ranges := []mediaRange{}
+	for _, part := range strings.Split(accept, ",") {
+		mt, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		r := mediaRange{q: 1}
+		r.typ, r.subtype = mt, "*"
+		if i := strings.IndexByte(mt, '/'); i >= 0 {
+			r.typ, r.subtype = mt[:i], mt[i+1:]
+		}
+		if q, err := strconv.ParseFloat(params["q"], 64); err == nil {
+			r.q = q
+		}
+		ranges = append(ranges, r)
+	}
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].q > ranges[j].q
+	})
+	return ranges
+}
+
+// match returns the specificity of the range if it matches mediaType (2 for
+// an exact match, 1 for type/*, 0 for */*) or -1 if it doesn't.
+func (r mediaRange) match(mediaType string) int {
+	typ, subtype := mediaType, ""
+	if i := strings.IndexByte(mediaType, '/'); i >= 0 {
+		typ, subtype = mediaType[:i], mediaType[i+1:]
+	}
+	switch {
+	case r.typ == "*":
+		return 0
+	case r.typ != typ:
+		return -1
+	case r.subtype == "*":
+		return 1
+	case r.subtype == subtype:
+		return 2
+	}
+	return -1
+}
+
+type encoderKey struct{}
+type codecsKey struct{}
+
+// contextWithEncoder stores the negotiated encoder in ctx.
+func contextWithEncoder(ctx context.Context, e Encoder) context.Context {
+	return context.WithValue(ctx, encoderKey{}, e)
+}
+
+// EncoderFromContext returns the encoder negotiated for the response of the
+// request, or JSONCodec if none was. It is meant to be used by custom
+// ResponseSender implementations.
+func EncoderFromContext(ctx context.Context) Encoder {
+	if e, ok := ctx.Value(encoderKey{}).(Encoder); ok {
+		return e
+	}
+	return JSONCodec{}
+}
+
+func contextWithCodecs(ctx context.Context, c *Codecs) context.Context {
+	return context.WithValue(ctx, codecsKey{}, c)
+}
+
+func codecsFromContext(ctx context.Context) *Codecs {
+	c, _ := ctx.Value(codecsKey{}).(*Codecs)
+	return c
+}
+
+// negotiateEncoder returns ctx holding the encoder of the response
+// negotiated from the Accept header of r, or an error if no encoder is
+// acceptable.
+func (h *Handler) negotiateEncoder(ctx context.Context, r *http.Request, list bool) (context.Context, *Error) {
+	if h.Codecs == nil {
+		return ctx, nil
+	}
+	e, ok := h.Codecs.Encoder(r.Header.Get("Accept"), list)
+	if !ok {
+		return ctx, &Error{http.StatusNotAcceptable, "Not Acceptable: supported media types are " + strings.Join(h.Codecs.MediaTypes(), ", "), nil}
+	}
+	return contextWithEncoder(ctx, e), nil
+}
+
+// JSONCodec encodes and decodes JSON bodies.
+type JSONCodec struct{}
+
+// MediaType implements Encoder and Decoder interfaces.
+func (JSONCodec) MediaType() string {
+	return "application/json"
+}
+
+// Encode implements Encoder interface.
+func (JSONCodec) Encode(ctx context.Context, w io.Writer, v interface{}) error {
+	j, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(j)
+	return err
+}
+
+// Decode implements Decoder interface.
+func (JSONCodec) Decode(r io.Reader, v *map[string]interface{}) error {
+	return json.NewDecoder(r).Decode(v)
+}
+
+// normalizeValue converts the maps and numbers of a decoded value to the
+// types produced by encoding/json: map[string]interface{} and float64.
+func normalizeValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, val := range v {
+			v[k] = normalizeValue(val)
+		}
+		return v
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			m[toString(k)] = normalizeValue(val)
+		}
+		return m
+	case []interface{}:
+		for i, val := range v {
+			v[i] = normalizeValue(val)
+		}
+		return v
+	case int:
+		return float64(v)
+	case int8:
+		return float64(v)
+	case int16:
+		return float64(v)
+	case int32:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case uint:
+		return float64(v)
+	case uint8:
+		return float64(v)
+	case uint16:
+		return float64(v)
+	case uint32:
+		return float64(v)
+	case uint64:
+		return float64(v)
+	case float32:
+		return float64(v)
+	}
+	return v
+}
+
+func toString(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	j, _ := json.Marshal(v)
+	return strings.Trim(string(j), `"`)
+}
@@ -0,0 +1,137 @@
+package rest
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/rs/rest-layer/schema/query"
+)
+
+// CSVEncoder encodes lists of items as CSV, with a header row. The columns
+// are the fields of the projection of the request if any, or all the fields
+// of the listed items, id first, sorted by name. A "*" in the projection is
+// expanded the same way. Objects and arrays are encoded as JSON in their cell.
+type CSVEncoder struct{}
+
+// MediaType implements Encoder interface.
+func (CSVEncoder) MediaType() string {
+	return "text/csv"
+}
+
+// ListOnly implements ListEncoder interface.
+func (CSVEncoder) ListOnly() bool {
+	return true
+}
+
+// Encode implements Encoder interface.
+func (CSVEncoder) Encode(ctx context.Context, w io.Writer, v interface{}) error {
+	items, ok := v.([]map[string]interface{})
+	if !ok {
+		return fmt.Errorf("can't encode %T as CSV", v)
+	}
+	columns := csvColumns(ctx, items)
+	cw := csv.NewWriter(w)
+	if err := cw.Write(columns); err != nil {
+		return err
+	}
+	row := make([]string, len(columns))
+	for _, item := range items {
+		for i, col := range columns {
+			cell, err := csvCell(item[col])
+			if err != nil {
+				return err
+			}
+			row[i] = cell
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// csvColumns returns the columns of the CSV listing items: the fields of the
+// projection if any, or the id followed by the sorted fields of the items. A
+// "*" in the projection is expanded to the fields of the items not listed
+// explicitly.
+func csvColumns(ctx context.Context, items []map[string]interface{}) []string {
+	if route, ok := RouteFromContext(ctx); ok {
+		if q, err := route.Query(); err == nil && len(q.Projection) > 0 {
+			explicit := map[string]bool{}
+			for _, pf := range q.Projection {
+				if pf.Name != "*" {
+					explicit[projectionColumn(pf)] = true
+				}
+			}
+			columns := make([]string, 0, len(q.Projection))
+			for _, pf := range q.Projection {
+				if pf.Name == "*" {
+					columns = append(columns, itemColumns(items, explicit)...)
+				} else {
+					columns = append(columns, projectionColumn(pf))
+				}
+			}
+			return columns
+		}
+	}
+	return itemColumns(items, nil)
+}
+
+// projectionColumn returns the column of the projected field pf.
+func projectionColumn(pf query.ProjectionField) string {
+	if pf.Alias != "" {
+		return pf.Alias
+	}
+	return pf.Name
+}
+
+// itemColumns returns the id followed by the sorted fields of items, skipping
+// those in skip.
+func itemColumns(items []map[string]interface{}, skip map[string]bool) []string {
+	seen := map[string]bool{}
+	columns := []string{}
+	for _, item := range items {
+		for k := range item {
+			if !seen[k] && !skip[k] && k != "id" {
+				seen[k] = true
+				columns = append(columns, k)
+			}
+		}
+	}
+	sort.Strings(columns)
+	if skip["id"] {
+		return columns
+	}
+	return append([]string{"id"}, columns...)
+}
+
+// csvCell formats v as a CSV cell.
+func csvCell(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case time.Time:
+		return v.Format(time.RFC3339Nano), nil
+	}
+	j, err := json.Marshal(v)
+	if err != nil {
+		return "", errors.New("can't encode value as CSV: " + err.Error())
+	}
+	return string(j), nil
+}
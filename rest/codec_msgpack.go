@@ -0,0 +1,38 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// MsgPackCodec encodes and decodes MessagePack bodies.
+type MsgPackCodec struct{}
+
+// MediaType implements Encoder and Decoder interfaces.
+func (MsgPackCodec) MediaType() string {
+	return "application/msgpack"
+}
+
+// Encode implements Encoder interface.
+func (MsgPackCodec) Encode(ctx context.Context, w io.Writer, v interface{}) error {
+	enc := msgpack.NewEncoder(w)
+	enc.SetCustomStructTag("json")
+	return enc.Encode(v)
+}
+
+// Decode implements Decoder interface.
+func (MsgPackCodec) Decode(r io.Reader, v *map[string]interface{}) error {
+	var payload interface{}
+	if err := msgpack.NewDecoder(r).Decode(&payload); err != nil {
+		return err
+	}
+	m, ok := normalizeValue(payload).(map[string]interface{})
+	if !ok {
+		return errors.New("payload is not an object")
+	}
+	*v = m
+	return nil
+}
@@ -0,0 +1,180 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/resource/testing/mem"
+	"github.com/rs/rest-layer/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestCodecsEncoder(t *testing.T) {
+	c := NewCodecs()
+	cases := []struct {
+		accept string
+		list   bool
+		want   string
+	}{
+		{"", false, "application/json"},
+		{"*/*", false, "application/json"},
+		{"application/yaml", false, "application/yaml"},
+		{"text/html,application/msgpack;q=0.9,*/*;q=0.8", false, "application/msgpack"},
+		{"application/*", false, "application/json"},
+		{"application/json;q=0, */*", false, "application/msgpack"},
+		{"text/csv", true, "text/csv"},
+		{"text/csv", false, ""},
+		{"text/csv, application/json;q=0.5", false, "application/json"},
+		{"text/html", false, ""},
+		{"application/yaml;q=0", false, ""},
+	}
+	for _, tc := range cases {
+		e, ok := c.Encoder(tc.accept, tc.list)
+		if tc.want == "" {
+			assert.False(t, ok, tc.accept)
+			continue
+		}
+		if assert.True(t, ok, tc.accept) {
+			assert.Equal(t, tc.want, e.MediaType(), tc.accept)
+		}
+	}
+}
+
+func TestCodecsDecoder(t *testing.T) {
+	c := NewCodecs()
+	d, ok := c.Decoder("")
+	assert.True(t, ok)
+	assert.Equal(t, "application/json", d.MediaType())
+	d, ok = c.Decoder("application/yaml; charset=utf-8")
+	assert.True(t, ok)
+	assert.Equal(t, "application/yaml", d.MediaType())
+	_, ok = c.Decoder("text/csv")
+	assert.False(t, ok)
+	_, ok = c.Decoder("text/plain")
+	assert.False(t, ok)
+}
+
+func TestCodecsSetDefault(t *testing.T) {
+	c := NewCodecs()
+	assert.True(t, c.SetDefault("application/yaml"))
+	e, _ := c.Encoder("", false)
+	assert.Equal(t, "application/yaml", e.MediaType())
+	d, _ := c.Decoder("")
+	assert.Equal(t, "application/yaml", d.MediaType())
+	assert.Equal(t, []string{"application/yaml", "application/json", "application/msgpack", "text/csv"}, c.MediaTypes())
+	assert.False(t, c.SetDefault("text/html"))
+}
+
+func newCodecTestHandler(t *testing.T) *Handler {
+	index := resource.NewIndex()
+	index.Bind("users", schema.Schema{Fields: schema.Fields{
+		"id":   {},
+		"name": {},
+		"age":  {Validator: &schema.Integer{}},
+		"tags": {},
+	}}, mem.NewHandler(), resource.Conf{AllowedModes: resource.ReadWrite})
+	h, err := NewHandler(index)
+	assert.NoError(t, err)
+	return h
+}
+
+func TestHandlerCodecs(t *testing.T) {
+	h := newCodecTestHandler(t)
+
+	// Create an item in YAML.
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("PUT", "/users/1", strings.NewReader("name: john\nage: 42\ntags: [a, b]\n"))
+	r.Header.Set("Content-Type", "application/yaml")
+	h.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	// Read it in MessagePack.
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", "/users/1", nil)
+	r.Header.Set("Accept", "application/msgpack")
+	h.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/msgpack", w.Header().Get("Content-Type"))
+	var item map[string]interface{}
+	assert.NoError(t, msgpack.Unmarshal(w.Body.Bytes(), &item))
+	assert.Equal(t, "john", item["name"])
+	assert.EqualValues(t, 42, item["age"])
+
+	// Update it in MessagePack.
+	body, _ := msgpack.Marshal(map[string]interface{}{"age": 43})
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("PATCH", "/users/1", bytes.NewReader(body))
+	r.Header.Set("Content-Type", "application/msgpack")
+	r.Header.Set("Accept", "application/yaml")
+	h.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "application/yaml", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), "age: 43\n")
+
+	// List it in CSV.
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", "/users?fields=id,name,n:age,tags", nil)
+	r.Header.Set("Accept", "text/csv")
+	h.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/csv", w.Header().Get("Content-Type"))
+	assert.Equal(t, "id,name,n,tags\n1,john,43,\"[\"\"a\"\",\"\"b\"\"]\"\n", w.Body.String())
+
+	// The * projection is expanded to the fields of the items.
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", "/users?fields=*,n:age", nil)
+	r.Header.Set("Accept", "text/csv")
+	h.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, strings.HasPrefix(w.Body.String(), "id,_etag,age,name,tags,n\n1,"), w.Body.String())
+	assert.True(t, strings.HasSuffix(w.Body.String(), ",43,john,\"[\"\"a\"\",\"\"b\"\"]\",43\n"), w.Body.String())
+
+	// Errors of a CSV request are sent in JSON.
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", "/users?filter=invalid", nil)
+	r.Header.Set("Accept", "text/csv")
+	h.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+}
+
+func TestHandlerCodecsErrors(t *testing.T) {
+	h := newCodecTestHandler(t)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/users/1", nil)
+	r.Header.Set("Accept", "text/csv")
+	h.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusNotAcceptable, w.Code)
+	assert.Equal(t, `{"code":406,"message":"Not Acceptable: supported media types are application/json, application/msgpack, application/yaml, text/csv"}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("POST", "/users", strings.NewReader(`id,name`))
+	r.Header.Set("Content-Type", "text/csv")
+	h.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("POST", "/users", strings.NewReader(`- a`))
+	r.Header.Set("Content-Type", "application/yaml")
+	h.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "Malformed body: payload is not an object")
+}
+
+func TestCSVEncoderColumns(t *testing.T) {
+	buf := &bytes.Buffer{}
+	err := CSVEncoder{}.Encode(context.Background(), buf, []map[string]interface{}{
+		{"id": "1", "b": true, "a": 1.5},
+		{"id": "2", "c": nil},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "id,a,b,c\n1,1.5,true,\n2,,,\n", buf.String())
+	assert.Error(t, CSVEncoder{}.Encode(context.Background(), buf, map[string]interface{}{}))
+}
@@ -0,0 +1,40 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"gopkg.in/yaml.v3"
+)
+
+// YAMLCodec encodes and decodes YAML bodies.
+type YAMLCodec struct{}
+
+// MediaType implements Encoder and Decoder interfaces.
+func (YAMLCodec) MediaType() string {
+	return "application/yaml"
+}
+
+// Encode implements Encoder interface.
+func (YAMLCodec) Encode(ctx context.Context, w io.Writer, v interface{}) error {
+	enc := yaml.NewEncoder(w)
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// Decode implements Decoder interface.
+func (YAMLCodec) Decode(r io.Reader, v *map[string]interface{}) error {
+	var payload interface{}
+	if err := yaml.NewDecoder(r).Decode(&payload); err != nil {
+		return err
+	}
+	m, ok := normalizeValue(payload).(map[string]interface{})
+	if !ok {
+		return errors.New("payload is not an object")
+	}
+	*v = m
+	return nil
+}
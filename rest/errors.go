@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/rs/rest-layer/resource"
 )
@@ -53,11 +55,22 @@ func NewError(err error) (error, int) {
 	if errors.As(err, &Err) {
 		return err, Err.Code
 	}
-	switch err {
-	case context.Canceled:
+	var uErr *resource.UniqueError
+	if errors.As(err, &uErr) {
+		return &Error{http.StatusConflict, "Conflict", uErr.Issues()}, http.StatusConflict
+	}
+	var unErr *resource.UnavailableError
+	if errors.As(err, &unErr) {
+		e := &Error{http.StatusServiceUnavailable, unErr.Error(), nil}
+		return &retryError{e, unErr.RetryAfter}, e.Code
+	}
+	switch {
+	case errors.Is(err, context.Canceled):
 		return ErrClientClosedRequest, ErrClientClosedRequest.Code
-	case context.DeadlineExceeded:
+	case errors.Is(err, context.DeadlineExceeded):
 		return ErrGatewayTimeout, ErrGatewayTimeout.Code
+	}
+	switch err {
 	case resource.ErrNotFound:
 		return ErrNotFound, ErrNotFound.Code
 	case resource.ErrForbidden:
@@ -79,3 +92,29 @@ func NewError(err error) (error, int) {
 func (e *Error) Error() string {
 	return e.Message
 }
+
+// retryError is an Error sent with a Retry-After header.
+type retryError struct {
+	err   *Error
+	after time.Duration
+}
+
+// Error implements the built-in error interface.
+func (e *retryError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the rest.Error.
+func (e *retryError) Unwrap() error {
+	return e.err
+}
+
+// retryAfter returns the value of the Retry-After header in seconds, rounded
+// up.
+func (e *retryError) retryAfter() string {
+	secs := int64((e.after + time.Second - 1) / time.Second)
+	if secs < 1 {
+		secs = 1
+	}
+	return strconv.FormatInt(secs, 10)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/rs/rest-layer/resource"
 	"github.com/stretchr/testify/assert"
@@ -50,6 +51,12 @@ func TestNewError(t *testing.T) {
 		assert.Equal(t, e, ErrNotFound)
 		assert.Equal(t, code, ErrNotFound.Code)
 	}
+	{
+		e, code := NewError(&resource.UnavailableError{Reason: "overloaded", RetryAfter: 1500 * time.Millisecond})
+		assert.Equal(t, e, &retryError{&Error{503, "Service Unavailable: overloaded", nil}, 1500 * time.Millisecond})
+		assert.Equal(t, code, 503)
+		assert.Equal(t, "2", e.(*retryError).retryAfter())
+	}
 }
 
 func TestError(t *testing.T) {
@@ -0,0 +1,35 @@
+package hal
+
+import (
+	"context"
+	"io"
+
+	"github.com/rs/rest-layer/rest"
+)
+
+// MediaType is the media type of HAL documents.
+const MediaType = "application/hal+json"
+
+// Codec encodes HAL documents.
+type Codec struct{}
+
+// MediaType implements rest.Encoder interface.
+func (Codec) MediaType() string {
+	return MediaType
+}
+
+// Encode implements rest.Encoder interface.
+func (Codec) Encode(ctx context.Context, w io.Writer, v interface{}) error {
+	return rest.JSONCodec{}.Encode(ctx, w, v)
+}
+
+// Configure sets api up to render HAL documents with links built by urls, and
+// to send them as application/hal+json by default.
+func Configure(api *rest.Handler, urls rest.URLBuilder) {
+	api.ResponseFormatter = Formatter{URLs: urls}
+	if api.Codecs == nil {
+		api.Codecs = rest.NewCodecs()
+	}
+	api.Codecs.Register(Codec{})
+	api.Codecs.SetDefault(MediaType)
+}
@@ -0,0 +1,25 @@
+/*
+Package hal renders the responses of a rest.Handler as HAL documents
+(https://tools.ietf.org/html/draft-kelly-json-hal).
+
+Items get a _links member holding the link to themselves (self), to their
+collection (collection), to the parent item of a sub-resource (up), to their
+sub-resources and to the commands registered on their resource, named after
+them. Lists link to their pages (self, first, prev, next and last) and hold the
+items in _embedded, under the name of the resource. The items embedded by the
+projection through references and connections are moved to the _embedded
+member of their item, with their own links.
+
+	api, err := rest.NewHandler(index)
+	if err != nil {
+		log.Fatal(err)
+	}
+	hal.Configure(api, rest.URLBuilder{Base: "/api"})
+	http.Handle("/api/", http.StripPrefix("/api/", api))
+
+Errors are rendered by rest.DefaultResponseFormatter.
+
+This package is part of the rest-layer project. See http://rest-layer.io for
+full REST Layer documentation.
+*/
+package hal
@@ -0,0 +1,200 @@
+package hal
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/rest"
+	"github.com/rs/rest-layer/schema"
+)
+
+// Formatter is a rest.ResponseFormatter producing HAL documents. It sets the
+// same headers as rest.DefaultResponseFormatter.
+type Formatter struct {
+	rest.DefaultResponseFormatter
+	// URLs builds the URLs of the links.
+	URLs rest.URLBuilder
+}
+
+// FormatItem implements rest.ResponseFormatter.
+func (f Formatter) FormatItem(ctx context.Context, headers http.Header, i *resource.Item, skipBody bool) (context.Context, interface{}) {
+	ctx, body := f.DefaultResponseFormatter.FormatItem(ctx, headers, i, skipBody)
+	route, ok := rest.RouteFromContext(ctx)
+	if body == nil || !ok {
+		return ctx, body
+	}
+	return ctx, f.item(ctx, route, i, false)
+}
+
+// FormatList implements rest.ResponseFormatter.
+func (f Formatter) FormatList(ctx context.Context, headers http.Header, l *resource.ItemList, skipBody bool) (context.Context, interface{}) {
+	route, ok := rest.RouteFromContext(ctx)
+	if skipBody || !ok || route.Resource() == nil {
+		return f.DefaultResponseFormatter.FormatList(ctx, headers, l, skipBody)
+	}
+	ctx, _ = f.DefaultResponseFormatter.FormatList(ctx, headers, l, true)
+	items := make([]interface{}, 0, len(l.Items))
+	for _, i := range l.Items {
+		items = append(items, f.item(ctx, route, i, true))
+	}
+	doc := map[string]interface{}{
+		"_links":    f.listLinks(route, l),
+		"_embedded": map[string]interface{}{route.Resource().Name(): items},
+	}
+	if l.Total >= 0 {
+		doc["total"] = l.Total
+	}
+	return ctx, doc
+}
+
+// item returns the HAL representation of an item of the route's collection.
+// Items of lists don't repeat the links to the collection and its parent, but
+// hold their etag like with rest.DefaultResponseFormatter.
+func (f Formatter) item(ctx context.Context, route *rest.RouteMatch, i *resource.Item, inList bool) map[string]interface{} {
+	doc := f.resource(ctx, route.Resource(), i.Payload, f.URLs.Item(route.ResourcePath, i.ID))
+	if inList {
+		if i.ETag != "" {
+			doc["_etag"] = i.ETag
+		}
+		return doc
+	}
+	links := doc["_links"].(map[string]interface{})
+	links["collection"] = href(f.URLs.Collection(route.ResourcePath))
+	if up, ok := f.URLs.Parent(route.ResourcePath); ok {
+		links["up"] = href(up)
+	}
+	return doc
+}
+
+// listLinks returns the links of a list: its current, first, previous, next
+// and last pages, and the parent item of its collection.
+func (f Formatter) listLinks(route *rest.RouteMatch, l *resource.ItemList) map[string]interface{} {
+	collection := f.URLs.Collection(route.ResourcePath)
+	self := collection
+	if len(route.Params) > 0 {
+		self += "?" + route.Params.Encode()
+	}
+	links := map[string]interface{}{"self": href(self)}
+	if up, ok := f.URLs.Parent(route.ResourcePath); ok {
+		links["up"] = href(up)
+	}
+	for rel, q := range rest.PageLinks(route, l) {
+		links[rel] = href(collection + "?" + q.Encode())
+	}
+	return links
+}
+
+// resource returns the HAL representation of an item of rsc with payload,
+// located at self. The items embedded in payload are moved to _embedded. If
+// self is empty, the item has no links.
+func (f Formatter) resource(ctx context.Context, rsc *resource.Resource, payload map[string]interface{}, self string) map[string]interface{} {
+	doc := make(map[string]interface{}, len(payload)+1)
+	embedded := map[string]interface{}{}
+	index, _ := rest.IndexFromContext(ctx)
+	var fields schema.Fields
+	if rsc != nil {
+		fields = rsc.Schema().Fields
+	}
+	for k, v := range payload {
+		if def, found := fields[k]; found {
+			if target, ok := related(index, rsc, def); ok {
+				if e, ok := f.embed(ctx, target, v); ok {
+					embedded[k] = e
+					continue
+				}
+			}
+		}
+		doc[k] = v
+	}
+	links := map[string]interface{}{}
+	if self != "" && rsc != nil {
+		for _, sub := range rsc.GetResources() {
+			links[sub.Name()] = href(self + "/" + sub.Name())
+		}
+		for _, name := range rsc.GetCommandNames() {
+			links[name] = href(self + "/" + name)
+		}
+		for _, name := range rsc.GetReadOnlyCommandNames() {
+			links[name] = href(self + "/" + name)
+		}
+		links["self"] = href(self)
+	}
+	doc["_links"] = links
+	if len(embedded) > 0 {
+		doc["_embedded"] = embedded
+	}
+	return doc
+}
+
+// embed returns the HAL representation of the item or the list of items of
+// rsc embedded in v. If v holds no embedded item (i.e.: a reference id), ok is
+// false.
+func (f Formatter) embed(ctx context.Context, rsc *resource.Resource, v interface{}) (e interface{}, ok bool) {
+	if p, ok := v.(*[]interface{}); ok && p != nil {
+		// Arrays of embedded references are set by the projection
+		// evaluator as pointers.
+		v = *p
+	}
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return f.embedded(ctx, rsc, v), true
+	case []map[string]interface{}:
+		l := make([]interface{}, 0, len(v))
+		for _, m := range v {
+			l = append(l, f.embedded(ctx, rsc, m))
+		}
+		return l, true
+	case []interface{}:
+		if len(v) == 0 {
+			return nil, false
+		}
+		l := make([]interface{}, 0, len(v))
+		for _, e := range v {
+			m, ok := e.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+			l = append(l, f.embedded(ctx, rsc, m))
+		}
+		return l, true
+	}
+	return nil, false
+}
+
+func (f Formatter) embedded(ctx context.Context, rsc *resource.Resource, payload map[string]interface{}) map[string]interface{} {
+	var self string
+	if rsc != nil {
+		self, _ = f.URLs.ResourceItem(rsc, payload)
+	}
+	return f.resource(ctx, rsc, payload, self)
+}
+
+// related returns the resource linked by the def field of rsc. If the field
+// is not a reference, an array of references or a connection, ok is false.
+// The resource is nil if it can't be found.
+func related(index resource.Index, rsc *resource.Resource, def schema.Field) (target *resource.Resource, ok bool) {
+	var path string
+	switch v := def.Validator.(type) {
+	case *schema.Reference:
+		path = v.Path
+	case *schema.Connection:
+		path = v.Path
+	case *schema.Array:
+		ref, ok := v.Values.Validator.(*schema.Reference)
+		if !ok {
+			return nil, false
+		}
+		path = ref.Path
+	default:
+		return nil, false
+	}
+	if index != nil {
+		target, _ = index.GetResource(path, rsc)
+	}
+	return target, true
+}
+
+func href(u string) map[string]interface{} {
+	return map[string]interface{}{"href": u}
+}
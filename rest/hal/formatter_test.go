@@ -0,0 +1,198 @@
+package hal
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/resource/testing/mem"
+	"github.com/rs/rest-layer/rest"
+	"github.com/rs/rest-layer/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestHandler(t *testing.T) http.Handler {
+	users := mem.NewHandler()
+	users.Insert(context.Background(), []*resource.Item{
+		{ID: "1", ETag: "u1", Payload: map[string]interface{}{"id": "1", "name": "John"}},
+		{ID: "2", ETag: "u2", Payload: map[string]interface{}{"id": "2", "name": "Jane"}},
+		{ID: "3", ETag: "u3", Payload: map[string]interface{}{"id": "3", "name": "Jim"}},
+	})
+	posts := mem.NewHandler()
+	posts.Insert(context.Background(), []*resource.Item{
+		{ID: "a", ETag: "pa", Payload: map[string]interface{}{"id": "a", "title": "First", "user": "1", "reviewers": []interface{}{"2"}}},
+	})
+	post := schema.Schema{Fields: schema.Fields{
+		"id":        {Sortable: true},
+		"title":     {},
+		"user":      {Filterable: true, Validator: &schema.Reference{Path: "users"}},
+		"reviewers": {Validator: &schema.Array{Values: schema.Field{Validator: &schema.Reference{Path: "users"}}}},
+	}}
+	index := resource.NewIndex()
+	u := index.Bind("users", schema.Schema{Fields: schema.Fields{
+		"id":    {Sortable: true},
+		"name":  {},
+		"posts": {Validator: &schema.Connection{Path: ".posts", Field: "user", Validator: &post}},
+	}}, users, resource.Conf{AllowedModes: resource.ReadOnly, PaginationDefaultLimit: 2})
+	p := u.Bind("posts", "user", post, posts, resource.Conf{AllowedModes: resource.ReadOnly})
+	p.Command("publish", func(ctx context.Context, r *http.Request, item *resource.Item, payload map[string]interface{}) (http.Header, *resource.Item, map[string]interface{}, error) {
+		return nil, item, nil, nil
+	})
+	api, err := rest.NewHandler(index)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	Configure(api, rest.URLBuilder{Base: "/api"})
+	return api
+}
+
+func get(h http.Handler, target string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+	return w
+}
+
+func TestFormatItem(t *testing.T) {
+	h := newTestHandler(t)
+	w := get(h, "/users/1/posts/a?fields=id,title,user{id,name},reviewers{id}")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, MediaType, w.Header().Get("Content-Type"))
+	assert.Equal(t, `W/"pa"`, w.Header().Get("Etag"))
+	assert.JSONEq(t, `{
+		"id": "a",
+		"title": "First",
+		"_links": {
+			"self": {"href": "/api/users/1/posts/a"},
+			"collection": {"href": "/api/users/1/posts"},
+			"up": {"href": "/api/users/1"},
+			"publish": {"href": "/api/users/1/posts/a/publish"}
+		},
+		"_embedded": {
+			"user": {
+				"id": "1",
+				"name": "John",
+				"_links": {
+					"self": {"href": "/api/users/1"},
+					"posts": {"href": "/api/users/1/posts"}
+				}
+			},
+			"reviewers": [{
+				"id": "2",
+				"_links": {
+					"self": {"href": "/api/users/2"},
+					"posts": {"href": "/api/users/2/posts"}
+				}
+			}]
+		}
+	}`, w.Body.String())
+
+	// References which are not embedded are left as is.
+	w = get(h, "/users/1/posts/a")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{
+		"id": "a",
+		"title": "First",
+		"user": "1",
+		"reviewers": ["2"],
+		"_links": {
+			"self": {"href": "/api/users/1/posts/a"},
+			"collection": {"href": "/api/users/1/posts"},
+			"up": {"href": "/api/users/1"},
+			"publish": {"href": "/api/users/1/posts/a/publish"}
+		}
+	}`, w.Body.String())
+}
+
+func TestFormatItemConnection(t *testing.T) {
+	h := newTestHandler(t)
+	w := get(h, "/users/1?fields=id,posts{id,user}")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{
+		"id": "1",
+		"_links": {
+			"self": {"href": "/api/users/1"},
+			"collection": {"href": "/api/users"},
+			"posts": {"href": "/api/users/1/posts"}
+		},
+		"_embedded": {
+			"posts": [{
+				"id": "a",
+				"user": "1",
+				"_links": {
+					"self": {"href": "/api/users/1/posts/a"},
+					"publish": {"href": "/api/users/1/posts/a/publish"}
+				}
+			}]
+		}
+	}`, w.Body.String())
+}
+
+func TestFormatList(t *testing.T) {
+	h := newTestHandler(t)
+	w := get(h, "/users?sort=id&fields=id")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{
+		"_links": {
+			"self": {"href": "/api/users?fields=id&sort=id"},
+			"first": {"href": "/api/users?fields=id&page=1&sort=id"},
+			"next": {"href": "/api/users?fields=id&page=2&sort=id"},
+			"last": {"href": "/api/users?fields=id&page=2&sort=id"}
+		},
+		"_embedded": {
+			"users": [
+				{"id": "1", "_etag": "u1", "_links": {"self": {"href": "/api/users/1"}, "posts": {"href": "/api/users/1/posts"}}},
+				{"id": "2", "_etag": "u2", "_links": {"self": {"href": "/api/users/2"}, "posts": {"href": "/api/users/2/posts"}}}
+			]
+		},
+		"total": 3
+	}`, w.Body.String())
+
+	w = get(h, "/users?sort=id&fields=id&page=2")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{
+		"_links": {
+			"self": {"href": "/api/users?fields=id&page=2&sort=id"},
+			"first": {"href": "/api/users?fields=id&page=1&sort=id"},
+			"prev": {"href": "/api/users?fields=id&page=1&sort=id"},
+			"last": {"href": "/api/users?fields=id&page=2&sort=id"}
+		},
+		"_embedded": {
+			"users": [
+				{"id": "3", "_etag": "u3", "_links": {"self": {"href": "/api/users/3"}, "posts": {"href": "/api/users/3/posts"}}}
+			]
+		},
+		"total": 3
+	}`, w.Body.String())
+
+	w = get(h, "/users/1/posts")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{
+		"_links": {
+			"self": {"href": "/api/users/1/posts"},
+			"up": {"href": "/api/users/1"}
+		},
+		"_embedded": {
+			"posts": [{
+				"id": "a",
+				"title": "First",
+				"user": "1",
+				"reviewers": ["2"],
+				"_etag": "pa",
+				"_links": {
+					"self": {"href": "/api/users/1/posts/a"},
+					"publish": {"href": "/api/users/1/posts/a/publish"}
+				}
+			}]
+		},
+		"total": 1
+	}`, w.Body.String())
+}
+
+func TestFormatError(t *testing.T) {
+	h := newTestHandler(t)
+	w := get(h, "/users/4")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.JSONEq(t, `{"code": 404, "message": "Not Found"}`, w.Body.String())
+}
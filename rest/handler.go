@@ -2,10 +2,15 @@ package rest
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/trace"
 )
 
 // Handler is a net/http compatible handler used to serve the configured REST
@@ -18,10 +23,56 @@ type Handler struct {
 	// FallbackHandlerFunc is called when REST layer doesn't find a route for
 	// the request. If not set, a 404 or 405 standard REST error is returned.
 	FallbackHandlerFunc func(ctx context.Context, w http.ResponseWriter, r *http.Request)
+	// Metrics, if set, records the status and duration of each served
+	// request.
+	Metrics HTTPMetrics
+	// TimeoutHeader is the request header a client can use to request a
+	// deadline shorter than the timeout configured for the resource and mode
+	// (see resource.Conf.Timeout). Its value is a number of seconds or a
+	// duration (i.e.: 1.5 or 1500ms). Set to an empty string to ignore client
+	// timeouts.
+	TimeoutHeader string
+	// Codecs lists the formats the request and response bodies can be
+	// encoded with, negotiated with the Accept and Content-Type headers. If
+	// nil, only JSON is supported.
+	Codecs *Codecs
+	// BatchPath is the path of the batch endpoint (i.e.: /_batch), executing
+	// the sub-requests POSTed to it as a JSON array in a single call. If
+	// empty, the batch endpoint is disabled.
+	BatchPath string
+	// BatchLimit is the maximum number of sub-requests of a batch, 100 by
+	// default. If zero, the number of sub-requests is not limited.
+	BatchLimit int
+	// IdempotencyStore, if set, stores the responses of the POST, PATCH and
+	// item command requests sent with an Idempotency-Key header. A retry
+	// with the same key replays the stored response instead of executing
+	// the request again.
+	IdempotencyStore IdempotencyStore
+	// IdempotencyTTL is the time the responses are kept in the
+	// IdempotencyStore, 24 hours by default.
+	IdempotencyTTL time.Duration
+	// IdempotencyLockTTL is the time a key stays locked while its request is
+	// in progress, 1 minute by default. The lock is released as soon as the
+	// request fails, and expires after this delay if the server crashes. It
+	// should exceed the longest request timeout.
+	IdempotencyLockTTL time.Duration
+	// IdempotencyScope, if set, returns the scope of the Idempotency-Key of
+	// a request, usually the authenticated client, so a key only replays the
+	// responses of the same scope. When nil, keys are global: clients must
+	// not be able to guess each others' keys.
+	IdempotencyScope func(ctx context.Context, r *http.Request) string
 	// index stores the resource router.
 	index resource.Index
 }
 
+// HTTPMetrics records the outcome of the requests served by a Handler.
+type HTTPMetrics interface {
+	// ObserveRequest is called after each request with the request method, the
+	// path of the routed resource (empty if no resource matched), the response
+	// status and the time it took to serve the request.
+	ObserveRequest(ctx context.Context, method, resource string, status int, duration time.Duration)
+}
+
 type methodHandler func(ctx context.Context, r *http.Request, route *RouteMatch) (int, http.Header, interface{})
 
 // NewHandler creates an new REST API HTTP handler with the specified resource
@@ -33,9 +84,14 @@ func NewHandler(i resource.Index) (*Handler, error) {
 		}
 	}
 	h := &Handler{
-		ResponseFormatter: DefaultResponseFormatter{},
-		ResponseSender:    DefaultResponseSender{},
-		index:             i,
+		ResponseFormatter:  DefaultResponseFormatter{},
+		ResponseSender:     DefaultResponseSender{},
+		TimeoutHeader:      "X-Request-Timeout",
+		Codecs:             NewCodecs(),
+		BatchLimit:         100,
+		IdempotencyTTL:     24 * time.Hour,
+		IdempotencyLockTTL: time.Minute,
+		index:              i,
 	}
 	return h, nil
 }
@@ -48,6 +104,49 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 // ServeHTTPC handles requests as a xhandler.HandlerC (deprecated).
 func (h *Handler) ServeHTTPC(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	var span trace.Span
+	var sw *statusWriter
+	if h.Metrics != nil || trace.Enabled() {
+		sw = &statusWriter{ResponseWriter: w}
+		w = sw
+		if trace.Enabled() {
+			ctx, span = trace.Start(ctx, "rest.ServeHTTP",
+				trace.Attr("http.method", r.Method),
+				trace.Attr("http.target", r.URL.Path))
+		}
+		defer func(t time.Time) {
+			status := sw.Status()
+			if h.Metrics != nil {
+				h.Metrics.ObserveRequest(ctx, r.Method, sw.resource, status, time.Since(t))
+			}
+			if span != nil {
+				span.SetAttributes(trace.Attr("http.status_code", status))
+				var err error
+				if status >= 500 {
+					err = errors.New(http.StatusText(status))
+				}
+				span.End(err)
+			}
+		}(time.Now())
+	}
+	if id := requestID(r); id != "" {
+		ctx = resource.WithLogFields(ctx, map[string]interface{}{"request_id": id})
+	}
+	if h.Codecs != nil {
+		ctx = contextWithCodecs(ctx, h.Codecs)
+	}
+	if h.IdempotencyStore != nil && r.Header.Get("Idempotency-Key") != "" && h.needsIdempotency(r) {
+		var done func()
+		var ok bool
+		if w, done, ok = h.startIdempotent(ctx, w, r); !ok {
+			return
+		}
+		defer done()
+	}
+	if h.BatchPath != "" && r.URL.Path == h.BatchPath {
+		h.serveBatch(ctx, w, r)
+		return
+	}
 	// Skip body if method is HEAD
 	skipBody := r.Method == "HEAD"
 	route, err := FindRoute(h.index, r)
@@ -55,11 +154,41 @@ func (h *Handler) ServeHTTPC(ctx context.Context, w http.ResponseWriter, r *http
 		if h.FallbackHandlerFunc != nil {
 			h.FallbackHandlerFunc(ctx, w, r)
 		} else {
+			// Use the requested format if possible, JSON otherwise.
+			ctx, _ = h.negotiateEncoder(ctx, r, false)
 			h.sendResponse(ctx, w, 0, http.Header{}, err, skipBody)
 		}
 		return
 	}
 	defer route.Release()
+	if sw != nil {
+		sw.resource = route.ResourcePath.Path()
+	}
+	if span != nil {
+		span.SetAttributes(
+			trace.Attr(trace.AttrResource, route.ResourcePath.Path()),
+			trace.Attr(trace.AttrMode, routeMode(route).String()))
+	}
+	// Negotiate the format of the response. List only formats are only
+	// considered for collection reads.
+	isList := (r.Method == http.MethodGet || r.Method == http.MethodHead) && route.ResourceID() == nil
+	ctx, e := h.negotiateEncoder(ctx, r, isList)
+	if e != nil {
+		h.sendResponse(ctx, w, 0, http.Header{}, e, skipBody)
+		return
+	}
+	// Apply the request deadline, shared by all the resource operations and
+	// sub-requests of the request.
+	timeout, err := h.requestTimeout(r, route)
+	if err != nil {
+		h.sendResponse(ctx, w, 0, http.Header{}, err, skipBody)
+		return
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
 	// Store the route and the router in the context
 	ctx = contextWithRoute(ctx, route)
 	ctx = contextWithIndex(ctx, h.index)
@@ -69,6 +198,11 @@ func (h *Handler) ServeHTTPC(ctx context.Context, w http.ResponseWriter, r *http
 	if headers == nil {
 		headers = http.Header{}
 	}
+	if _, isErr := body.(error); isErr && timeout > 0 && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		// Whatever the error returned, the request failed because it
+		// exceeded its deadline.
+		status, body = http.StatusGatewayTimeout, &Error{http.StatusGatewayTimeout, fmt.Sprintf("Deadline Exceeded: request timeout of %s reached", timeout), nil}
+	}
 	if h.FallbackHandlerFunc != nil && (body == errResourceNotFound || body == ErrInvalidMethod) {
 		h.FallbackHandlerFunc(ctx, w, r)
 		return
@@ -87,6 +221,9 @@ func routeHandler(ctx context.Context, r *http.Request, route *RouteMatch) (stat
 	if rsrc == nil {
 		return http.StatusNotFound, nil, errResourceNotFound
 	}
+	if route.CommandName() != "" {
+		return routeCommand(ctx, r, route)
+	}
 	conf := rsrc.Conf()
 	isItem := route.ResourceID() != nil
 	mh := getAllowedMethodHandler(isItem, route.Method, conf)
@@ -104,6 +241,77 @@ func (h *Handler) sendResponse(ctx context.Context, w http.ResponseWriter, statu
 	h.ResponseSender.Send(ctx, w, status, headers, body)
 }
 
+// requestID returns the id of the request found in the X-Request-Id or
+// Request-Id header if any.
+func requestID(r *http.Request) string {
+	if id := r.Header.Get("X-Request-Id"); id != "" {
+		return id
+	}
+	return r.Header.Get("Request-Id")
+}
+
+// routeMode returns the resource.Mode matching the route's method. PUT
+// requests are considered as Replace.
+func routeMode(route *RouteMatch) resource.Mode {
+	isItem := route.ResourceID() != nil
+	switch route.Method {
+	case http.MethodPost:
+		return resource.Create
+	case http.MethodPut:
+		return resource.Replace
+	case http.MethodPatch:
+		return resource.Update
+	case http.MethodDelete:
+		if isItem {
+			return resource.Delete
+		}
+		return resource.Clear
+	}
+	if isItem {
+		return resource.Read
+	}
+	return resource.List
+}
+
+// requestTimeout returns the timeout of the request: the timeout of the
+// route's resource for the request mode, shortened by the timeout requested by
+// the client if any.
+func (h *Handler) requestTimeout(r *http.Request, route *RouteMatch) (time.Duration, error) {
+	var timeout time.Duration
+	if rsrc := route.Resource(); rsrc != nil {
+		timeout = rsrc.Conf().ModeTimeout(routeMode(route))
+	}
+	if h.TimeoutHeader == "" {
+		return timeout, nil
+	}
+	v := r.Header.Get(h.TimeoutHeader)
+	if v == "" {
+		return timeout, nil
+	}
+	client, err := parseTimeout(v)
+	if err != nil {
+		return 0, &Error{http.StatusBadRequest, fmt.Sprintf("Invalid %s header: %v", h.TimeoutHeader, err), nil}
+	}
+	if timeout == 0 || client < timeout {
+		timeout = client
+	}
+	return timeout, nil
+}
+
+// parseTimeout parses a positive number of seconds or a duration.
+func parseTimeout(v string) (time.Duration, error) {
+	var d time.Duration
+	if secs, err := strconv.ParseFloat(v, 64); err == nil {
+		d = time.Duration(secs * float64(time.Second))
+	} else if d, err = time.ParseDuration(v); err != nil {
+		return 0, errors.New("not a number of seconds or a duration")
+	}
+	if d <= 0 {
+		return 0, errors.New("must be positive")
+	}
+	return d, nil
+}
+
 func isNoContent(r *http.Request) bool {
 	if pr := r.Header.Get("Prefer"); pr != "" {
 		items := strings.SplitN(pr, ";", -1)
@@ -120,3 +328,41 @@ func isNoContent(r *http.Request) bool {
 	}
 	return false
 }
+
+// statusWriter captures the status of a response for metrics and tracing.
+type statusWriter struct {
+	http.ResponseWriter
+	status   int
+	resource string
+}
+
+// WriteHeader implements http.ResponseWriter interface.
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write implements http.ResponseWriter interface.
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher interface.
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Status returns the status sent, or 200 if none was explicitly sent.
+func (w *statusWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
@@ -6,10 +6,12 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/rs/rest-layer/resource"
 	"github.com/rs/rest-layer/resource/testing/mem"
 	"github.com/rs/rest-layer/schema"
+	"github.com/rs/rest-layer/trace"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -160,3 +162,103 @@ func TestHandlerServeHTTPGetNotFoundItem(t *testing.T) {
 	b, _ := ioutil.ReadAll(w.Body)
 	assert.Equal(t, "{\"code\":404,\"message\":\"Not Found\"}", string(b))
 }
+
+func TestHandlerTracing(t *testing.T) {
+	rec := &trace.Recorder{}
+	trace.DefaultTracer = rec
+	defer func() { trace.DefaultTracer = nil }()
+
+	i := resource.NewIndex()
+	s := mem.NewHandler()
+	users := i.Bind("users", schema.Schema{Fields: schema.Fields{"id": {}}}, s, resource.DefaultConf)
+	posts := i.Bind("posts", schema.Schema{Fields: schema.Fields{
+		"id":   {},
+		"user": {Validator: &schema.Reference{Path: "users"}},
+	}}, s, resource.DefaultConf)
+	ctx := context.Background()
+	users.Insert(ctx, []*resource.Item{{ID: "u1", ETag: "a", Payload: map[string]interface{}{"id": "u1"}}})
+	posts.Insert(ctx, []*resource.Item{{ID: "p1", ETag: "b", Payload: map[string]interface{}{"id": "p1", "user": "u1"}}})
+	h, err := NewHandler(i)
+	if !assert.NoError(t, err) {
+		return
+	}
+	rec.Reset()
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/posts/p1?fields=id,user{id}", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	spans := rec.Spans()
+	if !assert.NotEmpty(t, spans) {
+		return
+	}
+	root := spans[len(spans)-1]
+	assert.Equal(t, "rest.ServeHTTP", root.Name)
+	assert.Equal(t, "posts", root.Attributes[trace.AttrResource])
+	assert.Equal(t, "read", root.Attributes[trace.AttrMode])
+	assert.Equal(t, http.StatusOK, root.Attributes["http.status_code"])
+	names := rec.Names()
+	assert.Contains(t, names, "resource.get")
+	assert.Contains(t, names, "projection.MultiGet")
+	for _, s := range spans {
+		if s.Name == "projection.MultiGet" {
+			assert.Equal(t, "users", s.Attributes[trace.AttrResource])
+			assert.Equal(t, root, s.Parent)
+		}
+	}
+}
+
+func TestHandlerRequestIDLogField(t *testing.T) {
+	var fields []map[string]interface{}
+	logger, level := resource.Logger, resource.LoggerLevel
+	resource.Logger = func(ctx context.Context, level resource.LogLevel, msg string, f map[string]interface{}) {
+		fields = append(fields, f)
+	}
+	resource.LoggerLevel = resource.LogLevelDebug
+	defer func() { resource.Logger, resource.LoggerLevel = logger, level }()
+
+	i := resource.NewIndex()
+	i.Bind("users", schema.Schema{Fields: schema.Fields{"id": {}}}, mem.NewHandler(), resource.DefaultConf)
+	h, _ := NewHandler(i)
+	r := httptest.NewRequest("GET", "/users", nil)
+	r.Header.Set("X-Request-Id", "abc")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	if assert.NotEmpty(t, fields) {
+		assert.Equal(t, "abc", fields[0]["request_id"])
+		assert.Equal(t, "users", fields[0]["resource"])
+	}
+}
+
+func TestHandlerTimeout(t *testing.T) {
+	i := resource.NewIndex()
+	conf := resource.DefaultConf
+	conf.Timeout = time.Second
+	i.Bind("users", schema.Schema{Fields: schema.Fields{"id": {}}}, mem.NewSlowHandler(50*time.Millisecond), conf)
+	h, _ := NewHandler(i)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/users", nil)
+	r.Header.Set("X-Request-Timeout", "10ms")
+	h.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+	assert.Contains(t, w.Body.String(), "Deadline Exceeded: request timeout of 10ms reached")
+
+	// The client can't extend the server timeout.
+	conf.Timeout = 10 * time.Millisecond
+	i.Bind("slow", schema.Schema{Fields: schema.Fields{"id": {}}}, mem.NewSlowHandler(50*time.Millisecond), conf)
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", "/slow", nil)
+	r.Header.Set("X-Request-Timeout", "1")
+	h.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", "/users", nil)
+	r.Header.Set("X-Request-Timeout", "soon")
+	h.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/users", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
@@ -0,0 +1,276 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrRequestInProgress is returned by IdempotencyStore.Lock when a request
+// with the same idempotency key is in progress.
+var ErrRequestInProgress = errors.New("request in progress")
+
+// IdempotencyStore stores the responses of the requests sent with an
+// Idempotency-Key header, so the retries of a request replay its response.
+type IdempotencyStore interface {
+	// Lock marks the request with key as in progress until it is saved or
+	// unlocked, or until ttl expires. If a response is stored for key, it is
+	// returned instead. If a request with key is in progress,
+	// ErrRequestInProgress is returned.
+	Lock(ctx context.Context, key string, ttl time.Duration) (*StoredResponse, error)
+	// Save stores the response of the request with key for ttl, releasing
+	// the lock on key.
+	Save(ctx context.Context, key string, res *StoredResponse, ttl time.Duration) error
+	// Unlock releases the lock on key without storing a response, so the
+	// request can be retried.
+	Unlock(ctx context.Context, key string) error
+}
+
+// StoredResponse is a response stored in an IdempotencyStore.
+type StoredResponse struct {
+	// Fingerprint is the hash of the method, the URL and the body of the
+	// request the response was sent for.
+	Fingerprint string
+	// Status is the status of the response.
+	Status int
+	// Header holds the headers of the response.
+	Header http.Header
+	// Body is the encoded body of the response.
+	Body []byte
+}
+
+// MemoryIdempotencyStore is an IdempotencyStore keeping the responses in
+// memory. It is suitable for tests and single instance deployments.
+type MemoryIdempotencyStore struct {
+	mu        sync.Mutex
+	entries   map[string]memoryIdempotencyEntry
+	lastSweep time.Time
+}
+
+// memoryIdempotencyEntry is a stored response, or a lock if res is nil.
+type memoryIdempotencyEntry struct {
+	res     *StoredResponse
+	expires time.Time
+}
+
+// NewMemoryIdempotencyStore creates an empty in-memory idempotency store.
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{
+		entries:   map[string]memoryIdempotencyEntry{},
+		lastSweep: time.Now(),
+	}
+}
+
+// Lock implements the IdempotencyStore interface.
+func (s *MemoryIdempotencyStore) Lock(ctx context.Context, key string, ttl time.Duration) (*StoredResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if now.Sub(s.lastSweep) > time.Minute {
+		// Evict the expired entries from time to time.
+		for k, e := range s.entries {
+			if now.After(e.expires) {
+				delete(s.entries, k)
+			}
+		}
+		s.lastSweep = now
+	}
+	if e, found := s.entries[key]; found && now.Before(e.expires) {
+		if e.res == nil {
+			return nil, ErrRequestInProgress
+		}
+		return e.res, nil
+	}
+	s.entries[key] = memoryIdempotencyEntry{expires: now.Add(ttl)}
+	return nil, nil
+}
+
+// Save implements the IdempotencyStore interface.
+func (s *MemoryIdempotencyStore) Save(ctx context.Context, key string, res *StoredResponse, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[key] = memoryIdempotencyEntry{res: res, expires: time.Now().Add(ttl)}
+	return nil
+}
+
+// Unlock implements the IdempotencyStore interface.
+func (s *MemoryIdempotencyStore) Unlock(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, found := s.entries[key]; found && e.res == nil {
+		delete(s.entries, key)
+	}
+	return nil
+}
+
+// needsIdempotency returns true if r is a non-idempotent request supporting
+// the Idempotency-Key header: POST, PATCH and item commands.
+func (h *Handler) needsIdempotency(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodPost, http.MethodPatch:
+		return true
+	case http.MethodPut:
+		route, err := FindRoute(h.index, r)
+		if err != nil {
+			return false
+		}
+		defer route.Release()
+		return route.CommandName() != ""
+	}
+	return false
+}
+
+// startIdempotent locks the Idempotency-Key of r. If the request was already
+// served, its response is replayed and ok is false. Otherwise, the returned
+// writer records the response, stored by calling done once it is sent.
+//
+// The request body is hashed as it is read, so it is never held in memory.
+func (h *Handler) startIdempotent(ctx context.Context, w http.ResponseWriter, r *http.Request) (rw http.ResponseWriter, done func(), ok bool) {
+	key := idempotencyKey(ctx, r, h.IdempotencyScope)
+	fail := func(err error) (http.ResponseWriter, func(), bool) {
+		ctx, _ := h.negotiateEncoder(ctx, r, false)
+		h.sendResponse(ctx, w, 0, http.Header{}, err, false)
+		return nil, nil, false
+	}
+	body := newHashingBody(r)
+	res, err := h.IdempotencyStore.Lock(ctx, key, h.IdempotencyLockTTL)
+	switch {
+	case errors.Is(err, ErrRequestInProgress):
+		return fail(&Error{http.StatusConflict, "A request with the same Idempotency-Key is in progress", nil})
+	case err != nil:
+		return fail(err)
+	case res != nil:
+		fingerprint, err := body.fingerprint()
+		if err != nil {
+			return fail(&Error{400, "Malformed body: " + err.Error(), nil})
+		}
+		if res.Fingerprint != fingerprint {
+			return fail(&Error{http.StatusUnprocessableEntity, "Idempotency-Key already used for a different request", nil})
+		}
+		for k, v := range res.Header {
+			w.Header()[k] = v
+		}
+		w.Header().Set("Idempotent-Replayed", "true")
+		w.WriteHeader(res.Status)
+		w.Write(res.Body)
+		return nil, nil, false
+	}
+	r.Body = body
+	rec := &recordWriter{ResponseWriter: w}
+	return rec, func() {
+		// The response must be stored even if the client is gone.
+		ctx := context.WithoutCancel(ctx)
+		fingerprint, err := body.fingerprint()
+		if err != nil || rec.status == 0 || rec.status >= 500 {
+			// Server errors may not happen again: let the client retry.
+			if err := h.IdempotencyStore.Unlock(ctx, key); err != nil {
+				logErrorf(ctx, "cannot unlock Idempotency-Key %s: %v", key, err)
+			}
+			return
+		}
+		res := &StoredResponse{
+			Fingerprint: fingerprint,
+			Status:      rec.status,
+			Header:      rec.header,
+			Body:        rec.body.Bytes(),
+		}
+		if err := h.IdempotencyStore.Save(ctx, key, res, h.IdempotencyTTL); err != nil {
+			logErrorf(ctx, "cannot store response of Idempotency-Key %s: %v", key, err)
+		}
+	}, true
+}
+
+// idempotencyKey returns the store key of the Idempotency-Key of r, prefixed
+// by the scope of the request if any.
+func idempotencyKey(ctx context.Context, r *http.Request, scope func(ctx context.Context, r *http.Request) string) string {
+	key := r.Header.Get("Idempotency-Key")
+	if scope != nil {
+		// Header values can't contain a new line, so scopes can't collide.
+		key = scope(ctx, r) + "\n" + key
+	}
+	return key
+}
+
+// hashingBody wraps a request body to hash it as it is read. The hash covers
+// the method and the URL of the request, then the body.
+type hashingBody struct {
+	body   io.ReadCloser
+	hash   hash.Hash
+	err    error
+	closed bool
+}
+
+func newHashingBody(r *http.Request) *hashingBody {
+	b := &hashingBody{body: r.Body, hash: sha256.New()}
+	io.WriteString(b.hash, r.Method+" "+r.URL.RequestURI()+"\n")
+	if b.body == nil {
+		b.body = http.NoBody
+	}
+	return b
+}
+
+// Read implements the io.Reader interface.
+func (b *hashingBody) Read(p []byte) (int, error) {
+	n, err := b.body.Read(p)
+	b.hash.Write(p[:n])
+	if err != nil && err != io.EOF {
+		b.err = err
+	}
+	return n, err
+}
+
+// Close implements the io.Closer interface. The unread part of the body is
+// hashed before closing it.
+func (b *hashingBody) Close() error {
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+	if _, err := io.Copy(b.hash, b.body); err != nil && b.err == nil {
+		b.err = err
+	}
+	return b.body.Close()
+}
+
+// fingerprint closes the body and returns the hash of the request.
+func (b *hashingBody) fingerprint() (string, error) {
+	b.Close()
+	if b.err != nil {
+		return "", b.err
+	}
+	return hex.EncodeToString(b.hash.Sum(nil)), nil
+}
+
+// recordWriter records the response written to the wrapped ResponseWriter.
+// Only the response is buffered, which for NDJSON imports is the summary.
+type recordWriter struct {
+	http.ResponseWriter
+	status int
+	header http.Header
+	body   bytes.Buffer
+}
+
+// WriteHeader implements http.ResponseWriter interface.
+func (w *recordWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+		w.header = w.Header().Clone()
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write implements http.ResponseWriter interface.
+func (w *recordWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
@@ -0,0 +1,168 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/rest-layer/schema/query"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdempotency(t *testing.T) {
+	h, users, _ := newBatchTestHandler(t)
+	store := NewMemoryIdempotencyStore()
+	h.IdempotencyStore = store
+	send := func(method, target, key, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		if key != "" {
+			r.Header.Set("Idempotency-Key", key)
+		}
+		h.ServeHTTP(w, r)
+		return w
+	}
+	count := func() int {
+		l, _ := users.Find(context.Background(), &query.Query{})
+		return len(l.Items)
+	}
+
+	w := send("POST", "/users", "k1", `{"name": "Bob"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "", w.Header().Get("Idempotent-Replayed"))
+	first := w.Body.String()
+	etag := w.Header().Get("Etag")
+
+	t.Run("Replay", func(t *testing.T) {
+		w := send("POST", "/users", "k1", `{"name": "Bob"}`)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, "true", w.Header().Get("Idempotent-Replayed"))
+		assert.Equal(t, etag, w.Header().Get("Etag"))
+		assert.Equal(t, first, w.Body.String())
+		assert.Equal(t, 3, count())
+	})
+	t.Run("DifferentPayload", func(t *testing.T) {
+		w := send("POST", "/users", "k1", `{"name": "Alice"}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		assert.JSONEq(t, `{"code": 422, "message": "Idempotency-Key already used for a different request"}`, w.Body.String())
+		assert.Equal(t, 3, count())
+	})
+	t.Run("InProgress", func(t *testing.T) {
+		store.Lock(context.Background(), "k2", time.Minute)
+		defer store.Unlock(context.Background(), "k2")
+		w := send("POST", "/users", "k2", `{"name": "Bob"}`)
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.JSONEq(t, `{"code": 409, "message": "A request with the same Idempotency-Key is in progress"}`, w.Body.String())
+		assert.Equal(t, 3, count())
+	})
+	t.Run("NoKey", func(t *testing.T) {
+		send("POST", "/users", "", `{"name": "Bob"}`)
+		send("POST", "/users", "", `{"name": "Bob"}`)
+		assert.Equal(t, 5, count())
+	})
+	t.Run("Command", func(t *testing.T) {
+		w := send("PUT", "/users/1/notify", "k3", `{}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+		w = send("PUT", "/users/1/notify", "k3", `{}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "true", w.Header().Get("Idempotent-Replayed"))
+	})
+	t.Run("NotCovered", func(t *testing.T) {
+		send("PUT", "/users/3", "k4", `{"name": "Carl"}`)
+		_, err := store.Lock(context.Background(), "k4", time.Minute)
+		assert.NoError(t, err)
+	})
+	t.Run("ClientErrorStored", func(t *testing.T) {
+		w := send("POST", "/users/missing/posts", "k5", `{"title": "a"}`)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		w = send("POST", "/users/missing/posts", "k5", `{"title": "a"}`)
+		assert.Equal(t, "true", w.Header().Get("Idempotent-Replayed"))
+	})
+}
+
+// lockTTLStore records the TTL of the locks.
+type lockTTLStore struct {
+	*MemoryIdempotencyStore
+	ttls []time.Duration
+}
+
+func (s *lockTTLStore) Lock(ctx context.Context, key string, ttl time.Duration) (*StoredResponse, error) {
+	s.ttls = append(s.ttls, ttl)
+	return s.MemoryIdempotencyStore.Lock(ctx, key, ttl)
+}
+
+func TestIdempotencyOptions(t *testing.T) {
+	h, users, _ := newBatchTestHandler(t)
+	store := &lockTTLStore{MemoryIdempotencyStore: NewMemoryIdempotencyStore()}
+	h.IdempotencyStore = store
+	h.IdempotencyScope = func(ctx context.Context, r *http.Request) string {
+		return r.Header.Get("X-Client")
+	}
+	send := func(client, contentType, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("POST", "/users", strings.NewReader(body))
+		r.Header.Set("Idempotency-Key", "k1")
+		r.Header.Set("X-Client", client)
+		if contentType != "" {
+			r.Header.Set("Content-Type", contentType)
+		}
+		h.ServeHTTP(w, r)
+		return w
+	}
+	count := func() int {
+		l, _ := users.Find(context.Background(), &query.Query{})
+		return len(l.Items)
+	}
+
+	t.Run("LockTTL", func(t *testing.T) {
+		assert.Equal(t, http.StatusCreated, send("a", "", `{"name": "Bob"}`).Code)
+		assert.Equal(t, []time.Duration{time.Minute}, store.ttls)
+	})
+	t.Run("Scope", func(t *testing.T) {
+		// The same key used by another client is another request.
+		w := send("b", "", `{"name": "Alice"}`)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, "", w.Header().Get("Idempotent-Replayed"))
+		assert.Equal(t, 4, count())
+		assert.Equal(t, "true", send("a", "", `{"name": "Bob"}`).Header().Get("Idempotent-Replayed"))
+	})
+	t.Run("NDJSON", func(t *testing.T) {
+		body := "{\"name\": \"Carl\"}\n{\"name\": \"Dan\"}\n"
+		w := send("c", "application/x-ndjson", body)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"accepted": 2, "rejected": 0, "errors": []}`, w.Body.String())
+		w = send("c", "application/x-ndjson", body)
+		assert.Equal(t, "true", w.Header().Get("Idempotent-Replayed"))
+		assert.Equal(t, 6, count())
+		w = send("c", "application/x-ndjson", body+"{\"name\": \"Eve\"}\n")
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	})
+}
+
+func TestMemoryIdempotencyStore(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryIdempotencyStore()
+	res, err := s.Lock(ctx, "a", time.Minute)
+	assert.NoError(t, err)
+	assert.Nil(t, res)
+	_, err = s.Lock(ctx, "a", time.Minute)
+	assert.Equal(t, ErrRequestInProgress, err)
+	assert.NoError(t, s.Unlock(ctx, "a"))
+	_, err = s.Lock(ctx, "a", time.Minute)
+	assert.NoError(t, err)
+
+	stored := &StoredResponse{Fingerprint: "f", Status: 201}
+	assert.NoError(t, s.Save(ctx, "a", stored, time.Minute))
+	assert.NoError(t, s.Unlock(ctx, "a"), "unlock keeps the stored response")
+	res, err = s.Lock(ctx, "a", time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, stored, res)
+
+	assert.NoError(t, s.Save(ctx, "b", stored, -time.Second))
+	res, err = s.Lock(ctx, "b", time.Minute)
+	assert.NoError(t, err)
+	assert.Nil(t, res, "expired responses are ignored")
+}
@@ -0,0 +1,114 @@
+package jsonapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/rs/rest-layer/rest"
+)
+
+// MediaType is the media type of JSON:API documents.
+const MediaType = "application/vnd.api+json"
+
+// Codec encodes JSON:API documents and decodes the resource object of request
+// bodies into item payloads.
+type Codec struct{}
+
+// MediaType implements rest.Encoder and rest.Decoder interfaces.
+func (Codec) MediaType() string {
+	return MediaType
+}
+
+// Encode implements rest.Encoder interface.
+func (Codec) Encode(ctx context.Context, w io.Writer, v interface{}) error {
+	return rest.JSONCodec{}.Encode(ctx, w, v)
+}
+
+// Decode implements rest.Decoder interface. The attributes of the primary
+// data become the fields of the payload, the id its id field and the
+// relationships are replaced by the ids of the linked resources. The type of
+// the resource object isn't checked.
+func (Codec) Decode(r io.Reader, v *map[string]interface{}) error {
+	var doc struct {
+		Data *struct {
+			ID            interface{}                `json:"id"`
+			Attributes    map[string]interface{}     `json:"attributes"`
+			Relationships map[string]json.RawMessage `json:"relationships"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return err
+	}
+	if doc.Data == nil {
+		return errors.New("missing primary data")
+	}
+	payload := make(map[string]interface{}, len(doc.Data.Attributes)+len(doc.Data.Relationships)+1)
+	for k, val := range doc.Data.Attributes {
+		payload[k] = val
+	}
+	if doc.Data.ID != nil {
+		payload["id"] = doc.Data.ID
+	}
+	for k, raw := range doc.Data.Relationships {
+		var rel struct {
+			Data json.RawMessage `json:"data"`
+		}
+		if err := json.Unmarshal(raw, &rel); err != nil {
+			return fmt.Errorf("relationship %s: %v", k, err)
+		}
+		if rel.Data == nil {
+			// Relationship without linkage (i.e.: links only).
+			continue
+		}
+		id, err := decodeLinkage(rel.Data)
+		if err != nil {
+			return fmt.Errorf("relationship %s: %v", k, err)
+		}
+		payload[k] = id
+	}
+	*v = payload
+	return nil
+}
+
+// decodeLinkage returns the id or the list of ids of the resource linkage
+// data.
+func decodeLinkage(data json.RawMessage) (interface{}, error) {
+	type identifier struct {
+		ID interface{} `json:"id"`
+	}
+	data = bytes.TrimSpace(data)
+	switch {
+	case bytes.Equal(data, []byte("null")):
+		return nil, nil
+	case len(data) > 0 && data[0] == '[':
+		var l []identifier
+		if err := json.Unmarshal(data, &l); err != nil {
+			return nil, err
+		}
+		ids := make([]interface{}, 0, len(l))
+		for _, i := range l {
+			ids = append(ids, i.ID)
+		}
+		return ids, nil
+	}
+	var i identifier
+	if err := json.Unmarshal(data, &i); err != nil {
+		return nil, err
+	}
+	return i.ID, nil
+}
+
+// Configure sets api up to render JSON:API documents with Formatter and to
+// encode and decode bodies with Codec by default.
+func Configure(api *rest.Handler) {
+	api.ResponseFormatter = Formatter{}
+	if api.Codecs == nil {
+		api.Codecs = rest.NewCodecs()
+	}
+	api.Codecs.Register(Codec{})
+	api.Codecs.SetDefault(MediaType)
+}
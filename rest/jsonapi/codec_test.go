@@ -0,0 +1,69 @@
+package jsonapi
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCodecDecode(t *testing.T) {
+	cases := []struct {
+		body    string
+		payload map[string]interface{}
+		err     string
+	}{
+		{
+			`{"data": {"type": "posts", "id": "c", "attributes": {"title": "Third"}, "relationships": {
+				"user": {"data": {"type": "users", "id": "1"}},
+				"reviewers": {"data": [{"type": "users", "id": "1"}, {"type": "users", "id": "2"}]},
+				"editor": {"data": null},
+				"comments": {"links": {"related": "/posts/c/comments"}}
+			}}}`,
+			map[string]interface{}{
+				"id":        "c",
+				"title":     "Third",
+				"user":      "1",
+				"reviewers": []interface{}{"1", "2"},
+				"editor":    nil,
+			},
+			"",
+		},
+		{`{"data": {"type": "posts"}}`, map[string]interface{}{}, ""},
+		{`{"title": "Third"}`, nil, "missing primary data"},
+		{`{"data": {"relationships": {"user": {"data": 1}}}}`, nil, "relationship user: json: cannot unmarshal number into Go value of type jsonapi.identifier"},
+		{`{"data"`, nil, "unexpected EOF"},
+	}
+	for _, tc := range cases {
+		var payload map[string]interface{}
+		err := Codec{}.Decode(strings.NewReader(tc.body), &payload)
+		if tc.err != "" {
+			assert.EqualError(t, err, tc.err, tc.body)
+			continue
+		}
+		if assert.NoError(t, err, tc.body) {
+			assert.Equal(t, tc.payload, payload, tc.body)
+		}
+	}
+}
+
+func TestCodecHandler(t *testing.T) {
+	h, _ := newTestHandler(t)
+	w := serve(h, "POST", "/posts", `{"data": {"type": "posts", "id": "c", "attributes": {"title": "Third"}, "relationships": {"user": {"data": {"type": "users", "id": "2"}}}}}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, MediaType, w.Header().Get("Content-Type"))
+
+	w = serve(h, "GET", "/users/2?include=posts&fields[posts]=title", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{
+		"data": {
+			"type": "users",
+			"id": "2",
+			"attributes": {"name": "Jane", "age": 25},
+			"relationships": {"posts": {"data": [{"type": "posts", "id": "c"}]}},
+			"meta": {"etag": "u2"}
+		},
+		"included": [{"type": "posts", "id": "c", "attributes": {"title": "Third"}}]
+	}`, w.Body.String())
+}
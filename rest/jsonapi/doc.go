@@ -0,0 +1,30 @@
+/*
+Package jsonapi makes a rest.Handler speak JSON:API (https://jsonapi.org).
+
+Items are rendered as resource objects, with their fields split between
+attributes and relationships. Fields validated by schema.Reference, arrays of
+references and schema.Connection fields become relationships, and the items
+they embed through the projection are moved to the top-level included member.
+Request bodies are read from the same resource objects.
+
+	api, err := rest.NewHandler(index)
+	if err != nil {
+		log.Fatal(err)
+	}
+	jsonapi.Configure(api)
+	http.Handle("/", jsonapi.Params(index, api))
+
+Params translates the JSON:API query parameters to the ones understood by the
+rest package: fields[type] and include become a fields projection,
+filter[field] an equality filter and page[number], page[size], page[offset] and
+page[limit] the page, limit and skip parameters. The sort parameter and the
+filter parameter holding a full predicate use the same syntax and are passed
+as is.
+
+Resource object types are the names of the resources and ids are sent as
+strings.
+
+This package is part of the rest-layer project. See http://rest-layer.io for
+full REST Layer documentation.
+*/
+package jsonapi
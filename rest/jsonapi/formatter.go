@@ -0,0 +1,315 @@
+package jsonapi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/rest"
+	"github.com/rs/rest-layer/schema"
+)
+
+// Formatter is a rest.ResponseFormatter producing JSON:API documents. It sets
+// the same headers as rest.DefaultResponseFormatter.
+type Formatter struct {
+	rest.DefaultResponseFormatter
+}
+
+// FormatItem implements rest.ResponseFormatter.
+func (f Formatter) FormatItem(ctx context.Context, headers http.Header, i *resource.Item, skipBody bool) (context.Context, interface{}) {
+	ctx, _ = f.DefaultResponseFormatter.FormatItem(ctx, headers, i, true)
+	if skipBody || i.Payload == nil {
+		return ctx, nil
+	}
+	d, rsc := newDocument(ctx)
+	d.markSeen(rsc, i.Payload)
+	return ctx, d.body(d.resourceObject(rsc, i.Payload, i.ETag), nil)
+}
+
+// FormatList implements rest.ResponseFormatter.
+func (f Formatter) FormatList(ctx context.Context, headers http.Header, l *resource.ItemList, skipBody bool) (context.Context, interface{}) {
+	ctx, _ = f.DefaultResponseFormatter.FormatList(ctx, headers, l, true)
+	if skipBody {
+		return ctx, nil
+	}
+	d, rsc := newDocument(ctx)
+	for _, item := range l.Items {
+		d.markSeen(rsc, item.Payload)
+	}
+	data := make([]interface{}, 0, len(l.Items))
+	for _, item := range l.Items {
+		data = append(data, d.resourceObject(rsc, item.Payload, item.ETag))
+	}
+	meta := map[string]interface{}{}
+	if l.Total >= 0 {
+		meta["total"] = l.Total
+	}
+	if l.Offset > 0 {
+		meta["offset"] = l.Offset
+	}
+	return ctx, d.body(data, meta)
+}
+
+// FormatError implements rest.ResponseFormatter. The issues of the error are
+// rendered as separate error objects, pointing at the faulty member of the
+// request body for mutations or at the faulty query parameter otherwise.
+func (f Formatter) FormatError(ctx context.Context, headers http.Header, err error, skipBody bool) (context.Context, interface{}) {
+	ctx, _ = f.DefaultResponseFormatter.FormatError(ctx, headers, err, true)
+	if skipBody {
+		return ctx, nil
+	}
+	code := 500
+	message := "Server Error"
+	var issues map[string][]interface{}
+	if err != nil {
+		message = err.Error()
+		if e, ok := err.(*rest.Error); ok {
+			code = e.Code
+			issues = e.Issues
+		}
+	}
+	status := strconv.Itoa(code)
+	errs := []interface{}{}
+	if len(issues) == 0 {
+		errs = append(errs, map[string]interface{}{
+			"status": status,
+			"title":  message,
+		})
+	}
+	fields := make([]string, 0, len(issues))
+	for field := range issues {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		source := errorSource(ctx, field)
+		for _, issue := range issues[field] {
+			errs = append(errs, map[string]interface{}{
+				"status": status,
+				"title":  message,
+				"detail": fmt.Sprint(issue),
+				"source": source,
+			})
+		}
+	}
+	return ctx, map[string]interface{}{"errors": errs}
+}
+
+// errorSource returns the source member of the error objects reporting an
+// issue on field.
+func errorSource(ctx context.Context, field string) map[string]interface{} {
+	route, ok := rest.RouteFromContext(ctx)
+	if !ok {
+		return map[string]interface{}{"parameter": field}
+	}
+	switch route.Method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+	default:
+		return map[string]interface{}{"parameter": field}
+	}
+	member := "attributes"
+	if rsc := route.Resource(); rsc != nil {
+		if def, found := rsc.Schema().Fields[field]; found && isRelationship(def) {
+			member = "relationships"
+		}
+	}
+	return map[string]interface{}{"pointer": "/data/" + member + "/" + strings.Replace(field, ".", "/", -1)}
+}
+
+// isRelationship returns true if the field links to other resources.
+func isRelationship(def schema.Field) bool {
+	switch v := def.Validator.(type) {
+	case *schema.Reference, *schema.Connection:
+		return true
+	case *schema.Array:
+		_, ok := v.Values.Validator.(*schema.Reference)
+		return ok
+	}
+	return false
+}
+
+// document accumulates the included resource objects of a response.
+type document struct {
+	index    resource.Index
+	included []interface{}
+	seen     map[string]bool
+}
+
+// newDocument returns a document for the response of the request in ctx and
+// the resource of its primary data.
+func newDocument(ctx context.Context) (*document, *resource.Resource) {
+	d := &document{seen: map[string]bool{}}
+	d.index, _ = rest.IndexFromContext(ctx)
+	var rsc *resource.Resource
+	if route, ok := rest.RouteFromContext(ctx); ok {
+		rsc = route.Resource()
+	}
+	return d, rsc
+}
+
+// body returns the top-level document.
+func (d *document) body(data interface{}, meta map[string]interface{}) map[string]interface{} {
+	doc := map[string]interface{}{"data": data}
+	if len(meta) > 0 {
+		doc["meta"] = meta
+	}
+	if len(d.included) > 0 {
+		doc["included"] = d.included
+	}
+	return doc
+}
+
+// markSeen records the item with payload as part of the document so it is
+// not included again.
+func (d *document) markSeen(rsc *resource.Resource, payload map[string]interface{}) {
+	d.seen[typeName(rsc)+"/"+formatID(payload["id"])] = true
+}
+
+// resourceObject returns the JSON:API resource object of an item of rsc.
+func (d *document) resourceObject(rsc *resource.Resource, payload map[string]interface{}, etag string) map[string]interface{} {
+	obj := map[string]interface{}{"type": typeName(rsc)}
+	if id, found := payload["id"]; found {
+		obj["id"] = formatID(id)
+	}
+	var fields schema.Fields
+	if rsc != nil {
+		fields = rsc.Schema().Fields
+	}
+	keys := make([]string, 0, len(payload))
+	for k := range payload {
+		keys = append(keys, k)
+	}
+	// Walk the fields in order so included items are in a stable order.
+	sort.Strings(keys)
+	attributes := map[string]interface{}{}
+	relationships := map[string]interface{}{}
+	for _, k := range keys {
+		if k == "id" {
+			continue
+		}
+		v := payload[k]
+		if def, found := fields[k]; found && isRelationship(def) {
+			relationships[k] = d.relationship(rsc, def, v)
+			continue
+		}
+		attributes[k] = v
+	}
+	if len(attributes) > 0 {
+		obj["attributes"] = attributes
+	}
+	if len(relationships) > 0 {
+		obj["relationships"] = relationships
+	}
+	if etag != "" {
+		obj["meta"] = map[string]interface{}{"etag": etag}
+	}
+	return obj
+}
+
+// relationship returns the relationship object of the v value of the def
+// field of an item of rsc.
+func (d *document) relationship(rsc *resource.Resource, def schema.Field, v interface{}) map[string]interface{} {
+	var data interface{}
+	switch val := def.Validator.(type) {
+	case *schema.Reference:
+		data = d.linkage(d.resource(val.Path, rsc), v)
+	case *schema.Connection:
+		data = d.linkages(d.resource(val.Path, rsc), v)
+	case *schema.Array:
+		ref := val.Values.Validator.(*schema.Reference)
+		data = d.linkages(d.resource(ref.Path, rsc), v)
+	}
+	return map[string]interface{}{"data": data}
+}
+
+// resource returns the resource at path, relative to parent if path starts
+// with a dot, or nil if not found.
+func (d *document) resource(path string, parent *resource.Resource) *resource.Resource {
+	if d.index == nil {
+		return nil
+	}
+	rsc, _ := d.index.GetResource(path, parent)
+	return rsc
+}
+
+// linkage returns the resource identifier of a to-one relationship. If the
+// projection embedded the linked item, it is added to the included items.
+func (d *document) linkage(rsc *resource.Resource, v interface{}) interface{} {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case map[string]interface{}:
+		d.include(rsc, v)
+		return identifier(rsc, v["id"])
+	}
+	return identifier(rsc, v)
+}
+
+// linkages returns the resource identifiers of a to-many relationship.
+func (d *document) linkages(rsc *resource.Resource, v interface{}) []interface{} {
+	ids := []interface{}{}
+	if p, ok := v.(*[]interface{}); ok && p != nil {
+		// Arrays of embedded references are set by the projection
+		// evaluator as pointers.
+		v = *p
+	}
+	switch v := v.(type) {
+	case []interface{}:
+		for _, e := range v {
+			if l := d.linkage(rsc, e); l != nil {
+				ids = append(ids, l)
+			}
+		}
+	case []map[string]interface{}:
+		for _, e := range v {
+			if l := d.linkage(rsc, e); l != nil {
+				ids = append(ids, l)
+			}
+		}
+	}
+	return ids
+}
+
+// include adds the item with payload to the included resource objects unless
+// it is already part of the document.
+func (d *document) include(rsc *resource.Resource, payload map[string]interface{}) {
+	key := typeName(rsc) + "/" + formatID(payload["id"])
+	if d.seen[key] {
+		return
+	}
+	d.seen[key] = true
+	d.included = append(d.included, d.resourceObject(rsc, payload, ""))
+}
+
+// identifier returns a resource identifier object.
+func identifier(rsc *resource.Resource, id interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"type": typeName(rsc),
+		"id":   formatID(id),
+	}
+}
+
+// typeName returns the JSON:API type of the items of rsc.
+func typeName(rsc *resource.Resource) string {
+	if rsc == nil {
+		return ""
+	}
+	return rsc.Name()
+}
+
+// formatID returns id as a string, as required by JSON:API.
+func formatID(id interface{}) string {
+	switch id := id.(type) {
+	case string:
+		return id
+	case float64:
+		return strconv.FormatFloat(id, 'f', -1, 64)
+	case nil:
+		return ""
+	}
+	return fmt.Sprint(id)
+}
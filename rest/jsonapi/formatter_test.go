@@ -0,0 +1,185 @@
+package jsonapi
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/resource/testing/mem"
+	"github.com/rs/rest-layer/rest"
+	"github.com/rs/rest-layer/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestHandler(t *testing.T) (http.Handler, resource.Index) {
+	users := mem.NewHandler()
+	users.Insert(context.Background(), []*resource.Item{
+		{ID: "1", ETag: "u1", Payload: map[string]interface{}{"id": "1", "name": "John", "age": 30}},
+		{ID: "2", ETag: "u2", Payload: map[string]interface{}{"id": "2", "name": "Jane", "age": 25}},
+	})
+	posts := mem.NewHandler()
+	posts.Insert(context.Background(), []*resource.Item{
+		{ID: "a", ETag: "pa", Payload: map[string]interface{}{"id": "a", "title": "First", "user": "1", "reviewers": []interface{}{"2"}}},
+		{ID: "b", ETag: "pb", Payload: map[string]interface{}{"id": "b", "title": "Second", "user": "1"}},
+	})
+	post := schema.Schema{Fields: schema.Fields{
+		"id":        {Sortable: true},
+		"title":     {Required: true},
+		"user":      {Filterable: true, Validator: &schema.Reference{Path: "users"}},
+		"reviewers": {Validator: &schema.Array{Values: schema.Field{Validator: &schema.Reference{Path: "users"}}}},
+	}}
+	index := resource.NewIndex()
+	index.Bind("users", schema.Schema{Fields: schema.Fields{
+		"id":    {Sortable: true, Filterable: true},
+		"name":  {Filterable: true},
+		"age":   {Filterable: true, Validator: &schema.Integer{}},
+		"posts": {Validator: &schema.Connection{Path: "posts", Field: "user", Validator: &post}},
+	}}, users, resource.Conf{AllowedModes: resource.ReadWrite})
+	index.Bind("posts", post, posts, resource.Conf{AllowedModes: resource.ReadWrite})
+	api, err := rest.NewHandler(index)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	Configure(api)
+	return Params(index, api), index
+}
+
+func serve(h http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	var r *http.Request
+	if body != "" {
+		r = httptest.NewRequest(method, target, strings.NewReader(body))
+	} else {
+		r = httptest.NewRequest(method, target, nil)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestFormatItem(t *testing.T) {
+	h, _ := newTestHandler(t)
+	w := serve(h, "GET", "/posts/a", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, MediaType, w.Header().Get("Content-Type"))
+	assert.Equal(t, `W/"pa"`, w.Header().Get("Etag"))
+	assert.JSONEq(t, `{
+		"data": {
+			"type": "posts",
+			"id": "a",
+			"attributes": {"title": "First"},
+			"relationships": {
+				"user": {"data": {"type": "users", "id": "1"}},
+				"reviewers": {"data": [{"type": "users", "id": "2"}]}
+			},
+			"meta": {"etag": "pa"}
+		}
+	}`, w.Body.String())
+
+	// Explicit JSON is still available, rendered as JSON:API.
+	r := httptest.NewRequest("HEAD", "/posts/a", nil)
+	r.Header.Set("Accept", "application/json")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.Equal(t, "", w.Body.String())
+}
+
+func TestFormatItemIncluded(t *testing.T) {
+	h, _ := newTestHandler(t)
+	w := serve(h, "GET", "/posts/a?include=user,reviewers&fields[posts]=title&fields[users]=name", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{
+		"data": {
+			"type": "posts",
+			"id": "a",
+			"attributes": {"title": "First"},
+			"relationships": {
+				"user": {"data": {"type": "users", "id": "1"}},
+				"reviewers": {"data": [{"type": "users", "id": "2"}]}
+			},
+			"meta": {"etag": "pa"}
+		},
+		"included": [
+			{"type": "users", "id": "2", "attributes": {"name": "Jane"}},
+			{"type": "users", "id": "1", "attributes": {"name": "John"}}
+		]
+	}`, w.Body.String())
+}
+
+func TestFormatList(t *testing.T) {
+	h, _ := newTestHandler(t)
+	w := serve(h, "GET", "/users?include=posts.user&fields[posts]=title,user&fields[users]=name&sort=id", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "2", w.Header().Get("X-Total"))
+	assert.JSONEq(t, `{
+		"data": [
+			{
+				"type": "users",
+				"id": "1",
+				"attributes": {"name": "John"},
+				"relationships": {
+					"posts": {"data": [{"type": "posts", "id": "a"}, {"type": "posts", "id": "b"}]}
+				},
+				"meta": {"etag": "u1"}
+			},
+			{
+				"type": "users",
+				"id": "2",
+				"attributes": {"name": "Jane"},
+				"relationships": {"posts": {"data": []}},
+				"meta": {"etag": "u2"}
+			}
+		],
+		"included": [
+			{
+				"type": "posts",
+				"id": "a",
+				"attributes": {"title": "First"},
+				"relationships": {"user": {"data": {"type": "users", "id": "1"}}}
+			},
+			{
+				"type": "posts",
+				"id": "b",
+				"attributes": {"title": "Second"},
+				"relationships": {"user": {"data": {"type": "users", "id": "1"}}}
+			}
+		],
+		"meta": {"total": 2}
+	}`, w.Body.String())
+}
+
+func TestFormatError(t *testing.T) {
+	h, _ := newTestHandler(t)
+	w := serve(h, "GET", "/users/3", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.JSONEq(t, `{"errors": [{"status": "404", "title": "Not Found"}]}`, w.Body.String())
+
+	w = serve(h, "GET", "/users?include=unknown", "")
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.JSONEq(t, `{"errors": [{
+		"status": "422",
+		"title": "URL parameters contain error(s)",
+		"detail": "unknown: unknown field",
+		"source": {"parameter": "fields"}
+	}]}`, w.Body.String())
+
+	w = serve(h, "POST", "/posts", `{"data": {"type": "posts", "relationships": {"user": {"data": {"type": "users", "id": "3"}}}}}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.JSONEq(t, `{"errors": [
+		{
+			"status": "422",
+			"title": "Document contains error(s)",
+			"detail": "required",
+			"source": {"pointer": "/data/attributes/title"}
+		},
+		{
+			"status": "422",
+			"title": "Document contains error(s)",
+			"detail": "Not Found",
+			"source": {"pointer": "/data/relationships/user"}
+		}
+	]}`, w.Body.String())
+}
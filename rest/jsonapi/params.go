@@ -0,0 +1,210 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/rest"
+	"github.com/rs/rest-layer/schema"
+)
+
+// pageParams maps the page[...] parameters to the rest package ones.
+var pageParams = map[string]string{
+	"number": "page",
+	"size":   "limit",
+	"offset": "skip",
+	"limit":  "limit",
+}
+
+// Params returns a handler translating the JSON:API query parameters of the
+// requests routed to a resource of index before calling next.
+func Params(index resource.Index, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.RawQuery != "" {
+			if route, err := rest.FindRoute(index, r); err == nil {
+				rsc := route.Resource()
+				route.Release()
+				if rsc != nil {
+					params := translateParams(index, rsc, r.URL.Query())
+					r = r.Clone(r.Context())
+					r.URL.RawQuery = params.Encode()
+				}
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// translateParams returns params with the JSON:API parameters replaced by
+// their rest package equivalent for a request on rsc.
+func translateParams(index resource.Index, rsc *resource.Resource, params url.Values) url.Values {
+	fieldsets := map[string]string{}
+	var filters []string
+	for key, values := range params {
+		if typ, ok := bracketed(key, "fields"); ok {
+			fieldsets[typ] = strings.Join(values, ",")
+			delete(params, key)
+		} else if field, ok := bracketed(key, "filter"); ok {
+			for _, v := range values {
+				filters = append(filters, filterPredicate(rsc, field, v))
+			}
+			delete(params, key)
+		} else if name, ok := bracketed(key, "page"); ok {
+			if p, found := pageParams[name]; found {
+				params[p] = values
+				delete(params, key)
+			}
+		}
+	}
+	// Map iteration order is random, keep the filters stable.
+	sort.Strings(filters)
+	for _, f := range filters {
+		params.Add("filter", f)
+	}
+	include := newIncludeTree(params.Get("include"))
+	params.Del("include")
+	if len(fieldsets) > 0 || len(include.children) > 0 {
+		params.Set("fields", projection(index, rsc, fieldsets, include))
+	}
+	return params
+}
+
+// bracketed returns the member name of a family[member] parameter.
+func bracketed(key, family string) (string, bool) {
+	if len(key) > len(family)+2 && strings.HasPrefix(key, family+"[") && key[len(key)-1] == ']' {
+		return key[len(family)+1 : len(key)-1], true
+	}
+	return "", false
+}
+
+// filterPredicate returns the predicate matching the items of rsc for which
+// field equals value, or any of the comma separated values. Values of number
+// and boolean fields are converted so the predicate validates.
+func filterPredicate(rsc *resource.Resource, field, value string) string {
+	var def *schema.Field
+	if v := rsc.Validator(); v != nil {
+		def = v.GetField(field)
+	}
+	values := []interface{}{}
+	for _, v := range strings.Split(value, ",") {
+		values = append(values, filterValue(def, v))
+	}
+	var cond interface{} = values[0]
+	if len(values) > 1 {
+		cond = map[string]interface{}{"$in": values}
+	}
+	j, _ := json.Marshal(map[string]interface{}{field: cond})
+	return string(j)
+}
+
+func filterValue(def *schema.Field, v string) interface{} {
+	if def == nil {
+		return v
+	}
+	switch def.Validator.(type) {
+	case *schema.Integer, *schema.Float:
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return f
+		}
+	case *schema.Bool:
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b
+		}
+	}
+	return v
+}
+
+// includeTree holds the relationship paths of the include parameter.
+type includeTree struct {
+	name     string
+	children []*includeTree
+}
+
+func newIncludeTree(include string) *includeTree {
+	root := &includeTree{}
+	for _, path := range strings.Split(include, ",") {
+		if path = strings.TrimSpace(path); path == "" {
+			continue
+		}
+		node := root
+		for _, name := range strings.Split(path, ".") {
+			node = node.child(name)
+		}
+	}
+	return root
+}
+
+func (t *includeTree) child(name string) *includeTree {
+	if c := t.lookup(name); c != nil {
+		return c
+	}
+	c := &includeTree{name: name}
+	t.children = append(t.children, c)
+	return c
+}
+
+func (t *includeTree) lookup(name string) *includeTree {
+	for _, c := range t.children {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// projection returns the fields parameter selecting the fields of rsc listed
+// in its sparse fieldset (all of them if none) and embedding the included
+// relationships. Unknown relationships are kept so the projection is rejected
+// by the query parser.
+func projection(index resource.Index, rsc *resource.Resource, fieldsets map[string]string, include *includeTree) string {
+	var fields []string
+	if fieldset, found := fieldsets[typeName(rsc)]; found && rsc != nil {
+		fields = append(fields, "id")
+		for _, f := range strings.Split(fieldset, ",") {
+			if f = strings.TrimSpace(f); f != "" && f != "id" && include.lookup(f) == nil {
+				fields = append(fields, f)
+			}
+		}
+	} else {
+		fields = append(fields, "*")
+	}
+	for _, c := range include.children {
+		target := relatedResource(index, rsc, c.name)
+		fields = append(fields, c.name+"{"+projection(index, target, fieldsets, c)+"}")
+	}
+	return strings.Join(fields, ",")
+}
+
+// relatedResource returns the resource linked by the field of rsc or nil if
+// the field isn't a relationship.
+func relatedResource(index resource.Index, rsc *resource.Resource, field string) *resource.Resource {
+	if rsc == nil {
+		return nil
+	}
+	def, found := rsc.Schema().Fields[field]
+	if !found {
+		return nil
+	}
+	var path string
+	switch v := def.Validator.(type) {
+	case *schema.Reference:
+		path = v.Path
+	case *schema.Connection:
+		path = v.Path
+	case *schema.Array:
+		ref, ok := v.Values.Validator.(*schema.Reference)
+		if !ok {
+			return nil
+		}
+		path = ref.Path
+	default:
+		return nil
+	}
+	target, _ := index.GetResource(path, rsc)
+	return target
+}
@@ -0,0 +1,59 @@
+package jsonapi
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTranslateParams(t *testing.T) {
+	_, index := newTestHandler(t)
+	users, _ := index.GetResource("users", nil)
+	posts, _ := index.GetResource("posts", nil)
+	cases := []struct {
+		path   string
+		params string
+		want   url.Values
+	}{
+		{"users", "sort=-name&filter=%7B%22age%22%3A30%7D", url.Values{"sort": {"-name"}, "filter": {`{"age":30}`}}},
+		{"users", "fields[users]=name,age", url.Values{"fields": {"id,name,age"}}},
+		{"users", "fields[posts]=title", url.Values{"fields": {"*"}}},
+		{"users", "include=posts.user,posts&fields[users]=name", url.Values{"fields": {"id,name,posts{*,user{id,name}}"}}},
+		{"posts", "include=user,reviewers&fields[posts]=user,title", url.Values{"fields": {"id,title,user{*},reviewers{*}"}}},
+		{"posts", "include=unknown.foo", url.Values{"fields": {"*,unknown{*,foo{*}}"}}},
+		{"users", "filter[age]=30&filter[name]=John,Jane", url.Values{"filter": {`{"age":30}`, `{"name":{"$in":["John","Jane"]}}`}}},
+		{"users", "filter[age]=x", url.Values{"filter": {`{"age":"x"}`}}},
+		{"users", "page[number]=2&page[size]=10", url.Values{"page": {"2"}, "limit": {"10"}}},
+		{"users", "page[offset]=5&page[limit]=10&page[cursor]=x", url.Values{"skip": {"5"}, "limit": {"10"}, "page[cursor]": {"x"}}},
+	}
+	for _, tc := range cases {
+		rsc := users
+		if tc.path == "posts" {
+			rsc = posts
+		}
+		params, err := url.ParseQuery(tc.params)
+		if !assert.NoError(t, err) {
+			continue
+		}
+		assert.Equal(t, tc.want, translateParams(index, rsc, params), tc.params)
+	}
+}
+
+func TestParams(t *testing.T) {
+	h, _ := newTestHandler(t)
+	w := serve(h, "GET", "/users?filter[age]=25&fields[users]=name", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{
+		"data": [{"type": "users", "id": "2", "attributes": {"name": "Jane"}, "meta": {"etag": "u2"}}],
+		"meta": {"total": 1}
+	}`, w.Body.String())
+
+	w = serve(h, "GET", "/users?sort=id&page[number]=2&page[size]=1", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{
+		"data": [{"type": "users", "id": "2", "attributes": {"name": "Jane", "age": 25}, "meta": {"etag": "u2"}}],
+		"meta": {"total": 2, "offset": 1}
+	}`, w.Body.String())
+}
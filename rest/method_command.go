@@ -0,0 +1,184 @@
+package rest
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	clone "github.com/huandu/go-clone/generic"
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+	"github.com/rs/rest-layer/schema/query"
+)
+
+// routeCommand executes the command targeted by route if the request method is
+// the one of the command: GET for read-only commands, PUT for other item
+// commands and POST for collection commands. Collection commands require the
+// Create or Update mode.
+func routeCommand(ctx context.Context, r *http.Request, route *RouteMatch) (status int, headers http.Header, body interface{}) {
+	rsrc := route.Resource()
+	isItem := route.ResourceID() != nil
+	var mh methodHandler
+	method, allow := http.MethodPut, "PUT"
+	switch {
+	case !isItem:
+		mh, method, allow = listPostCommand, http.MethodPost, "POST"
+	case isReadOnlyCommand(route):
+		mh, method, allow = itemGetCommand, http.MethodGet, "GET, HEAD"
+	default:
+		mh = itemPutCommand
+	}
+	// Item commands are subject to the modes allowing their method, and
+	// collection commands to the modes allowing changes.
+	conf := rsrc.Conf()
+	allowed := isMethodAllowed(isItem, method, conf)
+	if !isItem {
+		allowed = conf.IsModeAllowed(resource.Create) || conf.IsModeAllowed(resource.Update)
+	}
+	headers = http.Header{}
+	if allowed {
+		headers.Set("Allow", allow)
+	}
+	switch {
+	case route.Method == http.MethodOptions:
+		return 200, headers, nil
+	case !allowed || (route.Method != method && (method != http.MethodGet || route.Method != http.MethodHead)):
+		return ErrInvalidMethod.Code, headers, ErrInvalidMethod
+	}
+	return mh(ctx, r, route)
+}
+
+// isReadOnlyCommand returns true if route targets a read-only command.
+func isReadOnlyCommand(route *RouteMatch) bool {
+	_, found := route.Resource().GetReadOnlyCommand(route.CommandName())
+	return found
+}
+
+// itemGetCommand handles GET and HEAD requests executing a read-only command on
+// an item URL.
+func itemGetCommand(ctx context.Context, r *http.Request, route *RouteMatch) (status int, headers http.Header, body interface{}) {
+	q, e := route.Query()
+	if e != nil {
+		return e.Code, nil, e
+	}
+	rsrc := route.Resource()
+	name := route.CommandName()
+	q.Window = &query.Window{Limit: 1}
+	list, err := rsrc.Find(ctx, q)
+	if err != nil {
+		e, code := NewError(err)
+		return code, nil, e
+	} else if len(list.Items) == 0 {
+		return ErrNotFound.Code, nil, ErrNotFound
+	}
+	item := list.Items[0]
+
+	// The response of the command only depends on the item and the
+	// query-string.
+	etag := commandETag(item, name, route.Params)
+	policy := rsrc.Conf().ItemCache
+	if match, e := notModified(r, etag, item.Updated); e != nil {
+		return e.Code, nil, e
+	} else if match {
+		return http.StatusNotModified, notModifiedHeaders(policy, etag, item.Updated), nil
+	}
+
+	spec, _ := rsrc.GetCommandSpec(name)
+	payload, errs := validateCommandPayload(ctx, spec, queryPayload(route.Params, spec.Payload))
+	if len(errs) > 0 {
+		return 422, nil, &Error{422, "URL parameters contain error(s)", errs}
+	}
+	command, _ := rsrc.GetReadOnlyCommand(name)
+	headers, response, err := command(ctx, r, clone.Clone(item), payload)
+	if err != nil {
+		e, code := NewError(err)
+		return code, nil, e
+	}
+	if headers == nil {
+		headers = http.Header{}
+	}
+	setCacheHeaders(headers, policy)
+	return 200, headers, &resource.Item{
+		ID:      item.ID,
+		ETag:    etag,
+		Updated: item.Updated,
+		Payload: map[string]interface{}{"response": response},
+	}
+}
+
+// listPostCommand handles POST requests executing a command on a resource URL.
+func listPostCommand(ctx context.Context, r *http.Request, route *RouteMatch) (status int, headers http.Header, body interface{}) {
+	var payload map[string]interface{}
+	if e := decodePayload(ctx, r, &payload); e != nil {
+		return e.Code, nil, e
+	}
+	q, e := route.Query()
+	if e != nil {
+		return e.Code, nil, e
+	}
+	rsrc := route.Resource()
+	name := route.CommandName()
+	spec, _ := rsrc.GetCollectionCommandSpec(name)
+	payload, errs := validateCommandPayload(ctx, spec, payload)
+	if len(errs) > 0 {
+		return 422, nil, &Error{422, "Document contains error(s)", errs}
+	}
+	command, _ := rsrc.GetCollectionCommand(name)
+	headers, response, err := command(ctx, r, q, payload)
+	if err != nil {
+		e, code := NewError(err)
+		return code, nil, e
+	}
+	return 200, headers, map[string]interface{}{"response": response}
+}
+
+// validateCommandPayload validates payload against the payload schema of spec
+// if any, and returns the payload with its default values set.
+func validateCommandPayload(ctx context.Context, spec resource.CommandSpec, payload map[string]interface{}) (map[string]interface{}, map[string][]interface{}) {
+	if spec.Payload == nil {
+		return payload, nil
+	}
+	changes, base := spec.Payload.Prepare(ctx, payload, nil, false)
+	return spec.Payload.Validate(changes, base)
+}
+
+// queryPayload returns the payload of a read-only command from the
+// query-string parameters params. Values are decoded as JSON when possible,
+// unless the payload schema s expects a string. Repeated parameters give an
+// array.
+func queryPayload(params url.Values, s *schema.Schema) map[string]interface{} {
+	payload := make(map[string]interface{}, len(params))
+	for k, vs := range params {
+		str := false
+		if s != nil {
+			if f := s.GetField(k); f != nil {
+				_, str = f.Validator.(*schema.String)
+			}
+		}
+		values := make([]interface{}, 0, len(vs))
+		for _, v := range vs {
+			var value interface{}
+			if str || json.Unmarshal([]byte(v), &value) != nil {
+				value = v
+			}
+			values = append(values, value)
+		}
+		if len(values) == 1 {
+			payload[k] = values[0]
+		} else {
+			payload[k] = values
+		}
+	}
+	return payload
+}
+
+// commandETag returns the etag of the response of the read-only command name
+// executed on item with the query-string parameters params.
+func commandETag(item *resource.Item, name string, params url.Values) string {
+	h := md5.New()
+	fmt.Fprintf(h, "%s:%v\x00%s\x00%s", item.ETag, item.ID, name, params.Encode())
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
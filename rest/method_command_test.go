@@ -0,0 +1,237 @@
+package rest_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/resource/testing/mem"
+	"github.com/rs/rest-layer/rest"
+	"github.com/rs/rest-layer/schema"
+	"github.com/rs/rest-layer/schema/query"
+)
+
+func newCommandTestIndex(conf resource.Conf) func() *requestTestVars {
+	return func() *requestTestVars {
+		s := mem.NewHandler()
+		s.Insert(context.Background(), []*resource.Item{
+			{ID: "1", ETag: "a", Payload: map[string]interface{}{"id": "1", "foo": "odd", "n": 1}},
+			{ID: "2", ETag: "b", Payload: map[string]interface{}{"id": "2", "foo": "even", "n": 2}},
+			{ID: "3", ETag: "c", Payload: map[string]interface{}{"id": "3", "foo": "odd", "n": 3}},
+		})
+		idx := resource.NewIndex()
+		foo := idx.Bind("foo", schema.Schema{Fields: schema.Fields{
+			"id":  {Sortable: true, Filterable: true},
+			"foo": {Filterable: true},
+			"n":   {Validator: &schema.Integer{}},
+		}}, s, conf)
+		foo.CollectionCommand("sum", resource.CommandSpec{
+			Payload: &schema.Schema{Fields: schema.Fields{
+				"factor": {Required: true, Validator: &schema.Integer{}},
+			}},
+		}, func(ctx context.Context, r *http.Request, q *query.Query, payload map[string]interface{}) (http.Header, map[string]interface{}, error) {
+			l, err := foo.Find(ctx, q)
+			if err != nil {
+				return nil, nil, err
+			}
+			sum := 0
+			for _, item := range l.Items {
+				sum += item.Payload["n"].(int)
+			}
+			return http.Header{"X-Count": {"2"}}, map[string]interface{}{"sum": sum * payload["factor"].(int)}, nil
+		})
+		foo.ReadOnlyCommand("format", resource.CommandSpec{
+			Payload: &schema.Schema{Fields: schema.Fields{
+				"prefix": {Default: "#", Validator: &schema.String{}},
+				"width":  {Validator: &schema.Integer{}},
+			}},
+		}, func(ctx context.Context, r *http.Request, item *resource.Item, payload map[string]interface{}) (http.Header, map[string]interface{}, error) {
+			// Changes to the item are not stored.
+			item.Payload["foo"] = "changed"
+			return nil, map[string]interface{}{"text": payload["prefix"].(string) + item.ID.(string), "width": payload["width"]}, nil
+		})
+		foo.Command("touch", func(ctx context.Context, r *http.Request, item *resource.Item, payload map[string]interface{}) (http.Header, *resource.Item, map[string]interface{}, error) {
+			return nil, item, nil, nil
+		})
+		foo.SetCommandSpec("touch", resource.CommandSpec{Payload: &schema.Schema{Fields: schema.Fields{
+			"reason": {Required: true, Validator: &schema.String{}},
+		}}})
+		return &requestTestVars{Index: idx, Storers: map[string]resource.Storer{"foo": s}}
+	}
+}
+
+func TestCommands(t *testing.T) {
+	init := newCommandTestIndex(resource.DefaultConf)
+	tests := map[string]requestTest{
+		"collection": {
+			Init: init,
+			NewRequest: func() (*http.Request, error) {
+				return http.NewRequest("POST", `/foo/sum?filter={"foo":"odd"}`, bytes.NewBufferString(`{"factor": 2}`))
+			},
+			ResponseCode:   http.StatusOK,
+			ResponseHeader: http.Header{"X-Count": {"2"}},
+			ResponseBody:   `{"response": {"sum": 8}}`,
+		},
+		"collection:invalid-payload": {
+			Init: init,
+			NewRequest: func() (*http.Request, error) {
+				return http.NewRequest("POST", `/foo/sum`, bytes.NewBufferString(`{"factor": "2"}`))
+			},
+			ResponseCode: http.StatusUnprocessableEntity,
+			ResponseBody: `{"code": 422, "message": "Document contains error(s)", "issues": {"factor": ["not an integer"]}}`,
+		},
+		"collection:item": {
+			// Other methods than POST reach the item with the command's name.
+			Init: func() *requestTestVars {
+				vars := init()
+				vars.Storers["foo"].Insert(context.Background(), []*resource.Item{
+					{ID: "sum", ETag: "d", Payload: map[string]interface{}{"id": "sum", "foo": "none", "n": 0}},
+				})
+				return vars
+			},
+			NewRequest: func() (*http.Request, error) {
+				return http.NewRequest("GET", `/foo/sum`, nil)
+			},
+			ResponseCode: http.StatusOK,
+			ResponseBody: `{"id": "sum", "foo": "none", "n": 0}`,
+		},
+		"collection:options": {
+			Init: init,
+			NewRequest: func() (*http.Request, error) {
+				return http.NewRequest("OPTIONS", `/foo/sum`, nil)
+			},
+			ResponseCode:   http.StatusOK,
+			ResponseHeader: http.Header{"Allow": {"POST"}},
+		},
+		"collection:mode": {
+			Init: newCommandTestIndex(resource.Conf{AllowedModes: resource.ReadOnly}),
+			NewRequest: func() (*http.Request, error) {
+				return http.NewRequest("POST", `/foo/sum`, bytes.NewBufferString(`{"factor": 2}`))
+			},
+			ResponseCode:   http.StatusMethodNotAllowed,
+			ResponseHeader: http.Header{"Allow": nil},
+			ResponseBody:   `{"code": 405, "message": "Invalid Method"}`,
+		},
+		"read-only": {
+			Init: init,
+			NewRequest: func() (*http.Request, error) {
+				return http.NewRequest("GET", `/foo/1/format?width=10`, nil)
+			},
+			ResponseCode: http.StatusOK,
+			ResponseBody: `{"response": {"text": "#1", "width": 10}}`,
+			ExtraTest:    checkPayload("foo", "1", map[string]interface{}{"id": "1", "foo": "odd", "n": 1}),
+		},
+		"read-only:string-param": {
+			Init: init,
+			NewRequest: func() (*http.Request, error) {
+				return http.NewRequest("GET", `/foo/1/format?prefix=10`, nil)
+			},
+			ResponseCode: http.StatusOK,
+			ResponseBody: `{"response": {"text": "101", "width": null}}`,
+		},
+		"read-only:invalid-payload": {
+			Init: init,
+			NewRequest: func() (*http.Request, error) {
+				return http.NewRequest("GET", `/foo/1/format?width=wide&other=1`, nil)
+			},
+			ResponseCode: http.StatusUnprocessableEntity,
+			ResponseBody: `{"code": 422, "message": "URL parameters contain error(s)", "issues": {"other": ["invalid field"], "width": ["not an integer"]}}`,
+		},
+		"read-only:not-found": {
+			Init: init,
+			NewRequest: func() (*http.Request, error) {
+				return http.NewRequest("GET", `/foo/4/format`, nil)
+			},
+			ResponseCode: http.StatusNotFound,
+			ResponseBody: `{"code": 404, "message": "Not Found"}`,
+		},
+		"read-only:invalid-method": {
+			Init: init,
+			NewRequest: func() (*http.Request, error) {
+				return http.NewRequest("PUT", `/foo/1/format`, bytes.NewBufferString(`{}`))
+			},
+			ResponseCode:   http.StatusMethodNotAllowed,
+			ResponseHeader: http.Header{"Allow": {"GET, HEAD"}},
+			ResponseBody:   `{"code": 405, "message": "Invalid Method"}`,
+		},
+		"read-only:mode": {
+			Init: newCommandTestIndex(resource.Conf{AllowedModes: []resource.Mode{resource.List}}),
+			NewRequest: func() (*http.Request, error) {
+				return http.NewRequest("GET", `/foo/1/format`, nil)
+			},
+			ResponseCode:   http.StatusMethodNotAllowed,
+			ResponseHeader: http.Header{"Allow": nil},
+			ResponseBody:   `{"code": 405, "message": "Invalid Method"}`,
+		},
+		"item:invalid-payload": {
+			Init: init,
+			NewRequest: func() (*http.Request, error) {
+				return http.NewRequest("PUT", `/foo/1/touch`, bytes.NewBufferString(`{}`))
+			},
+			ResponseCode: http.StatusUnprocessableEntity,
+			ResponseBody: `{"code": 422, "message": "Document contains error(s)", "issues": {"reason": ["required"]}}`,
+		},
+		"item:options": {
+			Init: init,
+			NewRequest: func() (*http.Request, error) {
+				return http.NewRequest("OPTIONS", `/foo/1/touch`, nil)
+			},
+			ResponseCode:   http.StatusOK,
+			ResponseHeader: http.Header{"Allow": {"PUT"}},
+		},
+		"item:invalid-method": {
+			Init: init,
+			NewRequest: func() (*http.Request, error) {
+				return http.NewRequest("DELETE", `/foo/1/touch`, nil)
+			},
+			ResponseCode: http.StatusMethodNotAllowed,
+			ResponseBody: `{"code": 405, "message": "Invalid Method"}`,
+			ExtraTest:    checkPayload("foo", "1", map[string]interface{}{"id": "1", "foo": "odd", "n": 1}),
+		},
+	}
+	for n, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(n, tc.Test)
+	}
+}
+
+func TestReadOnlyCommandConditional(t *testing.T) {
+	vars := newCommandTestIndex(resource.DefaultConf)()
+	h, err := rest.NewHandler(vars.Index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := func(target, inm string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", target, nil)
+		if inm != "" {
+			r.Header.Set("If-None-Match", inm)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+	w := get("/foo/1/format?width=10", "")
+	etag := w.Header().Get("Etag")
+	if w.Code != http.StatusOK || etag == "" {
+		t.Fatalf("Expected 200 with an etag, got %d %q", w.Code, etag)
+	}
+	if w := get("/foo/1/format?width=10", etag); w.Code != http.StatusNotModified {
+		t.Errorf("Expected 304, got %d", w.Code)
+	}
+	// The etag depends on the payload.
+	if w := get("/foo/1/format?width=20", etag); w.Code != http.StatusOK {
+		t.Errorf("Expected 200, got %d", w.Code)
+	}
+	// The etag depends on the item.
+	item, _ := resource.NewItem(map[string]interface{}{"id": "1", "foo": "odd", "n": 10})
+	original := &resource.Item{ID: "1", ETag: "a"}
+	if err := vars.Storers["foo"].Update(context.Background(), item, original); err != nil {
+		t.Fatal(err)
+	}
+	if w := get("/foo/1/format?width=10", etag); w.Code != http.StatusOK {
+		t.Errorf("Expected 200, got %d", w.Code)
+	}
+}
@@ -42,6 +42,20 @@ func listGet(ctx context.Context, r *http.Request, route *RouteMatch) (status in
 	if win := q.Window; win != nil && win.Offset > 0 {
 		list.Offset = win.Offset
 	}
+	// Handle conditional requests: If-None-Match and If-Modified-Since. If the
+	// projection embeds other data, the etag is computed from the projected
+	// items.
+	policy := rsc.Conf().ListCache
+	updated := lastModified(list.Items)
+	projected := embedsData(q.Projection, rsc.Validator())
+	if !projected {
+		list.ETag = listETag(list, q, false)
+		if match, e := notModified(r, list.ETag, updated); e != nil {
+			return e.Code, nil, e
+		} else if match {
+			return http.StatusNotModified, notModifiedHeaders(policy, list.ETag, updated), nil
+		}
+	}
 	for _, item := range list.Items {
 		item.Payload, err = q.Projection.Eval(ctx, item.Payload, restResource{rsc})
 		if err != nil {
@@ -49,7 +63,17 @@ func listGet(ctx context.Context, r *http.Request, route *RouteMatch) (status in
 			return code, nil, e
 		}
 	}
-	return 200, nil, list
+	if projected {
+		list.ETag = listETag(list, q, true)
+		if match, e := notModified(r, list.ETag, updated); e != nil {
+			return e.Code, nil, e
+		} else if match {
+			return http.StatusNotModified, notModifiedHeaders(policy, list.ETag, updated), nil
+		}
+	}
+	headers = http.Header{}
+	setCacheHeaders(headers, policy)
+	return 200, headers, list
 }
 
 func getUintParam(params url.Values, name string) (int, bool, error) {
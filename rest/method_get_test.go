@@ -246,7 +246,7 @@ func TestGetListFieldHandler(t *testing.T) {
 			},
 			ResponseCode:   200,
 			ResponseBody:   `[{"foo": "bar"}]`,
-			ResponseHeader: http.Header{"Etag": []string{`W/"d41d8cd98f00b204e9800998ecf8427e"`}},
+			ResponseHeader: http.Header{"Etag": []string{`W/"96ac3db22998a903b0d1a46bfa9125d2"`}},
 		},
 		`fields:foo(bar:baz)`: {
 			Init: sharedInit,
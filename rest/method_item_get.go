@@ -3,7 +3,6 @@ package rest
 import (
 	"context"
 	"net/http"
-	"time"
 
 	"github.com/rs/rest-layer/resource"
 	"github.com/rs/rest-layer/schema/query"
@@ -40,19 +39,12 @@ func itemGet(ctx context.Context, r *http.Request, route *RouteMatch) (status in
 			return ErrNotFound.Code, nil, ErrNotFound
 		}
 	}
-	// Handle conditional request: If-None-Match.
-	if compareEtag(r.Header.Get("If-None-Match"), item.ETag) {
-		return 304, nil, nil
-	}
-	// Handle conditional request: If-Modified-Since.
-	if r.Header.Get("If-Modified-Since") != "" {
-		if ifModTime, err := time.Parse(time.RFC1123, r.Header.Get("If-Modified-Since")); err != nil {
-			return 400, nil, &Error{400, "Invalid If-Modified-Since header", nil}
-		} else if u := item.Updated.Truncate(time.Second); u.Equal(ifModTime) || u.Before(ifModTime) {
-			// Item's update time is truncated to the second because RFC1123
-			// doesn't support more.
-			return 304, nil, nil
-		}
+	// Handle conditional requests: If-None-Match and If-Modified-Since.
+	policy := rsrc.Conf().ItemCache
+	if match, e := notModified(r, item.ETag, item.Updated); e != nil {
+		return e.Code, nil, e
+	} else if match {
+		return http.StatusNotModified, notModifiedHeaders(policy, item.ETag, item.Updated), nil
 	}
 	var err error
 	item.Payload, err = q.Projection.Eval(ctx, item.Payload, restResource{rsrc})
@@ -60,5 +52,7 @@ func itemGet(ctx context.Context, r *http.Request, route *RouteMatch) (status in
 		e, code := NewError(err)
 		return code, nil, e
 	}
-	return 200, nil, item
+	headers = http.Header{}
+	setCacheHeaders(headers, policy)
+	return 200, headers, item
 }
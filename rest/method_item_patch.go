@@ -9,11 +9,20 @@ import (
 
 	jsonpatch "github.com/evanphx/json-patch"
 	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
 	"github.com/rs/rest-layer/schema/query"
 )
 
 func isJSONPatch(r *http.Request) bool {
-	if ct := r.Header.Get("Content-Type"); ct != "" && strings.TrimSpace(strings.SplitN(ct, ";", 2)[0]) == "application/json-patch+json" {
+	return hasContentType(r, "application/json-patch+json")
+}
+
+func isMergePatch(r *http.Request) bool {
+	return hasContentType(r, "application/merge-patch+json")
+}
+
+func hasContentType(r *http.Request, mediaType string) bool {
+	if ct := r.Header.Get("Content-Type"); ct != "" && strings.TrimSpace(strings.SplitN(ct, ";", 2)[0]) == mediaType {
 		return true
 	}
 	return false
@@ -21,19 +30,21 @@ func isJSONPatch(r *http.Request) bool {
 
 // itemPatch handles PATCH requests on an item URL.
 //
-// Reference: http://tools.ietf.org/html/rfc5789, http://tools.ietf.org/html/rfc6902
+// Reference: http://tools.ietf.org/html/rfc5789, http://tools.ietf.org/html/rfc6902,
+// http://tools.ietf.org/html/rfc7396
 func itemPatch(ctx context.Context, r *http.Request, route *RouteMatch) (status int, headers http.Header, body interface{}) {
 	var payload map[string]interface{}
 	var patchJSON []byte
 
 	isJSONPatch := isJSONPatch(r)
-	if isJSONPatch {
+	isMergePatch := isMergePatch(r)
+	if isJSONPatch || isMergePatch {
 		if r.Body != nil {
 			patchJSON, _ = io.ReadAll(r.Body)
 			r.Body.Close()
 		}
 	} else {
-		if e := decodePayload(r, &payload); e != nil {
+		if e := decodePayload(ctx, r, &payload); e != nil {
 			return e.Code, nil, e
 		}
 	}
@@ -60,19 +71,32 @@ func itemPatch(ctx context.Context, r *http.Request, route *RouteMatch) (status
 		return err.Code, nil, err
 	}
 
-	if isJSONPatch {
+	if isJSONPatch || isMergePatch {
 		// Recreate the new document
 		originalJSON, err := json.Marshal(original.Payload)
 		if err != nil {
 			return 422, nil, &Error{422, err.Error(), nil}
 		}
-		patch, err := jsonpatch.DecodePatch(patchJSON)
-		if err != nil {
-			return 400, nil, &Error{400, "Malformed patch document: " + err.Error(), nil}
-		}
-		payloadJSON, err := patch.Apply(originalJSON)
-		if err != nil {
-			return 422, nil, &Error{422, err.Error(), nil}
+		var payloadJSON []byte
+		if isJSONPatch {
+			patch, err := jsonpatch.DecodePatch(patchJSON)
+			if err != nil {
+				return 400, nil, &Error{400, "Malformed patch document: " + err.Error(), nil}
+			}
+			payloadJSON, err = patch.Apply(originalJSON)
+			if err != nil {
+				return 422, nil, &Error{422, err.Error(), nil}
+			}
+		} else {
+			if !json.Valid(patchJSON) {
+				return 400, nil, &Error{400, "Malformed patch document: invalid JSON", nil}
+			}
+			// Sub-documents are merged recursively and null values remove
+			// fields.
+			payloadJSON, err = jsonpatch.MergePatch(originalJSON, patchJSON)
+			if err != nil {
+				return 422, nil, &Error{422, err.Error(), nil}
+			}
 		}
 		err = json.Unmarshal(payloadJSON, &payload)
 		if err != nil {
@@ -80,8 +104,15 @@ func itemPatch(ctx context.Context, r *http.Request, route *RouteMatch) (status
 		}
 	}
 
-	// If JSON-Patch then `replace=true`, because we can delete fields
-	changes, base := rsrc.Validator().Prepare(ctx, payload, &original.Payload, isJSONPatch)
+	var changes, base map[string]interface{}
+	if mp, ok := rsrc.Validator().(schema.MergePreparer); ok && isMergePatch {
+		// Merge Patch merges sub-documents at every depth.
+		changes, base = mp.PrepareMerge(ctx, payload, &original.Payload)
+	} else {
+		// If JSON-Patch or Merge Patch then `replace=true`, because we can
+		// delete fields.
+		changes, base = rsrc.Validator().Prepare(ctx, payload, &original.Payload, isJSONPatch || isMergePatch)
+	}
 	// Append lookup fields to base payload so it isn't caught by ReadOnly
 	// (i.e.: contains id and parent resource refs if any).
 	for k, v := range route.ResourcePath.Values() {
@@ -91,6 +122,9 @@ func itemPatch(ctx context.Context, r *http.Request, route *RouteMatch) (status
 	if len(errs) > 0 {
 		return 422, nil, &Error{422, "Document contains error(s)", errs}
 	}
+	if e, code := verifyReferences(ctx, rsrc.Validator(), doc); e != nil {
+		return code, nil, e
+	}
 	if id, found := doc["id"]; found && id != original.ID {
 		return 422, nil, &Error{422, "Cannot change document ID", nil}
 	}
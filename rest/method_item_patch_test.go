@@ -811,3 +811,110 @@ func TestPatchItemNullable(t *testing.T) {
 		t.Run(n, tc.Test)
 	}
 }
+
+func TestPatchItemMergePatch(t *testing.T) {
+	init := func() *requestTestVars {
+		s := mem.NewHandler()
+		s.Insert(context.Background(), []*resource.Item{
+			{ID: "1", ETag: "a", Payload: map[string]interface{}{
+				"id":   "1",
+				"name": "foo",
+				"meta": map[string]interface{}{"a": "1", "b": "2", "locked": "l", "updated": "old"},
+				"tags": map[string]interface{}{"x": "1", "y": "2"},
+			}},
+		})
+		idx := resource.NewIndex()
+		idx.Bind("foo", schema.Schema{
+			Fields: schema.Fields{
+				"id":   {},
+				"name": {Validator: &schema.String{}},
+				"meta": {Schema: &schema.Schema{Fields: schema.Fields{
+					"a":      {},
+					"b":      {Default: "default"},
+					"locked": {ReadOnly: true},
+					"updated": {OnUpdate: func(ctx context.Context, value interface{}) interface{} {
+						return "now"
+					}},
+				}}},
+				"tags": {Validator: &schema.Dict{Values: schema.Field{Validator: &schema.String{}}}},
+			},
+		}, s, resource.DefaultConf)
+		return &requestTestVars{Index: idx, Storers: map[string]resource.Storer{"foo": s}}
+	}
+	newRequest := func(body string) func() (*http.Request, error) {
+		return func() (*http.Request, error) {
+			r, err := http.NewRequest("PATCH", "/foo/1", bytes.NewReader([]byte(body)))
+			r.Header.Set("Content-Type", "application/merge-patch+json")
+			return r, err
+		}
+	}
+	checkPayload := func(payload map[string]interface{}) requestCheckerFunc {
+		return func(t *testing.T, vars *requestTestVars) {
+			q := query.Query{Predicate: query.Predicate{&query.Equal{Field: "id", Value: "1"}}, Window: &query.Window{Limit: 1}}
+			items, err := vars.Storers["foo"].Find(context.Background(), &q)
+			if err != nil || len(items.Items) != 1 {
+				t.Fatalf("item not found: %v", err)
+			}
+			if !reflect.DeepEqual(payload, items.Items[0].Payload) {
+				t.Errorf("Unexpected stored payload:\nexpect: %#v\ngot: %#v", payload, items.Items[0].Payload)
+			}
+		}
+	}
+	tests := map[string]requestTest{
+		"DeepMerge": {
+			Init:         init,
+			NewRequest:   newRequest(`{"name": "bar", "meta": {"a": "2"}, "tags": {"y": null, "z": "3"}}`),
+			ResponseCode: http.StatusOK,
+			ResponseBody: `{"id": "1", "name": "bar", "meta": {"a": "2", "b": "2", "locked": "l", "updated": "now"}, "tags": {"x": "1", "z": "3"}}`,
+			ExtraTest: checkPayload(map[string]interface{}{
+				"id":   "1",
+				"name": "bar",
+				"meta": map[string]interface{}{"a": "2", "b": "2", "locked": "l", "updated": "now"},
+				"tags": map[string]interface{}{"x": "1", "z": "3"},
+			}),
+		},
+		"NullRemovesField": {
+			Init:         init,
+			NewRequest:   newRequest(`{"name": null, "meta": {"a": null, "b": null}}`),
+			ResponseCode: http.StatusOK,
+			ResponseBody: `{"id": "1", "meta": {"b": "default", "locked": "l", "updated": "now"}, "tags": {"x": "1", "y": "2"}}`,
+		},
+		"NullRemovesSubDocument": {
+			Init:         init,
+			NewRequest:   newRequest(`{"meta": null, "tags": null}`),
+			ResponseCode: http.StatusOK,
+			ResponseBody: `{"id": "1", "name": "foo", "meta": {"updated": "now"}}`,
+		},
+		"NestedReadOnly": {
+			Init:         init,
+			NewRequest:   newRequest(`{"meta": {"locked": "m"}}`),
+			ResponseCode: http.StatusUnprocessableEntity,
+			ResponseBody: `{"code": 422, "message": "Document contains error(s)", "issues": {"meta": [{"locked": ["read-only"]}]}}`,
+		},
+		"NestedInvalid": {
+			Init:         init,
+			NewRequest:   newRequest(`{"tags": {"x": 1}}`),
+			ResponseCode: http.StatusUnprocessableEntity,
+			ResponseBody: "{\"code\": 422, \"message\": \"Document contains error(s)\", \"issues\": {\"tags\": [\"invalid value for key `x': not a string\"]}}",
+		},
+		"PlainJSON": {
+			// A plain JSON patch replaces the sub-documents.
+			Init: init,
+			NewRequest: func() (*http.Request, error) {
+				return http.NewRequest("PATCH", "/foo/1", bytes.NewReader([]byte(`{"meta": {"a": "2"}}`)))
+			},
+			ResponseCode: http.StatusOK,
+			ResponseBody: `{"id": "1", "name": "foo", "meta": {"a": "2", "updated": "now"}, "tags": {"x": "1", "y": "2"}}`,
+		},
+		"Malformed": {
+			Init:         init,
+			NewRequest:   newRequest(`{"name": `),
+			ResponseCode: http.StatusBadRequest,
+			ResponseBody: `{"code": 400, "message": "Malformed patch document: invalid JSON"}`,
+		},
+	}
+	for n, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(n, tc.Test)
+	}
+}
@@ -3,6 +3,7 @@ package rest
 import (
 	"context"
 	"net/http"
+	"strings"
 
 	clone "github.com/huandu/go-clone/generic"
 	"github.com/rs/rest-layer/resource"
@@ -22,7 +23,7 @@ func itemPut(ctx context.Context, r *http.Request, route *RouteMatch) (status in
 
 func itemPutCreateReplace(ctx context.Context, r *http.Request, route *RouteMatch) (status int, headers http.Header, body interface{}) {
 	var payload map[string]interface{}
-	if e := decodePayload(r, &payload); e != nil {
+	if e := decodePayload(ctx, r, &payload); e != nil {
 		return e.Code, nil, e
 	}
 	q, e := route.Query()
@@ -80,6 +81,9 @@ func itemPutCreateReplace(ctx context.Context, r *http.Request, route *RouteMatc
 	if len(errs) > 0 {
 		return 422, nil, &Error{422, "Document contains error(s)", errs}
 	}
+	if e, code := verifyReferences(ctx, rsrc.Validator(), doc); e != nil {
+		return code, nil, e
+	}
 	if original != nil {
 		if id, found := doc["id"]; found && id != original.ID {
 			return 422, nil, &Error{422, "Cannot change document ID", nil}
@@ -149,9 +153,14 @@ func itemPutCreateReplace(ctx context.Context, r *http.Request, route *RouteMatc
 
 func itemPutCommand(ctx context.Context, r *http.Request, route *RouteMatch) (status int, headers http.Header, body interface{}) {
 	var payload map[string]interface{}
-	if e := decodePayload(r, &payload); e != nil {
+	if e := decodePayload(ctx, r, &payload); e != nil {
 		return e.Code, nil, e
 	}
+	spec, _ := route.Resource().GetCommandSpec(route.CommandName())
+	payload, errs := validateCommandPayload(ctx, spec, payload)
+	if len(errs) > 0 {
+		return 422, nil, &Error{422, "Document contains error(s)", errs}
+	}
 
 	q, e := route.Query()
 	if e != nil {
@@ -177,6 +186,10 @@ func itemPutCommand(ctx context.Context, r *http.Request, route *RouteMatch) (st
 		return err.Code, nil, err
 	}
 
+	if q, command, found := rsrc.GetAsyncCommand(route.CommandName()); found {
+		return itemPutAsyncCommand(ctx, r, route, q, command, original, payload)
+	}
+
 	command := route.Command()
 	commandHeaders, item, responseBody, err := command(ctx, r, clone.Clone(original), payload)
 	if err != nil {
@@ -195,6 +208,9 @@ func itemPutCommand(ctx context.Context, r *http.Request, route *RouteMatch) (st
 		if len(errs) > 0 {
 			return 422, nil, &Error{422, "Document contains error(s)", errs}
 		}
+		if e, code := verifyReferences(ctx, rsrc.Validator(), doc); e != nil {
+			return code, nil, e
+		}
 		if id, found := doc["id"]; found && id != original.ID {
 			return 422, nil, &Error{422, "Cannot change document ID", nil}
 		}
@@ -213,12 +229,15 @@ func itemPutCommand(ctx context.Context, r *http.Request, route *RouteMatch) (st
 			e, code := NewError(err)
 			return code, nil, e
 		}
+		recordCommandEvent(ctx, route, item, payload)
 
 		item.Payload, err = q.Projection.Eval(ctx, item.Payload, restResource{rsrc})
 		if err != nil {
 			e, code := NewError(err)
 			return code, nil, e
 		}
+	} else {
+		recordCommandEvent(ctx, route, item, payload)
 	}
 
 	status = 200
@@ -233,3 +252,32 @@ func itemPutCommand(ctx context.Context, r *http.Request, route *RouteMatch) (st
 
 	return status, commandHeaders, dummyItem
 }
+
+// itemPutAsyncCommand queues the execution of an asynchronous command on
+// original and returns the job tracking it with a 202 status.
+func itemPutAsyncCommand(ctx context.Context, r *http.Request, route *RouteMatch, q resource.JobQueue, command resource.AsyncCommand, original *resource.Item, payload map[string]interface{}) (status int, headers http.Header, body interface{}) {
+	job, err := q.Enqueue(ctx, route.Resource(), route.CommandName(), command, clone.Clone(original), payload)
+	if err != nil {
+		e, code := NewError(err)
+		return code, nil, e
+	}
+	headers = http.Header{}
+	// The jobs resource is served by the same handler as the command.
+	base := strings.TrimSuffix(r.URL.Path, URLBuilder{}.Path(route.ResourcePath))
+	if u, ok := (URLBuilder{Base: base}).ResourceItem(q.Jobs(), job.Payload); ok {
+		headers.Set("Location", u)
+	}
+	return http.StatusAccepted, headers, job
+}
+
+// recordCommandEvent records the execution of the route's command on item when
+// the resource publishes events.
+func recordCommandEvent(ctx context.Context, route *RouteMatch, item *resource.Item, data map[string]interface{}) {
+	rsrc := route.Resource()
+	e := rsrc.NewEvent(resource.EventCommand, item)
+	e.Command = route.CommandName()
+	e.Data = data
+	if err := rsrc.RecordEvent(ctx, e); err != nil {
+		logErrorf(ctx, "%s: cannot record command event: %v", rsrc.Path(), err)
+	}
+}
@@ -3,12 +3,16 @@ package rest_test
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"fmt"
 	"net/http"
+	"net/http/httptest"
 	"reflect"
 	"testing"
 	"time"
 
 	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/resource/jobs"
 	"github.com/rs/rest-layer/resource/testing/mem"
 	"github.com/rs/rest-layer/rest"
 	"github.com/rs/rest-layer/schema"
@@ -884,3 +888,59 @@ func TestPutItemCommand(t *testing.T) {
 		t.Run(n, tc.Test)
 	}
 }
+
+func TestPutItemAsyncCommand(t *testing.T) {
+	s := mem.NewHandler()
+	s.Insert(context.Background(), []*resource.Item{
+		{ID: "1", ETag: "a", Payload: map[string]interface{}{"id": "1", "foo": "odd"}},
+	})
+	idx := resource.NewIndex()
+	fooRes := idx.Bind("foo", schema.Schema{Fields: schema.Fields{
+		"id":  {},
+		"foo": {},
+	}}, s, resource.DefaultConf)
+	jobsRes := idx.Bind("jobs", jobs.Schema, mem.NewHandler(), resource.DefaultConf)
+	pool := jobs.NewPool(jobsRes)
+	pool.MaxPending = 1
+	fooRes.AsyncCommand("export", pool, func(ctx context.Context, item *resource.Item, payload map[string]interface{}, progress func(float64)) (map[string]interface{}, error) {
+		return nil, nil
+	})
+	h, err := rest.NewHandler(idx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("PUT", "/foo/1/export", bytes.NewReader([]byte(`{"format": "csv"}`))))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected HTTP response code 202, got %d: %s", w.Code, w.Body)
+	}
+	var job map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &job); err != nil {
+		t.Fatal(err)
+	}
+	if loc, want := w.Header().Get("Location"), fmt.Sprintf("/jobs/%s", job["id"]); loc != want {
+		t.Errorf("Expected Location %q, got %q", want, loc)
+	}
+	for k, v := range map[string]interface{}{"resource": "foo", "item": "1", "command": "export", "status": "pending"} {
+		if job[k] != v {
+			t.Errorf("Expected job %s to be %v, got %v", k, v, job[k])
+		}
+	}
+	if _, err := jobsRes.Get(context.Background(), job["id"]); err != nil {
+		t.Errorf("Expected job to be stored, got %v", err)
+	}
+
+	// The pool is full.
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("PUT", "/foo/1/export", bytes.NewReader([]byte(`{}`))))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected HTTP response code 503, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("PUT", "/foo/2/export", bytes.NewReader([]byte(`{}`))))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected HTTP response code 404, got %d", w.Code)
+	}
+}
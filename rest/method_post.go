@@ -11,12 +11,15 @@ import (
 
 // listPost handles POST resquests on a resource URL.
 func listPost(ctx context.Context, r *http.Request, route *RouteMatch) (status int, headers http.Header, body interface{}) {
+	if isNDJSON(r) {
+		return listPostNDJSON(ctx, r, route)
+	}
 	q, e := route.Query()
 	if e != nil {
 		return e.Code, nil, e
 	}
 	var payload map[string]interface{}
-	if e = decodePayload(r, &payload); e != nil {
+	if e = decodePayload(ctx, r, &payload); e != nil {
 		return e.Code, nil, e
 	}
 	rsrc := route.Resource()
@@ -30,6 +33,9 @@ func listPost(ctx context.Context, r *http.Request, route *RouteMatch) (status i
 	if len(errs) > 0 {
 		return 422, nil, &Error{422, "Document contains error(s)", errs}
 	}
+	if e, code := verifyReferences(ctx, rsrc.Validator(), doc); e != nil {
+		return code, nil, e
+	}
 	item, err := resource.NewItem(doc)
 	if err != nil {
 		e, code := NewError(err)
@@ -0,0 +1,222 @@
+package rest
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/rs/rest-layer/resource"
+)
+
+const (
+	// defaultImportChunkSize is the number of items inserted at once by
+	// imports when resource.Conf.ImportChunkSize is not set.
+	defaultImportChunkSize = 100
+	// maxImportErrors is the number of rejected lines reported in the import
+	// summary.
+	maxImportErrors = 100
+	// maxImportLineSize is the maximum size of an imported document.
+	maxImportLineSize = 4 << 20
+)
+
+// isNDJSON returns true if the body of r is a stream of newline delimited JSON
+// documents.
+func isNDJSON(r *http.Request) bool {
+	switch strings.TrimSpace(strings.SplitN(r.Header.Get("Content-Type"), ";", 2)[0]) {
+	case "application/x-ndjson", "application/ndjson":
+		return true
+	}
+	return false
+}
+
+// importer inserts the documents of an NDJSON import by chunks and keeps
+// track of the accepted and rejected lines.
+type importer struct {
+	route *RouteMatch
+	rsrc  *resource.Resource
+	// failFast is false when the import continues after rejected lines.
+	failFast bool
+	accepted int
+	rejected int
+	errors   []interface{}
+	// chunk holds the validated items waiting to be inserted, and lines their
+	// line numbers.
+	chunk []*resource.Item
+	lines []int
+	// err is the first error met.
+	err *Error
+}
+
+// listPostNDJSON handles POST requests on a resource URL with an NDJSON body,
+// importing each line as a new item. Unless the on_error query-string
+// parameter is set to continue, the import stops at the first rejected line.
+func listPostNDJSON(ctx context.Context, r *http.Request, route *RouteMatch) (status int, headers http.Header, body interface{}) {
+	var failFast bool
+	switch r.URL.Query().Get("on_error") {
+	case "", "stop":
+		failFast = true
+	case "continue":
+	default:
+		e := &Error{422, "URL parameters contain error(s)", map[string][]interface{}{
+			"on_error": {"invalid value, must be stop or continue"},
+		}}
+		return e.Code, nil, e
+	}
+	rsrc := route.Resource()
+	chunkSize := rsrc.Conf().ImportChunkSize
+	if chunkSize <= 0 {
+		chunkSize = defaultImportChunkSize
+	}
+	imp := &importer{
+		route:    route,
+		rsrc:     rsrc,
+		failFast: failFast,
+		errors:   []interface{}{},
+		chunk:    make([]*resource.Item, 0, chunkSize),
+		lines:    make([]int, 0, chunkSize),
+	}
+	if r.Body != nil {
+		defer r.Body.Close()
+		s := bufio.NewScanner(r.Body)
+		s.Buffer(make([]byte, 0, 64*1024), maxImportLineSize)
+		line := 0
+		for s.Scan() {
+			line++
+			b := bytes.TrimSpace(s.Bytes())
+			if len(b) == 0 {
+				continue
+			}
+			if err := ctx.Err(); err != nil {
+				e, _ := NewError(err)
+				imp.reject(line, toError(e))
+				break
+			}
+			if item, e := imp.prepare(ctx, b); e != nil {
+				imp.reject(line, e)
+			} else {
+				imp.add(ctx, line, item)
+			}
+			if failFast && imp.err != nil {
+				break
+			}
+		}
+		if err := s.Err(); err != nil && (!failFast || imp.err == nil) {
+			imp.reject(line+1, &Error{400, fmt.Sprintf("Malformed line: %v", err), nil})
+		}
+	}
+	// The lines validated before the first rejected one are inserted in
+	// fail-fast mode too.
+	imp.flush(ctx)
+	status = http.StatusOK
+	if failFast && imp.err != nil {
+		status = imp.err.Code
+	}
+	return status, nil, map[string]interface{}{
+		"accepted": imp.accepted,
+		"rejected": imp.rejected,
+		"errors":   imp.errors,
+	}
+}
+
+// prepare decodes and validates the document b and returns the item to
+// insert.
+func (imp *importer) prepare(ctx context.Context, b []byte) (*resource.Item, *Error) {
+	var payload map[string]interface{}
+	if err := (JSONCodec{}).Decode(bytes.NewReader(b), &payload); err != nil {
+		return nil, &Error{400, fmt.Sprintf("Malformed line: %v", err), nil}
+	}
+	changes, base := imp.rsrc.Validator().Prepare(ctx, payload, nil, false)
+	// Append lookup fields to base payload so it isn't caught by ReadOnly
+	// (i.e.: contains id and parent resource refs if any).
+	for k, v := range imp.route.ResourcePath.Values() {
+		base[k] = v
+	}
+	doc, errs := imp.rsrc.Validator().Validate(changes, base)
+	if len(errs) > 0 {
+		return nil, &Error{422, "Document contains error(s)", errs}
+	}
+	if e, _ := verifyReferences(ctx, imp.rsrc.Validator(), doc); e != nil {
+		return nil, toError(e)
+	}
+	item, err := resource.NewItem(doc)
+	if err != nil {
+		e, _ := NewError(err)
+		return nil, toError(e)
+	}
+	return item, nil
+}
+
+// add queues the item of line for insertion, inserting the chunk once full.
+func (imp *importer) add(ctx context.Context, line int, item *resource.Item) {
+	imp.chunk = append(imp.chunk, item)
+	imp.lines = append(imp.lines, line)
+	if len(imp.chunk) == cap(imp.chunk) {
+		imp.flush(ctx)
+	}
+}
+
+// flush inserts the pending items. As the insertion of a chunk is atomic, a
+// failed chunk is inserted again item by item when the import continues on
+// error, so only the lines actually failing are rejected. In fail-fast mode,
+// all the lines of a failed chunk are rejected.
+func (imp *importer) flush(ctx context.Context) {
+	if len(imp.chunk) == 0 {
+		return
+	}
+	if err := imp.rsrc.Insert(ctx, imp.chunk); err == nil {
+		imp.accepted += len(imp.chunk)
+	} else if imp.failFast || len(imp.chunk) == 1 {
+		e, _ := NewError(err)
+		for _, line := range imp.lines {
+			imp.reject(line, toError(e))
+		}
+	} else {
+		for i, item := range imp.chunk {
+			if err := imp.rsrc.Insert(ctx, []*resource.Item{item}); err != nil {
+				e, _ := NewError(err)
+				imp.reject(imp.lines[i], toError(e))
+			} else {
+				imp.accepted++
+			}
+		}
+	}
+	// Release the items for the next chunk.
+	for i := range imp.chunk {
+		imp.chunk[i] = nil
+	}
+	imp.chunk, imp.lines = imp.chunk[:0], imp.lines[:0]
+}
+
+// reject records the rejection of line with e. Only the first rejections are
+// reported so the summary has a bounded size.
+func (imp *importer) reject(line int, e *Error) {
+	imp.rejected++
+	if imp.err == nil {
+		imp.err = e
+	}
+	if len(imp.errors) >= maxImportErrors {
+		return
+	}
+	entry := map[string]interface{}{
+		"line":    line,
+		"code":    e.Code,
+		"message": e.Message,
+	}
+	if e.Issues != nil {
+		entry["issues"] = e.Issues
+	}
+	imp.errors = append(imp.errors, entry)
+}
+
+// toError returns the *Error wrapped by err, as returned by NewError.
+func toError(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	return &Error{520, err.Error(), nil}
+}
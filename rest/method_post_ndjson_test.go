@@ -0,0 +1,122 @@
+package rest_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/resource/testing/mem"
+	"github.com/rs/rest-layer/schema"
+	"github.com/rs/rest-layer/schema/query"
+	"github.com/stretchr/testify/assert"
+)
+
+// chunkRecorder records the number of items of each Insert call.
+type chunkRecorder struct {
+	*mem.MemoryHandler
+	chunks []int
+}
+
+func (s *chunkRecorder) Insert(ctx context.Context, items []*resource.Item) error {
+	s.chunks = append(s.chunks, len(items))
+	return s.MemoryHandler.Insert(ctx, items)
+}
+
+func TestHandlerPostListNDJSON(t *testing.T) {
+	init := func() *requestTestVars {
+		i := resource.NewIndex()
+		s := &chunkRecorder{MemoryHandler: mem.NewHandler()}
+		s.MemoryHandler.Insert(context.Background(), []*resource.Item{{ID: "0", ETag: "e0", Payload: map[string]interface{}{"id": "0", "name": "zero"}}})
+		i.Bind("foo", schema.Schema{Fields: schema.Fields{
+			"id":   {Required: true},
+			"name": {Required: true, Validator: &schema.String{}},
+		}}, s, resource.Conf{AllowedModes: resource.ReadWrite, ImportChunkSize: 2})
+		return &requestTestVars{Index: i, Storers: map[string]resource.Storer{"foo": s}}
+	}
+	newRequest := func(target, body string) func() (*http.Request, error) {
+		return func() (*http.Request, error) {
+			r, err := http.NewRequest("POST", target, strings.NewReader(body))
+			if err == nil {
+				r.Header.Set("Content-Type", "application/x-ndjson")
+			}
+			return r, err
+		}
+	}
+	stored := func(t *testing.T, vars *requestTestVars) []interface{} {
+		l, err := vars.Storers["foo"].Find(context.Background(), &query.Query{Sort: query.Sort{{Name: "id"}}})
+		if !assert.NoError(t, err) {
+			return nil
+		}
+		var ids []interface{}
+		for _, item := range l.Items {
+			ids = append(ids, item.ID)
+		}
+		return ids
+	}
+	tests := map[string]requestTest{
+		"OK": {
+			Init: init,
+			NewRequest: newRequest("/foo", `{"id": "1", "name": "a"}
+{"id": "2", "name": "b"}
+
+{"id": "3", "name": "c"}
+{"id": "4", "name": "d"}
+{"id": "5", "name": "e"}
+`),
+			ResponseCode: http.StatusOK,
+			ResponseBody: `{"accepted": 5, "rejected": 0, "errors": []}`,
+			ExtraTest: func(t *testing.T, vars *requestTestVars) {
+				assert.Equal(t, []int{2, 2, 1}, vars.Storers["foo"].(*chunkRecorder).chunks)
+				assert.Equal(t, []interface{}{"0", "1", "2", "3", "4", "5"}, stored(t, vars))
+			},
+		},
+		"FailFast": {
+			Init: init,
+			NewRequest: newRequest("/foo", `{"id": "1", "name": "a"}
+{"id": "2", "name": "b"}
+{"id": "3", "name": "c"}
+{"id": "4"}
+{"id": "5", "name": "e"}
+`),
+			ResponseCode: http.StatusUnprocessableEntity,
+			ResponseBody: `{"accepted": 3, "rejected": 1, "errors": [
+				{"line": 4, "code": 422, "message": "Document contains error(s)", "issues": {"name": ["required"]}}
+			]}`,
+			ExtraTest: func(t *testing.T, vars *requestTestVars) {
+				assert.Equal(t, []interface{}{"0", "1", "2", "3"}, stored(t, vars))
+			},
+		},
+		"ContinueOnError": {
+			Init: init,
+			NewRequest: newRequest("/foo?on_error=continue", `{"id": "1", "name": "a"}
+{"id": "2", "name":
+{"id": "3", "name": "c"}
+{"id": "0", "name": "d"}
+{"id": "5", "name": "e"}
+`),
+			ResponseCode: http.StatusOK,
+			ResponseBody: `{"accepted": 3, "rejected": 2, "errors": [
+				{"line": 2, "code": 400, "message": "Malformed line: unexpected EOF"},
+				{"line": 4, "code": 409, "message": "Conflict"}
+			]}`,
+			ExtraTest: func(t *testing.T, vars *requestTestVars) {
+				// The chunk holding the conflicting item is inserted again
+				// item by item.
+				assert.Equal(t, []int{2, 2, 1, 1}, vars.Storers["foo"].(*chunkRecorder).chunks)
+				assert.Equal(t, []interface{}{"0", "1", "3", "5"}, stored(t, vars))
+			},
+		},
+		"InvalidOnError": {
+			Init:         init,
+			NewRequest:   newRequest("/foo?on_error=ignore", `{"id": "1", "name": "a"}`),
+			ResponseCode: http.StatusUnprocessableEntity,
+			ResponseBody: `{"code": 422, "message": "URL parameters contain error(s)", "issues": {"on_error": ["invalid value, must be stop or continue"]}}`,
+		},
+	}
+	for n, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(n, tc.Test)
+	}
+}
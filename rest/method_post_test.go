@@ -201,6 +201,27 @@ func TestHandlerPostList(t *testing.T) {
 				"message": "No Storage Defined"
 			}`,
 		},
+		"UniqueViolation": {
+			Init: func() *requestTestVars {
+				s := mem.NewHandler()
+				s.Insert(context.Background(), []*resource.Item{{ID: "1", Payload: map[string]interface{}{"id": "1", "email": "a@example.com"}}})
+				index := resource.NewIndex()
+				index.Bind("users", schema.Schema{Fields: schema.Fields{
+					"id":    {},
+					"email": {Unique: true},
+				}}, s, resource.DefaultConf)
+				return &requestTestVars{Index: index}
+			},
+			NewRequest: func() (*http.Request, error) {
+				return http.NewRequest("POST", "/users", bytes.NewBufferString(`{"id": "2", "email": "a@example.com"}`))
+			},
+			ResponseCode: http.StatusConflict,
+			ResponseBody: `{
+				"code": 409,
+				"message": "Conflict",
+				"issues": {"email": ["must be unique"]}
+			}`,
+		},
 		"WithReferenceNotFound": {
 			Init: func() *requestTestVars {
 				s := mem.NewHandler()
@@ -222,6 +243,49 @@ func TestHandlerPostList(t *testing.T) {
 				"issues": {"foo": ["Not Found"]}
 			}`,
 		},
+		"WithReferenceVerifyNotFound": {
+			Init: func() *requestTestVars {
+				s := mem.NewHandler()
+				s.Insert(context.Background(), []*resource.Item{{ID: "ref", Payload: map[string]interface{}{"id": "ref"}}})
+				index := resource.NewIndex()
+				index.Bind("foo", schema.Schema{Fields: schema.Fields{"id": {}}}, s, resource.DefaultConf)
+				index.Bind("bar", schema.Schema{Fields: schema.Fields{
+					"id":  {},
+					"foo": {Validator: &schema.Reference{Path: "foo", Verify: true}},
+					"foos": {Validator: &schema.Array{Values: schema.Field{
+						Validator: &schema.Reference{Path: "foo", Verify: true},
+					}}},
+				}}, s, resource.DefaultConf)
+				return &requestTestVars{Index: index}
+			},
+			NewRequest: func() (*http.Request, error) {
+				return http.NewRequest("POST", "/bar", bytes.NewBufferString(`{"id": "1", "foo": "nonexisting", "foos": ["ref", "nonexisting"]}`))
+			},
+			ResponseCode: http.StatusUnprocessableEntity,
+			ResponseBody: `{
+				"code": 422,
+				"message": "Document contains error(s)",
+				"issues": {"foo": ["Not Found"], "foos": ["invalid value at #2: Not Found"]}
+			}`,
+		},
+		"WithReferenceVerify": {
+			Init: func() *requestTestVars {
+				s := mem.NewHandler()
+				s.Insert(context.Background(), []*resource.Item{{ID: "ref", Payload: map[string]interface{}{"id": "ref"}}})
+				index := resource.NewIndex()
+				index.Bind("foo", schema.Schema{Fields: schema.Fields{"id": {}}}, s, resource.DefaultConf)
+				index.Bind("bar", schema.Schema{Fields: schema.Fields{
+					"id":  {},
+					"foo": {Validator: &schema.Reference{Path: "foo", Verify: true}},
+				}}, s, resource.DefaultConf)
+				return &requestTestVars{Index: index}
+			},
+			NewRequest: func() (*http.Request, error) {
+				return http.NewRequest("POST", "/bar", bytes.NewBufferString(`{"id": "1", "foo": "ref"}`))
+			},
+			ResponseCode: http.StatusCreated,
+			ResponseBody: `{"id": "1", "foo": "ref"}`,
+		},
 		"WithReferenceSkipCheck": {
 			Init: func() *requestTestVars {
 				s := mem.NewHandler()
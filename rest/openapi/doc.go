@@ -0,0 +1,35 @@
+/*
+Package openapi generates the OpenAPI 3.1 (https://spec.openapis.org/oas/v3.1.0)
+document describing the REST API served by a rest.Handler for a resource.Index.
+
+The document holds the collection, item, alias and command paths of every
+resource and sub-resource, with the methods allowed by their
+resource.Conf.AllowedModes, the query-string parameters they support (filter,
+sort, fields, limit, page, skip and total) and their error responses. The
+schema of each resource is described as a component, with the parameters of
+its fields listed in an x-params extension.
+
+The document can be served at any URL:
+
+	api, err := rest.NewHandler(index)
+	if err != nil {
+		log.Fatal(err)
+	}
+	doc, err := openapi.NewHandler(index, openapi.Config{
+		Title:   "Blog API",
+		Version: "1.0.0",
+		Servers: []string{"/api"},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	http.Handle("/api/", http.StripPrefix("/api/", api))
+	http.Handle("/openapi.json", doc)
+
+The document describes the default JSON representation of the responses.
+Projections may return a different set of fields.
+
+This package is part of the rest-layer project. See http://rest-layer.io for
+full REST Layer documentation.
+*/
+package openapi
@@ -0,0 +1,47 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/rs/rest-layer/resource"
+)
+
+// Handler is a net/http compatible handler serving the OpenAPI document of an
+// API as JSON.
+type Handler struct {
+	doc []byte
+}
+
+// NewHandler creates a handler serving the OpenAPI document of the API
+// served for index. The document is generated once, the resources bound to
+// the index after this call are not described.
+func NewHandler(i resource.Index, conf Config) (*Handler, error) {
+	if c, ok := i.(resource.Compiler); ok {
+		if err := c.Compile(); err != nil {
+			return nil, err
+		}
+	}
+	doc, err := Document(i, conf)
+	if err != nil {
+		return nil, err
+	}
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	return &Handler{doc: b}, nil
+}
+
+// ServeHTTP implements http.Handler interface.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method == http.MethodGet {
+		w.Write(h.doc)
+	}
+}
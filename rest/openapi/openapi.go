@@ -0,0 +1,760 @@
+package openapi
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+	"github.com/rs/rest-layer/schema/encoding/jsonschema"
+)
+
+// Version is the version of the OpenAPI specification the documents comply
+// with.
+const Version = "3.1.0"
+
+// Config holds the general information of the generated document.
+type Config struct {
+	// Title is the title of the API, "REST API" if empty.
+	Title string
+	// Description is a description of the API, in CommonMark syntax.
+	Description string
+	// Version is the version of the API, "1.0.0" if empty.
+	Version string
+	// Servers lists the URLs the API is served on (i.e.:
+	// https://api.example.com/v1 or /api). The paths of the document are
+	// relative to them.
+	Servers []string
+}
+
+// generator builds the paths and the components of a document.
+type generator struct {
+	index   resource.Index
+	paths   map[string]interface{}
+	schemas map[string]interface{}
+}
+
+// Document returns the OpenAPI document of the API served for index. An error
+// is returned if the schema of a resource can't be described.
+func Document(index resource.Index, conf Config) (map[string]interface{}, error) {
+	g := &generator{
+		index:   index,
+		paths:   map[string]interface{}{},
+		schemas: map[string]interface{}{"Error": errorSchema(), "ImportSummary": importSummarySchema()},
+	}
+	for _, rsc := range index.GetResources() {
+		if err := g.addResource(rsc, "", nil); err != nil {
+			return nil, err
+		}
+	}
+	info := map[string]interface{}{
+		"title":   conf.Title,
+		"version": conf.Version,
+	}
+	if conf.Title == "" {
+		info["title"] = "REST API"
+	}
+	if conf.Version == "" {
+		info["version"] = "1.0.0"
+	}
+	if conf.Description != "" {
+		info["description"] = conf.Description
+	}
+	doc := map[string]interface{}{
+		"openapi": Version,
+		"info":    info,
+		"paths":   g.paths,
+		"components": map[string]interface{}{
+			"schemas":    g.schemas,
+			"parameters": windowParameters(),
+			"responses":  errorResponses(),
+		},
+	}
+	if len(conf.Servers) > 0 {
+		servers := make([]interface{}, 0, len(conf.Servers))
+		for _, u := range conf.Servers {
+			servers = append(servers, map[string]interface{}{"url": u})
+		}
+		doc["servers"] = servers
+	}
+	return doc, nil
+}
+
+// addResource adds the paths and the schemas of rsc and of its sub-resources,
+// prefixed by the path of its parent item and its parameters.
+func (g *generator) addResource(rsc *resource.Resource, prefix string, parentParams []interface{}) error {
+	name := rsc.Path()
+	item, err := g.itemSchema(rsc)
+	if err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	g.schemas[name] = item
+	patch := make(map[string]interface{}, len(item))
+	for k, v := range item {
+		if k != "required" {
+			patch[k] = v
+		}
+	}
+	g.schemas[name+"Patch"] = patch
+
+	conf := rsc.Conf()
+	collection := prefix + "/" + rsc.Name()
+	itemPath := collection + "/{id}"
+	idParam := pathParameter("id", g.idSchema(rsc), fmt.Sprintf("The id of the %s item.", rsc.Name()))
+	itemParams := append(append([]interface{}{}, parentParams...), idParam)
+
+	ops := map[string]interface{}{}
+	if conf.IsModeAllowed(resource.List) {
+		ops["get"] = g.listOperation(rsc, name+".list", "List "+rsc.Name()+".")
+	}
+	if conf.IsModeAllowed(resource.Create) {
+		// An NDJSON body imports one item per line.
+		body := jsonBody(schemaRef(name))
+		body["content"].(map[string]interface{})["application/x-ndjson"] = map[string]interface{}{
+			"schema": schemaRef(name),
+		}
+		ops["post"] = operation(name+".create", "Create a "+rsc.Name()+" item, or import items from an NDJSON stream.",
+			[]interface{}{g.fieldsParameter(rsc), map[string]interface{}{
+				"name":        "on_error",
+				"in":          "query",
+				"description": "The behavior of NDJSON imports on a rejected line: stop the import (default) or continue with the next line.",
+				"schema":      map[string]interface{}{"enum": []string{"stop", "continue"}},
+			}},
+			body,
+			responses("201", itemResponse("Created", name), "200", map[string]interface{}{
+				"description": "Imported",
+				"content":     jsonContent(schemaRef("ImportSummary")),
+			}, "409", "422"))
+	}
+	if conf.IsModeAllowed(resource.Clear) {
+		ops["delete"] = operation(name+".clear", "Delete the "+rsc.Name()+" items matching the filter.",
+			append(g.filterParameters(rsc), ref("parameters", "limit"), ref("parameters", "page"), ref("parameters", "skip")),
+			nil,
+			responses("204", map[string]interface{}{
+				"description": "Deleted",
+				"headers": map[string]interface{}{
+					"X-Total": header("The number of deleted items.", "integer"),
+				},
+			}, "422"))
+	}
+	g.addPath(collection, parentParams, ops)
+
+	ops = map[string]interface{}{}
+	if conf.IsModeAllowed(resource.Read) {
+		ops["get"] = operation(name+".get", "Get a "+rsc.Name()+" item.",
+			[]interface{}{g.fieldsParameter(rsc)},
+			nil,
+			responses("200", itemResponse("OK", name), "304", "404"))
+	}
+	if conf.IsModeAllowed(resource.Create) || conf.IsModeAllowed(resource.Replace) {
+		ops["put"] = operation(name+".replace", "Create or replace a "+rsc.Name()+" item.",
+			[]interface{}{g.fieldsParameter(rsc)},
+			jsonBody(schemaRef(name)),
+			responses("200", itemResponse("Replaced", name), "201", itemResponse("Created", name), "412", "422"))
+	}
+	if conf.IsModeAllowed(resource.Update) {
+		body := jsonBody(schemaRef(name + "Patch"))
+		body["content"].(map[string]interface{})["application/json-patch+json"] = map[string]interface{}{
+			"schema": jsonPatchSchema(),
+		}
+		body["content"].(map[string]interface{})["application/merge-patch+json"] = map[string]interface{}{
+			"schema": schemaRef(name + "Patch"),
+		}
+		ops["patch"] = operation(name+".update", "Update a "+rsc.Name()+" item.",
+			[]interface{}{g.fieldsParameter(rsc)},
+			body,
+			responses("200", itemResponse("Updated", name), "404", "412", "422"))
+	}
+	if conf.IsModeAllowed(resource.Delete) {
+		ops["delete"] = operation(name+".delete", "Delete a "+rsc.Name()+" item.",
+			nil,
+			nil,
+			responses("204", map[string]interface{}{"description": "Deleted"}, "404", "412"))
+	}
+	g.addPath(itemPath, itemParams, ops)
+
+	if conf.IsModeAllowed(resource.List) {
+		aliases := rsc.GetAliases()
+		sort.Strings(aliases)
+		for _, alias := range aliases {
+			v, _ := rsc.GetAlias(alias)
+			g.addPath(collection+"/"+alias, parentParams, map[string]interface{}{
+				"get": g.listOperation(rsc, name+".alias."+alias, fmt.Sprintf("List %s with %s.", rsc.Name(), aliasQuery(v))),
+			})
+		}
+	}
+	if conf.IsModeAllowed(resource.Create) || conf.IsModeAllowed(resource.Update) {
+		for _, command := range rsc.GetCollectionCommandNames() {
+			spec, _ := rsc.GetCollectionCommandSpec(command)
+			payload, err := g.payloadSchema(rsc, spec)
+			if err != nil {
+				return fmt.Errorf("%s: command %s: %v", name, command, err)
+			}
+			params := g.filterParameters(rsc)
+			if p := g.sortParameter(rsc); p != nil {
+				params = append(params, p)
+			}
+			g.addPath(collection+"/"+command, parentParams, map[string]interface{}{
+				"post": operation(name+".collection."+command, commandSummary(spec, fmt.Sprintf("Execute the %s command on the %s items matching the filter.", command, rsc.Name())),
+					params,
+					jsonBody(payload),
+					responses("200", commandResponse(nil), "422")),
+			})
+		}
+	}
+	if conf.IsModeAllowed(resource.Read) {
+		for _, command := range rsc.GetReadOnlyCommandNames() {
+			spec, _ := rsc.GetCommandSpec(command)
+			params, err := g.payloadParameters(rsc, spec)
+			if err != nil {
+				return fmt.Errorf("%s: command %s: %v", name, command, err)
+			}
+			g.addPath(itemPath+"/"+command, itemParams, map[string]interface{}{
+				"get": operation(name+".command."+command, commandSummary(spec, fmt.Sprintf("Execute the %s read-only command on a %s item.", command, rsc.Name())),
+					params,
+					nil,
+					responses("200", commandResponse(nil), "304", "404", "422")),
+			})
+		}
+	}
+	if conf.IsModeAllowed(resource.Create) || conf.IsModeAllowed(resource.Replace) {
+		for _, command := range rsc.GetCommandNames() {
+			spec, _ := rsc.GetCommandSpec(command)
+			payload, err := g.payloadSchema(rsc, spec)
+			if err != nil {
+				return fmt.Errorf("%s: command %s: %v", name, command, err)
+			}
+			resps := responses("200", commandResponse(schemaRef(name)), "404", "412", "422")
+			if q, _, async := rsc.GetAsyncCommand(command); async {
+				delete(resps, "200")
+				resps["202"] = map[string]interface{}{
+					"description": "Queued",
+					"headers": map[string]interface{}{
+						"Location": header("The URL of the job executing the command.", "string"),
+					},
+					"content": jsonContent(schemaRef(q.Jobs().Path())),
+				}
+			}
+			g.addPath(itemPath+"/"+command, itemParams, map[string]interface{}{
+				"put": operation(name+".command."+command, commandSummary(spec, fmt.Sprintf("Execute the %s command on a %s item.", command, rsc.Name())),
+					[]interface{}{g.fieldsParameter(rsc)},
+					jsonBody(payload),
+					resps),
+			})
+		}
+	}
+
+	for _, sub := range rsc.GetResources() {
+		field := sub.ParentField()
+		param := pathParameter(field, g.idSchema(rsc), fmt.Sprintf("The id of the parent %s item.", rsc.Name()))
+		if err := g.addResource(sub, collection+"/{"+field+"}", append(append([]interface{}{}, parentParams...), param)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// payloadSchema returns the schema of the payload of a command of rsc, any
+// object if spec has no payload schema.
+func (g *generator) payloadSchema(rsc *resource.Resource, spec resource.CommandSpec) (map[string]interface{}, error) {
+	if spec.Payload == nil {
+		return map[string]interface{}{"type": "object"}, nil
+	}
+	return g.objectSchema(rsc, *spec.Payload)
+}
+
+// payloadParameters returns the query-string parameters of a read-only
+// command of rsc, one for each field of its payload schema.
+func (g *generator) payloadParameters(rsc *resource.Resource, spec resource.CommandSpec) ([]interface{}, error) {
+	if spec.Payload == nil {
+		return nil, nil
+	}
+	names := make([]string, 0, len(spec.Payload.Fields))
+	for name := range spec.Payload.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	params := make([]interface{}, 0, len(names))
+	for _, name := range names {
+		f := spec.Payload.Fields[name]
+		if f.ReadOnly {
+			continue
+		}
+		s, err := g.fieldSchema(rsc, f)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		p := map[string]interface{}{
+			"name":   name,
+			"in":     "query",
+			"schema": s,
+		}
+		if f.Required {
+			p["required"] = true
+		}
+		if f.Description != "" {
+			p["description"] = f.Description
+		}
+		params = append(params, p)
+	}
+	return params, nil
+}
+
+// commandSummary returns the summary of a command operation: the description
+// of its spec, or def.
+func commandSummary(spec resource.CommandSpec, def string) string {
+	if spec.Description != "" {
+		return spec.Description
+	}
+	return def
+}
+
+// commandResponse returns the response of a command, including the item it
+// was executed on if item is not nil.
+func commandResponse(item map[string]interface{}) map[string]interface{} {
+	props := map[string]interface{}{
+		"response": map[string]interface{}{"description": "The response of the command."},
+	}
+	if item != nil {
+		props["item"] = item
+	}
+	return map[string]interface{}{
+		"description": "Executed",
+		"content": jsonContent(map[string]interface{}{
+			"type":       "object",
+			"properties": props,
+		}),
+	}
+}
+
+// addPath adds a path item with ops if any.
+func (g *generator) addPath(path string, params []interface{}, ops map[string]interface{}) {
+	if len(ops) == 0 {
+		return
+	}
+	if len(params) > 0 {
+		ops["parameters"] = params
+	}
+	g.paths[path] = ops
+}
+
+// listOperation returns a list operation on rsc.
+func (g *generator) listOperation(rsc *resource.Resource, id, summary string) map[string]interface{} {
+	params := append(g.filterParameters(rsc), g.sortParameter(rsc), g.fieldsParameter(rsc),
+		ref("parameters", "limit"), ref("parameters", "page"), ref("parameters", "skip"), ref("parameters", "total"))
+	var p []interface{}
+	for _, param := range params {
+		if param != nil {
+			p = append(p, param)
+		}
+	}
+	return operation(id, summary, p, nil, responses("200", map[string]interface{}{
+		"description": "OK",
+		"headers": map[string]interface{}{
+			"Etag":          header("The etag of the list.", "string"),
+			"Last-Modified": header("The update time of the most recently updated item.", "string"),
+			"X-Total":       header("The total number of items matching the filter, if computed.", "integer"),
+			"Link":          header("The links to the first, previous, next and last pages.", "string"),
+		},
+		"content": jsonContent(map[string]interface{}{
+			"type":  "array",
+			"items": schemaRef(rsc.Path()),
+		}),
+	}, "304", "422"))
+}
+
+// filterParameters returns the filter parameter of rsc, if any of its fields
+// is filterable.
+func (g *generator) filterParameters(rsc *resource.Resource) []interface{} {
+	fields := fieldNames(rsc.Schema(), func(f schema.Field) bool { return f.Filterable })
+	if len(fields) == 0 {
+		return nil
+	}
+	return []interface{}{map[string]interface{}{
+		"name":        "filter",
+		"in":          "query",
+		"description": "A query in the REST Layer filter syntax (i.e.: {\"field\": {\"$gt\": 1}}) on the filterable fields: " + strings.Join(fields, ", ") + ".",
+		"schema":      map[string]interface{}{"type": "string"},
+	}}
+}
+
+// sortParameter returns the sort parameter of rsc, or nil if none of its
+// fields is sortable.
+func (g *generator) sortParameter(rsc *resource.Resource) interface{} {
+	fields := fieldNames(rsc.Schema(), func(f schema.Field) bool { return f.Sortable })
+	if len(fields) == 0 {
+		return nil
+	}
+	return map[string]interface{}{
+		"name":        "sort",
+		"in":          "query",
+		"description": "A comma separated list of fields to sort by, prefixed by - for descending order. Sortable fields: " + strings.Join(fields, ", ") + ".",
+		"schema":      map[string]interface{}{"type": "string"},
+	}
+}
+
+// fieldsParameter returns the fields parameter of rsc, listing the parameters
+// of its fields.
+func (g *generator) fieldsParameter(rsc *resource.Resource) map[string]interface{} {
+	desc := "A comma separated list of the fields to return, with optional aliases, parameters and embedded fields (i.e.: id,title,author{name})."
+	s := rsc.Schema()
+	var params []string
+	for _, name := range fieldNames(s, func(f schema.Field) bool { return len(f.Params) > 0 }) {
+		names := make([]string, 0, len(s.Fields[name].Params))
+		for p := range s.Fields[name].Params {
+			names = append(names, p)
+		}
+		sort.Strings(names)
+		params = append(params, name+"("+strings.Join(names, ", ")+")")
+	}
+	if len(params) > 0 {
+		desc += " Field parameters: " + strings.Join(params, ", ") + "."
+	}
+	return map[string]interface{}{
+		"name":        "fields",
+		"in":          "query",
+		"description": desc,
+		"schema":      map[string]interface{}{"type": "string"},
+	}
+}
+
+// itemSchema returns the schema of the items of rsc.
+func (g *generator) itemSchema(rsc *resource.Resource) (map[string]interface{}, error) {
+	return g.objectSchema(rsc, rsc.Schema())
+}
+
+// objectSchema returns the schema of the documents validated by s, with
+// references relative to rsc.
+func (g *generator) objectSchema(rsc *resource.Resource, s schema.Schema) (map[string]interface{}, error) {
+	m := map[string]interface{}{"type": "object"}
+	if s.Description != "" {
+		m["description"] = s.Description
+	}
+	props := make(map[string]interface{}, len(s.Fields))
+	required := []string{}
+	for name, f := range s.Fields {
+		p, err := g.fieldSchema(rsc, f)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		props[name] = p
+		if _, isConn := f.Validator.(*schema.Connection); f.Required && !isConn {
+			required = append(required, name)
+		}
+	}
+	m["properties"] = props
+	if len(required) > 0 {
+		sort.Strings(required)
+		m["required"] = required
+	}
+	return m, nil
+}
+
+// fieldSchema returns the schema of the field f of rsc. References and
+// connections are described as the referenced items, as they can be embedded
+// by projections, and sub-documents by the schema of their fields.
+func (g *generator) fieldSchema(rsc *resource.Resource, f schema.Field) (map[string]interface{}, error) {
+	var m map[string]interface{}
+	if f.Schema != nil {
+		// Sub-schemas take precedence over the validator.
+		f.Validator = nil
+	}
+	switch v := f.Validator.(type) {
+	case nil:
+		m = map[string]interface{}{}
+		if f.Schema != nil {
+			var err error
+			if m, err = g.objectSchema(rsc, *f.Schema); err != nil {
+				return nil, err
+			}
+		}
+	case *schema.Reference:
+		m = map[string]interface{}{}
+		if target, found := g.index.GetResource(v.Path, rsc); found {
+			m["anyOf"] = []interface{}{g.idSchema(target), schemaRef(target.Path())}
+		}
+	case *schema.Connection:
+		m = map[string]interface{}{"type": "array", "readOnly": true}
+		if target, found := g.index.GetResource(v.Path, rsc); found {
+			m["items"] = schemaRef(target.Path())
+		}
+	default:
+		var err error
+		if m, err = validatorSchema(f.Validator); err != nil {
+			return nil, err
+		}
+	}
+	if f.Description != "" {
+		m["description"] = f.Description
+	}
+	if f.ReadOnly {
+		m["readOnly"] = true
+	}
+	if f.Hidden {
+		m["writeOnly"] = true
+	}
+	if f.Default != nil {
+		m["default"] = f.Default
+	}
+	if len(f.Params) > 0 {
+		params := make(map[string]interface{}, len(f.Params))
+		for name, p := range f.Params {
+			ps, err := validatorSchema(p.Validator)
+			if err != nil {
+				return nil, fmt.Errorf("param %s: %v", name, err)
+			}
+			if p.Description != "" {
+				ps["description"] = p.Description
+			}
+			params[name] = ps
+		}
+		m["x-params"] = params
+	}
+	return m, nil
+}
+
+// idSchema returns the schema of the id of the items of rsc, a string if it
+// can't be described.
+func (g *generator) idSchema(rsc *resource.Resource) map[string]interface{} {
+	if f, found := rsc.Schema().Fields["id"]; found {
+		if m, err := validatorSchema(f.Validator); err == nil && len(m) > 0 {
+			return m
+		}
+	}
+	return map[string]interface{}{"type": "string"}
+}
+
+// validatorSchema returns the JSON Schema of v. Validators not supported by
+// the jsonschema package are described by an empty schema.
+func validatorSchema(v schema.FieldValidator) (map[string]interface{}, error) {
+	b, err := jsonschema.ValidatorBuilder(v)
+	if err == jsonschema.ErrNotImplemented {
+		return map[string]interface{}{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	m, err := b.BuildJSONSchema()
+	if err == jsonschema.ErrNotImplemented {
+		return map[string]interface{}{}, nil
+	}
+	return m, err
+}
+
+// fieldNames returns the sorted names of the fields of s matching fn.
+func fieldNames(s schema.Schema, fn func(f schema.Field) bool) []string {
+	var names []string
+	for name, f := range s.Fields {
+		if fn(f) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// aliasQuery returns the query-string of an alias with its parameters sorted.
+func aliasQuery(v url.Values) string {
+	q, _ := url.QueryUnescape(v.Encode())
+	return q
+}
+
+func operation(id, summary string, params []interface{}, body map[string]interface{}, resps map[string]interface{}) map[string]interface{} {
+	op := map[string]interface{}{
+		"operationId": id,
+		"summary":     summary,
+		"responses":   resps,
+	}
+	if len(params) > 0 {
+		op["parameters"] = params
+	}
+	if body != nil {
+		op["requestBody"] = body
+	}
+	return op
+}
+
+// responses returns the responses of an operation from code and response
+// pairs. Error codes may be given without response, in which case the error
+// response with the same code is referenced. The default response is an
+// error.
+func responses(codesAndResponses ...interface{}) map[string]interface{} {
+	resps := map[string]interface{}{"default": ref("responses", "Error")}
+	for i := 0; i < len(codesAndResponses); i++ {
+		code := codesAndResponses[i].(string)
+		if i+1 < len(codesAndResponses) {
+			if r, ok := codesAndResponses[i+1].(map[string]interface{}); ok {
+				resps[code] = r
+				i++
+				continue
+			}
+		}
+		resps[code] = ref("responses", code)
+	}
+	return resps
+}
+
+func itemResponse(desc, name string) map[string]interface{} {
+	return map[string]interface{}{
+		"description": desc,
+		"headers": map[string]interface{}{
+			"Etag":          header("The etag of the item.", "string"),
+			"Last-Modified": header("The update time of the item.", "string"),
+		},
+		"content": jsonContent(schemaRef(name)),
+	}
+}
+
+func header(desc, typ string) map[string]interface{} {
+	return map[string]interface{}{
+		"description": desc,
+		"schema":      map[string]interface{}{"type": typ},
+	}
+}
+
+func pathParameter(name string, s map[string]interface{}, desc string) map[string]interface{} {
+	return map[string]interface{}{
+		"name":        name,
+		"in":          "path",
+		"required":    true,
+		"description": desc,
+		"schema":      s,
+	}
+}
+
+func jsonBody(s map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"required": true,
+		"content":  jsonContent(s),
+	}
+}
+
+func jsonContent(s map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"application/json": map[string]interface{}{"schema": s},
+	}
+}
+
+func schemaRef(name string) map[string]interface{} {
+	return ref("schemas", name)
+}
+
+func ref(kind, name string) map[string]interface{} {
+	return map[string]interface{}{"$ref": "#/components/" + kind + "/" + name}
+}
+
+// windowParameters returns the pagination parameters shared by all the lists.
+func windowParameters() map[string]interface{} {
+	integer := func(min int) map[string]interface{} {
+		return map[string]interface{}{"type": "integer", "minimum": min}
+	}
+	return map[string]interface{}{
+		"limit": map[string]interface{}{
+			"name":        "limit",
+			"in":          "query",
+			"description": "The maximum number of items to return.",
+			"schema":      integer(0),
+		},
+		"page": map[string]interface{}{
+			"name":        "page",
+			"in":          "query",
+			"description": "The page to return, starting at 1.",
+			"schema":      integer(1),
+		},
+		"skip": map[string]interface{}{
+			"name":        "skip",
+			"in":          "query",
+			"description": "The number of items to skip before the page.",
+			"schema":      integer(0),
+		},
+		"total": map[string]interface{}{
+			"name":        "total",
+			"in":          "query",
+			"description": "If 1, the total number of items is returned in the X-Total header.",
+			"schema":      map[string]interface{}{"type": "integer", "enum": []int{0, 1}},
+		},
+	}
+}
+
+// errorSchema returns the schema of the errors returned by rest.Handler.
+func errorSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type":     "object",
+		"required": []string{"code", "message"},
+		"properties": map[string]interface{}{
+			"code":    map[string]interface{}{"type": "integer"},
+			"message": map[string]interface{}{"type": "string"},
+			"issues": map[string]interface{}{
+				"description": "The errors of each field or parameter.",
+				"type":        "object",
+				"additionalProperties": map[string]interface{}{
+					"type": "array",
+				},
+			},
+		},
+	}
+}
+
+// importSummarySchema returns the schema of the summary of NDJSON imports.
+func importSummarySchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type":     "object",
+		"required": []string{"accepted", "rejected", "errors"},
+		"properties": map[string]interface{}{
+			"accepted": map[string]interface{}{"type": "integer"},
+			"rejected": map[string]interface{}{"type": "integer"},
+			"errors": map[string]interface{}{
+				"description": "The errors of the first rejected lines.",
+				"type":        "array",
+				"items": map[string]interface{}{
+					"allOf": []interface{}{
+						schemaRef("Error"),
+						map[string]interface{}{
+							"type":       "object",
+							"properties": map[string]interface{}{"line": map[string]interface{}{"type": "integer"}},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// errorResponses returns the error responses referenced by the operations.
+func errorResponses() map[string]interface{} {
+	resps := map[string]interface{}{
+		"304": map[string]interface{}{"description": "Not Modified"},
+	}
+	for code, desc := range map[string]string{
+		"Error": "Error",
+		"404":   "Not Found",
+		"409":   "Conflict",
+		"412":   "Precondition Failed",
+		"422":   "Unprocessable Entity",
+	} {
+		resps[code] = map[string]interface{}{
+			"description": desc,
+			"content":     jsonContent(schemaRef("Error")),
+		}
+	}
+	return resps
+}
+
+// jsonPatchSchema returns the schema of a JSON Patch document (RFC 6902).
+func jsonPatchSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "array",
+		"items": map[string]interface{}{
+			"type":     "object",
+			"required": []string{"op", "path"},
+			"properties": map[string]interface{}{
+				"op":    map[string]interface{}{"enum": []string{"add", "remove", "replace", "move", "copy", "test"}},
+				"path":  map[string]interface{}{"type": "string"},
+				"from":  map[string]interface{}{"type": "string"},
+				"value": map[string]interface{}{},
+			},
+		},
+	}
+}
@@ -0,0 +1,245 @@
+package openapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/resource/jobs"
+	"github.com/rs/rest-layer/schema"
+	"github.com/rs/rest-layer/schema/query"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestIndex() resource.Index {
+	index := resource.NewIndex()
+	users := index.Bind("users", schema.Schema{
+		Description: "A user",
+		Fields: schema.Fields{
+			"id": schema.IDField,
+			"name": {
+				Required:   true,
+				Filterable: true,
+				Sortable:   true,
+				Validator:  &schema.String{MaxLen: 150},
+				Params: schema.Params{
+					"upper": {Description: "Upper case the name", Validator: &schema.Bool{}},
+				},
+			},
+			"password": {Hidden: true, Validator: &schema.Password{}},
+			"posts":    {Validator: &schema.Connection{Path: ".posts", Field: "user"}},
+		},
+	}, nil, resource.DefaultConf)
+	users.Alias("admins", url.Values{"filter": {`{"admin":true}`}})
+	users.Command("notify", func(ctx context.Context, r *http.Request, item *resource.Item, payload map[string]interface{}) (http.Header, *resource.Item, map[string]interface{}, error) {
+		return nil, item, nil, nil
+	})
+	users.ReadOnlyCommand("summary", resource.CommandSpec{
+		Description: "Summarize a user.",
+		Payload: &schema.Schema{Fields: schema.Fields{
+			"format": {Required: true, Validator: &schema.String{Allowed: []string{"short", "long"}}},
+		}},
+	}, func(ctx context.Context, r *http.Request, item *resource.Item, payload map[string]interface{}) (http.Header, map[string]interface{}, error) {
+		return nil, nil, nil
+	})
+	users.CollectionCommand("reindex", resource.CommandSpec{
+		Payload: &schema.Schema{Fields: schema.Fields{"full": {Validator: &schema.Bool{}}}},
+	}, func(ctx context.Context, r *http.Request, q *query.Query, payload map[string]interface{}) (http.Header, map[string]interface{}, error) {
+		return nil, nil, nil
+	})
+	jobsRsrc := index.Bind("jobs", jobs.Schema, nil, resource.Conf{AllowedModes: []resource.Mode{resource.Read, resource.Delete}})
+	users.AsyncCommand("export", jobs.NewPool(jobsRsrc), func(ctx context.Context, item *resource.Item, payload map[string]interface{}, progress func(float64)) (map[string]interface{}, error) {
+		return nil, nil
+	})
+	users.Bind("posts", "user", schema.Schema{Fields: schema.Fields{
+		"id":    {Validator: &schema.Integer{}},
+		"user":  {Validator: &schema.Reference{Path: "users"}},
+		"title": {Required: true, Validator: &schema.String{}},
+		"meta": {Description: "Metadata", Schema: &schema.Schema{Fields: schema.Fields{
+			"lang":   {Required: true, Validator: &schema.String{}},
+			"editor": {Validator: &schema.Reference{Path: "users"}},
+		}}},
+	}}, nil, resource.Conf{AllowedModes: resource.ReadOnly})
+	return index
+}
+
+func TestDocument(t *testing.T) {
+	doc, err := Document(newTestIndex(), Config{Title: "Test", Servers: []string{"/api"}})
+	if !assert.NoError(t, err) {
+		return
+	}
+	// Compare with the JSON representation of the document.
+	var d map[string]interface{}
+	b, err := json.Marshal(doc)
+	if !assert.NoError(t, err) || !assert.NoError(t, json.Unmarshal(b, &d)) {
+		return
+	}
+	assert.Equal(t, "3.1.0", d["openapi"])
+	assert.Equal(t, map[string]interface{}{"title": "Test", "version": "1.0.0"}, d["info"])
+	assert.Equal(t, []interface{}{map[string]interface{}{"url": "/api"}}, d["servers"])
+
+	paths := d["paths"].(map[string]interface{})
+	methods := map[string][]string{}
+	for path, item := range paths {
+		for m := range item.(map[string]interface{}) {
+			if m != "parameters" {
+				methods[path] = append(methods[path], m)
+			}
+		}
+		sort.Strings(methods[path])
+	}
+	assert.Equal(t, map[string][]string{
+		"/users":                   {"delete", "get", "post"},
+		"/users/{id}":              {"delete", "get", "patch", "put"},
+		"/users/admins":            {"get"},
+		"/users/{id}/notify":       {"put"},
+		"/users/{id}/export":       {"put"},
+		"/users/{id}/summary":      {"get"},
+		"/users/reindex":           {"post"},
+		"/jobs/{id}":               {"delete", "get"},
+		"/users/{user}/posts":      {"get"},
+		"/users/{user}/posts/{id}": {"get"},
+	}, methods)
+
+	list := paths["/users"].(map[string]interface{})["get"].(map[string]interface{})
+	assert.Equal(t, "users.list", list["operationId"])
+	var params []string
+	for _, p := range list["parameters"].([]interface{}) {
+		p := p.(map[string]interface{})
+		if r, ok := p["$ref"]; ok {
+			params = append(params, r.(string))
+		} else {
+			params = append(params, p["name"].(string))
+		}
+	}
+	assert.Equal(t, []string{"filter", "sort", "fields",
+		"#/components/parameters/limit", "#/components/parameters/page", "#/components/parameters/skip", "#/components/parameters/total"}, params)
+	fields := list["parameters"].([]interface{})[2].(map[string]interface{})
+	assert.Contains(t, fields["description"], "Field parameters: name(upper).")
+	assert.Equal(t, "List users with filter={\"admin\":true}.", paths["/users/admins"].(map[string]interface{})["get"].(map[string]interface{})["summary"])
+
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{
+			"name":        "user",
+			"in":          "path",
+			"required":    true,
+			"description": "The id of the parent users item.",
+			"schema":      map[string]interface{}{"type": "string", "pattern": "^[0-9a-v]{20}$"},
+		},
+		map[string]interface{}{
+			"name":        "id",
+			"in":          "path",
+			"required":    true,
+			"description": "The id of the posts item.",
+			"schema":      map[string]interface{}{"type": "integer"},
+		},
+	}, paths["/users/{user}/posts/{id}"].(map[string]interface{})["parameters"])
+
+	patch := paths["/users/{id}"].(map[string]interface{})["patch"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"$ref": "#/components/schemas/usersPatch"},
+		patch["requestBody"].(map[string]interface{})["content"].(map[string]interface{})["application/json"].(map[string]interface{})["schema"])
+	assert.Equal(t, map[string]interface{}{"$ref": "#/components/schemas/usersPatch"},
+		patch["requestBody"].(map[string]interface{})["content"].(map[string]interface{})["application/merge-patch+json"].(map[string]interface{})["schema"])
+	assert.Equal(t, map[string]interface{}{"$ref": "#/components/responses/412"}, patch["responses"].(map[string]interface{})["412"])
+
+	create := paths["/users"].(map[string]interface{})["post"].(map[string]interface{})
+	assert.Contains(t, create["requestBody"].(map[string]interface{})["content"], "application/x-ndjson")
+	assert.Equal(t, map[string]interface{}{"$ref": "#/components/schemas/ImportSummary"},
+		create["responses"].(map[string]interface{})["200"].(map[string]interface{})["content"].(map[string]interface{})["application/json"].(map[string]interface{})["schema"])
+
+	export := paths["/users/{id}/export"].(map[string]interface{})["put"].(map[string]interface{})["responses"].(map[string]interface{})
+	assert.NotContains(t, export, "200")
+	assert.Equal(t, map[string]interface{}{"$ref": "#/components/schemas/jobs"},
+		export["202"].(map[string]interface{})["content"].(map[string]interface{})["application/json"].(map[string]interface{})["schema"])
+	assert.Contains(t, paths["/users/{id}/notify"].(map[string]interface{})["put"].(map[string]interface{})["responses"], "200")
+
+	summary := paths["/users/{id}/summary"].(map[string]interface{})["get"].(map[string]interface{})
+	assert.Equal(t, "Summarize a user.", summary["summary"])
+	assert.Equal(t, []interface{}{map[string]interface{}{
+		"name":     "format",
+		"in":       "query",
+		"required": true,
+		"schema":   map[string]interface{}{"type": "string", "enum": []interface{}{"short", "long"}},
+	}}, summary["parameters"])
+	reindex := paths["/users/reindex"].(map[string]interface{})["post"].(map[string]interface{})
+	assert.Equal(t, "users.collection.reindex", reindex["operationId"])
+	assert.Equal(t, map[string]interface{}{
+		"type":       "object",
+		"properties": map[string]interface{}{"full": map[string]interface{}{"type": "boolean"}},
+	}, reindex["requestBody"].(map[string]interface{})["content"].(map[string]interface{})["application/json"].(map[string]interface{})["schema"])
+
+	schemas := d["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{
+		"type":        "object",
+		"description": "A user",
+		"required":    []interface{}{"id", "name"},
+		"properties": map[string]interface{}{
+			"id": map[string]interface{}{
+				"type":        "string",
+				"pattern":     "^[0-9a-v]{20}$",
+				"description": "The item's id",
+				"readOnly":    true,
+			},
+			"name": map[string]interface{}{
+				"type":      "string",
+				"maxLength": float64(150),
+				"x-params": map[string]interface{}{
+					"upper": map[string]interface{}{"type": "boolean", "description": "Upper case the name"},
+				},
+			},
+			"password": map[string]interface{}{"type": "string", "format": "password", "writeOnly": true},
+			"posts": map[string]interface{}{
+				"type":     "array",
+				"readOnly": true,
+				"items":    map[string]interface{}{"$ref": "#/components/schemas/users.posts"},
+			},
+		},
+	}, schemas["users"])
+	assert.NotContains(t, schemas["usersPatch"], "required")
+	assert.Equal(t, map[string]interface{}{
+		"anyOf": []interface{}{
+			map[string]interface{}{"type": "string", "pattern": "^[0-9a-v]{20}$"},
+			map[string]interface{}{"$ref": "#/components/schemas/users"},
+		},
+	}, schemas["users.posts"].(map[string]interface{})["properties"].(map[string]interface{})["user"])
+	assert.Equal(t, map[string]interface{}{
+		"type":        "object",
+		"description": "Metadata",
+		"required":    []interface{}{"lang"},
+		"properties": map[string]interface{}{
+			"lang": map[string]interface{}{"type": "string"},
+			"editor": map[string]interface{}{
+				"anyOf": []interface{}{
+					map[string]interface{}{"type": "string", "pattern": "^[0-9a-v]{20}$"},
+					map[string]interface{}{"$ref": "#/components/schemas/users"},
+				},
+			},
+		},
+	}, schemas["users.posts"].(map[string]interface{})["properties"].(map[string]interface{})["meta"])
+	assert.Contains(t, schemas, "Error")
+	assert.Contains(t, schemas, "ImportSummary")
+}
+
+func TestHandler(t *testing.T) {
+	h, err := NewHandler(newTestIndex(), Config{})
+	if !assert.NoError(t, err) {
+		return
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/openapi.json", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	var doc map[string]interface{}
+	if assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &doc)) {
+		assert.Equal(t, map[string]interface{}{"title": "REST API", "version": "1.0.0"}, doc["info"])
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "/openapi.json", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
@@ -0,0 +1,67 @@
+package rest
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/rs/rest-layer/resource"
+)
+
+// pageRelations lists the link relations of PageLinks in order.
+var pageRelations = []string{"first", "prev", "next", "last"}
+
+// PageLinks returns the query parameters of the pages around the list l
+// returned for route, keyed by link relation: first, prev, next and last. The
+// parameters of the request are kept, page being set to the page of the
+// relation. Relations without a page, like prev on the first page or last when
+// the total is unknown, are omitted. If the list is not paginated, nil is
+// returned.
+func PageLinks(route *RouteMatch, l *resource.ItemList) map[string]url.Values {
+	rsc := route.Resource()
+	if rsc == nil {
+		return nil
+	}
+	p := parsePageParams(route.Params, rsc.Conf().PaginationDefaultLimit, func(string, interface{}) {})
+	if p.limit <= 0 {
+		return nil
+	}
+	if p.page < 1 {
+		p.page = 1
+	}
+	page := func(n int) url.Values {
+		q := make(url.Values, len(route.Params)+1)
+		for k, v := range route.Params {
+			q[k] = v
+		}
+		q.Set("page", strconv.Itoa(n))
+		return q
+	}
+	links := map[string]url.Values{"first": page(1)}
+	if p.page > 1 {
+		links["prev"] = page(p.page - 1)
+	}
+	if (l.Total >= 0 && p.skip+p.page*p.limit < l.Total) || (l.Total < 0 && len(l.Items) >= p.limit) {
+		links["next"] = page(p.page + 1)
+	}
+	if l.Total >= 0 {
+		last := (l.Total - p.skip + p.limit - 1) / p.limit
+		if last < 1 {
+			last = 1
+		}
+		links["last"] = page(last)
+	}
+	return links
+}
+
+// linkHeader formats links as the value of an RFC 8288 Link header. The URLs
+// are query-only references, relative to the URL of the request.
+func linkHeader(links map[string]url.Values) string {
+	values := make([]string, 0, len(links))
+	for _, rel := range pageRelations {
+		if q, found := links[rel]; found {
+			values = append(values, `<?`+q.Encode()+`>; rel="`+rel+`"`)
+		}
+	}
+	return strings.Join(values, ", ")
+}
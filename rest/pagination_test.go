@@ -0,0 +1,137 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/resource/testing/mem"
+	"github.com/rs/rest-layer/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPageLinks(t *testing.T) {
+	index := resource.NewIndex()
+	index.Bind("users", schema.Schema{}, nil, resource.Conf{PaginationDefaultLimit: 10})
+	index.Bind("all", schema.Schema{}, nil, resource.Conf{})
+	items := func(n int) []*resource.Item {
+		return make([]*resource.Item, n)
+	}
+	cases := []struct {
+		target string
+		list   *resource.ItemList
+		want   map[string]string
+	}{
+		{"/users", &resource.ItemList{Total: 25, Items: items(10)}, map[string]string{
+			"first": "page=1",
+			"next":  "page=2",
+			"last":  "page=3",
+		}},
+		{"/users?page=3&sort=-id&filter=%7B%7D", &resource.ItemList{Total: 25, Items: items(5)}, map[string]string{
+			"first": "filter=%7B%7D&page=1&sort=-id",
+			"prev":  "filter=%7B%7D&page=2&sort=-id",
+			"last":  "filter=%7B%7D&page=3&sort=-id",
+		}},
+		{"/users?limit=5&skip=2&page=2", &resource.ItemList{Total: 13, Items: items(5)}, map[string]string{
+			"first": "limit=5&page=1&skip=2",
+			"prev":  "limit=5&page=1&skip=2",
+			"next":  "limit=5&page=3&skip=2",
+			"last":  "limit=5&page=3&skip=2",
+		}},
+		{"/users?page=2", &resource.ItemList{Total: -1, Items: items(10)}, map[string]string{
+			"first": "page=1",
+			"prev":  "page=1",
+			"next":  "page=3",
+		}},
+		{"/users", &resource.ItemList{Total: -1, Items: items(3)}, map[string]string{
+			"first": "page=1",
+		}},
+		{"/users", &resource.ItemList{Total: 0}, map[string]string{
+			"first": "page=1",
+			"last":  "page=1",
+		}},
+		{"/all", &resource.ItemList{Total: 25, Items: items(25)}, nil},
+		{"/all?limit=0", &resource.ItemList{Total: 0}, nil},
+	}
+	for _, tc := range cases {
+		route, err := FindRoute(index, httptest.NewRequest("GET", tc.target, nil))
+		if !assert.NoError(t, err, tc.target) {
+			continue
+		}
+		var got map[string]string
+		if links := PageLinks(route, tc.list); links != nil {
+			got = map[string]string{}
+			for rel, q := range links {
+				got[rel] = q.Encode()
+			}
+		}
+		assert.Equal(t, tc.want, got, tc.target)
+	}
+}
+
+func TestLinkHeader(t *testing.T) {
+	assert.Equal(t, `<?page=1>; rel="first", <?page=2>; rel="prev", <?page=3>; rel="last"`, linkHeader(map[string]url.Values{
+		"last":  {"page": {"3"}},
+		"first": {"page": {"1"}},
+		"prev":  {"page": {"2"}},
+	}))
+}
+
+func TestHandlerPagination(t *testing.T) {
+	s := mem.NewHandler()
+	for _, id := range []string{"1", "2", "3"} {
+		s.Insert(context.Background(), []*resource.Item{{ID: id, ETag: "e" + id, Payload: map[string]interface{}{"id": id}}})
+	}
+	index := resource.NewIndex()
+	index.Bind("users", schema.Schema{Fields: schema.Fields{"id": {Sortable: true}}}, s, resource.Conf{
+		AllowedModes:           resource.ReadOnly,
+		PaginationDefaultLimit: 2,
+		ForceTotal:             resource.TotalAlways,
+	})
+	h, err := NewHandler(index)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/users?sort=id&fields=id", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `<?fields=id&page=1&sort=id>; rel="first", <?fields=id&page=2&sort=id>; rel="next", <?fields=id&page=2&sort=id>; rel="last"`, w.Header().Get("Link"))
+	assert.JSONEq(t, `[{"id": "1", "_etag": "e1"}, {"id": "2", "_etag": "e2"}]`, w.Body.String())
+
+	h.ResponseFormatter = DefaultResponseFormatter{Envelope: true}
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/users?sort=id", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{
+		"items": [{"id": "1", "_etag": "e1"}, {"id": "2", "_etag": "e2"}],
+		"total": 3,
+		"offset": 0,
+		"limit": 2,
+		"next": "?page=2&sort=id"
+	}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/users?sort=id&page=2", nil))
+	var body map[string]interface{}
+	if assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body)) {
+		assert.Equal(t, map[string]interface{}{
+			"items":  []interface{}{map[string]interface{}{"id": "3", "_etag": "e3"}},
+			"total":  float64(3),
+			"offset": float64(2),
+			"limit":  float64(2),
+		}, body)
+	}
+	assert.Equal(t, `<?page=1&sort=id>; rel="first", <?page=1&sort=id>; rel="prev", <?page=2&sort=id>; rel="last"`, w.Header().Get("Link"))
+
+	r := httptest.NewRequest("GET", "/users?sort=id", nil)
+	r.Header.Set("Accept", "text/csv")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	assert.Equal(t, "text/csv", w.Header().Get("Content-Type"))
+	assert.Equal(t, "id,_etag\n1,e1\n2,e2\n", w.Body.String())
+}
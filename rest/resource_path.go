@@ -25,6 +25,8 @@ type ResourcePathComponent struct {
 	Resource *resource.Resource
 	// Command holds the command to execute on the resource
 	Command resource.Command
+	// CommandName holds the name of the command to execute on the resource
+	CommandName string
 }
 
 var resourcePathComponentPool = sync.Pool{
@@ -69,6 +71,7 @@ func (p *ResourcePath) append(rsrc *resource.Resource, field string, value inter
 	rp.Value = value
 	rp.Resource = rsrc
 	rp.Command = command
+	rp.CommandName = ""
 	*p = append(*p, rp)
 	return
 }
@@ -79,6 +82,8 @@ func (p *ResourcePath) clear() {
 		rp.Field = ""
 		rp.Value = nil
 		rp.Resource = nil
+		rp.Command = nil
+		rp.CommandName = ""
 		resourcePathComponentPool.Put(rp)
 		(*p)[i] = nil
 	}
@@ -1,11 +1,12 @@
 package rest
 
 import (
+	"bytes"
 	"context"
 	md5 "crypto/md5"
-	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
 	"time"
 
@@ -35,6 +36,12 @@ type ResponseSender interface {
 // default. This formatter can easily be extended or replaced by implementing
 // ResponseFormatter interface and setting it on Handler.ResponseFormatter.
 type DefaultResponseFormatter struct {
+	// Envelope, if true, wraps the items of lists in an object holding the
+	// pagination metadata: {"items": [...], "total": 42, "offset": 20,
+	// "limit": 10, "next": "?page=3"}. The total is omitted if unknown, the
+	// limit if the list is not paginated and next on the last page. Lists
+	// negotiated in a list only format, like CSV, are not wrapped.
+	Envelope bool
 }
 
 // DefaultResponseSender provides a base response sender to be used by default.
@@ -43,27 +50,36 @@ type DefaultResponseFormatter struct {
 type DefaultResponseSender struct {
 }
 
-// Send sends headers with the given status and marshal the data in JSON.
+// Send sends headers with the given status and marshal the data with the
+// encoder negotiated for the request (see EncoderFromContext), JSON by default.
 func (s DefaultResponseSender) Send(ctx context.Context, w http.ResponseWriter, status int, headers http.Header, body interface{}) {
-	headers.Set("Content-Type", "application/json")
-	// Apply headers to the response
-	for key, values := range headers {
-		for _, value := range values {
-			w.Header().Add(key, value)
+	enc := EncoderFromContext(ctx)
+	if le, ok := enc.(ListEncoder); ok && le.ListOnly() {
+		if _, isList := body.([]map[string]interface{}); !isList {
+			enc = JSONCodec{}
 		}
 	}
-	w.WriteHeader(status)
-
+	var buf bytes.Buffer
 	if body != nil {
-		j, err := json.Marshal(body)
-		if err != nil {
-			w.WriteHeader(500)
+		if err := enc.Encode(ctx, &buf, body); err != nil {
 			logErrorf(ctx, "Can't build response: %v", err)
 			msg := fmt.Sprintf("Can't build response: %q", err.Error())
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(500)
 			w.Write([]byte(fmt.Sprintf("{\"code\": 500, \"msg\": \"%s\"}", msg)))
 			return
 		}
-		if _, err = w.Write(j); err != nil {
+	}
+	headers.Set("Content-Type", enc.MediaType())
+	// Apply headers to the response
+	for key, values := range headers {
+		for _, value := range values {
+			w.Header().Add(key, value)
+		}
+	}
+	w.WriteHeader(status)
+	if body != nil {
+		if _, err := w.Write(buf.Bytes()); err != nil {
 			logErrorf(ctx, "Can't send response: %v", err)
 		}
 	}
@@ -92,13 +108,27 @@ func (f DefaultResponseFormatter) FormatList(ctx context.Context, headers http.H
 		headers.Set("X-Offset", strconv.Itoa(l.Offset))
 	}
 
-	hash := md5.New()
-	for _, item := range l.Items {
-		if item.ETag != "" {
-			hash.Write([]byte(item.ETag))
+	etag := l.ETag
+	if etag == "" {
+		hash := md5.New()
+		for _, item := range l.Items {
+			if item.ETag != "" {
+				hash.Write([]byte(item.ETag))
+			}
+		}
+		etag = fmt.Sprintf("%x", hash.Sum(nil))
+	}
+	headers.Set("ETag", `W/"`+etag+`"`)
+	if updated := lastModified(l.Items); !updated.IsZero() {
+		headers.Set("Last-Modified", updated.In(time.UTC).Format("Mon, 02 Jan 2006 15:04:05 GMT"))
+	}
+
+	var links map[string]url.Values
+	if route, ok := RouteFromContext(ctx); ok {
+		if links = PageLinks(route, l); links != nil {
+			headers.Set("Link", linkHeader(links))
 		}
 	}
-	headers.Set("ETag", `W/"`+fmt.Sprintf("%x", hash.Sum(nil))+`"`)
 
 	if !skipBody {
 		payload := make([]map[string]interface{}, len(l.Items))
@@ -113,11 +143,36 @@ func (f DefaultResponseFormatter) FormatList(ctx context.Context, headers http.H
 			}
 			payload[i] = d
 		}
+		// List only encoders like CSV can't encode the envelope.
+		if le, ok := EncoderFromContext(ctx).(ListEncoder); f.Envelope && !(ok && le.ListOnly()) {
+			return ctx, envelope(ctx, l, payload, links)
+		}
 		return ctx, payload
 	}
 	return ctx, nil
 }
 
+// envelope returns the body of a list in envelope mode.
+func envelope(ctx context.Context, l *resource.ItemList, items []map[string]interface{}, links map[string]url.Values) map[string]interface{} {
+	body := map[string]interface{}{
+		"items":  items,
+		"offset": l.Offset,
+	}
+	if l.Total >= 0 {
+		body["total"] = l.Total
+	}
+	if route, ok := RouteFromContext(ctx); ok && route.Resource() != nil {
+		p := parsePageParams(route.Params, route.Resource().Conf().PaginationDefaultLimit, func(string, interface{}) {})
+		if p.limit > 0 {
+			body["limit"] = p.limit
+		}
+	}
+	if next, found := links["next"]; found {
+		body["next"] = "?" + next.Encode()
+	}
+	return body
+}
+
 // FormatError implements ResponseFormatter.
 func (f DefaultResponseFormatter) FormatError(ctx context.Context, headers http.Header, err error, skipBody bool) (context.Context, interface{}) {
 	code := 500
@@ -160,6 +215,12 @@ func formatResponse(ctx context.Context, f ResponseFormatter, w http.ResponseWri
 			status = resp.Code
 		}
 		ctx, body = f.FormatError(ctx, headers, resp, skipBody)
+	case *retryError:
+		if status == 0 {
+			status = resp.err.Code
+		}
+		headers.Set("Retry-After", resp.retryAfter())
+		ctx, body = f.FormatError(ctx, headers, resp.err, skipBody)
 	case error:
 		if status == 0 {
 			status = 500
@@ -99,8 +99,13 @@ func findRoute(path string, index resource.Index, route *RouteMatch) error {
 
 			// Handle sub-resources (/resource1/id1/resource2/id2).
 			if len(path) >= 1 {
-				if c, found := rsrc.GetCommand(path); found {
-					return route.ResourcePath.append(rsrc, "id", id, name, c)
+				_, readOnly := rsrc.GetReadOnlyCommand(path)
+				if c, found := rsrc.GetCommand(path); found || readOnly {
+					if err := route.ResourcePath.append(rsrc, "id", id, name, c); err != nil {
+						return err
+					}
+					route.ResourcePath[len(route.ResourcePath)-1].CommandName = path
+					return nil
 				}
 
 				subPathComp, _ := nextPathComponent(path)
@@ -121,6 +126,17 @@ func findRoute(path string, index resource.Index, route *RouteMatch) error {
 				return nil
 			}
 
+			// Handle collection commands (/resource/command). Only POST and
+			// OPTIONS requests are routed to the command so other methods can
+			// still reach an item with the same id.
+			if _, found := rsrc.GetCollectionCommand(id); found && (route.Method == http.MethodPost || route.Method == http.MethodOptions) {
+				if err := route.ResourcePath.append(rsrc, "", nil, name, nil); err != nil {
+					return err
+				}
+				route.ResourcePath[len(route.ResourcePath)-1].CommandName = id
+				return nil
+			}
+
 			// Handle aliases (/resource/alias or /resource1/id1/resource2/alias).
 			if alias, found := rsrc.GetAlias(id); found {
 				// Apply aliases query to the request.
@@ -179,6 +195,15 @@ func (r *RouteMatch) Command() resource.Command {
 	return (r.ResourcePath)[l-1].Command
 }
 
+// CommandName returns the last resource path's command name if any.
+func (r *RouteMatch) CommandName() string {
+	l := len(r.ResourcePath)
+	if l == 0 {
+		return ""
+	}
+	return (r.ResourcePath)[l-1].CommandName
+}
+
 // ResourceID returns the last resource path's resource id value if any.
 //
 // If this method returns a non nil value, it means the route is an item request,
@@ -206,6 +231,20 @@ func (r *RouteMatch) Query() (*query.Query, *Error) {
 	}
 
 	// Parse query string params.
+	if r.CommandName() != "" {
+		if r.ResourceID() == nil {
+			// Collection commands are given the query selecting the items
+			// they apply to.
+			qp.parsePredicate(r.Params)
+			qp.parseWindow(r.Params, false)
+			qp.parseSort(r.Params)
+			return qp.results()
+		}
+		if r.Method == "GET" || r.Method == "HEAD" {
+			// The query-string of read-only commands is their payload.
+			return qp.results()
+		}
+	}
 	switch r.Method {
 	case "DELETE":
 		qp.parsePredicate(r.Params)
@@ -295,37 +334,49 @@ func (qp *queryParser) parseSort(params url.Values) {
 }
 
 func (qp *queryParser) parseWindow(params url.Values, allowDefaultLimit bool) {
-	limit := -1
+	defaultLimit := -1
+	if allowDefaultLimit {
+		if l := qp.rsc.Conf().PaginationDefaultLimit; l > 0 {
+			defaultLimit = l
+		}
+	}
+	p := parsePageParams(params, defaultLimit, qp.addIssue)
+	qp.q.Window = query.Page(p.page, p.limit, p.skip)
+}
+
+// pageParams holds the pagination parameters of a request.
+type pageParams struct {
+	page, limit, skip int
+}
+
+// parsePageParams parses the page, limit and skip parameters, reporting
+// invalid ones to addIssue. The limit is defaultLimit if not set, -1 meaning
+// no limit.
+func parsePageParams(params url.Values, defaultLimit int, addIssue func(field string, err interface{})) pageParams {
+	p := pageParams{page: 1, limit: defaultLimit}
 	if l, found, err := getUintParam(params, "limit"); found {
 		if err != nil {
-			qp.addIssue("limit", err.Error())
+			addIssue("limit", err.Error())
 		} else {
-			limit = l
-		}
-	} else if allowDefaultLimit {
-		if l := qp.rsc.Conf().PaginationDefaultLimit; l > 0 {
-			limit = l
+			p.limit = l
 		}
 	}
-	skip := 0
 	if s, found, err := getUintParam(params, "skip"); found {
 		if err != nil {
-			qp.addIssue("skip", err.Error())
+			addIssue("skip", err.Error())
 		} else {
-			skip = s
+			p.skip = s
 		}
 	}
-	page := 1
-	if p, found, err := getUintParam(params, "page"); found {
+	if pg, found, err := getUintParam(params, "page"); found {
 		if err != nil {
-			qp.addIssue("page", err.Error())
+			addIssue("page", err.Error())
 		} else {
-			page = p
+			p.page = pg
 		}
 	}
-	if page > 1 && limit <= 0 {
-		qp.addIssue("limit", "required when page is set and there is no resource default")
+	if p.page > 1 && p.limit <= 0 {
+		addIssue("limit", "required when page is set and there is no resource default")
 	}
-
-	qp.q.Window = query.Page(page, limit, skip)
+	return p
 }
@@ -0,0 +1,112 @@
+package rest
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/rs/rest-layer/resource"
+)
+
+// URLBuilder builds the URLs of the collections, items and commands of an API
+// from their ResourcePath.
+type URLBuilder struct {
+	// Base is the URL the API handler is served on, prepended to the built
+	// URLs (i.e.: https://api.example.com/v1 or /api). It must not end with a
+	// slash.
+	Base string
+}
+
+// Path returns the URL of the collection, the item or the item command
+// targeted by p.
+func (b URLBuilder) Path(p ResourcePath) string {
+	return b.build(p, true)
+}
+
+// Collection returns the URL of the collection targeted by p, ignoring the
+// id and the command of its last component.
+func (b URLBuilder) Collection(p ResourcePath) string {
+	return b.build(p, false)
+}
+
+// Item returns the URL of the item with id in the collection targeted by p.
+func (b URLBuilder) Item(p ResourcePath, id interface{}) string {
+	return b.Collection(p) + "/" + pathID(id)
+}
+
+// Parent returns the URL of the parent item of the collection targeted by p.
+// If the collection is not a sub-resource, ok is false.
+func (b URLBuilder) Parent(p ResourcePath) (u string, ok bool) {
+	named := 0
+	for i := len(p) - 1; i >= 0; i-- {
+		if p[i].Name == "" {
+			continue
+		}
+		if named++; named == 2 {
+			return b.build(p[:i+1], true), true
+		}
+	}
+	return "", false
+}
+
+// ResourceItem returns the URL of the item of rsc with payload, for instance
+// an item embedded by a projection. Items of sub-resources are located using
+// the parent field of their payload. If the payload lacks an id or the parent
+// id of the item can't be determined, ok is false.
+func (b URLBuilder) ResourceItem(rsc *resource.Resource, payload map[string]interface{}) (u string, ok bool) {
+	id, found := payload["id"]
+	if !found || id == nil {
+		return "", false
+	}
+	names := strings.Split(rsc.Path(), ".")
+	switch len(names) {
+	case 1:
+		return b.Base + "/" + names[0] + "/" + pathID(id), true
+	case 2:
+		parent, found := payload[rsc.ParentField()]
+		if !found || parent == nil {
+			return "", false
+		}
+		return b.Base + "/" + names[0] + "/" + pathID(parent) + "/" + names[1] + "/" + pathID(id), true
+	}
+	// The ids of the grand parents are not stored in the items.
+	return "", false
+}
+
+func (b URLBuilder) build(p ResourcePath, target bool) string {
+	var u strings.Builder
+	u.WriteString(b.Base)
+	last := len(p) - 1
+	for i, c := range p {
+		if c.Name == "" {
+			// Skip the components prepended to filter the resource.
+			continue
+		}
+		u.WriteByte('/')
+		u.WriteString(c.Name)
+		if i == last && !target {
+			break
+		}
+		if c.Value != nil {
+			u.WriteByte('/')
+			u.WriteString(pathID(c.Value))
+		}
+		if c.CommandName != "" {
+			u.WriteByte('/')
+			u.WriteString(c.CommandName)
+		}
+	}
+	if u.Len() == 0 {
+		return "/"
+	}
+	return u.String()
+}
+
+// pathID returns id escaped as a path component.
+func pathID(id interface{}) string {
+	s, ok := id.(string)
+	if !ok {
+		s = fmt.Sprint(id)
+	}
+	return url.PathEscape(s)
+}
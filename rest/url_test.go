@@ -0,0 +1,68 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestURLBuilder(t *testing.T) {
+	index := resource.NewIndex()
+	users := index.Bind("users", schema.Schema{Fields: schema.Fields{"id": {}}}, nil, resource.DefaultConf)
+	posts := users.Bind("posts", "user", schema.Schema{Fields: schema.Fields{"id": {}, "user": {}}}, nil, resource.DefaultConf)
+	posts.Command("publish", func(ctx context.Context, r *http.Request, item *resource.Item, payload map[string]interface{}) (http.Header, *resource.Item, map[string]interface{}, error) {
+		return nil, item, nil, nil
+	})
+	b := URLBuilder{Base: "/api"}
+	route := func(path string) ResourcePath {
+		r, err := FindRoute(index, httptest.NewRequest("GET", path, nil))
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		return r.ResourcePath
+	}
+
+	p := route("/users")
+	assert.Equal(t, "/api/users", b.Path(p))
+	assert.Equal(t, "/api/users", b.Collection(p))
+	assert.Equal(t, "/api/users/a%2Fb", b.Item(p, "a/b"))
+	_, ok := b.Parent(p)
+	assert.False(t, ok)
+
+	p = route("/users/1/posts/2")
+	assert.Equal(t, "/api/users/1/posts/2", b.Path(p))
+	assert.Equal(t, "/api/users/1/posts", b.Collection(p))
+	assert.Equal(t, "/api/users/1/posts/3", b.Item(p, 3))
+	u, ok := b.Parent(p)
+	assert.True(t, ok)
+	assert.Equal(t, "/api/users/1", u)
+
+	p = route("/users/1/posts/2/publish")
+	assert.Equal(t, "/api/users/1/posts/2/publish", b.Path(p))
+	assert.Equal(t, "/api/users/1/posts", b.Collection(p))
+
+	// Components prepended to filter the resource are not part of the URLs.
+	p = route("/users")
+	p.Prepend(nil, "tenant", "x")
+	assert.Equal(t, "/api/users", b.Path(p))
+	_, ok = b.Parent(p)
+	assert.False(t, ok)
+
+	u, ok = b.ResourceItem(users, map[string]interface{}{"id": "1"})
+	assert.True(t, ok)
+	assert.Equal(t, "/api/users/1", u)
+	u, ok = b.ResourceItem(posts, map[string]interface{}{"id": "2", "user": "1"})
+	assert.True(t, ok)
+	assert.Equal(t, "/api/users/1/posts/2", u)
+	_, ok = b.ResourceItem(posts, map[string]interface{}{"id": "2"})
+	assert.False(t, ok)
+	_, ok = b.ResourceItem(users, map[string]interface{}{"name": "John"})
+	assert.False(t, ok)
+
+	assert.Equal(t, "/", URLBuilder{}.Path(ResourcePath{}))
+}
@@ -2,13 +2,13 @@ package rest
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
 )
 
 // getMethodHandler returns the method handler for a given HTTP method in item
@@ -137,18 +137,24 @@ func compareEtag(etag, baseEtag string) bool {
 	return false
 }
 
-// decodePayload decodes the payload from the provided request.
-func decodePayload(r *http.Request, payload *map[string]interface{}) *Error {
-	// Check content-type, if not specified, assume it's JSON and fail later
-	if ct := r.Header.Get("Content-Type"); ct != "" && strings.TrimSpace(strings.SplitN(ct, ";", 2)[0]) != "application/json" {
-		return &Error{501, fmt.Sprintf("Invalid Content-Type header: `%s' not supported", ct), nil}
+// decodePayload decodes the payload from the provided request with the
+// decoder matching its Content-Type, JSON if not specified.
+func decodePayload(ctx context.Context, r *http.Request, payload *map[string]interface{}) *Error {
+	ct := r.Header.Get("Content-Type")
+	var dec Decoder = JSONCodec{}
+	if codecs := codecsFromContext(ctx); codecs != nil {
+		var ok bool
+		if dec, ok = codecs.Decoder(ct); !ok {
+			return &Error{http.StatusUnsupportedMediaType, fmt.Sprintf("Invalid Content-Type header: `%s' not supported", ct), nil}
+		}
+	} else if ct != "" && strings.TrimSpace(strings.SplitN(ct, ";", 2)[0]) != "application/json" {
+		return &Error{http.StatusUnsupportedMediaType, fmt.Sprintf("Invalid Content-Type header: `%s' not supported", ct), nil}
 	}
 	if r.Body == nil {
 		return nil
 	}
-	decoder := json.NewDecoder(r.Body)
 	defer r.Body.Close()
-	if err := decoder.Decode(payload); err != nil {
+	if err := dec.Decode(r.Body, payload); err != nil {
 		return &Error{400, fmt.Sprintf("Malformed body: %v", err), nil}
 	}
 	return nil
@@ -179,8 +185,23 @@ func checkIntegrityRequest(r *http.Request, original *resource.Item) *Error {
 	return nil
 }
 
-func logErrorf(ctx context.Context, format string, a ...interface{}) {
-	if resource.Logger != nil {
-		resource.Logger(ctx, resource.LogLevelError, fmt.Sprintf(format, a...), nil)
+// verifyReferences ensures the items referenced by doc exist when the validator
+// supports it. Missing items are reported as a 422 error.
+func verifyReferences(ctx context.Context, v schema.Validator, doc map[string]interface{}) (error, int) {
+	rv, ok := v.(schema.ReferenceVerifier)
+	if !ok {
+		return nil, 0
+	}
+	errs, err := rv.VerifyReferences(ctx, doc)
+	if err != nil {
+		return NewError(err)
+	}
+	if len(errs) > 0 {
+		return &Error{422, "Document contains error(s)", errs}, 422
 	}
+	return nil, 0
+}
+
+func logErrorf(ctx context.Context, format string, a ...interface{}) {
+	resource.Log(ctx, resource.LogLevelError, fmt.Sprintf(format, a...), nil)
 }
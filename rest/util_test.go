@@ -2,6 +2,7 @@ package rest
 
 import (
 	"bytes"
+	"context"
 	"io/ioutil"
 	"net/http"
 	"testing"
@@ -154,7 +155,7 @@ func TestRequestDecodePayload(t *testing.T) {
 		Body: ioutil.NopCloser(bytes.NewBufferString("{\"foo\":\"bar\"}")),
 	}
 	var p map[string]interface{}
-	err := decodePayload(r, &p)
+	err := decodePayload(context.Background(), r, &p)
 	assert.Nil(t, err)
 	assert.Equal(t, map[string]interface{}{"foo": "bar"}, p)
 }
@@ -165,14 +166,14 @@ func TestRequestDecodePayloadContentType(t *testing.T) {
 		Body:   ioutil.NopCloser(bytes.NewBufferString("{\"foo\":\"bar\"}")),
 	}
 	var p map[string]interface{}
-	err := decodePayload(r, &p)
+	err := decodePayload(context.Background(), r, &p)
 	assert.Nil(t, err)
 	assert.Equal(t, map[string]interface{}{"foo": "bar"}, p)
 	r = &http.Request{
 		Header: map[string][]string{"Content-Type": {"application/json; charset=utf8"}},
 		Body:   ioutil.NopCloser(bytes.NewBufferString("{\"foo\":\"bar\"}")),
 	}
-	err = decodePayload(r, &p)
+	err = decodePayload(context.Background(), r, &p)
 	assert.Nil(t, err)
 	assert.Equal(t, map[string]interface{}{"foo": "bar"}, p)
 }
@@ -183,8 +184,8 @@ func TestRequestDecodePayloadWrongContentType(t *testing.T) {
 		Body:   ioutil.NopCloser(bytes.NewBufferString("{\"foo\":\"bar\"}")),
 	}
 	var p map[string]interface{}
-	err := decodePayload(r, &p)
-	assert.Equal(t, &Error{501, "Invalid Content-Type header: `text/plain' not supported", nil}, err)
+	err := decodePayload(context.Background(), r, &p)
+	assert.Equal(t, &Error{415, "Invalid Content-Type header: `text/plain' not supported", nil}, err)
 }
 
 func TestRequestDecodePayloadInvalidJSON(t *testing.T) {
@@ -192,7 +193,7 @@ func TestRequestDecodePayloadInvalidJSON(t *testing.T) {
 		Body: ioutil.NopCloser(bytes.NewBufferString("{\"foo\":\"")),
 	}
 	var p map[string]interface{}
-	err := decodePayload(r, &p)
+	err := decodePayload(context.Background(), r, &p)
 	assert.Equal(t, &Error{400, "Malformed body: unexpected EOF", nil}, err)
 }
 
@@ -1,6 +1,7 @@
 package schema_test
 
 import (
+	"context"
 	"errors"
 	"reflect"
 	"testing"
@@ -192,14 +193,34 @@ func (rc fakeReferenceChecker) ReferenceChecker(path string, skipCheck bool) (sc
 			id = value
 		}
 
-		if !skipCheck {
-			// Check that the ID exists.
+		if skipCheck {
+			return id, nil
+		}
+		// Check that the ID exists.
+		for _, rscID := range rsc.IDs {
+			if id == rscID {
+				return id, nil
+			}
+		}
+		return nil, errors.New("not found")
+	}), rsc.SchemaValidator
+}
+
+func (rc fakeReferenceChecker) ReferenceLookup(path string) schema.ReferenceLookupFunc {
+	rsc, ok := rc[path]
+	if !ok {
+		return nil
+	}
+	return func(ctx context.Context, ids []interface{}) (missing []interface{}, err error) {
+	next:
+		for _, id := range ids {
 			for _, rscID := range rsc.IDs {
 				if id == rscID {
-					return id, nil
+					continue next
 				}
 			}
+			missing = append(missing, id)
 		}
-		return nil, errors.New("not found")
-	}), rsc.SchemaValidator
+		return missing, nil
+	}
 }
@@ -1,5 +1,7 @@
 package schema
 
+import "context"
+
 // Compiler is similar to the Compiler interface, but intended for types that implements, or may hold, a
 // reference. All nested types must implement this interface.
 type Compiler interface {
@@ -13,6 +15,19 @@ type ReferenceChecker interface {
 	ReferenceChecker(path string, skipCheck bool) (FieldValidator, Validator)
 }
 
+// ReferenceLookup is an optional interface a ReferenceChecker can implement to
+// allow the existence of referenced items to be verified in batch.
+type ReferenceLookup interface {
+	// ReferenceLookup should return a ReferenceLookupFunc for the resource
+	// matching path. If there is no resource matching path, nil should be
+	// returned.
+	ReferenceLookup(path string) ReferenceLookupFunc
+}
+
+// ReferenceLookupFunc looks up the provided ids and returns those for which no
+// item exists.
+type ReferenceLookupFunc func(ctx context.Context, ids []interface{}) (missing []interface{}, err error)
+
 // ReferenceCheckerFunc is an adapter that allows ordinary functions to be used as reference checkers.
 type ReferenceCheckerFunc func(path string) FieldValidator
 
@@ -53,12 +53,16 @@ type Field struct {
 	Dependency Predicate
 	// Filterable defines that the field can be used with the `filter` parameter.
 	// When this property is set to `true`, you may want to ensure the backend
-	// database has this field indexed.
+	// database has this field indexed (see resource.Conf.Indexes).
 	Filterable bool
 	// Sortable defines that the field can be used with the `sort` parameter.
 	// When this property is set to `true`, you may want to ensure the backend
-	// database has this field indexed.
+	// database has this field indexed (see resource.Conf.Indexes).
 	Sortable bool
+	// Unique defines that no two items of the resource may have the same value
+	// for this field. Items without the field are not constrained. Use
+	// resource.Conf.Unique for compound or parent scoped constraints.
+	Unique bool
 	// Schema can be set to a sub-schema to allow multi-level schema.
 	Schema *Schema
 }
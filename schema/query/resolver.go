@@ -6,6 +6,7 @@ import (
 	"sync"
 
 	"github.com/rs/rest-layer/schema"
+	"github.com/rs/rest-layer/trace"
 )
 
 type referenceResponseHandler func(payloads []map[string]interface{}, validator schema.Validator, rsc Resource) error
@@ -85,7 +86,14 @@ type referenceSingleRequest struct {
 	handler referenceResponseHandler
 }
 
-func (r referenceSingleRequest) execute(ctx context.Context) error {
+func (r referenceSingleRequest) execute(ctx context.Context) (err error) {
+	if trace.Enabled() {
+		var span trace.Span
+		ctx, span = trace.Start(ctx, "projection.Find",
+			trace.Attr(trace.AttrResource, r.rsc.Path()),
+			trace.Attr(trace.AttrPredicate, r.query.Predicate.String()))
+		defer func() { span.End(err) }()
+	}
 	payloads, err := r.rsc.Find(ctx, r.query)
 	if err != nil {
 		return err
@@ -104,7 +112,14 @@ func (r *referenceMultiGetRequest) add(id interface{}, handler referenceResponse
 	r.handlers = append(r.handlers, handler)
 }
 
-func (r *referenceMultiGetRequest) execute(ctx context.Context) error {
+func (r *referenceMultiGetRequest) execute(ctx context.Context) (err error) {
+	if trace.Enabled() {
+		var span trace.Span
+		ctx, span = trace.Start(ctx, "projection.MultiGet",
+			trace.Attr(trace.AttrResource, r.rsc.Path()),
+			trace.Attr(trace.AttrItemCount, len(r.ids)))
+		defer func() { span.End(err) }()
+	}
 	payloads, err := r.rsc.MultiGet(ctx, r.ids)
 	if err != nil {
 		return err
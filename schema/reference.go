@@ -11,6 +11,12 @@ type Reference struct {
 	validator       FieldValidator
 	SchemaValidator Validator
 	SkipCheck       bool
+	// Verify defers the existence check of the referenced item to
+	// Schema.VerifyReferences, which batches the lookups per referenced
+	// resource and uses the request's context. When set, Validate only checks
+	// the format of the id.
+	Verify bool
+	lookup ReferenceLookupFunc
 }
 
 // Compile validates v.Path against rc and stores the a FieldValidator for later use by v.Validate.
@@ -19,9 +25,18 @@ func (r *Reference) Compile(rc ReferenceChecker) error {
 		return fmt.Errorf("rc can not be nil")
 	}
 
-	if v, sv := rc.ReferenceChecker(r.Path, r.SkipCheck); v != nil && sv != nil {
+	if v, sv := rc.ReferenceChecker(r.Path, r.SkipCheck || r.Verify); v != nil && sv != nil {
 		r.validator = v
 		r.SchemaValidator = sv
+		if r.Verify && !r.SkipCheck {
+			rl, ok := rc.(ReferenceLookup)
+			if !ok {
+				return fmt.Errorf("can't verify references to resource '%s'", r.Path)
+			}
+			if r.lookup = rl.ReferenceLookup(r.Path); r.lookup == nil {
+				return fmt.Errorf("can't find resource '%s'", r.Path)
+			}
+		}
 		return nil
 	}
 
@@ -1,6 +1,8 @@
 package schema_test
 
 import (
+	"context"
+	"reflect"
 	"testing"
 
 	"github.com/rs/rest-layer/schema"
@@ -31,3 +33,83 @@ func TestReferenceValidate(t *testing.T) {
 		cases[i].Run(t)
 	}
 }
+
+func TestReferenceVerify(t *testing.T) {
+	rc := fakeReferenceChecker{
+		"foo": {IDs: []interface{}{"a", "b"}, Validator: &schema.String{}, SchemaValidator: &schema.Schema{}},
+		"bar": {IDs: []interface{}{"x"}, Validator: &schema.String{}, SchemaValidator: &schema.Schema{}},
+		"any": {IDs: []interface{}{"a"}, SchemaValidator: &schema.Schema{}},
+	}
+	s := schema.Schema{Fields: schema.Fields{
+		"foo":  {Validator: &schema.Reference{Path: "foo", Verify: true}},
+		"foos": {Validator: &schema.Array{Values: schema.Field{Validator: &schema.Reference{Path: "foo", Verify: true}}}},
+		"sub": {Schema: &schema.Schema{Fields: schema.Fields{
+			"bar": {Validator: &schema.Reference{Path: "bar", Verify: true}},
+		}}},
+		"obj": {Validator: &schema.Object{Schema: &schema.Schema{Fields: schema.Fields{
+			"bar": {Validator: &schema.Reference{Path: "bar", Verify: true}},
+		}}}},
+		"unchecked": {Validator: &schema.Reference{Path: "foo", SkipCheck: true}},
+		"dict":      {Validator: &schema.Dict{Values: schema.Field{Validator: &schema.Reference{Path: "foo", Verify: true}}}},
+		"anyof":     {Validator: &schema.AnyOf{&schema.Reference{Path: "foo", Verify: true}, &schema.Integer{}}},
+		"allof":     {Validator: &schema.AllOf{&schema.Reference{Path: "foo", Verify: true}}},
+		"raw":       {Validator: &schema.Reference{Path: "any", Verify: true}},
+	}}
+	if err := s.Compile(rc); err != nil {
+		t.Fatalf("Compile(): unexpected error: %v", err)
+	}
+
+	t.Run("Valid", func(t *testing.T) {
+		doc, errs := s.Validate(map[string]interface{}{
+			"foo":   "a",
+			"foos":  []interface{}{"a", "b"},
+			"sub":   map[string]interface{}{"bar": "x"},
+			"dict":  map[string]interface{}{"k": "a"},
+			"anyof": 1,
+			"allof": "b",
+			"raw":   map[string]interface{}{"unhashable": true},
+		}, map[string]interface{}{})
+		if len(errs) > 0 {
+			t.Fatalf("Validate(): unexpected errors: %v", errs)
+		}
+		errs, err := s.VerifyReferences(context.Background(), doc)
+		if err != nil {
+			t.Fatalf("VerifyReferences(): unexpected error: %v", err)
+		}
+		if len(errs) > 0 {
+			t.Errorf("VerifyReferences(): unexpected errors: %v", errs)
+		}
+	})
+	t.Run("Missing", func(t *testing.T) {
+		doc, errs := s.Validate(map[string]interface{}{
+			"foo":       "c",
+			"foos":      []interface{}{"a", "c"},
+			"sub":       map[string]interface{}{"bar": "y"},
+			"obj":       map[string]interface{}{"bar": "z"},
+			"unchecked": "c",
+			"dict":      map[string]interface{}{"k": "a", "l": "c"},
+			"anyof":     "c",
+			"allof":     "c",
+			"raw":       []interface{}{"a"},
+		}, map[string]interface{}{})
+		if len(errs) > 0 {
+			t.Fatalf("Validate(): unexpected errors: %v", errs)
+		}
+		errs, err := s.VerifyReferences(context.Background(), doc)
+		if err != nil {
+			t.Fatalf("VerifyReferences(): unexpected error: %v", err)
+		}
+		expect := map[string][]interface{}{
+			"foo":     {"Not Found"},
+			"foos":    {"invalid value at #2: Not Found"},
+			"sub.bar": {"Not Found"},
+			"obj.bar": {"Not Found"},
+			"dict.l":  {"Not Found"},
+			"anyof":   {"Not Found"},
+			"allof":   {"Not Found"},
+		}
+		if !reflect.DeepEqual(errs, expect) {
+			t.Errorf("VerifyReferences(): expected %v, got %v", expect, errs)
+		}
+	})
+}
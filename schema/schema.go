@@ -19,6 +19,12 @@ type Validator interface {
 	Validate(changes map[string]interface{}, base map[string]interface{}) (doc map[string]interface{}, errs map[string][]interface{})
 }
 
+// MergePreparer is an optional interface a Validator can implement to
+// prepare the documents resulting from a JSON Merge Patch (RFC 7396).
+type MergePreparer interface {
+	PrepareMerge(ctx context.Context, payload map[string]interface{}, original *map[string]interface{}) (changes map[string]interface{}, base map[string]interface{})
+}
+
 // Schema defines fields for a document.
 type Schema struct {
 	// Description of the object described by this schema.
@@ -100,6 +106,19 @@ func (s Schema) GetField(name string) *Field {
 // ReadOnly flag can throw an error and the field will be removed from the
 // output document. The OnInit is also called instead of the OnUpdate.
 func (s Schema) Prepare(ctx context.Context, payload map[string]interface{}, original *map[string]interface{}, replace bool) (changes map[string]interface{}, base map[string]interface{}) {
+	return s.prepare(ctx, payload, original, replace, false)
+}
+
+// PrepareMerge implements the MergePreparer interface. The payload is the
+// full document resulting from the merge of a JSON Merge Patch (RFC 7396)
+// into the original. Unlike Prepare, sub-documents are compared to their
+// original counterparts, so the ReadOnly, OnUpdate and Default properties of
+// their fields apply at every depth.
+func (s Schema) PrepareMerge(ctx context.Context, payload map[string]interface{}, original *map[string]interface{}) (changes map[string]interface{}, base map[string]interface{}) {
+	return s.prepare(ctx, payload, original, true, true)
+}
+
+func (s Schema) prepare(ctx context.Context, payload map[string]interface{}, original *map[string]interface{}, replace, merge bool) (changes map[string]interface{}, base map[string]interface{}) {
 	changes = map[string]interface{}{}
 	base = map[string]interface{}{}
 	for field, def := range s.Fields {
@@ -160,7 +179,12 @@ func (s Schema) Prepare(ctx context.Context, payload map[string]interface{}, ori
 				// is a dictionary. Otherwise, use an empty dict.
 				oValue := (*original)[field]
 				subOriginal = &map[string]interface{}{}
-				if su, ok := oValue.(*map[string]interface{}); ok {
+				switch su := oValue.(type) {
+				case map[string]interface{}:
+					if merge {
+						subOriginal = &su
+					}
+				case *map[string]interface{}:
 					subOriginal = su
 				}
 			}
@@ -168,7 +192,7 @@ func (s Schema) Prepare(ctx context.Context, payload map[string]interface{}, ori
 				if subPayload, ok := value.(map[string]interface{}); ok {
 					// If payload contains a sub-document for this field, validate it
 					// using the sub-validator.
-					c, b := def.Schema.Prepare(ctx, subPayload, subOriginal, replace)
+					c, b := def.Schema.prepare(ctx, subPayload, subOriginal, replace, merge)
 					changes[field] = c
 					base[field] = b
 				} else {
@@ -176,8 +200,12 @@ func (s Schema) Prepare(ctx context.Context, payload map[string]interface{}, ori
 				}
 			} else {
 				// If the payload doesn't contain a sub-document, perform validation
-				// on an empty one so we don't miss default values.
-				c, b := def.Schema.Prepare(ctx, map[string]interface{}{}, subOriginal, replace)
+				// on an empty one so we don't miss default values. When merging,
+				// the sub-document is removed: its original fields are ignored.
+				if merge {
+					subOriginal = &map[string]interface{}{}
+				}
+				c, b := def.Schema.prepare(ctx, map[string]interface{}{}, subOriginal, replace, merge)
 				if len(c) > 0 || len(b) > 0 {
 					// Only apply prepared field if something was added.
 					changes[field] = c
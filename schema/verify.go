@@ -0,0 +1,142 @@
+package schema
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+// ReferenceVerifier is an optional interface for a Validator able to verify
+// that the items referenced by a validated document exist.
+type ReferenceVerifier interface {
+	// VerifyReferences checks the existence of the items referenced by doc and
+	// reports missing ones as field errors. An error is returned if the lookup
+	// itself failed.
+	VerifyReferences(ctx context.Context, doc map[string]interface{}) (errs map[string][]interface{}, err error)
+}
+
+// pendingReference is a reference found in a document, waiting to be verified.
+type pendingReference struct {
+	field string
+	index int
+	id    interface{}
+	ref   *Reference
+}
+
+// VerifyReferences implements the ReferenceVerifier interface. It looks up all
+// the ids held by Reference fields with Verify set, including those found in
+// sub-schemas, objects, arrays, dicts and AnyOf or AllOf validators. Lookups
+// are batched so each referenced resource is only queried once. Missing items
+// are reported using the dotted path of the field as key.
+func (s Schema) VerifyReferences(ctx context.Context, doc map[string]interface{}) (errs map[string][]interface{}, err error) {
+	refs := s.collectReferences(doc, "", nil)
+	if len(refs) == 0 {
+		return nil, nil
+	}
+	// Group ids by referenced resource.
+	paths := []string{}
+	byPath := map[string][]pendingReference{}
+	for _, r := range refs {
+		if _, found := byPath[r.ref.Path]; !found {
+			paths = append(paths, r.ref.Path)
+		}
+		byPath[r.ref.Path] = append(byPath[r.ref.Path], r)
+	}
+	errs = map[string][]interface{}{}
+	for _, path := range paths {
+		pending := byPath[path]
+		ids := make([]interface{}, 0, len(pending))
+		seen := map[interface{}]bool{}
+		for _, r := range pending {
+			if !seen[r.id] {
+				seen[r.id] = true
+				ids = append(ids, r.id)
+			}
+		}
+		missing, err := pending[0].ref.lookup(ctx, ids)
+		if err != nil {
+			return nil, err
+		}
+		if len(missing) == 0 {
+			continue
+		}
+		notFound := make(map[interface{}]bool, len(missing))
+		for _, id := range missing {
+			notFound[id] = true
+		}
+		for _, r := range pending {
+			if !notFound[r.id] {
+				continue
+			}
+			if r.index > 0 {
+				addFieldError(errs, r.field, fmt.Sprintf("invalid value at #%d: Not Found", r.index))
+			} else {
+				addFieldError(errs, r.field, "Not Found")
+			}
+		}
+	}
+	return errs, nil
+}
+
+// collectReferences walks doc and returns the references to verify.
+func (s Schema) collectReferences(doc map[string]interface{}, prefix string, refs []pendingReference) []pendingReference {
+	for name, value := range doc {
+		def, found := s.Fields[name]
+		if !found || value == nil {
+			continue
+		}
+		if def.Schema != nil {
+			if sub, ok := value.(map[string]interface{}); ok {
+				refs = def.Schema.collectReferences(sub, prefix+name+".", refs)
+			}
+			continue
+		}
+		refs = collectFieldReferences(def.Validator, value, prefix+name, 0, refs)
+	}
+	return refs
+}
+
+// collectFieldReferences returns the references to verify held by value given
+// its validator. Ids which can't be compared, like maps or slices, are
+// skipped as they can't reference an item. For AnyOf, only the first validator
+// accepting value is walked, as done by AnyOf.Validate.
+func collectFieldReferences(v FieldValidator, value interface{}, field string, index int, refs []pendingReference) []pendingReference {
+	switch v := v.(type) {
+	case *Reference:
+		if v.lookup != nil && value != nil && reflect.TypeOf(value).Comparable() {
+			refs = append(refs, pendingReference{field: field, index: index, id: value, ref: v})
+		}
+	case *Object:
+		if sub, ok := value.(map[string]interface{}); ok && v.Schema != nil {
+			refs = v.Schema.collectReferences(sub, field+".", refs)
+		}
+	case *Array:
+		if values, ok := value.([]interface{}); ok {
+			for i, val := range values {
+				refs = collectFieldReferences(v.Values.Validator, val, field, i+1, refs)
+			}
+		}
+	case *Dict:
+		if values, ok := value.(map[string]interface{}); ok {
+			for key, val := range values {
+				refs = collectFieldReferences(v.Values.Validator, val, field+"."+key, 0, refs)
+			}
+		}
+	case AllOf:
+		for _, sv := range v {
+			refs = collectFieldReferences(sv, value, field, index, refs)
+		}
+	case *AllOf:
+		refs = collectFieldReferences(*v, value, field, index, refs)
+	case AnyOf:
+		for _, sv := range v {
+			if _, err := sv.Validate(value); err == nil {
+				refs = collectFieldReferences(sv, value, field, index, refs)
+				break
+			}
+		}
+	case *AnyOf:
+		refs = collectFieldReferences(*v, value, field, index, refs)
+	}
+	return refs
+}
@@ -0,0 +1,15 @@
+/*
+Package trace defines the tracing hooks of REST Layer.
+
+When DefaultTracer is set, REST Layer creates spans for the requests served by
+rest.Handler, each resource operation, middleware, event hook and storage
+handler call, as well as for each projection sub-request resolving references.
+By default no tracer is set and tracing has a negligible cost.
+
+See the github.com/rs/rest-layer/trace/otel module for an OpenTelemetry
+adapter.
+
+This package is part of the rest-layer project. See http://rest-layer.io for
+full REST Layer documentation.
+*/
+package trace
@@ -0,0 +1,81 @@
+/*
+Package otel is an OpenTelemetry adapter for the REST Layer tracing hooks.
+
+	trace.DefaultTracer = otel.NewTracer(otelapi.Tracer("rest-layer"))
+
+This package is part of the rest-layer project. See http://rest-layer.io for
+full REST Layer documentation.
+*/
+package otel
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rs/rest-layer/trace"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// Tracer implements the trace.Tracer interface using an OpenTelemetry tracer.
+type Tracer struct {
+	tracer oteltrace.Tracer
+}
+
+// NewTracer creates a REST Layer tracer creating spans with t.
+func NewTracer(t oteltrace.Tracer) *Tracer {
+	return &Tracer{tracer: t}
+}
+
+// Start implements the trace.Tracer interface.
+func (t *Tracer) Start(ctx context.Context, name string, attrs ...trace.Attribute) (context.Context, trace.Span) {
+	opts := []oteltrace.SpanStartOption{oteltrace.WithAttributes(convert(attrs)...)}
+	if name == "rest.ServeHTTP" {
+		opts = append(opts, oteltrace.WithSpanKind(oteltrace.SpanKindServer))
+	}
+	ctx, s := t.tracer.Start(ctx, name, opts...)
+	return ctx, span{s}
+}
+
+type span struct {
+	s oteltrace.Span
+}
+
+// SetAttributes implements the trace.Span interface.
+func (s span) SetAttributes(attrs ...trace.Attribute) {
+	s.s.SetAttributes(convert(attrs)...)
+}
+
+// End implements the trace.Span interface.
+func (s span) End(err error) {
+	if err != nil {
+		s.s.RecordError(err)
+		s.s.SetStatus(codes.Error, err.Error())
+	}
+	s.s.End()
+}
+
+// convert converts REST Layer attributes to OpenTelemetry attributes.
+func convert(attrs []trace.Attribute) []attribute.KeyValue {
+	kvs := make([]attribute.KeyValue, 0, len(attrs))
+	for _, a := range attrs {
+		switch v := a.Value.(type) {
+		case string:
+			kvs = append(kvs, attribute.String(a.Key, v))
+		case bool:
+			kvs = append(kvs, attribute.Bool(a.Key, v))
+		case int:
+			kvs = append(kvs, attribute.Int(a.Key, v))
+		case int64:
+			kvs = append(kvs, attribute.Int64(a.Key, v))
+		case float64:
+			kvs = append(kvs, attribute.Float64(a.Key, v))
+		case []string:
+			kvs = append(kvs, attribute.StringSlice(a.Key, v))
+		default:
+			kvs = append(kvs, attribute.String(a.Key, fmt.Sprint(v)))
+		}
+	}
+	return kvs
+}
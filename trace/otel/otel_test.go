@@ -0,0 +1,36 @@
+package otel
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/rs/rest-layer/trace"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracer(t *testing.T) {
+	exp := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exp))
+	tr := NewTracer(tp.Tracer("test"))
+
+	ctx, parent := tr.Start(context.Background(), "resource.find", trace.Attr(trace.AttrResource, "users"))
+	_, child := tr.Start(ctx, "storage.Find")
+	child.SetAttributes(trace.Attr(trace.AttrItemCount, 2))
+	child.End(errors.New("boom"))
+	parent.End(nil)
+
+	spans := exp.GetSpans()
+	if assert.Len(t, spans, 2) {
+		assert.Equal(t, "storage.Find", spans[0].Name)
+		assert.Equal(t, spans[1].SpanContext.SpanID(), spans[0].Parent.SpanID())
+		assert.Equal(t, codes.Error, spans[0].Status.Code)
+		assert.Equal(t, []attribute.KeyValue{attribute.Int(trace.AttrItemCount, 2)}, spans[0].Attributes)
+		assert.Equal(t, []attribute.KeyValue{attribute.String(trace.AttrResource, "users")}, spans[1].Attributes)
+		assert.Equal(t, codes.Unset, spans[1].Status.Code)
+	}
+}
@@ -0,0 +1,80 @@
+package trace
+
+import (
+	"context"
+	"sync"
+)
+
+// Recorder is a Tracer keeping ended spans in memory. It is mostly useful for
+// testing.
+type Recorder struct {
+	mu    sync.Mutex
+	spans []*RecordedSpan
+}
+
+// RecordedSpan is a span recorded by a Recorder.
+type RecordedSpan struct {
+	// Name is the name of the span.
+	Name string
+	// Parent is the parent span if any.
+	Parent *RecordedSpan
+	// Attributes holds the attributes set on the span.
+	Attributes map[string]interface{}
+	// Err is the error the span ended with.
+	Err error
+
+	r *Recorder
+}
+
+type recorderKey struct{}
+
+// Start implements the Tracer interface.
+func (r *Recorder) Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span) {
+	parent, _ := ctx.Value(recorderKey{}).(*RecordedSpan)
+	s := &RecordedSpan{Name: name, Parent: parent, Attributes: map[string]interface{}{}, r: r}
+	s.SetAttributes(attrs...)
+	return context.WithValue(ctx, recorderKey{}, s), s
+}
+
+// Spans returns the ended spans in the order they ended.
+func (r *Recorder) Spans() []*RecordedSpan {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	spans := make([]*RecordedSpan, len(r.spans))
+	copy(spans, r.spans)
+	return spans
+}
+
+// Names returns the names of the ended spans in the order they ended.
+func (r *Recorder) Names() []string {
+	spans := r.Spans()
+	names := make([]string, 0, len(spans))
+	for _, s := range spans {
+		names = append(names, s.Name)
+	}
+	return names
+}
+
+// Reset removes all recorded spans.
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spans = nil
+}
+
+// SetAttributes implements the Span interface.
+func (s *RecordedSpan) SetAttributes(attrs ...Attribute) {
+	s.r.mu.Lock()
+	defer s.r.mu.Unlock()
+	for _, a := range attrs {
+		s.Attributes[a.Key] = a.Value
+	}
+}
+
+// End implements the Span interface.
+func (s *RecordedSpan) End(err error) {
+	s.r.mu.Lock()
+	defer s.r.mu.Unlock()
+	s.Err = err
+	s.r.spans = append(s.r.spans, s)
+}
@@ -0,0 +1,72 @@
+package trace
+
+import "context"
+
+// Tracer creates spans.
+type Tracer interface {
+	// Start starts a span named name as a child of the span stored in ctx if
+	// any, and returns a context holding the new span.
+	Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span)
+}
+
+// Span is an operation being traced.
+type Span interface {
+	// SetAttributes adds attributes to the span.
+	SetAttributes(attrs ...Attribute)
+	// End ends the span, recording err as the span's error if not nil.
+	End(err error)
+}
+
+// Attribute is a key value pair describing a span. Value is usually a string,
+// a bool, an int or a float64.
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// Attribute keys set by REST Layer.
+const (
+	// AttrResource is the path of the resource (i.e.: users.posts).
+	AttrResource = "restlayer.resource"
+	// AttrOperation is the name of a resource operation (i.e.: find, insert).
+	AttrOperation = "restlayer.operation"
+	// AttrID is the id of the item an operation is performed on.
+	AttrID = "restlayer.id"
+	// AttrMode is the resource.Mode of a request (i.e.: list, read, create).
+	AttrMode = "restlayer.mode"
+	// AttrItemCount is the number of items read or written.
+	AttrItemCount = "restlayer.item_count"
+	// AttrPredicate is the string representation of a query predicate.
+	AttrPredicate = "restlayer.predicate"
+	// AttrHandler is the Go type of the event handler of a hook span.
+	AttrHandler = "restlayer.handler"
+)
+
+// Attr creates an Attribute.
+func Attr(key string, value interface{}) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// DefaultTracer is the tracer used by REST Layer. By default it is nil and no
+// spans are created.
+var DefaultTracer Tracer
+
+// Enabled returns true if DefaultTracer is set. It can be used to avoid the
+// computation of costly attributes.
+func Enabled() bool {
+	return DefaultTracer != nil
+}
+
+// Start starts a span using DefaultTracer. If DefaultTracer is nil, ctx is
+// returned with a no-op span.
+func Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span) {
+	if DefaultTracer == nil {
+		return ctx, noopSpan{}
+	}
+	return DefaultTracer.Start(ctx, name, attrs...)
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttributes(attrs ...Attribute) {}
+func (noopSpan) End(err error)                    {}